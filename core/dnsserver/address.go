@@ -15,9 +15,10 @@ import (
 type zoneAddr struct {
 	Zone      string
 	Port      string
-	Transport string     // dns, tls or grpc
+	Transport string     // dns, tls, grpc, https or quic
 	IPNet     *net.IPNet // if reverse zone this hold the IPNet
 	Address   string     // used for bound zoneAddr - validation of overlapping
+	View      string     // the view this zone is part of, zones can be defined once per view
 }
 
 // String returns the string representation of z.
@@ -26,6 +27,9 @@ func (z zoneAddr) String() string {
 	if z.Address != "" {
 		s += " on " + z.Address
 	}
+	if z.View != "" {
+		s += " in view " + z.View
+	}
 	return s
 }
 
@@ -49,6 +53,8 @@ func normalizeZone(str string) (zoneAddr, error) {
 			port = transport.GRPCPort
 		case transport.HTTPS:
 			port = transport.HTTPSPort
+		case transport.QUIC:
+			port = transport.QUICPort
 		}
 	}
 
@@ -87,7 +93,7 @@ func (zo *zoneOverlap) registerAndCheck(z zoneAddr) (existingZone *zoneAddr, ove
 		// exact same zone already registered
 		return &exist, nil
 	}
-	uz := zoneAddr{Zone: z.Zone, Address: "", Port: z.Port, Transport: z.Transport}
+	uz := zoneAddr{Zone: z.Zone, Address: "", Port: z.Port, Transport: z.Transport, View: z.View}
 	if already, ok := zo.unboundOverlap[uz]; ok {
 		if z.Address == "" {
 			// current is not bound to an address, but there is already another zone with a bind address registered
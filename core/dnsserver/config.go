@@ -1,10 +1,12 @@
 package dnsserver
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/request"
 
 	"github.com/mholt/caddy"
 )
@@ -37,15 +39,33 @@ type Config struct {
 	// on a non-octet boundary, i.e. /17
 	FilterFunc func(string) bool
 
+	// ViewName is the name of the view this config is part of, see the view plugin. Several configs
+	// for the same zone and listener may exist, as long as their view names differ.
+	ViewName string
+
+	// If ViewFilter is not nil, it must return true for a request to be handled by this config.
+	// Configs with a ViewFilter are tried in order, before the config without one.
+	ViewFilter func(context.Context, request.Request) bool
+
 	// TLSConfig when listening for encrypted connections (gRPC, DNS-over-TLS).
 	TLSConfig *tls.Config
 
+	// HTTPS holds the DNS-over-HTTPS settings, see the https plugin.
+	HTTPS *HTTPSConfig
+
+	// TsigSecret maps TSIG key names to their base64 encoded secrets, see the tsig plugin. The
+	// keys of all configs on a listener are used to verify the incoming messages.
+	TsigSecret map[string]string
+
 	// Plugin stack.
 	Plugin []plugin.Plugin
 
 	// Compiled plugin stack.
 	pluginChain plugin.Handler
 
+	// metaCollector collects the metadata before the ViewFilter is called.
+	metaCollector MetadataCollector
+
 	// Plugin interested in announcing that they exist, so other plugin can call methods
 	// on them should register themselves here. The name should be the name as return by the
 	// Handler's Name method.
@@ -1,9 +1,14 @@
 package dnsserver
 
 import (
+	"crypto/tls"
 	"net"
+	"time"
 
+	"github.com/coredns/coredns/plugin/pkg/doh"
 	"github.com/coredns/coredns/plugin/pkg/nonwriter"
+
+	"github.com/miekg/dns"
 )
 
 // DoHWriter is a nonwriter.Writer that adds more specific LocalAddr and RemoteAddr methods.
@@ -14,6 +19,8 @@ type DoHWriter struct {
 	raddr net.Addr
 	// laddr is our address. This can be optionally set.
 	laddr net.Addr
+	// tlsState is the TLS connection state of the request. This can be optionally set.
+	tlsState *tls.ConnectionState
 }
 
 // RemoteAddr returns the remote address.
@@ -21,3 +28,79 @@ func (d *DoHWriter) RemoteAddr() net.Addr { return d.raddr }
 
 // LocalAddr returns the local address.
 func (d *DoHWriter) LocalAddr() net.Addr { return d.laddr }
+
+// ConnectionState implements the dns.ConnectionStater interface.
+func (d *DoHWriter) ConnectionState() *tls.ConnectionState { return d.tlsState }
+
+// TsigStatus implements the dns.ResponseWriter interface. TSIG isn't verified for DNS-over-HTTPS.
+func (d *DoHWriter) TsigStatus() error { return errTsigTransport }
+
+// HTTPSConfig holds the DNS-over-HTTPS settings of a server block, see the https plugin. Zero values
+// mean the default is used.
+type HTTPSConfig struct {
+	// Paths are the URL paths on which queries are accepted, defaults to /dns-query.
+	Paths []string
+	// JSON enables the application/dns-json API next to the wire format.
+	JSON bool
+	// MaxRequestSize is the maximum size in bytes of a POST body or GET query.
+	MaxRequestSize int64
+	// ReadTimeout, WriteTimeout and IdleTimeout are used for the HTTP server.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// MaxConcurrentStreams is the maximum number of concurrent HTTP/2 streams per connection.
+	MaxConcurrentStreams int
+}
+
+// Defaults used for the HTTPSConfig.
+const (
+	defaultHTTPSMaxRequestSize = dns.MaxMsgSize
+	defaultHTTPSReadTimeout    = 5 * time.Second
+	defaultHTTPSWriteTimeout   = 5 * time.Second
+	defaultHTTPSIdleTimeout    = 120 * time.Second
+)
+
+// mergeHTTPSConfig merges the HTTPS configs of all configs in group. The paths are combined, for the
+// other settings the last block that sets them wins.
+func mergeHTTPSConfig(group []*Config) *HTTPSConfig {
+	h := &HTTPSConfig{
+		MaxRequestSize: defaultHTTPSMaxRequestSize,
+		ReadTimeout:    defaultHTTPSReadTimeout,
+		WriteTimeout:   defaultHTTPSWriteTimeout,
+		IdleTimeout:    defaultHTTPSIdleTimeout,
+	}
+	seen := map[string]bool{}
+	for _, c := range group {
+		if c.HTTPS == nil {
+			continue
+		}
+		for _, p := range c.HTTPS.Paths {
+			if !seen[p] {
+				seen[p] = true
+				h.Paths = append(h.Paths, p)
+			}
+		}
+		if c.HTTPS.JSON {
+			h.JSON = true
+		}
+		if c.HTTPS.MaxRequestSize > 0 {
+			h.MaxRequestSize = c.HTTPS.MaxRequestSize
+		}
+		if c.HTTPS.ReadTimeout > 0 {
+			h.ReadTimeout = c.HTTPS.ReadTimeout
+		}
+		if c.HTTPS.WriteTimeout > 0 {
+			h.WriteTimeout = c.HTTPS.WriteTimeout
+		}
+		if c.HTTPS.IdleTimeout > 0 {
+			h.IdleTimeout = c.HTTPS.IdleTimeout
+		}
+		if c.HTTPS.MaxConcurrentStreams > 0 {
+			h.MaxConcurrentStreams = c.HTTPS.MaxConcurrentStreams
+		}
+	}
+	if len(h.Paths) == 0 {
+		h.Paths = []string{doh.Path}
+	}
+	return h
+}
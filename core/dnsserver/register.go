@@ -141,6 +141,13 @@ func (h *dnsContext) MakeServers() ([]caddy.Server, error) {
 				return nil, err
 			}
 			servers = append(servers, s)
+
+		case transport.QUIC:
+			s, err := NewServerQUIC(addr, group)
+			if err != nil {
+				return nil, err
+			}
+			servers = append(servers, s)
 		}
 
 	}
@@ -199,7 +206,7 @@ func (h *dnsContext) validateZonesAndListeningAddresses() error {
 	for _, conf := range h.configs {
 		for _, h := range conf.ListenHosts {
 			// Validate the overlapping of ZoneAddr
-			akey := zoneAddr{Transport: conf.Transport, Zone: conf.Zone, Address: h, Port: conf.Port}
+			akey := zoneAddr{Transport: conf.Transport, Zone: conf.Zone, Address: h, Port: conf.Port, View: conf.ViewName}
 			existZone, overlapZone := checker.registerAndCheck(akey)
 			if existZone != nil {
 				return fmt.Errorf("cannot serve %s - it is already defined", akey.String())
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"runtime"
+	"sort"
 	"sync"
 	"time"
 
@@ -33,12 +34,13 @@ type Server struct {
 	server [2]*dns.Server // 0 is a net.Listener, 1 is a net.PacketConn (a *UDPConn) in our case.
 	m      sync.Mutex     // protects the servers
 
-	zones        map[string]*Config // zones keyed by their address
-	dnsWg        sync.WaitGroup     // used to wait on outstanding connections
-	graceTimeout time.Duration      // the maximum duration of a graceful shutdown
-	trace        trace.Trace        // the trace plugin for the server
-	debug        bool               // disable recover()
-	classChaos   bool               // allow non-INET class queries
+	zones        map[string][]*Config // zones keyed by their address, several configs exist when views are used
+	dnsWg        sync.WaitGroup       // used to wait on outstanding connections
+	graceTimeout time.Duration        // the maximum duration of a graceful shutdown
+	trace        trace.Trace          // the trace plugin for the server
+	debug        bool                 // disable recover()
+	classChaos   bool                 // allow non-INET class queries
+	tsigSecret   map[string]string    // TSIG keys of all configs, nil if there are none
 }
 
 // NewServer returns a new CoreDNS server and compiles all plugins in to it. By default CH class
@@ -47,7 +49,7 @@ func NewServer(addr string, group []*Config) (*Server, error) {
 
 	s := &Server{
 		Addr:         addr,
-		zones:        make(map[string]*Config),
+		zones:        make(map[string][]*Config),
 		graceTimeout: 5 * time.Second,
 	}
 
@@ -65,7 +67,18 @@ func NewServer(addr string, group []*Config) (*Server, error) {
 			log.D.Set()
 		}
 		// set the config per zone
-		s.zones[site.Zone] = site
+		s.zones[site.Zone] = append(s.zones[site.Zone], site)
+
+		for name, secret := range site.TsigSecret {
+			if s.tsigSecret == nil {
+				s.tsigSecret = make(map[string]string)
+			}
+			// All zones on a listener share the keys, a key name can only have one secret.
+			if old, ok := s.tsigSecret[name]; ok && old != secret {
+				return nil, fmt.Errorf("TSIG key %q is defined with different secrets on %s", name, addr)
+			}
+			s.tsigSecret[name] = secret
+		}
 
 		// compile custom plugin for everything
 		var stack plugin.Handler
@@ -86,10 +99,19 @@ func NewServer(addr string, group []*Config) (*Server, error) {
 			if _, ok := EnableChaos[stack.Name()]; ok {
 				s.classChaos = true
 			}
+			if mc, ok := stack.(MetadataCollector); ok {
+				site.metaCollector = mc
+			}
 		}
 		site.pluginChain = stack
 	}
 
+	// Configs with a view are tried in the order they are defined, a config without one is the
+	// fallback and tried last.
+	for _, z := range s.zones {
+		sort.SliceStable(z, func(i, j int) bool { return z[i].ViewFilter != nil && z[j].ViewFilter == nil })
+	}
+
 	return s, nil
 }
 
@@ -97,7 +119,7 @@ func NewServer(addr string, group []*Config) (*Server, error) {
 // This implements caddy.TCPServer interface.
 func (s *Server) Serve(l net.Listener) error {
 	s.m.Lock()
-	s.server[tcp] = &dns.Server{Listener: l, Net: "tcp", Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+	s.server[tcp] = &dns.Server{Listener: l, Net: "tcp", TsigSecret: s.tsigSecret, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
 		ctx := context.WithValue(context.Background(), Key{}, s)
 		s.ServeDNS(ctx, w, r)
 	})}
@@ -110,7 +132,7 @@ func (s *Server) Serve(l net.Listener) error {
 // This implements caddy.UDPServer interface.
 func (s *Server) ServePacket(p net.PacketConn) error {
 	s.m.Lock()
-	s.server[udp] = &dns.Server{PacketConn: p, Net: "udp", Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+	s.server[udp] = &dns.Server{PacketConn: p, Net: "udp", TsigSecret: s.tsigSecret, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
 		ctx := context.WithValue(context.Background(), Key{}, s)
 		s.ServeDNS(ctx, w, r)
 	})}
@@ -221,10 +243,22 @@ func (s *Server) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 	var off int
 	var end bool
 
-	var dshandler *Config
+	var (
+		dshandler *Config
+		dsctx     context.Context
+	)
 
-	// Wrap the response writer in a ScrubWriter so we automatically make the reply fit in the client's buffer.
-	w = request.NewScrubWriter(r, w)
+	if t := r.IsTsig(); t != nil && s.tsigSecret != nil {
+		if err := w.TsigStatus(); err != nil {
+			tsigErrorFunc(s.Addr, w, r, err)
+			return
+		}
+		// Sign the reply after it has been scrubbed, leaving room for the TSIG record.
+		w = request.NewScrubWriterReserve(r, &tsigWriter{ResponseWriter: w, tsig: t}, tsigLen(t))
+	} else {
+		// Wrap the response writer in a ScrubWriter so we automatically make the reply fit in the client's buffer.
+		w = request.NewScrubWriter(r, w)
+	}
 
 	for {
 		l := len(q[off:])
@@ -236,31 +270,38 @@ func (s *Server) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 			}
 		}
 
-		if h, ok := s.zones[string(b[:l])]; ok {
-			if r.Question[0].Qtype != dns.TypeDS {
-				if h.FilterFunc == nil {
-					rcode, _ := h.pluginChain.ServeDNS(ctx, w, r)
-					if !plugin.ClientWrite(rcode) {
-						errorFunc(s.Addr, w, r, rcode)
-					}
-					return
+		if z, ok := s.zones[string(b[:l])]; ok {
+			for _, h := range z {
+				hctx, match := h.viewMatch(ctx, w, r)
+				if !match {
+					continue
 				}
-				// FilterFunc is set, call it to see if we should use this handler.
-				// This is given to full query name.
-				if h.FilterFunc(q) {
-					rcode, _ := h.pluginChain.ServeDNS(ctx, w, r)
-					if !plugin.ClientWrite(rcode) {
-						errorFunc(s.Addr, w, r, rcode)
+				if r.Question[0].Qtype != dns.TypeDS {
+					if h.FilterFunc == nil {
+						rcode, _ := h.pluginChain.ServeDNS(hctx, w, r)
+						if !plugin.ClientWrite(rcode) {
+							errorFunc(s.Addr, w, r, rcode)
+						}
+						return
+					}
+					// FilterFunc is set, call it to see if we should use this handler.
+					// This is given to full query name.
+					if h.FilterFunc(q) {
+						rcode, _ := h.pluginChain.ServeDNS(hctx, w, r)
+						if !plugin.ClientWrite(rcode) {
+							errorFunc(s.Addr, w, r, rcode)
+						}
+						return
 					}
-					return
 				}
+				// The type is DS, keep the handler, but keep on searching as maybe we are serving
+				// the parent as well and the DS should be routed to it - this will probably *misroute* DS
+				// queries to a possibly grand parent, but there is no way for us to know at this point
+				// if there is an actually delegation from grandparent -> parent -> zone.
+				// In all fairness: direct DS queries should not be needed.
+				dshandler, dsctx = h, hctx
+				break
 			}
-			// The type is DS, keep the handler, but keep on searching as maybe we are serving
-			// the parent as well and the DS should be routed to it - this will probably *misroute* DS
-			// queries to a possibly grand parent, but there is no way for us to know at this point
-			// if there is an actually delegation from grandparent -> parent -> zone.
-			// In all fairness: direct DS queries should not be needed.
-			dshandler = h
 		}
 		off, end = dns.NextLabel(q, off)
 		if end {
@@ -270,7 +311,7 @@ func (s *Server) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 
 	if r.Question[0].Qtype == dns.TypeDS && dshandler != nil && dshandler.pluginChain != nil {
 		// DS request, and we found a zone, use the handler for the query.
-		rcode, _ := dshandler.pluginChain.ServeDNS(ctx, w, r)
+		rcode, _ := dshandler.pluginChain.ServeDNS(dsctx, w, r)
 		if !plugin.ClientWrite(rcode) {
 			errorFunc(s.Addr, w, r, rcode)
 		}
@@ -278,8 +319,15 @@ func (s *Server) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 	}
 
 	// Wildcard match, if we have found nothing try the root zone as a last resort.
-	if h, ok := s.zones["."]; ok && h.pluginChain != nil {
-		rcode, _ := h.pluginChain.ServeDNS(ctx, w, r)
+	for _, h := range s.zones["."] {
+		if h.pluginChain == nil {
+			continue
+		}
+		hctx, match := h.viewMatch(ctx, w, r)
+		if !match {
+			continue
+		}
+		rcode, _ := h.pluginChain.ServeDNS(hctx, w, r)
 		if !plugin.ClientWrite(rcode) {
 			errorFunc(s.Addr, w, r, rcode)
 		}
@@ -8,12 +8,15 @@ import (
 	"net"
 
 	"github.com/coredns/coredns/pb"
+	"github.com/coredns/coredns/plugin/pkg/trace"
 	"github.com/coredns/coredns/plugin/pkg/transport"
 
 	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
 	"github.com/miekg/dns"
 	"github.com/opentracing/opentracing-go"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
 )
 
@@ -34,9 +37,11 @@ func NewServergRPC(addr string, group []*Config) (*ServergRPC, error) {
 	// The *tls* plugin must make sure that multiple conflicting
 	// TLS configuration return an error: it can only be specified once.
 	var tlsConfig *tls.Config
-	for _, conf := range s.zones {
-		// Should we error if some configs *don't* have TLS?
-		tlsConfig = conf.TLSConfig
+	for _, z := range s.zones {
+		for _, conf := range z {
+			// Should we error if some configs *don't* have TLS?
+			tlsConfig = conf.TLSConfig
+		}
 	}
 
 	return &ServergRPC{Server: s, tlsConfig: tlsConfig}, nil
@@ -48,21 +53,22 @@ func (s *ServergRPC) Serve(l net.Listener) error {
 	s.listenAddr = l.Addr()
 	s.m.Unlock()
 
+	var opts []grpc.ServerOption
 	if s.Tracer() != nil {
 		onlyIfParent := func(parentSpanCtx opentracing.SpanContext, method string, req, resp interface{}) bool {
 			return parentSpanCtx != nil
 		}
 		intercept := otgrpc.OpenTracingServerInterceptor(s.Tracer(), otgrpc.IncludingSpans(onlyIfParent))
-		s.grpcServer = grpc.NewServer(grpc.UnaryInterceptor(intercept))
-	} else {
-		s.grpcServer = grpc.NewServer()
+		opts = append(opts, grpc.UnaryInterceptor(intercept))
+	}
+	// Let gRPC do the TLS handshake, so the connection state is available in Query.
+	if s.tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(s.tlsConfig)))
 	}
+	s.grpcServer = grpc.NewServer(opts...)
 
 	pb.RegisterDnsServiceServer(s.grpcServer, s)
 
-	if s.tlsConfig != nil {
-		l = tls.NewListener(l, s.tlsConfig)
-	}
 	return s.grpcServer.Serve(l)
 }
 
@@ -128,8 +134,20 @@ func (s *ServergRPC) Query(ctx context.Context, in *pb.DnsPacket) (*pb.DnsPacket
 	}
 
 	w := &gRPCresponse{localAddr: s.listenAddr, remoteAddr: a, Msg: msg}
+	if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+		w.tlsState = &info.State
+	}
 
 	dnsCtx := context.WithValue(ctx, Key{}, s.Server)
+	if tracer := s.Tracer(); tracer != nil {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if tp := md.Get(trace.TraceParent); len(tp) > 0 {
+				if sc, err := trace.Extract(tracer, tp[0]); err == nil {
+					dnsCtx = trace.WithRemoteParent(dnsCtx, sc)
+				}
+			}
+		}
+	}
 	s.ServeDNS(dnsCtx, w, msg)
 
 	packed, err := w.Msg.Pack()
@@ -151,6 +169,7 @@ func (s *ServergRPC) Shutdown() error {
 type gRPCresponse struct {
 	localAddr  net.Addr
 	remoteAddr net.Addr
+	tlsState   *tls.ConnectionState
 	Msg        *dns.Msg
 }
 
@@ -164,9 +183,12 @@ func (r *gRPCresponse) Write(b []byte) (int, error) {
 
 // These methods implement the dns.ResponseWriter interface from Go DNS.
 func (r *gRPCresponse) Close() error              { return nil }
-func (r *gRPCresponse) TsigStatus() error         { return nil }
+func (r *gRPCresponse) TsigStatus() error         { return errTsigTransport }
 func (r *gRPCresponse) TsigTimersOnly(b bool)     { return }
 func (r *gRPCresponse) Hijack()                   { return }
 func (r *gRPCresponse) LocalAddr() net.Addr       { return r.localAddr }
 func (r *gRPCresponse) RemoteAddr() net.Addr      { return r.remoteAddr }
 func (r *gRPCresponse) WriteMsg(m *dns.Msg) error { r.Msg = m; return nil }
+
+// ConnectionState implements the dns.ConnectionStater interface.
+func (r *gRPCresponse) ConnectionState() *tls.ConnectionState { return r.tlsState }
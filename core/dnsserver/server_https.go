@@ -3,6 +3,8 @@ package dnsserver
 import (
 	"context"
 	"crypto/tls"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -12,7 +14,11 @@ import (
 	"github.com/coredns/coredns/plugin/pkg/dnsutil"
 	"github.com/coredns/coredns/plugin/pkg/doh"
 	"github.com/coredns/coredns/plugin/pkg/response"
+	"github.com/coredns/coredns/plugin/pkg/trace"
 	"github.com/coredns/coredns/plugin/pkg/transport"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/http2"
 )
 
 // ServerHTTPS represents an instance of a DNS-over-HTTPS server.
@@ -21,6 +27,7 @@ type ServerHTTPS struct {
 	httpsServer *http.Server
 	listenAddr  net.Addr
 	tlsConfig   *tls.Config
+	httpsConfig *HTTPSConfig
 }
 
 // NewServerHTTPS returns a new CoreDNS GRPC server and compiles all plugins in to it.
@@ -32,12 +39,33 @@ func NewServerHTTPS(addr string, group []*Config) (*ServerHTTPS, error) {
 	// The *tls* plugin must make sure that multiple conflicting
 	// TLS configuration return an error: it can only be specified once.
 	var tlsConfig *tls.Config
-	for _, conf := range s.zones {
-		// Should we error if some configs *don't* have TLS?
-		tlsConfig = conf.TLSConfig
+	for _, z := range s.zones {
+		for _, conf := range z {
+			// Should we error if some configs *don't* have TLS?
+			tlsConfig = conf.TLSConfig
+		}
+	}
+
+	if tlsConfig != nil {
+		// Advertise HTTP/2, RFC 8484 recommends it as the minimum version for DoH.
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+	}
+
+	httpsConfig := mergeHTTPSConfig(group)
+	srv := &http.Server{
+		ReadTimeout:  httpsConfig.ReadTimeout,
+		WriteTimeout: httpsConfig.WriteTimeout,
+		IdleTimeout:  httpsConfig.IdleTimeout,
+	}
+	if httpsConfig.MaxConcurrentStreams > 0 {
+		h2 := &http2.Server{MaxConcurrentStreams: uint32(httpsConfig.MaxConcurrentStreams)}
+		if err := http2.ConfigureServer(srv, h2); err != nil {
+			return nil, err
+		}
 	}
 
-	sh := &ServerHTTPS{Server: s, tlsConfig: tlsConfig, httpsServer: new(http.Server)}
+	sh := &ServerHTTPS{Server: s, tlsConfig: tlsConfig, httpsConfig: httpsConfig, httpsServer: srv}
 	sh.httpsServer.Handler = sh
 
 	return sh, nil
@@ -99,13 +127,35 @@ func (s *ServerHTTPS) Stop() error {
 // chain, converts it back and write it to the client.
 func (s *ServerHTTPS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
-	if r.URL.Path != doh.Path {
+	if !s.validPath(r.URL.Path) {
 		http.Error(w, "", http.StatusNotFound)
 		return
 	}
 
-	msg, err := doh.RequestToMsg(r)
+	json := s.httpsConfig.JSON && doh.IsJSON(r)
+
+	var (
+		msg *dns.Msg
+		err error
+	)
+	switch {
+	case r.Method == http.MethodGet && int64(len(r.URL.RawQuery)) > s.maxQueryLen():
+		http.Error(w, "", http.StatusRequestURITooLong)
+		return
+	case json:
+		msg, err = doh.RequestJSONToMsg(r)
+	default:
+		if r.Method == http.MethodPost {
+			r.Body = http.MaxBytesReader(w, r.Body, s.httpsConfig.MaxRequestSize)
+		}
+		msg, err = doh.RequestToMsg(r)
+	}
 	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, "", http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -113,11 +163,18 @@ func (s *ServerHTTPS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Create a DoHWriter with the correct addresses in it.
 	h, p, _ := net.SplitHostPort(r.RemoteAddr)
 	port, _ := strconv.Atoi(p)
-	dw := &DoHWriter{laddr: s.listenAddr, raddr: &net.TCPAddr{IP: net.ParseIP(h), Port: port}}
+	dw := &DoHWriter{laddr: s.listenAddr, raddr: &net.TCPAddr{IP: net.ParseIP(h), Port: port}, tlsState: r.TLS}
 
 	// We just call the normal chain handler - all error handling is done there.
 	// We should expect a packet to be returned that we can send to the client.
 	ctx := context.WithValue(context.Background(), Key{}, s.Server)
+	if tracer := s.Tracer(); tracer != nil {
+		if tp := r.Header.Get(trace.TraceParent); tp != "" {
+			if sc, err := trace.Extract(tracer, tp); err == nil {
+				ctx = trace.WithRemoteParent(ctx, sc)
+			}
+		}
+	}
 	s.ServeDNS(ctx, dw, msg)
 
 	// See section 4.2.1 of RFC 8484.
@@ -128,19 +185,47 @@ func (s *ServerHTTPS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	buf, _ := dw.Msg.Pack()
+	var buf []byte
+	mimeType := doh.MimeType
+	if json {
+		buf, err = doh.MsgToJSON(dw.Msg)
+		mimeType = doh.MimeTypeJSON
+	} else {
+		buf, err = dw.Msg.Pack()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
+	// See section 5.1 of RFC 8484, the freshness lifetime must not be longer than the smallest TTL.
 	mt, _ := response.Typify(dw.Msg, time.Now().UTC())
 	age := dnsutil.MinimalTTL(dw.Msg, mt)
 
-	w.Header().Set("Content-Type", doh.MimeType)
-	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%f", age.Seconds()))
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(age.Seconds())))
 	w.Header().Set("Content-Length", strconv.Itoa(len(buf)))
 	w.WriteHeader(http.StatusOK)
 
 	w.Write(buf)
 }
 
+// validPath returns true if path is one of the configured DoH paths.
+func (s *ServerHTTPS) validPath(path string) bool {
+	for _, p := range s.httpsConfig.Paths {
+		if path == p {
+			return true
+		}
+	}
+	return false
+}
+
+// maxQueryLen returns the maximum length of the query string of a GET request: enough for a base64
+// encoded message of MaxRequestSize octets.
+func (s *ServerHTTPS) maxQueryLen() int64 {
+	return int64(len("dns=") + base64.RawURLEncoding.EncodedLen(int(s.httpsConfig.MaxRequestSize)))
+}
+
 // Shutdown stops the server (non gracefully).
 func (s *ServerHTTPS) Shutdown() error {
 	if s.httpsServer != nil {
@@ -0,0 +1,115 @@
+package dnsserver
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/doh"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+type answerPlugin struct{}
+
+func (answerPlugin) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Answer = []dns.RR{test.A("example.com. 300 IN A 127.0.0.1"), test.A("example.com. 60 IN A 127.0.0.2")}
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+func (answerPlugin) Name() string { return "answer" }
+
+func testServerHTTPS(t *testing.T, h *HTTPSConfig) *ServerHTTPS {
+	c := testConfig("https", answerPlugin{})
+	c.HTTPS = h
+	s, err := NewServerHTTPS("127.0.0.1:443", []*Config{c})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServerHTTPS, got %s", err)
+	}
+	return s
+}
+
+func TestServeHTTP(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	wire, _ := m.Pack()
+
+	getReq, _ := doh.NewRequest(http.MethodGet, "example.com", m)
+	postReq, _ := doh.NewRequest(http.MethodPost, "example.com", m)
+
+	tests := []struct {
+		config   *HTTPSConfig
+		req      *http.Request
+		code     int
+		mimeType string
+	}{
+		{nil, getReq, http.StatusOK, doh.MimeType},
+		{nil, postReq, http.StatusOK, doh.MimeType},
+		{nil, httptest.NewRequest(http.MethodGet, "/resolve?dns=AAA", nil), http.StatusNotFound, ""},
+		{&HTTPSConfig{Paths: []string{"/resolve"}}, getReq, http.StatusNotFound, ""},
+		{&HTTPSConfig{Paths: []string{"/resolve"}}, httptest.NewRequest(http.MethodGet, "/resolve?"+getReq.URL.RawQuery, nil), http.StatusOK, doh.MimeType},
+		// JSON API
+		{nil, httptest.NewRequest(http.MethodGet, "/dns-query?name=example.com", nil), http.StatusBadRequest, ""},
+		{&HTTPSConfig{JSON: true}, httptest.NewRequest(http.MethodGet, "/dns-query?name=example.com", nil), http.StatusOK, doh.MimeTypeJSON},
+		{&HTTPSConfig{JSON: true}, getReq, http.StatusOK, doh.MimeType},
+		// limits
+		{&HTTPSConfig{MaxRequestSize: 16}, httptest.NewRequest(http.MethodPost, "/dns-query", bytes.NewReader(wire)), http.StatusRequestEntityTooLarge, ""},
+		{&HTTPSConfig{MaxRequestSize: 16}, getReq, http.StatusRequestURITooLong, ""},
+	}
+
+	for i, tc := range tests {
+		s := testServerHTTPS(t, tc.config)
+		// Requests are reused, make sure the body is fresh.
+		if tc.req == postReq {
+			tc.req, _ = doh.NewRequest(http.MethodPost, "example.com", m)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, tc.req)
+
+		if rec.Code != tc.code {
+			t.Errorf("Test %d: expected status %d, got %d", i, tc.code, rec.Code)
+			continue
+		}
+		if tc.code != http.StatusOK {
+			continue
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != tc.mimeType {
+			t.Errorf("Test %d: expected Content-Type %q, got %q", i, tc.mimeType, ct)
+		}
+		// The smallest TTL in the reply is 60.
+		if cc := rec.Header().Get("Cache-Control"); cc != "max-age=60" {
+			t.Errorf("Test %d: expected Cache-Control %q, got %q", i, "max-age=60", cc)
+		}
+		if tc.mimeType == doh.MimeTypeJSON && !strings.Contains(rec.Body.String(), `"data":"127.0.0.2"`) {
+			t.Errorf("Test %d: expected JSON answer, got %s", i, rec.Body.String())
+		}
+	}
+}
+
+func TestMergeHTTPSConfig(t *testing.T) {
+	a := testConfig("https", answerPlugin{})
+	a.HTTPS = &HTTPSConfig{Paths: []string{"/a", "/b"}, MaxRequestSize: 1024}
+	b := testConfig("https", answerPlugin{})
+	b.HTTPS = &HTTPSConfig{Paths: []string{"/b", "/c"}, JSON: true, MaxRequestSize: 2048}
+
+	h := mergeHTTPSConfig([]*Config{a, b, testConfig("https", answerPlugin{})})
+	if strings.Join(h.Paths, " ") != "/a /b /c" {
+		t.Errorf("Expected paths /a /b /c, got %v", h.Paths)
+	}
+	if !h.JSON {
+		t.Errorf("Expected JSON to be enabled")
+	}
+	if h.MaxRequestSize != 2048 {
+		t.Errorf("Expected max request size 2048, got %d", h.MaxRequestSize)
+	}
+	if h.ReadTimeout != defaultHTTPSReadTimeout {
+		t.Errorf("Expected read timeout %s, got %s", defaultHTTPSReadTimeout, h.ReadTimeout)
+	}
+}
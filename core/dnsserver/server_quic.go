@@ -0,0 +1,201 @@
+package dnsserver
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/doq"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// ServerQUIC represents an instance of a DNS-over-QUIC server.
+type ServerQUIC struct {
+	*Server
+	listener   *quic.Listener
+	tlsConfig  *tls.Config
+	quicConfig *quic.Config
+}
+
+// NewServerQUIC returns a new CoreDNS QUIC server and compiles all plugins in to it.
+func NewServerQUIC(addr string, group []*Config) (*ServerQUIC, error) {
+	s, err := NewServer(addr, group)
+	if err != nil {
+		return nil, err
+	}
+	// The *tls* plugin must make sure that multiple conflicting
+	// TLS configuration return an error: it can only be specified once.
+	var tlsConfig *tls.Config
+	for _, z := range s.zones {
+		for _, conf := range z {
+			tlsConfig = conf.TLSConfig
+		}
+	}
+	// QUIC can't do without TLS.
+	if tlsConfig == nil {
+		return nil, fmt.Errorf("no TLS configuration for %s, the tls plugin is required", addr)
+	}
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.NextProtos = []string{doq.ALPN}
+	tlsConfig.MinVersion = tls.VersionTLS13
+
+	// DoQ has no use for unidirectional streams.
+	quicConfig := &quic.Config{MaxIncomingUniStreams: -1}
+
+	return &ServerQUIC{Server: s, tlsConfig: tlsConfig, quicConfig: quicConfig}, nil
+}
+
+// Serve implements caddy.TCPServer interface.
+func (s *ServerQUIC) Serve(l net.Listener) error { return nil }
+
+// ServePacket implements caddy.UDPServer interface.
+func (s *ServerQUIC) ServePacket(p net.PacketConn) error {
+	l, err := quic.Listen(p, s.tlsConfig, s.quicConfig)
+	if err != nil {
+		return err
+	}
+	s.m.Lock()
+	s.listener = l
+	s.m.Unlock()
+
+	for {
+		conn, err := l.Accept(context.Background())
+		if err != nil {
+			// Accept only fails when the listener is closed.
+			return nil
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn handles the queries on conn, each arrives on its own stream.
+func (s *ServerQUIC) serveConn(conn quic.Connection) {
+	for {
+		stream, err := conn.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+		go s.serveStream(conn, stream)
+	}
+}
+
+func (s *ServerQUIC) serveStream(conn quic.Connection, stream quic.Stream) {
+	stream.SetDeadline(time.Now().Add(quicStreamTimeout))
+
+	msg, err := doq.ReadMsg(stream)
+	if err != nil {
+		if err == doq.ErrID {
+			conn.CloseWithError(quic.ApplicationErrorCode(doq.ProtocolError), err.Error())
+			return
+		}
+		stream.CancelRead(quic.StreamErrorCode(doq.ProtocolError))
+		stream.CancelWrite(quic.StreamErrorCode(doq.ProtocolError))
+		return
+	}
+
+	w := &DoQWriter{localAddr: tcpAddr(conn.LocalAddr()), remoteAddr: tcpAddr(conn.RemoteAddr()), stream: stream}
+
+	dnsCtx := context.WithValue(context.Background(), Key{}, s.Server)
+	s.ServeDNS(dnsCtx, w, msg)
+
+	stream.Close()
+}
+
+// Listen implements caddy.TCPServer interface.
+func (s *ServerQUIC) Listen() (net.Listener, error) { return nil, nil }
+
+// ListenPacket implements caddy.UDPServer interface.
+func (s *ServerQUIC) ListenPacket() (net.PacketConn, error) {
+	p, err := net.ListenPacket("udp", s.Addr[len(transport.QUIC+"://"):])
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// OnStartupComplete lists the sites served by this server
+// and any relevant information, assuming Quiet is false.
+func (s *ServerQUIC) OnStartupComplete() {
+	if Quiet {
+		return
+	}
+
+	out := startUpZones(transport.QUIC+"://", s.Addr, s.zones)
+	if out != "" {
+		fmt.Print(out)
+	}
+	return
+}
+
+// Stop stops the server. It blocks until the server is
+// totally stopped.
+func (s *ServerQUIC) Stop() (err error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	return
+}
+
+// Shutdown stops the server (non gracefully).
+func (s *ServerQUIC) Shutdown() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	return nil
+}
+
+// DoQWriter is the dns.ResponseWriter for a query received over DoQ. The addresses are reported as
+// TCP addresses, so plugins treat the query as received over a connection and don't truncate the reply.
+type DoQWriter struct {
+	localAddr  net.Addr
+	remoteAddr net.Addr
+	stream     quic.Stream
+}
+
+// Write writes the packed message b, prefixed with its length.
+func (w *DoQWriter) Write(b []byte) (int, error) {
+	if err := doq.WriteMsg(w.stream, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// WriteMsg writes the message m, prefixed with its length.
+func (w *DoQWriter) WriteMsg(m *dns.Msg) error {
+	buf, err := m.Pack()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// Close closes the stream.
+func (w *DoQWriter) Close() error { return w.stream.Close() }
+
+// These methods implement the dns.ResponseWriter interface from Go DNS.
+func (w *DoQWriter) TsigStatus() error     { return errTsigTransport }
+func (w *DoQWriter) TsigTimersOnly(b bool) { return }
+func (w *DoQWriter) Hijack()               { return }
+func (w *DoQWriter) LocalAddr() net.Addr   { return w.localAddr }
+func (w *DoQWriter) RemoteAddr() net.Addr  { return w.remoteAddr }
+
+// tcpAddr returns a as a *net.TCPAddr, when it is a *net.UDPAddr.
+func tcpAddr(a net.Addr) net.Addr {
+	if u, ok := a.(*net.UDPAddr); ok {
+		return &net.TCPAddr{IP: u.IP, Port: u.Port, Zone: u.Zone}
+	}
+	return a
+}
+
+// quicStreamTimeout is the time a client has to send its query and read the reply.
+const quicStreamTimeout = 5 * time.Second
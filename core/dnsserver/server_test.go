@@ -48,6 +48,23 @@ func TestNewServer(t *testing.T) {
 	}
 }
 
+func TestNewServerTsigSecret(t *testing.T) {
+	a := testConfig("dns", testPlugin{})
+	a.TsigSecret = map[string]string{"key.": "c2VjcmV0"}
+	b := testConfig("dns", testPlugin{})
+	b.Zone = "example.net."
+	b.TsigSecret = map[string]string{"key.": "c2VjcmV0"}
+
+	if _, err := NewServer("127.0.0.1:53", []*Config{a, b}); err != nil {
+		t.Errorf("Expected no error for a key with the same secret, got %s", err)
+	}
+
+	b.TsigSecret = map[string]string{"key.": "b3RoZXI="}
+	if _, err := NewServer("127.0.0.1:53", []*Config{a, b}); err == nil {
+		t.Errorf("Expected an error for a key with different secrets")
+	}
+}
+
 func BenchmarkCoreServeDNS(b *testing.B) {
 	s, err := NewServer("127.0.0.1:53", []*Config{testConfig("dns", testPlugin{})})
 	if err != nil {
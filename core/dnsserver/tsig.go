@@ -0,0 +1,85 @@
+package dnsserver
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/coredns/coredns/plugin/metrics/vars"
+	"github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/rcode"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// errTsigTransport is the TSIG status of the transports that don't verify TSIG: DNS-over-HTTPS,
+// gRPC and DNS-over-QUIC.
+var errTsigTransport = errors.New("dns: TSIG is not supported on this transport")
+
+// tsigWriter signs the replies to a request with a valid TSIG record, using the same key.
+type tsigWriter struct {
+	dns.ResponseWriter
+	tsig *dns.TSIG
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *tsigWriter) WriteMsg(m *dns.Msg) error {
+	if t := m.IsTsig(); t != nil {
+		m.Extra = m.Extra[:len(m.Extra)-1]
+	}
+	m.SetTsig(w.tsig.Hdr.Name, w.tsig.Algorithm, w.tsig.Fudge, time.Now().Unix())
+	return w.ResponseWriter.WriteMsg(m)
+}
+
+// tsigLen returns the length of the TSIG record that signs the reply to a request signed with t.
+// The reply uses the same key and algorithm, so its MAC has the same size.
+func tsigLen(t *dns.TSIG) int {
+	rr := *t
+	rr.OtherData = ""
+	return dns.Len(&rr)
+}
+
+// tsigErrorFunc replies NOTAUTH to a request that failed TSIG verification. The TSIG record of the
+// reply carries the TSIG error and no MAC (RFC 2845, section 4.5).
+func tsigErrorFunc(server string, w dns.ResponseWriter, r *dns.Msg, err error) {
+	state := request.Request{W: w, Req: r}
+	t := r.IsTsig()
+
+	m := new(dns.Msg)
+	m.SetRcode(r, dns.RcodeNotAuth)
+	rr := &dns.TSIG{
+		Hdr:        dns.RR_Header{Name: t.Hdr.Name, Rrtype: dns.TypeTSIG, Class: dns.ClassANY},
+		Algorithm:  t.Algorithm,
+		TimeSigned: t.TimeSigned,
+		Fudge:      t.Fudge,
+		OrigId:     r.Id,
+	}
+	switch err {
+	case dns.ErrSecret, errTsigTransport:
+		rr.Error = dns.RcodeBadKey
+	case dns.ErrTime:
+		rr.Error = dns.RcodeBadTime
+		// Tell the client our time.
+		rr.OtherLen = 6
+		rr.OtherData = fmt.Sprintf("%012x", time.Now().Unix())
+	default:
+		rr.Error = dns.RcodeBadSig
+	}
+	m.Extra = append(m.Extra, rr)
+
+	log.Warningf("TSIG verification of key %q from %s failed: %s", t.Hdr.Name, state.IP(), err)
+	vars.Report(server, state, vars.Dropped, rcode.ToString(dns.RcodeNotAuth), m.Len(), time.Now())
+
+	if err == errTsigTransport {
+		// These writers don't sign.
+		w.WriteMsg(m)
+		return
+	}
+	// Write the packed message, WriteMsg would sign it.
+	buf, err := m.Pack()
+	if err != nil {
+		return
+	}
+	w.Write(buf)
+}
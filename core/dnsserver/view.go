@@ -0,0 +1,42 @@
+package dnsserver
+
+import (
+	"context"
+
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// MetadataCollector is implemented by the metadata plugin. The server uses it to collect the
+// metadata before selecting a view, so views can match on metadata.
+type MetadataCollector interface {
+	Collect(context.Context, request.Request) context.Context
+}
+
+// ViewKey is the context key for the name of the view that handles the request.
+type ViewKey struct{}
+
+// viewMatch returns true when c should handle the request, and the context to use for it.
+func (c *Config) viewMatch(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (context.Context, bool) {
+	if c.ViewFilter == nil {
+		return ctx, true
+	}
+
+	state := request.Request{W: w, Req: r}
+	vctx := ctx
+	if c.metaCollector != nil {
+		vctx = c.metaCollector.Collect(vctx, state)
+	}
+	if !c.ViewFilter(vctx, state) {
+		return ctx, false
+	}
+	return context.WithValue(vctx, ViewKey{}, c.ViewName), true
+}
+
+// WithView returns the name of the view handling the request, or the empty string if
+// no view is used.
+func WithView(ctx context.Context) string {
+	v, _ := ctx.Value(ViewKey{}).(string)
+	return v
+}
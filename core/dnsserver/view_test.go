@@ -0,0 +1,100 @@
+package dnsserver
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// txtPlugin answers every query with a TXT record holding its text and the view name.
+type txtPlugin string
+
+func (tp txtPlugin) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Answer = []dns.RR{&dns.TXT{Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET}, Txt: []string{string(tp), WithView(ctx)}}}
+	w.WriteMsg(m)
+	return 0, nil
+}
+
+func (tp txtPlugin) Name() string { return "txtplugin" }
+
+func viewConfig(zone, view, cidr string) *Config {
+	c := testConfig("dns", txtPlugin(zone+"/"+view))
+	c.Zone = zone
+	c.ViewName = view
+	if cidr != "" {
+		_, n, _ := net.ParseCIDR(cidr)
+		c.ViewFilter = func(ctx context.Context, state request.Request) bool {
+			return n.Contains(net.ParseIP(state.IP()))
+		}
+	}
+	return c
+}
+
+func TestServeView(t *testing.T) {
+	// The fallback config comes first, but configs with a view must be tried first.
+	s, err := NewServer("127.0.0.1:53", []*Config{
+		viewConfig("example.com.", "", ""),
+		viewConfig("example.com.", "internal", "10.0.0.0/8"),
+		viewConfig("example.com.", "lab", "10.1.0.0/16"),
+		viewConfig(".", "internal", "10.0.0.0/8"),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServer, got %s", err)
+	}
+
+	tests := []struct {
+		qname    string
+		remote   string
+		expected []string
+		rcode    int
+	}{
+		{"a.example.com.", "10.1.0.1", []string{"example.com./internal", "internal"}, dns.RcodeSuccess},
+		{"a.example.com.", "10.2.0.1", []string{"example.com./internal", "internal"}, dns.RcodeSuccess},
+		{"a.example.com.", "192.168.0.1", []string{"example.com./", ""}, dns.RcodeSuccess},
+		{"a.example.org.", "10.2.0.1", []string{"./internal", "internal"}, dns.RcodeSuccess},
+		{"a.example.org.", "192.168.0.1", nil, dns.RcodeRefused},
+	}
+
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeTXT)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{RemoteIP: tc.remote})
+		s.ServeDNS(context.TODO(), rec, m)
+
+		if rec.Msg.Rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %d, got %d", i, tc.rcode, rec.Msg.Rcode)
+			continue
+		}
+		if tc.expected == nil {
+			continue
+		}
+		txt := rec.Msg.Answer[0].(*dns.TXT).Txt
+		if txt[0] != tc.expected[0] || txt[1] != tc.expected[1] {
+			t.Errorf("Test %d: expected %v, got %v", i, tc.expected, txt)
+		}
+	}
+}
+
+func TestViewOverlap(t *testing.T) {
+	ctx := &dnsContext{}
+	ctx.configs = []*Config{
+		viewConfig("example.com.", "internal", "10.0.0.0/8"),
+		viewConfig("example.com.", "", ""),
+	}
+	if err := ctx.validateZonesAndListeningAddresses(); err != nil {
+		t.Errorf("Expected no error for zones in different views, got %s", err)
+	}
+
+	ctx.configs = append(ctx.configs, viewConfig("example.com.", "internal", "10.0.0.0/8"))
+	if err := ctx.validateZonesAndListeningAddresses(); err == nil {
+		t.Errorf("Expected error for zone defined twice in the same view")
+	}
+}
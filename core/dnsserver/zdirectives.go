@@ -11,12 +11,16 @@ package dnsserver
 // care what plugin above them are doing.
 var Directives = []string{
 	"metadata",
+	"geoip",
 	"cancel",
 	"tls",
+	"https",
+	"tsig",
 	"reload",
 	"nsid",
 	"root",
 	"bind",
+	"view",
 	"debug",
 	"trace",
 	"ready",
@@ -26,9 +30,14 @@ var Directives = []string{
 	"errors",
 	"log",
 	"dnstap",
+	"cookie",
+	"ratelimit",
 	"any",
+	"blocklist",
+	"rpz",
 	"chaos",
 	"loadbalance",
+	"validator",
 	"cache",
 	"rewrite",
 	"dnssec",
@@ -43,8 +52,13 @@ var Directives = []string{
 	"auto",
 	"secondary",
 	"etcd",
+	"consul",
+	"redis",
+	"services",
+	"sql",
 	"loop",
 	"forward",
+	"recursive",
 	"grpc",
 	"erratic",
 	"whoami",
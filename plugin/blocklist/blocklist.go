@@ -0,0 +1,172 @@
+// Package blocklist implements a plugin that blocks names found in lists of domains.
+package blocklist
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// Blocklist is the blocklist plugin.
+type Blocklist struct {
+	Next  plugin.Handler
+	Zones []string
+
+	lists []*list
+	ttl   uint32
+	// negttl is the TTL of the SOA record in negative answers, it limits how long resolvers
+	// cache them (RFC 2308).
+	negttl uint32
+}
+
+// action is what is returned for a blocked name.
+type action struct {
+	// nodata returns an empty answer instead of NXDOMAIN.
+	nodata bool
+	// ips are the addresses of the sinkhole, when set the queries for A and AAAA records are
+	// answered with them.
+	ips []net.IP
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (b *Blocklist) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	qname := state.Name()
+
+	zone := plugin.Zones(b.Zones).Matches(qname)
+	if zone == "" {
+		return plugin.NextOrFailure(b.Name(), b.Next, ctx, w, r)
+	}
+
+	l := b.match(qname)
+	if l == nil {
+		return plugin.NextOrFailure(b.Name(), b.Next, ctx, w, r)
+	}
+	HitCount.WithLabelValues(metrics.WithServer(ctx), l.name).Inc()
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	switch {
+	case len(l.action.ips) > 0:
+		m.Answer = b.sinkhole(qname, state.QType(), l.action.ips)
+	case !l.action.nodata:
+		m.Rcode = dns.RcodeNameError
+	}
+	if len(m.Answer) == 0 {
+		m.Ns = []dns.RR{b.soa(zone, l)}
+	}
+
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// match returns the first list that contains qname, or nil.
+func (b *Blocklist) match(qname string) *list {
+	for _, l := range b.lists {
+		if l.match(qname) {
+			return l
+		}
+	}
+	return nil
+}
+
+// sinkhole returns the records for qtype pointing to the sinkhole addresses.
+func (b *Blocklist) sinkhole(qname string, qtype uint16, ips []net.IP) []dns.RR {
+	var rrs []dns.RR
+	for _, ip := range ips {
+		hdr := dns.RR_Header{Name: qname, Rrtype: qtype, Class: dns.ClassINET, Ttl: b.ttl}
+		switch {
+		case qtype == dns.TypeA && ip.To4() != nil:
+			rrs = append(rrs, &dns.A{Hdr: hdr, A: ip})
+		case qtype == dns.TypeAAAA && ip.To4() == nil:
+			rrs = append(rrs, &dns.AAAA{Hdr: hdr, AAAA: ip})
+		}
+	}
+	return rrs
+}
+
+// soa returns the SOA record for the negative answers from l in zone.
+func (b *Blocklist) soa(zone string, l *list) dns.RR {
+	Mbox := "hostmaster."
+	Ns := "ns.dns."
+	if zone != "." {
+		Mbox += zone
+		Ns += zone
+	}
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: b.negttl},
+		Ns:      Ns,
+		Mbox:    Mbox,
+		Serial:  l.serial(),
+		Refresh: 7200,
+		Retry:   1800,
+		Expire:  86400,
+		Minttl:  b.negttl,
+	}
+}
+
+// Name implements the plugin.Handler interface.
+func (b *Blocklist) Name() string { return "blocklist" }
+
+// loadAll loads all lists. A list from a file that fails to load is an error, a list from a URL is
+// retried later.
+func (b *Blocklist) loadAll() error {
+	for _, l := range b.lists {
+		if err := l.load(); err != nil {
+			LoadFailureCount.WithLabelValues(l.name).Inc()
+			if !isURL(l.source) {
+				return fmt.Errorf("failed to load list %q: %s", l.name, err)
+			}
+			log.Warningf("Failed to load list %q, retrying in %s: %s", l.name, retryInterval, err)
+		}
+	}
+	return nil
+}
+
+// run refreshes the lists every refresh, and retries the lists that aren't loaded yet, until stop
+// is closed.
+func (b *Blocklist) run(refresh time.Duration, stop chan struct{}) {
+	retry := time.NewTicker(retryInterval)
+	defer retry.Stop()
+
+	var tick <-chan time.Time
+	if refresh > 0 {
+		t := time.NewTicker(refresh)
+		defer t.Stop()
+		tick = t.C
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick:
+			b.refresh(false)
+		case <-retry.C:
+			b.refresh(true)
+		}
+	}
+}
+
+// refresh reloads the lists, or only those that aren't loaded yet when unloaded is true. Lists
+// that fail to load keep their current entries.
+func (b *Blocklist) refresh(unloaded bool) {
+	for _, l := range b.lists {
+		if unloaded && l.loaded() {
+			continue
+		}
+		if err := l.load(); err != nil {
+			LoadFailureCount.WithLabelValues(l.name).Inc()
+			log.Warningf("Failed to load list %q: %s", l.name, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package blocklist
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func newTestList(t *testing.T, name, format, content string, a action) *list {
+	tr, err := parseList(strings.NewReader(content), format)
+	if err != nil {
+		t.Fatalf("Failed to parse list %q: %s", name, err)
+	}
+	return &list{name: name, format: format, action: a, trie: tr}
+}
+
+func TestBlocklist(t *testing.T) {
+	b := &Blocklist{
+		Next:   test.NextHandler(dns.RcodeSuccess, nil),
+		Zones:  []string{"."},
+		ttl:    300,
+		negttl: 60,
+		lists: []*list{
+			newTestList(t, "nx", formatDomains, "nx.example.org\nshared.example.org\n", action{}),
+			newTestList(t, "nodata", formatDomains, "nodata.example.org\nshared.example.org\n", action{nodata: true}),
+			newTestList(t, "sinkhole", formatHosts, "0.0.0.0 sinkhole.example.org\n", action{ips: []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")}}),
+		},
+	}
+
+	soa := test.SOA(". 60 IN SOA ns.dns. hostmaster. 0 7200 1800 86400 60")
+	tests := []test.Case{
+		{Qname: "www.nx.example.org.", Qtype: dns.TypeA, Rcode: dns.RcodeNameError, Ns: []dns.RR{soa}},
+		{Qname: "nodata.example.org.", Qtype: dns.TypeA, Rcode: dns.RcodeSuccess, Ns: []dns.RR{soa}},
+		// The first list that matches is used.
+		{Qname: "shared.example.org.", Qtype: dns.TypeA, Rcode: dns.RcodeNameError, Ns: []dns.RR{soa}},
+		{Qname: "sinkhole.example.org.", Qtype: dns.TypeA, Rcode: dns.RcodeSuccess,
+			Answer: []dns.RR{test.A("sinkhole.example.org. 300 IN A 192.0.2.1")}},
+		{Qname: "sinkhole.example.org.", Qtype: dns.TypeAAAA, Rcode: dns.RcodeSuccess,
+			Answer: []dns.RR{test.AAAA("sinkhole.example.org. 300 IN AAAA 2001:db8::1")}},
+		{Qname: "sinkhole.example.org.", Qtype: dns.TypeMX, Rcode: dns.RcodeSuccess, Ns: []dns.RR{soa}},
+	}
+
+	ctx := context.TODO()
+	for i, tc := range tests {
+		m := tc.Msg()
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := b.ServeDNS(ctx, rec, m); err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if rec.Msg == nil {
+			t.Errorf("Test %d: expected a reply for %s", i, tc.Qname)
+			continue
+		}
+		if err := test.SortAndCheck(rec.Msg, tc); err != nil {
+			t.Errorf("Test %d: %s", i, err)
+		}
+	}
+
+	// Names that aren't blocked go to the next plugin.
+	for _, qname := range []string{"example.org.", "www.sinkhole.example.org."} {
+		m := new(dns.Msg)
+		m.SetQuestion(qname, dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		b.ServeDNS(ctx, rec, m)
+		if rec.Msg != nil {
+			t.Errorf("Expected %s to be passed to the next plugin", qname)
+		}
+	}
+}
+
+func TestBlocklistZones(t *testing.T) {
+	b := &Blocklist{
+		Next:  test.NextHandler(dns.RcodeSuccess, nil),
+		Zones: []string{"example.net."},
+		lists: []*list{newTestList(t, "nx", formatDomains, "example.org\nexample.net\n", action{})},
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	b.ServeDNS(context.TODO(), rec, m)
+	if rec.Msg != nil {
+		t.Errorf("Expected a name outside of the zones to be passed to the next plugin")
+	}
+
+	m.SetQuestion("www.example.net.", dns.TypeA)
+	rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	b.ServeDNS(context.TODO(), rec, m)
+	if rec.Msg == nil || rec.Msg.Rcode != dns.RcodeNameError {
+		t.Fatalf("Expected NXDOMAIN for www.example.net.")
+	}
+	if len(rec.Msg.Ns) != 1 || rec.Msg.Ns[0].Header().Name != "example.net." {
+		t.Errorf("Expected the SOA of example.net. in the authority section, got %v", rec.Msg.Ns)
+	}
+}
@@ -0,0 +1,227 @@
+package blocklist
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/miekg/dns"
+)
+
+// Formats of the lists.
+const (
+	// formatHosts is the hosts file format: "IP NAME...". The names are blocked, not their
+	// subdomains.
+	formatHosts = "hosts"
+	// formatDomains has a domain per line. The domain and all names below it are blocked, unless
+	// it starts with "*." in which case only the names below it are.
+	formatDomains = "domains"
+	// formatRPZ is a zone file with CNAME records as used for response policy zones.
+	formatRPZ = "rpz"
+)
+
+// list is a blocklist loaded from a file or URL.
+type list struct {
+	name   string
+	source string
+	format string
+	action action
+
+	mu   sync.RWMutex
+	trie *trie
+	// loadedAt is the time the list was last loaded, it is the serial of the SOA record.
+	loadedAt time.Time
+}
+
+// match returns true if name is in the list.
+func (l *list) match(name string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.trie == nil {
+		return false
+	}
+	return l.trie.match(name)
+}
+
+// loaded returns true if the list has been loaded.
+func (l *list) loaded() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.trie != nil
+}
+
+// serial returns the serial of the SOA record for the list: the time it was last loaded, in
+// seconds since the epoch.
+func (l *list) serial() uint32 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return uint32(l.loadedAt.Unix())
+}
+
+// load reads the list from its source and replaces the current entries. On error the current
+// entries are kept.
+func (l *list) load() error {
+	r, err := l.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	t, err := parseList(r, l.format)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.trie = t
+	l.loadedAt = time.Now()
+	l.mu.Unlock()
+
+	ListSize.WithLabelValues(l.name).Set(float64(t.Len()))
+	return nil
+}
+
+func (l *list) open() (io.ReadCloser, error) {
+	if !isURL(l.source) {
+		return os.Open(l.source)
+	}
+
+	resp, err := client.Get(l.source)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status fetching %s: %s", l.source, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// client fetches the lists from HTTP URLs.
+var client = &http.Client{Timeout: 30 * time.Second}
+
+// parseList reads the entries in format from r.
+func parseList(r io.Reader, format string) (*trie, error) {
+	switch format {
+	case formatHosts:
+		return parseHosts(r)
+	case formatDomains:
+		return parseDomains(r)
+	case formatRPZ:
+		return parseRPZ(r)
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+func parseHosts(r io.Reader) (*trie, error) {
+	t := new(trie)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		f := bytes.Fields(stripComment(scanner.Bytes()))
+		if len(f) < 2 || net.ParseIP(string(f[0])) == nil {
+			continue
+		}
+		for _, name := range f[1:] {
+			n, ok := normalize(string(name))
+			if !ok || localNames[n] {
+				continue
+			}
+			t.insert(n, true, false)
+		}
+	}
+	return t, scanner.Err()
+}
+
+func parseDomains(r io.Reader) (*trie, error) {
+	t := new(trie)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		f := bytes.Fields(stripComment(scanner.Bytes()))
+		if len(f) == 0 {
+			continue
+		}
+		name := string(f[0])
+		exact := true
+		if strings.HasPrefix(name, "*.") {
+			name, exact = name[2:], false
+		}
+		n, ok := normalize(name)
+		if !ok {
+			continue
+		}
+		t.insert(n, exact, true)
+	}
+	return t, scanner.Err()
+}
+
+// parseRPZ reads the CNAME records of a response policy zone. Names are taken relative to the
+// zone's origin, which is the owner of the SOA record. A name starting with "*." blocks the names
+// below it, otherwise only the name itself is blocked. Records pointing to "rpz-passthru." are
+// skipped, the policy action of the records is not used.
+func parseRPZ(r io.Reader) (*trie, error) {
+	t := new(trie)
+	origin := "."
+	zp := dns.NewZoneParser(r, ".", "")
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		switch x := rr.(type) {
+		case *dns.SOA:
+			origin = strings.ToLower(x.Hdr.Name)
+		case *dns.CNAME:
+			if x.Target == "rpz-passthru." {
+				continue
+			}
+			name := strings.ToLower(x.Hdr.Name)
+			if origin != "." {
+				if !dns.IsSubDomain(origin, name) || name == origin {
+					continue
+				}
+				name = name[:len(name)-len(origin)]
+			}
+			if strings.HasPrefix(name, "*.") {
+				t.insert(name[2:], false, true)
+				continue
+			}
+			t.insert(name, true, false)
+		}
+	}
+	return t, zp.Err()
+}
+
+func stripComment(line []byte) []byte {
+	if i := bytes.IndexByte(line, '#'); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+// normalize returns the lower case, fully qualified name, and false if name isn't a valid domain
+// name.
+func normalize(name string) (string, bool) {
+	if _, ok := dns.IsDomainName(name); !ok || net.ParseIP(name) != nil {
+		return "", false
+	}
+	return plugin.Name(name).Normalize(), true
+}
+
+// localNames are found in most hosts files, they are never blocked.
+var localNames = map[string]bool{
+	"localhost.":             true,
+	"localhost.localdomain.": true,
+	"local.":                 true,
+	"broadcasthost.":         true,
+	"ip6-localhost.":         true,
+	"ip6-loopback.":          true,
+}
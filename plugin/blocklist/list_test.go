@@ -0,0 +1,102 @@
+package blocklist
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseList(t *testing.T) {
+	tests := []struct {
+		format  string
+		content string
+		match   []string
+		noMatch []string
+	}{
+		{formatHosts, `# comment
+127.0.0.1 localhost
+0.0.0.0 0.0.0.0
+0.0.0.0 ads.example.org tracker.example.org # inline comment
+:: ADS.example.net
+bogus line.example.org`,
+			[]string{"ads.example.org.", "tracker.example.org.", "ads.example.net."},
+			[]string{"localhost.", "www.ads.example.org.", "line.example.org.", "example.org."},
+		},
+		{formatDomains, `# comment
+example.com
+*.example.net # only below
+`,
+			[]string{"example.com.", "www.example.com.", "www.example.net."},
+			[]string{"example.net.", "com."},
+		},
+		{formatRPZ, `$ORIGIN rpz.example.
+@ 3600 IN SOA ns.rpz.example. admin.rpz.example. 1 3600 600 86400 60
+@ 3600 IN NS ns.rpz.example.
+bad.example.org CNAME .
+*.bad.example.org CNAME .
+nodata.example.org CNAME *.
+good.example.org CNAME rpz-passthru.
+`,
+			[]string{"bad.example.org.", "www.bad.example.org.", "nodata.example.org."},
+			[]string{"example.org.", "good.example.org.", "ns.rpz.example."},
+		},
+	}
+
+	for i, tc := range tests {
+		tr, err := parseList(strings.NewReader(tc.content), tc.format)
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		for _, name := range tc.match {
+			if !tr.match(name) {
+				t.Errorf("Test %d: expected %s to match", i, name)
+			}
+		}
+		for _, name := range tc.noMatch {
+			if tr.match(name) {
+				t.Errorf("Test %d: expected %s not to match", i, name)
+			}
+		}
+	}
+}
+
+func TestLoadURL(t *testing.T) {
+	content := "example.org\n"
+	fail := false
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	defer s.Close()
+
+	l := &list{name: "test", source: s.URL, format: formatDomains}
+	if err := l.load(); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if !l.match("www.example.org.") {
+		t.Errorf("Expected www.example.org. to match")
+	}
+
+	// A failed refresh keeps the current entries.
+	fail = true
+	if err := l.load(); err == nil {
+		t.Errorf("Expected error for a failed fetch")
+	}
+	if !l.match("www.example.org.") {
+		t.Errorf("Expected www.example.org. to still match")
+	}
+
+	fail = false
+	content = "example.net\n"
+	if err := l.load(); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if l.match("www.example.org.") || !l.match("example.net.") {
+		t.Errorf("Expected the list to be replaced")
+	}
+}
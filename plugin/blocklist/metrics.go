@@ -0,0 +1,33 @@
+package blocklist
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// HitCount is the counter of queries blocked, per list.
+	HitCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "blocklist",
+		Name:      "hits_total",
+		Help:      "Counter of queries that were blocked, per list.",
+	}, []string{"server", "list"})
+
+	// ListSize is the number of names in each list.
+	ListSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "blocklist",
+		Name:      "entries",
+		Help:      "Gauge of the number of names in each list.",
+	}, []string{"list"})
+
+	// LoadFailureCount is the counter of failed list loads.
+	LoadFailureCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "blocklist",
+		Name:      "load_failures_total",
+		Help:      "Counter of the failures to load or refresh a list.",
+	}, []string{"list"})
+)
@@ -0,0 +1,178 @@
+package blocklist
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/mholt/caddy"
+)
+
+var log = clog.NewWithPlugin("blocklist")
+
+func init() {
+	caddy.RegisterPlugin("blocklist", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	b, refresh, err := parse(c)
+	if err != nil {
+		return plugin.Error("blocklist", err)
+	}
+
+	stop := make(chan struct{})
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, HitCount, ListSize, LoadFailureCount)
+		if err := b.loadAll(); err != nil {
+			return plugin.Error("blocklist", err)
+		}
+		go b.run(refresh, stop)
+		return nil
+	})
+	c.OnShutdown(func() error {
+		close(stop)
+		return nil
+	})
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		b.Next = next
+		return b
+	})
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (*Blocklist, time.Duration, error) {
+	config := dnsserver.GetConfig(c)
+	b := &Blocklist{ttl: defaultTTL, negttl: defaultNegativeTTL}
+	refresh := defaultRefresh
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, 0, plugin.ErrOnce
+		}
+		i++
+
+		b.Zones = make([]string, len(c.ServerBlockKeys))
+		copy(b.Zones, c.ServerBlockKeys)
+		if args := c.RemainingArgs(); len(args) > 0 {
+			b.Zones = args
+		}
+		for j := range b.Zones {
+			b.Zones[j] = plugin.Host(b.Zones[j]).Normalize()
+		}
+
+		names := map[string]bool{}
+		for c.NextBlock() {
+			switch c.Val() {
+			case "list":
+				args := c.RemainingArgs()
+				if len(args) < 3 {
+					return nil, 0, c.ArgErr()
+				}
+				l := &list{name: args[0], format: args[1], source: args[2]}
+				if names[l.name] {
+					return nil, 0, fmt.Errorf("list %q is defined more than once", l.name)
+				}
+				names[l.name] = true
+				switch l.format {
+				case formatHosts, formatDomains, formatRPZ:
+				default:
+					return nil, 0, fmt.Errorf("unknown format %q for list %q", l.format, l.name)
+				}
+				if !isURL(l.source) && !filepath.IsAbs(l.source) && config.Root != "" {
+					l.source = filepath.Join(config.Root, l.source)
+				}
+				a, err := parseAction(args[3:])
+				if err != nil {
+					return nil, 0, err
+				}
+				l.action = a
+				b.lists = append(b.lists, l)
+			case "refresh":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, 0, c.ArgErr()
+				}
+				d, err := time.ParseDuration(args[0])
+				if err != nil {
+					return nil, 0, err
+				}
+				if d < 0 {
+					return nil, 0, fmt.Errorf("refresh can not be negative: %s", d)
+				}
+				refresh = d
+			case "ttl":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, 0, c.ArgErr()
+				}
+				ttl, err := strconv.ParseUint(args[0], 10, 32)
+				if err != nil {
+					return nil, 0, err
+				}
+				b.ttl = uint32(ttl)
+			case "negative_ttl":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, 0, c.ArgErr()
+				}
+				ttl, err := strconv.ParseUint(args[0], 10, 32)
+				if err != nil {
+					return nil, 0, err
+				}
+				b.negttl = uint32(ttl)
+			default:
+				return nil, 0, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+		if len(b.lists) == 0 {
+			return nil, 0, fmt.Errorf("at least one list is required")
+		}
+	}
+	return b, refresh, nil
+}
+
+// parseAction parses the action of a list: nxdomain (the default), nodata or sinkhole addresses.
+func parseAction(args []string) (action, error) {
+	if len(args) == 0 {
+		return action{}, nil
+	}
+	switch args[0] {
+	case "nxdomain", "nodata":
+		if len(args) > 1 {
+			return action{}, fmt.Errorf("unexpected arguments after %s: %v", args[0], args[1:])
+		}
+		return action{nodata: args[0] == "nodata"}, nil
+	}
+	a := action{}
+	for _, s := range args {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return action{}, fmt.Errorf("invalid action %q, expected nxdomain, nodata or an IP address", s)
+		}
+		a.ips = append(a.ips, ip)
+	}
+	return a, nil
+}
+
+const (
+	defaultRefresh = 24 * time.Hour
+	defaultTTL     = 3600
+	// defaultNegativeTTL is the default TTL of NXDOMAIN and NODATA answers, short enough that
+	// names removed from a list are soon resolved again.
+	defaultNegativeTTL = 300
+	// retryInterval is the interval between attempts to load lists that failed to load at startup.
+	retryInterval = time.Minute
+)
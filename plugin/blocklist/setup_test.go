@@ -0,0 +1,106 @@
+package blocklist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupParse(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		zones     []string
+		lists     int
+		refresh   time.Duration
+		ttl       uint32
+		negttl    uint32
+	}{
+		{`blocklist {
+			list ads domains /etc/ads.txt
+		}`, false, []string{"."}, 1, defaultRefresh, defaultTTL, defaultNegativeTTL},
+		{`blocklist example.org {
+			list ads hosts https://example.net/hosts nodata
+			list malware rpz /etc/malware.rpz 0.0.0.0 ::
+			refresh 1h
+			ttl 60
+			negative_ttl 30
+		}`, false, []string{"example.org."}, 2, time.Hour, 60, 30},
+		{`blocklist {
+			list ads domains /etc/ads.txt nxdomain
+			refresh 0
+		}`, false, []string{"."}, 1, 0, defaultTTL, defaultNegativeTTL},
+		// fails
+		{`blocklist`, true, nil, 0, 0, 0, 0},
+		{`blocklist {
+			list ads domains
+		}`, true, nil, 0, 0, 0, 0},
+		{`blocklist {
+			list ads csv /etc/ads.csv
+		}`, true, nil, 0, 0, 0, 0},
+		{`blocklist {
+			list ads domains /etc/ads.txt
+			list ads hosts /etc/hosts
+		}`, true, nil, 0, 0, 0, 0},
+		{`blocklist {
+			list ads domains /etc/ads.txt nodata 0.0.0.0
+		}`, true, nil, 0, 0, 0, 0},
+		{`blocklist {
+			list ads domains /etc/ads.txt sinkhole
+		}`, true, nil, 0, 0, 0, 0},
+		{`blocklist {
+			list ads domains /etc/ads.txt
+			refresh -1s
+		}`, true, nil, 0, 0, 0, 0},
+		{`blocklist {
+			list ads domains /etc/ads.txt
+			ttl -1
+		}`, true, nil, 0, 0, 0, 0},
+		{`blocklist {
+			list ads domains /etc/ads.txt
+			negative_ttl
+		}`, true, nil, 0, 0, 0, 0},
+		{`blocklist {
+			list ads domains /etc/ads.txt
+			bogus
+		}`, true, nil, 0, 0, 0, 0},
+		{`blocklist {
+			list ads domains /etc/ads.txt
+		}
+		blocklist {
+			list ads domains /etc/ads.txt
+		}`, true, nil, 0, 0, 0, 0},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		c.ServerBlockKeys = []string{"."}
+		b, refresh, err := parse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if len(b.Zones) != len(tc.zones) || b.Zones[0] != tc.zones[0] {
+			t.Errorf("Test %d: expected zones %v, got %v", i, tc.zones, b.Zones)
+		}
+		if len(b.lists) != tc.lists {
+			t.Errorf("Test %d: expected %d lists, got %d", i, tc.lists, len(b.lists))
+		}
+		if refresh != tc.refresh {
+			t.Errorf("Test %d: expected refresh %s, got %s", i, tc.refresh, refresh)
+		}
+		if b.ttl != tc.ttl {
+			t.Errorf("Test %d: expected ttl %d, got %d", i, tc.ttl, b.ttl)
+		}
+		if b.negttl != tc.negttl {
+			t.Errorf("Test %d: expected negative_ttl %d, got %d", i, tc.negttl, b.negttl)
+		}
+	}
+}
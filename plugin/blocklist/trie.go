@@ -0,0 +1,64 @@
+package blocklist
+
+import "github.com/miekg/dns"
+
+// trie stores domain names by their labels, from the root down, so a lookup also finds the entries
+// for the parents of a name.
+type trie struct {
+	root node
+	len  int
+}
+
+type node struct {
+	children map[string]*node
+	// exact blocks the name of the node itself.
+	exact bool
+	// below blocks all names below the node.
+	below bool
+}
+
+// insert adds name to t. When exact is true the name itself is blocked, when below is true all
+// names below it are blocked. Names must be lower case and fully qualified.
+func (t *trie) insert(name string, exact, below bool) {
+	n := &t.root
+	labels := dns.SplitDomainName(name)
+	for i := len(labels) - 1; i >= 0; i-- {
+		if n.children == nil {
+			n.children = make(map[string]*node)
+		}
+		child, ok := n.children[labels[i]]
+		if !ok {
+			child = new(node)
+			n.children[labels[i]] = child
+		}
+		n = child
+	}
+	if (exact && !n.exact) || (below && !n.below) {
+		if !n.exact && !n.below {
+			t.len++
+		}
+		n.exact = n.exact || exact
+		n.below = n.below || below
+	}
+}
+
+// match returns true if name is blocked, either by an exact entry or by an entry for one of its
+// parents. Name must be lower case and fully qualified.
+func (t *trie) match(name string) bool {
+	n := &t.root
+	labels := dns.SplitDomainName(name)
+	for i := len(labels) - 1; i >= 0; i-- {
+		if n.below {
+			return true
+		}
+		child, ok := n.children[labels[i]]
+		if !ok {
+			return false
+		}
+		n = child
+	}
+	return n.exact
+}
+
+// Len returns the number of names in t.
+func (t *trie) Len() int { return t.len }
@@ -0,0 +1,35 @@
+package blocklist
+
+import "testing"
+
+func TestTrie(t *testing.T) {
+	tr := new(trie)
+	tr.insert("ads.example.org.", true, false)
+	tr.insert("tracker.example.", true, true)
+	tr.insert("example.net.", false, true)
+	tr.insert("example.net.", false, true) // duplicate
+
+	tests := []struct {
+		name  string
+		match bool
+	}{
+		{"ads.example.org.", true},
+		{"www.ads.example.org.", false},
+		{"example.org.", false},
+		{"tracker.example.", true},
+		{"a.b.tracker.example.", true},
+		{"example.", false},
+		{"example.net.", false},
+		{"www.example.net.", true},
+		{"other.org.", false},
+		{".", false},
+	}
+	for i, tc := range tests {
+		if x := tr.match(tc.name); x != tc.match {
+			t.Errorf("Test %d: expected match %t for %s, got %t", i, tc.match, tc.name, x)
+		}
+	}
+	if tr.Len() != 3 {
+		t.Errorf("Expected 3 names, got %d", tr.Len())
+	}
+}
@@ -31,6 +31,21 @@ type Cache struct {
 	pttl    time.Duration
 	minpttl time.Duration
 
+	// Eviction policy of pcache and ncache, and the bytes they may hold together, 0 for no limit.
+	policy   cache.Policy
+	maxBytes int64
+
+	// Hits and misses, for the hit ratio.
+	hits   uint64
+	misses uint64
+
+	// Snapshot file the cache is saved to and loaded from, and how often it is saved.
+	persistFile     string
+	persistInterval time.Duration
+
+	// Aggressive use of NSEC and NSEC3 records, nil when disabled.
+	nsec *nsecCache
+
 	// Prefetch.
 	prefetch   int
 	duration   time.Duration
@@ -170,11 +185,19 @@ func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
 		duration = computeTTL(msgTTL, w.minpttl, w.pttl)
 	}
 
+	cached := false
 	if hasKey && duration > 0 {
 		if w.state.Match(res) {
 			w.set(res, key, mt, duration)
+			cached = true
 			cacheSize.WithLabelValues(w.server, Success).Set(float64(w.pcache.Len()))
 			cacheSize.WithLabelValues(w.server, Denial).Set(float64(w.ncache.Len()))
+			cacheBytes.WithLabelValues(w.server, Success).Set(float64(w.pcache.Bytes()))
+			cacheBytes.WithLabelValues(w.server, Denial).Set(float64(w.ncache.Bytes()))
+			if w.nsec != nil && do && res.AuthenticatedData && (mt == response.NameError || mt == response.NoData) {
+				w.nsec.add(res, w.now(), w.nttl)
+				cacheSize.WithLabelValues(w.server, Nsec).Set(float64(w.nsec.Len()))
+			}
 		} else {
 			// Don't log it, but increment counter
 			cacheDrops.WithLabelValues(w.server).Inc()
@@ -185,6 +208,11 @@ func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
 		return nil
 	}
 
+	// The records are shared with the cache now, which may be reading them, e.g. to save them.
+	if cached {
+		res = res.Copy()
+	}
+
 	// Apply capped TTL to this reply to avoid jarring TTL experience 1799 -> 8 (e.g.)
 	ttl := uint32(duration.Seconds())
 	for i := range res.Answer {
@@ -238,8 +266,12 @@ const (
 
 	defaultCap = 10000 // default capacity of the cache.
 
+	defaultPersistInterval = 5 * time.Minute // default interval between saving the cache to disk.
+
 	// Success is the class for caching positive caching.
 	Success = "success"
 	// Denial is the class defined for negative caching.
 	Denial = "denial"
+	// Nsec is the class defined for replies synthesized from NSEC and NSEC3 records.
+	Nsec = "nsec"
 )
@@ -2,10 +2,12 @@ package cache
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/cache"
 	"github.com/coredns/coredns/plugin/pkg/response"
 	"github.com/coredns/coredns/plugin/test"
 	"github.com/coredns/coredns/request"
@@ -318,3 +320,30 @@ func TestComputeTTL(t *testing.T) {
 		}
 	}
 }
+
+func TestCacheMaxBytes(t *testing.T) {
+	c := New()
+	c.maxBytes = 4096
+	b := cache.NewBudget(c.maxBytes)
+	c.pcache = cache.New(defaultCap, cache.WithPolicy(cache.LRU), cache.WithBudget(b))
+	c.ncache = cache.New(defaultCap, cache.WithPolicy(cache.LRU), cache.WithBudget(b))
+	crr := &ResponseWriter{ResponseWriter: &test.ResponseWriter{}, Cache: c}
+
+	for i := 0; i < 100; i++ {
+		m := new(dns.Msg)
+		m.SetQuestion(fmt.Sprintf("%d.example.org.", i), dns.TypeA)
+		crr.state = request.Request{W: crr.ResponseWriter, Req: m}
+		m = m.Copy()
+		m.Response = true
+		m.Answer = []dns.RR{test.A(fmt.Sprintf("%d.example.org. 3600 IN A 127.0.0.1", i))}
+		crr.WriteMsg(m)
+	}
+	if used := b.Used(); used > c.maxBytes {
+		t.Errorf("Expected at most %d bytes in the cache, got %d", c.maxBytes, used)
+	}
+	// The most recently added names are still there.
+	state := request.Request{W: crr.ResponseWriter, Req: new(dns.Msg).SetQuestion("99.example.org.", dns.TypeA)}
+	if i, _ := c.get(time.Now(), state, "dns://:53"); i == nil {
+		t.Errorf("Expected 99.example.org. to be cached")
+	}
+}
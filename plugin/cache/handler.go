@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"math"
+	"sync/atomic"
 	"time"
 
 	"github.com/coredns/coredns/plugin"
@@ -28,6 +29,7 @@ func (c *Cache) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 
 	i, found := c.get(now, state, server)
 	if i != nil && found {
+		c.hit(server, true)
 		resp := i.toMsg(r, now)
 
 		w.WriteMsg(resp)
@@ -55,6 +57,17 @@ func (c *Cache) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 		return dns.RcodeSuccess, nil
 	}
 
+	if c.nsec != nil {
+		if resp := c.synthesize(state, now); resp != nil {
+			cacheHits.WithLabelValues(server, Nsec).Inc()
+			c.hit(server, true)
+			w.WriteMsg(resp)
+			return dns.RcodeSuccess, nil
+		}
+	}
+	cacheMisses.WithLabelValues(server).Inc()
+	c.hit(server, false)
+
 	crr := &ResponseWriter{ResponseWriter: w, Cache: c, state: state, server: server}
 	return plugin.NextOrFailure(c.Name(), c.Next, ctx, crr, r)
 }
@@ -74,10 +87,46 @@ func (c *Cache) get(now time.Time, state request.Request, server string) (*item,
 		cacheHits.WithLabelValues(server, Success).Inc()
 		return i.(*item), true
 	}
-	cacheMisses.WithLabelValues(server).Inc()
 	return nil, false
 }
 
+// synthesize returns the NXDOMAIN or NODATA reply for state that the cached NSEC or NSEC3 records
+// prove, or nil. Clients that didn't set the DO bit only get the SOA record.
+func (c *Cache) synthesize(state request.Request, now time.Time) *dns.Msg {
+	rcode, ns, ttl, ok := c.nsec.synthesize(state.Name(), state.QType(), now)
+	if !ok {
+		return nil
+	}
+
+	m := new(dns.Msg)
+	m.SetRcode(state.Req, rcode)
+	m.RecursionAvailable = true
+	m.AuthenticatedData = state.Do() || state.Req.AuthenticatedData
+	do := state.Do()
+	for _, rr := range ns {
+		if !do && rr.Header().Rrtype != dns.TypeSOA {
+			continue
+		}
+		rr = dns.Copy(rr)
+		rr.Header().Ttl = uint32(ttl.Seconds())
+		m.Ns = append(m.Ns, rr)
+	}
+	return m
+}
+
+// hit counts a hit, or a miss, and updates the hit ratio.
+func (c *Cache) hit(server string, hit bool) {
+	var hits, misses uint64
+	if hit {
+		hits = atomic.AddUint64(&c.hits, 1)
+		misses = atomic.LoadUint64(&c.misses)
+	} else {
+		hits = atomic.LoadUint64(&c.hits)
+		misses = atomic.AddUint64(&c.misses, 1)
+	}
+	cacheHitRatio.WithLabelValues(server).Set(float64(hits) / float64(hits+misses))
+}
+
 func (c *Cache) exists(state request.Request) *item {
 	k := hash(state.Name(), state.QType(), state.Do())
 	if i, ok := c.ncache.Get(k); ok {
@@ -104,6 +153,20 @@ var (
 		Help:      "The count of cache hits.",
 	}, []string{"server", "type"})
 
+	cacheBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "cache",
+		Name:      "size_bytes",
+		Help:      "The number of bytes held by the cache.",
+	}, []string{"server", "type"})
+
+	cacheHitRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "cache",
+		Name:      "hit_ratio",
+		Help:      "The ratio of queries answered from the cache.",
+	}, []string{"server"})
+
 	cacheMisses = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Namespace: plugin.Namespace,
 		Subsystem: "cache",
@@ -18,6 +18,7 @@ type item struct {
 
 	origTTL uint32
 	stored  time.Time
+	size    int
 
 	*freq.Freq
 }
@@ -42,6 +43,8 @@ func newItem(m *dns.Msg, now time.Time, d time.Duration) *item {
 	}
 	i.Extra = i.Extra[:j]
 
+	i.size = size(i)
+
 	i.origTTL = uint32(d.Seconds())
 	i.stored = now.UTC()
 
@@ -82,6 +85,23 @@ func (i *item) toMsg(m *dns.Msg, now time.Time) *dns.Msg {
 	return m1
 }
 
+// Size implements the cache.Sizer interface, it returns the size of the message in i.
+func (i *item) Size() int { return i.size }
+
+// size returns the length of the message with the records of i.
+func size(i *item) int {
+	l := dnsHeaderLen
+	for _, rrs := range [][]dns.RR{i.Answer, i.Ns, i.Extra} {
+		for _, r := range rrs {
+			l += dns.Len(r)
+		}
+	}
+	return l
+}
+
+// dnsHeaderLen is the length of the header of a DNS message.
+const dnsHeaderLen = 12
+
 func (i *item) ttl(now time.Time) int {
 	ttl := int(i.origTTL) - int(now.UTC().Sub(i.stored).Seconds())
 	return ttl
@@ -0,0 +1,416 @@
+package cache
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+
+	"github.com/miekg/dns"
+)
+
+// nsecCache indexes the NSEC and NSEC3 records of validated negative replies per zone, to
+// synthesize NXDOMAIN and NODATA replies for the names they cover (RFC 8198).
+type nsecCache struct {
+	sync.RWMutex
+	zones map[string]*nsecZone
+	size  int // number of NSEC and NSEC3 records in all zones
+	cap   int
+}
+
+// nsecZone holds the SOA and the NSEC or NSEC3 records of a zone.
+type nsecZone struct {
+	soa       []dns.RR // SOA record, followed by its signatures
+	soaExpire time.Time
+
+	nsec  []*denialRecord // sorted in canonical order
+	nsec3 []*denialRecord // sorted by hash
+}
+
+// denialRecord is an NSEC or NSEC3 record with its signatures.
+type denialRecord struct {
+	rrs    []dns.RR // the NSEC or NSEC3 record, followed by its signatures
+	key    string   // the owner name of an NSEC, the hash of an NSEC3
+	expire time.Time
+}
+
+func newNsecCache(cap int) *nsecCache { return &nsecCache{zones: map[string]*nsecZone{}, cap: cap} }
+
+// Len returns the number of NSEC and NSEC3 records in the cache.
+func (n *nsecCache) Len() int {
+	n.RLock()
+	defer n.RUnlock()
+	return n.size
+}
+
+// add indexes the signed NSEC and NSEC3 records in the authority section of m, which must be a
+// validated negative reply. Records are kept for the lowest of their TTL, the TTL and the minimum
+// TTL of the SOA record, and maxTTL.
+func (n *nsecCache) add(m *dns.Msg, now time.Time, maxTTL time.Duration) {
+	var soa *dns.SOA
+	for _, rr := range m.Ns {
+		if s, ok := rr.(*dns.SOA); ok {
+			soa = s
+			break
+		}
+	}
+	if soa == nil {
+		return
+	}
+	zone := strings.ToLower(soa.Hdr.Name)
+	soaSigs := signatures(m.Ns, soa.Hdr.Name, dns.TypeSOA, zone)
+	if len(soaSigs) == 0 {
+		return
+	}
+	ttl := time.Duration(soa.Hdr.Ttl) * time.Second
+	if min := time.Duration(soa.Minttl) * time.Second; min < ttl {
+		ttl = min
+	}
+	if ttl > maxTTL {
+		ttl = maxTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	n.Lock()
+	defer n.Unlock()
+
+	z, ok := n.zones[zone]
+	if !ok {
+		z = &nsecZone{}
+		n.zones[zone] = z
+	}
+	z.soa = append([]dns.RR{dns.Copy(soa)}, soaSigs...)
+	z.soaExpire = now.Add(ttl)
+
+	for _, rr := range m.Ns {
+		t := rr.Header().Rrtype
+		if t != dns.TypeNSEC && t != dns.TypeNSEC3 {
+			continue
+		}
+		if !dns.IsSubDomain(zone, rr.Header().Name) {
+			continue
+		}
+		sigs := signatures(m.Ns, rr.Header().Name, t, zone)
+		if len(sigs) == 0 {
+			continue
+		}
+		d := ttl
+		if rd := time.Duration(rr.Header().Ttl) * time.Second; rd < d {
+			d = rd
+		}
+		r := &denialRecord{rrs: append([]dns.RR{dns.Copy(rr)}, sigs...), expire: now.Add(d)}
+
+		switch x := rr.(type) {
+		case *dns.NSEC:
+			r.key = strings.ToLower(x.Hdr.Name)
+			z.nsec = n.insert(z.nsec, r, func(a, b string) int { return dnsutil.Compare(a, b) })
+		case *dns.NSEC3:
+			if x.Hash != dns.SHA1 || x.Iterations > dnsutil.MaxIterations {
+				continue
+			}
+			if len(z.nsec3) > 0 {
+				if p := z.nsec3[0].rrs[0].(*dns.NSEC3); p.Iterations != x.Iterations || !strings.EqualFold(p.Salt, x.Salt) {
+					// The zone changed its parameters, forget the old chain.
+					n.size -= len(z.nsec3)
+					z.nsec3 = nil
+				}
+			}
+			r.key = strings.ToUpper(x.Hdr.Name[:strings.IndexByte(x.Hdr.Name, '.')])
+			z.nsec3 = n.insert(z.nsec3, r, strings.Compare)
+		}
+	}
+	n.evict(now)
+}
+
+// insert inserts r in the sorted records, replacing the record with the same key.
+func (n *nsecCache) insert(records []*denialRecord, r *denialRecord, compare func(a, b string) int) []*denialRecord {
+	i := sort.Search(len(records), func(i int) bool { return compare(records[i].key, r.key) >= 0 })
+	if i < len(records) && compare(records[i].key, r.key) == 0 {
+		records[i] = r
+		return records
+	}
+	n.size++
+	records = append(records, nil)
+	copy(records[i+1:], records[i:])
+	records[i] = r
+	return records
+}
+
+// evict removes the expired records when the cache is over capacity, and if that isn't enough,
+// randomly picked zones.
+func (n *nsecCache) evict(now time.Time) {
+	if n.size <= n.cap {
+		return
+	}
+	for zone, z := range n.zones {
+		z.nsec = n.expire(z.nsec, now)
+		z.nsec3 = n.expire(z.nsec3, now)
+		if len(z.nsec) == 0 && len(z.nsec3) == 0 {
+			delete(n.zones, zone)
+		}
+	}
+	for zone, z := range n.zones {
+		if n.size <= n.cap {
+			return
+		}
+		n.size -= len(z.nsec) + len(z.nsec3)
+		delete(n.zones, zone)
+	}
+}
+
+func (n *nsecCache) expire(records []*denialRecord, now time.Time) []*denialRecord {
+	out := records[:0]
+	for _, r := range records {
+		if now.Before(r.expire) {
+			out = append(out, r)
+			continue
+		}
+		n.size--
+	}
+	return out
+}
+
+// synthesize returns the reply for qname and qtype that can be derived from the cached NSEC or
+// NSEC3 records: the rcode, the authority section and its TTL. If no reply can be derived ok is
+// false.
+func (n *nsecCache) synthesize(qname string, qtype uint16, now time.Time) (rcode int, ns []dns.RR, ttl time.Duration, ok bool) {
+	if qtype == dns.TypeANY || qtype == dns.TypeRRSIG {
+		return 0, nil, 0, false
+	}
+	qname = strings.ToLower(qname)
+
+	n.RLock()
+	defer n.RUnlock()
+
+	zone, z := n.closest(qname)
+	if z == nil || !now.Before(z.soaExpire) {
+		return 0, nil, 0, false
+	}
+	// The DS records of a zone are in its parent.
+	if qname == zone && qtype == dns.TypeDS {
+		return 0, nil, 0, false
+	}
+
+	var proof []*denialRecord
+	if len(z.nsec) > 0 {
+		rcode, proof = z.nsecProof(qname, qtype, now)
+	}
+	if proof == nil && len(z.nsec3) > 0 {
+		rcode, proof = z.nsec3Proof(zone, qname, qtype, now)
+	}
+	if proof == nil {
+		return 0, nil, 0, false
+	}
+
+	ttl = z.soaExpire.Sub(now)
+	ns = append(ns, z.soa...)
+	seen := map[*denialRecord]bool{}
+	for _, r := range proof {
+		if seen[r] {
+			continue
+		}
+		seen[r] = true
+		if d := r.expire.Sub(now); d < ttl {
+			ttl = d
+		}
+		ns = append(ns, r.rrs...)
+	}
+	return rcode, ns, ttl, true
+}
+
+// closest returns the zone that most closely encloses qname.
+func (n *nsecCache) closest(qname string) (string, *nsecZone) {
+	for off, end := 0, false; !end; off, end = dns.NextLabel(qname, off) {
+		if z, ok := n.zones[qname[off:]]; ok {
+			return qname[off:], z
+		}
+	}
+	if z, ok := n.zones["."]; ok {
+		return ".", z
+	}
+	return "", nil
+}
+
+// nsecProof returns the NSEC records that prove qname doesn't exist, or doesn't have qtype.
+func (z *nsecZone) nsecProof(qname string, qtype uint16, now time.Time) (int, []*denialRecord) {
+	if m := z.nsecMatch(qname, now); m != nil {
+		if nodata(m.rrs[0].(*dns.NSEC).TypeBitMap, qtype) {
+			return dns.RcodeSuccess, []*denialRecord{m}
+		}
+		return 0, nil
+	}
+
+	c := z.nsecCover(qname, now)
+	if c == nil {
+		return 0, nil
+	}
+	wildcard := "*." + closestEncloser(c.rrs[0].(*dns.NSEC), qname)
+	if w := z.nsecMatch(wildcard, now); w != nil {
+		// Answers synthesized from the wildcard, we can only do NODATA.
+		if nodata(w.rrs[0].(*dns.NSEC).TypeBitMap, qtype) {
+			return dns.RcodeSuccess, []*denialRecord{c, w}
+		}
+		return 0, nil
+	}
+	if w := z.nsecCover(wildcard, now); w != nil {
+		return dns.RcodeNameError, []*denialRecord{c, w}
+	}
+	return 0, nil
+}
+
+// nsecMatch returns the NSEC record with owner name.
+func (z *nsecZone) nsecMatch(name string, now time.Time) *denialRecord {
+	i := sort.Search(len(z.nsec), func(i int) bool { return dnsutil.Compare(z.nsec[i].key, name) >= 0 })
+	if i < len(z.nsec) && z.nsec[i].key == name && now.Before(z.nsec[i].expire) {
+		return z.nsec[i]
+	}
+	return nil
+}
+
+// nsecCover returns the NSEC record that proves name doesn't exist. This is the record that
+// sorts right before it, if its next name sorts after name.
+func (z *nsecZone) nsecCover(name string, now time.Time) *denialRecord {
+	i := sort.Search(len(z.nsec), func(i int) bool { return dnsutil.Compare(z.nsec[i].key, name) >= 0 })
+	if i == 0 {
+		return nil
+	}
+	r := z.nsec[i-1]
+	if !now.Before(r.expire) {
+		return nil
+	}
+	nsec := r.rrs[0].(*dns.NSEC)
+	// A delegation or DNAME doesn't say anything about the names below it.
+	if dns.IsSubDomain(r.key, name) && (dnsutil.IsDelegation(nsec.TypeBitMap) || dnsutil.HasType(nsec.TypeBitMap, dns.TypeDNAME)) {
+		return nil
+	}
+	if dnsutil.Compare(r.key, nsec.NextDomain) >= 0 {
+		// The last NSEC of the zone, its next name is the apex.
+		return r
+	}
+	if dnsutil.Compare(name, nsec.NextDomain) < 0 {
+		return r
+	}
+	return nil
+}
+
+// nsec3Proof returns the NSEC3 records that prove qname doesn't exist, or doesn't have qtype.
+func (z *nsecZone) nsec3Proof(zone, qname string, qtype uint16, now time.Time) (int, []*denialRecord) {
+	if m := z.nsec3Match(qname, now); m != nil {
+		if nodata(m.rrs[0].(*dns.NSEC3).TypeBitMap, qtype) {
+			return dns.RcodeSuccess, []*denialRecord{m}
+		}
+		return 0, nil
+	}
+
+	// The closest encloser proof of RFC 5155, section 8.3.
+	next := qname
+	for ce := qname; ce != zone; {
+		off, end := dns.NextLabel(ce, 0)
+		next, ce = ce, ce[off:]
+		if end {
+			ce = "."
+		}
+		m := z.nsec3Match(ce, now)
+		if m == nil {
+			continue
+		}
+		bitmap := m.rrs[0].(*dns.NSEC3).TypeBitMap
+		if dnsutil.IsDelegation(bitmap) || dnsutil.HasType(bitmap, dns.TypeDNAME) {
+			return 0, nil
+		}
+		c := z.nsec3Cover(next, now)
+		if c == nil || c.rrs[0].(*dns.NSEC3).Flags&1 == 1 {
+			// With opt-out the next closer name may be an unsigned delegation.
+			return 0, nil
+		}
+		if w := z.nsec3Cover("*."+ce, now); w != nil {
+			return dns.RcodeNameError, []*denialRecord{m, c, w}
+		}
+		return 0, nil
+	}
+	return 0, nil
+}
+
+// nsec3Match returns the NSEC3 record for name.
+func (z *nsecZone) nsec3Match(name string, now time.Time) *denialRecord {
+	h := z.hash(name)
+	i := sort.Search(len(z.nsec3), func(i int) bool { return z.nsec3[i].key >= h })
+	if i < len(z.nsec3) && z.nsec3[i].key == h && now.Before(z.nsec3[i].expire) {
+		return z.nsec3[i]
+	}
+	return nil
+}
+
+// nsec3Cover returns the NSEC3 record whose span covers the hash of name.
+func (z *nsecZone) nsec3Cover(name string, now time.Time) *denialRecord {
+	h := z.hash(name)
+	i := sort.Search(len(z.nsec3), func(i int) bool { return z.nsec3[i].key >= h })
+	if i < len(z.nsec3) && z.nsec3[i].key == h {
+		return nil
+	}
+	// The record before the hash, or the last one, that wraps around to the first.
+	r := z.nsec3[len(z.nsec3)-1]
+	if i > 0 {
+		r = z.nsec3[i-1]
+	}
+	if !now.Before(r.expire) {
+		return nil
+	}
+	next := strings.ToUpper(r.rrs[0].(*dns.NSEC3).NextDomain)
+	switch {
+	case r.key < next:
+		if r.key < h && h < next {
+			return r
+		}
+	default: // last in the chain
+		if h > r.key || h < next {
+			return r
+		}
+	}
+	return nil
+}
+
+func (z *nsecZone) hash(name string) string {
+	p := z.nsec3[0].rrs[0].(*dns.NSEC3)
+	return dns.HashName(name, p.Hash, p.Iterations, p.Salt)
+}
+
+// closestEncloser returns the closest existing ancestor of name proven by nsec, which covers
+// name: the longest common ancestor of name with the owner and the next name of nsec.
+func closestEncloser(nsec *dns.NSEC, name string) string {
+	n := dns.CompareDomainName(name, nsec.Hdr.Name)
+	if m := dns.CompareDomainName(name, nsec.NextDomain); m > n {
+		n = m
+	}
+	if n == 0 {
+		return "."
+	}
+	idx := dns.Split(name)
+	return name[idx[len(idx)-n]:]
+}
+
+// nodata returns true if a name with the types in bitmap has no records of qtype, and would
+// not be answered with a CNAME or a referral.
+func nodata(bitmap []uint16, qtype uint16) bool {
+	if dnsutil.HasType(bitmap, qtype) || dnsutil.HasType(bitmap, dns.TypeCNAME) || dnsutil.HasType(bitmap, dns.TypeDNAME) {
+		return false
+	}
+	return !dnsutil.IsDelegation(bitmap) || qtype == dns.TypeDS
+}
+
+// signatures returns copies of the signatures in rrs over the RRset of name and type t, made by zone.
+func signatures(rrs []dns.RR, name string, t uint16, zone string) []dns.RR {
+	var sigs []dns.RR
+	for _, rr := range rrs {
+		s, ok := rr.(*dns.RRSIG)
+		if !ok || s.TypeCovered != t || !strings.EqualFold(s.Hdr.Name, name) || !strings.EqualFold(s.SignerName, zone) {
+			continue
+		}
+		sigs = append(sigs, dns.Copy(s))
+	}
+	return sigs
+}
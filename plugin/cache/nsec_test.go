@@ -0,0 +1,242 @@
+package cache
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+const testSig = " 8 3 3600 20991231000000 20000101000000 12051 example.org. bm90IGEgcmVhbCBzaWduYXR1cmU="
+
+// nsecHandler answers for example.org., which only has the names example.org. and c.example.org.
+// with an A record, with validated negative replies. It counts the queries it gets.
+func nsecHandler(queries *int, ad bool) test.HandlerFunc {
+	return func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		*queries++
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.AuthenticatedData = ad
+		m.RecursionAvailable = true
+		m.SetEdns0(4096, true)
+
+		qname, qtype := strings.ToLower(r.Question[0].Name), r.Question[0].Qtype
+		soa := []dns.RR{
+			test.SOA("example.org. 3600 IN SOA ns.example.org. admin.example.org. 1 3600 600 86400 300"),
+			test.RRSIG("example.org. 3600 IN RRSIG SOA" + testSig),
+		}
+		apex := []dns.RR{
+			test.NSEC("example.org. 300 IN NSEC c.example.org. NS SOA RRSIG NSEC"),
+			test.RRSIG("example.org. 300 IN RRSIG NSEC" + testSig),
+		}
+		c := []dns.RR{
+			test.NSEC("c.example.org. 300 IN NSEC example.org. A RRSIG NSEC"),
+			test.RRSIG("c.example.org. 300 IN RRSIG NSEC" + testSig),
+		}
+		switch {
+		case qname == "c.example.org." && qtype == dns.TypeA:
+			m.Answer = []dns.RR{test.A("c.example.org. 300 IN A 127.0.0.1")}
+		case qname == "c.example.org.":
+			m.Ns = append(soa, c...)
+		case qname == "example.org.":
+			m.Ns = append(soa, apex...)
+		default:
+			m.Rcode = dns.RcodeNameError
+			m.Ns = append(append(soa, apex...), c...)
+		}
+		w.WriteMsg(m)
+		return m.Rcode, nil
+	}
+}
+
+func TestAggressiveNsec(t *testing.T) {
+	queries := 0
+	c := New()
+	c.nsec = newNsecCache(defaultCap)
+	c.Next = nsecHandler(&queries, true)
+
+	tests := []struct {
+		qname    string
+		qtype    uint16
+		do       bool
+		rcode    int
+		upstream bool // if the query is sent to the next plugin
+		nsec     int
+	}{
+		{"a.example.org.", dns.TypeA, true, dns.RcodeNameError, true, 2},
+		{"b.example.org.", dns.TypeA, true, dns.RcodeNameError, false, 1}, // also covers *.example.org.
+		{"x.y.example.org.", dns.TypeTXT, true, dns.RcodeNameError, false, 2},
+		{"z.example.org.", dns.TypeA, false, dns.RcodeNameError, false, 0},
+		{"c.example.org.", dns.TypeTXT, true, dns.RcodeSuccess, false, 1},
+		{"c.example.org.", dns.TypeA, true, dns.RcodeSuccess, true, 0},
+		{"example.org.", dns.TypeA, true, dns.RcodeSuccess, false, 1},
+		{"example.org.", dns.TypeDS, true, dns.RcodeSuccess, true, 1},
+		{"example.org.", dns.TypeNS, true, dns.RcodeSuccess, true, 1},
+	}
+
+	for i, tc := range tests {
+		before := queries
+		req := new(dns.Msg)
+		req.SetQuestion(tc.qname, tc.qtype)
+		if tc.do {
+			req.SetEdns0(4096, true)
+		}
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		c.ServeDNS(context.TODO(), rec, req)
+
+		if rec.Msg.Rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.rcode], dns.RcodeToString[rec.Msg.Rcode])
+		}
+		if upstream := queries > before; upstream != tc.upstream {
+			t.Errorf("Test %d: expected query to the next plugin %t, got %t", i, tc.upstream, upstream)
+		}
+		nsec := 0
+		for _, rr := range rec.Msg.Ns {
+			if rr.Header().Rrtype == dns.TypeNSEC {
+				nsec++
+			}
+		}
+		if nsec != tc.nsec {
+			t.Errorf("Test %d: expected %d NSEC records, got %d", i, tc.nsec, nsec)
+		}
+	}
+
+	// When the NSEC records expire, queries go to the next plugin again.
+	c.now = func() time.Time { return time.Now().Add(301 * time.Second) }
+	before := queries
+	req := new(dns.Msg)
+	req.SetQuestion("d.example.org.", dns.TypeA)
+	c.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req)
+	if queries == before {
+		t.Errorf("Expected query for d.example.org. to the next plugin after the NSEC records expired")
+	}
+}
+
+func TestAggressiveNsecNotValidated(t *testing.T) {
+	queries := 0
+	c := New()
+	c.nsec = newNsecCache(defaultCap)
+	c.Next = nsecHandler(&queries, false)
+
+	for _, qname := range []string{"a.example.org.", "b.example.org."} {
+		req := new(dns.Msg)
+		req.SetQuestion(qname, dns.TypeA)
+		req.SetEdns0(4096, true)
+		c.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req)
+	}
+	if queries != 2 {
+		t.Errorf("Expected 2 queries to the next plugin, got %d", queries)
+	}
+}
+
+// nsec3Reply returns a negative reply for nsec3.org. with NSEC3 records for names.
+func nsec3Reply(rcode int, flags uint8, names map[string][]uint16) *dns.Msg {
+	m := new(dns.Msg)
+	m.Rcode = rcode
+	m.Ns = []dns.RR{
+		test.SOA("nsec3.org. 3600 IN SOA ns.nsec3.org. admin.nsec3.org. 1 3600 600 86400 300"),
+		test.RRSIG("nsec3.org. 3600 IN RRSIG SOA 8 2 3600 20991231000000 20000101000000 12051 nsec3.org. bm90IGEgcmVhbCBzaWduYXR1cmU="),
+	}
+	var hashes []string
+	for n := range names {
+		hashes = append(hashes, dns.HashName(n, dns.SHA1, 1, "AABB"))
+	}
+	sort.Strings(hashes)
+	for i, h := range hashes {
+		var types []uint16
+		for n, t := range names {
+			if dns.HashName(n, dns.SHA1, 1, "AABB") == h {
+				types = t
+			}
+		}
+		owner := strings.ToLower(h) + ".nsec3.org."
+		m.Ns = append(m.Ns, &dns.NSEC3{
+			Hdr:        dns.RR_Header{Name: owner, Rrtype: dns.TypeNSEC3, Class: dns.ClassINET, Ttl: 300},
+			Hash:       dns.SHA1,
+			Flags:      flags,
+			Iterations: 1,
+			SaltLength: 2,
+			Salt:       "AABB",
+			HashLength: 20,
+			NextDomain: hashes[(i+1)%len(hashes)],
+			TypeBitMap: types,
+		}, test.RRSIG(owner+" 300 IN RRSIG NSEC3 8 3 300 20991231000000 20000101000000 12051 nsec3.org. bm90IGEgcmVhbCBzaWduYXR1cmU="))
+	}
+	return m
+}
+
+func TestAggressiveNsec3(t *testing.T) {
+	names := map[string][]uint16{
+		"nsec3.org.":     {dns.TypeNS, dns.TypeSOA, dns.TypeRRSIG, dns.TypeDNSKEY, dns.TypeNSEC3PARAM},
+		"www.nsec3.org.": {dns.TypeA, dns.TypeRRSIG},
+	}
+	now := time.Now()
+
+	tests := []struct {
+		flags uint8
+		qname string
+		qtype uint16
+		rcode int
+		ok    bool
+	}{
+		{0, "nx.nsec3.org.", dns.TypeA, dns.RcodeNameError, true},
+		{0, "a.b.nsec3.org.", dns.TypeA, dns.RcodeNameError, true},
+		{0, "www.nsec3.org.", dns.TypeMX, dns.RcodeSuccess, true},
+		{0, "www.nsec3.org.", dns.TypeA, 0, false},
+		{0, "nx.example.org.", dns.TypeA, 0, false},
+		// opt-out doesn't prove the next closer name doesn't exist
+		{1, "nx.nsec3.org.", dns.TypeA, 0, false},
+		{1, "www.nsec3.org.", dns.TypeMX, dns.RcodeSuccess, true},
+	}
+	for i, tc := range tests {
+		n := newNsecCache(defaultCap)
+		n.add(nsec3Reply(dns.RcodeNameError, tc.flags, names), now, maxNTTL)
+
+		rcode, ns, ttl, ok := n.synthesize(tc.qname, tc.qtype, now)
+		if ok != tc.ok {
+			t.Errorf("Test %d: expected ok %t, got %t", i, tc.ok, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.rcode], dns.RcodeToString[rcode])
+		}
+		if ttl != 300*time.Second {
+			t.Errorf("Test %d: expected TTL of 300s, got %s", i, ttl)
+		}
+		if len(ns) < 4 {
+			t.Errorf("Test %d: expected SOA and NSEC3 records, got %v", i, ns)
+		}
+	}
+}
+
+func TestNsecCacheEvict(t *testing.T) {
+	n := newNsecCache(2)
+	now := time.Now()
+	names := map[string][]uint16{"nsec3.org.": {dns.TypeSOA}, "www.nsec3.org.": {dns.TypeA}}
+	n.add(nsec3Reply(dns.RcodeNameError, 0, names), now, maxNTTL)
+	if n.Len() != 2 {
+		t.Fatalf("Expected 2 records, got %d", n.Len())
+	}
+
+	m := new(dns.Msg)
+	m.Rcode = dns.RcodeNameError
+	m.Ns = []dns.RR{
+		test.SOA("example.org. 3600 IN SOA ns.example.org. admin.example.org. 1 3600 600 86400 300"),
+		test.RRSIG("example.org. 3600 IN RRSIG SOA" + testSig),
+		test.NSEC("example.org. 300 IN NSEC c.example.org. NS SOA RRSIG NSEC"),
+		test.RRSIG("example.org. 300 IN RRSIG NSEC" + testSig),
+	}
+	n.add(m, now, maxNTTL)
+	if n.Len() > 2 {
+		t.Errorf("Expected at most 2 records, got %d", n.Len())
+	}
+}
@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/coredns/coredns/plugin/cache/freq"
+	"github.com/coredns/coredns/plugin/pkg/cache"
+
+	"github.com/miekg/dns"
+)
+
+// The snapshot starts with snapshotMagic and the version of the format. It is followed by the
+// items, each encoded as:
+//
+//	kind       1 byte, kindSuccess or kindDenial
+//	key        8 bytes
+//	stored     8 bytes, Unix time in seconds
+//	origTTL    4 bytes
+//	rcode      2 bytes
+//	flags      1 byte, the AA, AD and RA bits
+//	length     4 bytes
+//	message    length bytes, the records of the item as a DNS message in wire format
+//
+// All integers are big endian.
+const (
+	snapshotMagic   = "CDNSCACH"
+	snapshotVersion = 1
+
+	kindSuccess = 0
+	kindDenial  = 1
+
+	flagAuthoritative      = 1 << 0
+	flagAuthenticatedData  = 1 << 1
+	flagRecursionAvailable = 1 << 2
+)
+
+var errSnapshotFormat = errors.New("not a cache snapshot")
+
+// save writes the items of the cache to the file path. The file is replaced atomically.
+func (c *Cache) save(path string) (int, error) {
+	tmp, err := os.OpenFile(path+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, err
+	}
+	n, err := c.writeSnapshot(tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	return n, os.Rename(tmp.Name(), path)
+}
+
+// load adds the items in the file path that haven't expired to the cache. A missing file is
+// not an error.
+func (c *Cache) load(path string) (int, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return c.readSnapshot(f, c.now())
+}
+
+func (c *Cache) writeSnapshot(w io.Writer) (int, error) {
+	type kinded struct {
+		kind byte
+		key  uint64
+		i    *item
+	}
+	var items []kinded
+	for kind, ca := range map[byte]*cache.Cache{kindSuccess: c.pcache, kindDenial: c.ncache} {
+		ca.Walk(func(key uint64, el interface{}) bool {
+			items = append(items, kinded{kind, key, el.(*item)})
+			return true
+		})
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(snapshotMagic)
+	binary.Write(bw, binary.BigEndian, uint16(snapshotVersion))
+
+	now := c.now()
+	n := 0
+	for _, k := range items {
+		if k.i.ttl(now) <= 0 {
+			continue
+		}
+		m := &dns.Msg{Answer: k.i.Answer, Ns: k.i.Ns, Extra: k.i.Extra}
+		m.Compress = true
+		buf, err := m.Pack()
+		if err != nil {
+			log.Warningf("Failed to pack cached item for the snapshot: %s", err)
+			continue
+		}
+		flags := byte(0)
+		if k.i.Authoritative {
+			flags |= flagAuthoritative
+		}
+		if k.i.AuthenticatedData {
+			flags |= flagAuthenticatedData
+		}
+		if k.i.RecursionAvailable {
+			flags |= flagRecursionAvailable
+		}
+
+		bw.WriteByte(k.kind)
+		binary.Write(bw, binary.BigEndian, k.key)
+		binary.Write(bw, binary.BigEndian, k.i.stored.Unix())
+		binary.Write(bw, binary.BigEndian, k.i.origTTL)
+		binary.Write(bw, binary.BigEndian, uint16(k.i.Rcode))
+		bw.WriteByte(flags)
+		binary.Write(bw, binary.BigEndian, uint32(len(buf)))
+		bw.Write(buf)
+		n++
+	}
+	return n, bw.Flush()
+}
+
+func (c *Cache) readSnapshot(r io.Reader, now time.Time) (int, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != snapshotMagic {
+		return 0, errSnapshotFormat
+	}
+	var version uint16
+	if err := binary.Read(br, binary.BigEndian, &version); err != nil {
+		return 0, errSnapshotFormat
+	}
+	if version != snapshotVersion {
+		return 0, fmt.Errorf("unsupported cache snapshot version: %d", version)
+	}
+
+	n := 0
+	for {
+		kind, err := br.ReadByte()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		var hdr struct {
+			Key     uint64
+			Stored  int64
+			OrigTTL uint32
+			Rcode   uint16
+			Flags   byte
+			Length  uint32
+		}
+		if err := binary.Read(br, binary.BigEndian, &hdr); err != nil {
+			return n, fmt.Errorf("truncated cache snapshot: %s", err)
+		}
+		if hdr.Length > dns.MaxMsgSize {
+			return n, errSnapshotFormat
+		}
+		buf := make([]byte, hdr.Length)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return n, fmt.Errorf("truncated cache snapshot: %s", err)
+		}
+
+		i := &item{
+			Rcode:              int(hdr.Rcode),
+			Authoritative:      hdr.Flags&flagAuthoritative != 0,
+			AuthenticatedData:  hdr.Flags&flagAuthenticatedData != 0,
+			RecursionAvailable: hdr.Flags&flagRecursionAvailable != 0,
+			origTTL:            hdr.OrigTTL,
+			stored:             time.Unix(hdr.Stored, 0).UTC(),
+			Freq:               new(freq.Freq),
+		}
+		if i.ttl(now) <= 0 {
+			continue
+		}
+		m := new(dns.Msg)
+		if err := m.Unpack(buf); err != nil {
+			log.Warningf("Failed to unpack cached item from the snapshot: %s", err)
+			continue
+		}
+		i.Answer, i.Ns, i.Extra = m.Answer, m.Ns, m.Extra
+		i.size = size(i)
+
+		switch kind {
+		case kindSuccess:
+			c.pcache.Add(hdr.Key, i)
+		case kindDenial:
+			c.ncache.Add(hdr.Key, i)
+		default:
+			return n, errSnapshotFormat
+		}
+		n++
+	}
+}
+
+// persist saves the cache to its snapshot file every interval, until stop is closed.
+func (c *Cache) persist(path string, interval time.Duration, stop <-chan struct{}) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick.C:
+			c.saveLog(path)
+		}
+	}
+}
+
+// saveLog saves the cache to path and logs the result. A failure isn't fatal, the cache is still
+// in memory and the next save tries again.
+func (c *Cache) saveLog(path string) {
+	n, err := c.save(path)
+	if err != nil {
+		log.Warningf("Failed to save the cache to %s: %s", path, err)
+		return
+	}
+	log.Debugf("Saved %d items to %s", n, path)
+}
@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestPersist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "snapshot")
+
+	now := time.Now()
+	c := New()
+	c.now = func() time.Time { return now }
+
+	positive := new(dns.Msg)
+	positive.SetQuestion("example.org.", dns.TypeA)
+	positive.AuthenticatedData = true
+	positive.RecursionAvailable = true
+	positive.Answer = []dns.RR{test.A("example.org. 3600 IN A 127.0.0.1")}
+	c.pcache.Add(hash("example.org.", dns.TypeA, false), newItem(positive, now, time.Hour))
+
+	negative := new(dns.Msg)
+	negative.SetQuestion("nx.example.org.", dns.TypeA)
+	negative.Rcode = dns.RcodeNameError
+	negative.Ns = []dns.RR{test.SOA("example.org. 300 IN SOA ns.example.org. admin.example.org. 1 3600 600 86400 300")}
+	c.ncache.Add(hash("nx.example.org.", dns.TypeA, false), newItem(negative, now, 5*time.Minute))
+
+	expired := new(dns.Msg)
+	expired.SetQuestion("old.example.org.", dns.TypeA)
+	expired.Answer = []dns.RR{test.A("old.example.org. 10 IN A 127.0.0.2")}
+	c.pcache.Add(hash("old.example.org.", dns.TypeA, false), newItem(expired, now.Add(-time.Minute), 10*time.Second))
+
+	n, err := c.save(file)
+	if err != nil {
+		t.Fatalf("Failed to save the cache: %s", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 items to be saved, got %d", n)
+	}
+
+	// Loaded two minutes later, the negative item has 3 minutes left.
+	later := now.Add(2 * time.Minute)
+	c1 := New()
+	c1.now = func() time.Time { return later }
+	if n, err := c1.load(file); err != nil || n != 2 {
+		t.Fatalf("Expected 2 items to be loaded, got %d: %v", n, err)
+	}
+
+	i, ok := c1.pcache.Get(hash("example.org.", dns.TypeA, false))
+	if !ok {
+		t.Fatal("Expected example.org. to be loaded")
+	}
+	it := i.(*item)
+	if !it.AuthenticatedData || !it.RecursionAvailable || it.Authoritative {
+		t.Errorf("Expected the flags of the item to be restored")
+	}
+	if len(it.Answer) != 1 || it.Answer[0].(*dns.A).A.String() != "127.0.0.1" {
+		t.Errorf("Expected the answer to be restored, got %v", it.Answer)
+	}
+
+	i, ok = c1.ncache.Get(hash("nx.example.org.", dns.TypeA, false))
+	if !ok {
+		t.Fatal("Expected nx.example.org. to be loaded")
+	}
+	if it := i.(*item); it.Rcode != dns.RcodeNameError || it.ttl(later) > 180 || it.ttl(later) < 179 {
+		t.Errorf("Expected an NXDOMAIN with 180s left, got %s with %ds", dns.RcodeToString[it.Rcode], it.ttl(later))
+	}
+
+	if _, ok := c1.pcache.Get(hash("old.example.org.", dns.TypeA, false)); ok {
+		t.Errorf("Expected old.example.org. not to be loaded")
+	}
+
+	// All expired by now.
+	c2 := New()
+	c2.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if n, err := c2.load(file); err != nil || n != 0 {
+		t.Errorf("Expected no items to be loaded, got %d: %v", n, err)
+	}
+}
+
+func TestPersistLoadErrors(t *testing.T) {
+	c := New()
+	if n, err := c.load("/does/not/exist"); n != 0 || err != nil {
+		t.Errorf("Expected no error for a missing snapshot, got %v", err)
+	}
+
+	if _, err := c.readSnapshot(bytes.NewBufferString("not a snapshot"), time.Now()); err != errSnapshotFormat {
+		t.Errorf("Expected %q, got %v", errSnapshotFormat, err)
+	}
+	if _, err := c.readSnapshot(bytes.NewBufferString(snapshotMagic+"\x00\x02"), time.Now()); err == nil {
+		t.Errorf("Expected error for an unsupported version")
+	}
+
+	buf := &bytes.Buffer{}
+	c.pcache.Add(1, newItem(&dns.Msg{Answer: []dns.RR{test.A("example.org. 3600 IN A 127.0.0.1")}}, time.Now(), time.Hour))
+	c.writeSnapshot(buf)
+	truncated := buf.Bytes()[:buf.Len()-3]
+	if _, err := New().readSnapshot(bytes.NewBuffer(truncated), time.Now()); err == nil {
+		t.Errorf("Expected error for a truncated snapshot")
+	}
+}
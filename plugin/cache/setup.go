@@ -2,7 +2,10 @@ package cache
 
 import (
 	"fmt"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/coredns/coredns/core/dnsserver"
@@ -33,10 +36,36 @@ func setup(c *caddy.Controller) error {
 		return ca
 	})
 
+	if ca.persistFile != "" {
+		stop := make(chan struct{})
+		var once sync.Once
+		c.OnStartup(func() error {
+			n, err := ca.load(ca.persistFile)
+			if err != nil {
+				log.Warningf("Failed to load the cache from %s: %s", ca.persistFile, err)
+			} else if n > 0 {
+				log.Infof("Loaded %d items from %s", n, ca.persistFile)
+			}
+			go ca.persist(ca.persistFile, ca.persistInterval, stop)
+			return nil
+		})
+		// Save before the new instance starts on a reload, and when we quit.
+		save := func() error {
+			ca.saveLog(ca.persistFile)
+			return nil
+		}
+		c.OnRestart(save)
+		c.OnFinalShutdown(save)
+		c.OnShutdown(func() error {
+			once.Do(func() { close(stop) })
+			return nil
+		})
+	}
+
 	c.OnStartup(func() error {
 		metrics.MustRegister(c,
 			cacheSize, cacheHits, cacheMisses,
-			cachePrefetches, cacheDrops)
+			cachePrefetches, cacheDrops, cacheBytes, cacheHitRatio)
 		return nil
 	})
 
@@ -45,6 +74,7 @@ func setup(c *caddy.Controller) error {
 
 func cacheParse(c *caddy.Controller) (*Cache, error) {
 	ca := New()
+	config := dnsserver.GetConfig(c)
 
 	j := 0
 	for c.Next() {
@@ -181,6 +211,64 @@ func cacheParse(c *caddy.Controller) (*Cache, error) {
 					ca.percentage = num
 				}
 
+			case "eviction":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				policy, err := cache.PolicyFromString(args[0])
+				if err != nil {
+					return nil, err
+				}
+				ca.policy = policy
+			case "max_bytes":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				max, err := parseBytes(args[0])
+				if err != nil {
+					return nil, err
+				}
+				ca.maxBytes = max
+			case "persist":
+				args := c.RemainingArgs()
+				if len(args) == 0 || len(args) > 2 {
+					return nil, c.ArgErr()
+				}
+				ca.persistFile = args[0]
+				if !filepath.IsAbs(ca.persistFile) && config.Root != "" {
+					ca.persistFile = filepath.Join(config.Root, ca.persistFile)
+				}
+				ca.persistInterval = defaultPersistInterval
+				if len(args) > 1 {
+					dur, err := time.ParseDuration(args[1])
+					if err != nil {
+						return nil, err
+					}
+					if dur <= 0 {
+						return nil, fmt.Errorf("persist interval should be positive: %s", dur)
+					}
+					ca.persistInterval = dur
+				}
+			case "aggressive_nsec":
+				args := c.RemainingArgs()
+				if len(args) > 1 {
+					return nil, c.ArgErr()
+				}
+				ncap := defaultCap
+				if len(args) > 0 {
+					var err error
+					ncap, err = strconv.Atoi(args[0])
+					if err != nil {
+						return nil, err
+					}
+					if ncap <= 0 {
+						return nil, fmt.Errorf("aggressive_nsec capacity should be positive: %d", ncap)
+					}
+				}
+				ca.nsec = newNsecCache(ncap)
+
 			default:
 				return nil, c.ArgErr()
 			}
@@ -191,9 +279,40 @@ func cacheParse(c *caddy.Controller) (*Cache, error) {
 		}
 		ca.Zones = origins
 
-		ca.pcache = cache.New(ca.pcap)
-		ca.ncache = cache.New(ca.ncap)
+		opts := []cache.Option{cache.WithPolicy(ca.policy)}
+		if ca.maxBytes > 0 {
+			opts = append(opts, cache.WithBudget(cache.NewBudget(ca.maxBytes)))
+		}
+		ca.pcache = cache.New(ca.pcap, opts...)
+		ca.ncache = cache.New(ca.ncap, opts...)
 	}
 
 	return ca, nil
 }
+
+// parseBytes parses a number of bytes, with an optional K, M or G suffix for KiB, MiB or GiB.
+func parseBytes(s string) (int64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("max_bytes should be positive")
+	}
+	mult := int64(1)
+	switch strings.ToUpper(s[len(s)-1:]) {
+	case "K":
+		mult = 1 << 10
+	case "M":
+		mult = 1 << 20
+	case "G":
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("max_bytes should be positive: %d", n)
+	}
+	return n * mult, nil
+}
@@ -4,6 +4,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/coredns/coredns/plugin/pkg/cache"
+
 	"github.com/mholt/caddy"
 )
 
@@ -113,3 +115,151 @@ func TestSetup(t *testing.T) {
 		}
 	}
 }
+
+func TestSetupAggressiveNsec(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		capacity  int // 0 when disabled
+	}{
+		{`cache`, false, 0},
+		{`cache {
+			aggressive_nsec
+		}`, false, defaultCap},
+		{`cache {
+			aggressive_nsec 500
+		}`, false, 500},
+		// fails
+		{`cache {
+			aggressive_nsec 0
+		}`, true, 0},
+		{`cache {
+			aggressive_nsec aaa
+		}`, true, 0},
+		{`cache {
+			aggressive_nsec 10 20
+		}`, true, 0},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		ca, err := cacheParse(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %v: Expected error but found nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		capacity := 0
+		if ca.nsec != nil {
+			capacity = ca.nsec.cap
+		}
+		if capacity != test.capacity {
+			t.Errorf("Test %v: Expected aggressive_nsec capacity %d but found: %d", i, test.capacity, capacity)
+		}
+	}
+}
+
+func TestSetupEviction(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		policy    cache.Policy
+		maxBytes  int64
+	}{
+		{`cache`, false, cache.Random, 0},
+		{`cache {
+			eviction lru
+		}`, false, cache.LRU, 0},
+		{`cache {
+			eviction tinylfu
+			max_bytes 64M
+		}`, false, cache.TinyLFU, 64 << 20},
+		{`cache {
+			max_bytes 100000
+		}`, false, cache.Random, 100000},
+		{`cache {
+			max_bytes 512k
+		}`, false, cache.Random, 512 << 10},
+		// fails
+		{`cache {
+			eviction fifo
+		}`, true, cache.Random, 0},
+		{`cache {
+			eviction
+		}`, true, cache.Random, 0},
+		{`cache {
+			max_bytes 0
+		}`, true, cache.Random, 0},
+		{`cache {
+			max_bytes 10X
+		}`, true, cache.Random, 0},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		ca, err := cacheParse(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %v: Expected error but found nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if ca.policy != test.policy {
+			t.Errorf("Test %v: Expected eviction policy %s but found: %s", i, test.policy, ca.policy)
+		}
+		if ca.maxBytes != test.maxBytes {
+			t.Errorf("Test %v: Expected max_bytes %d but found: %d", i, test.maxBytes, ca.maxBytes)
+		}
+	}
+}
+
+func TestSetupPersist(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		file      string
+		interval  time.Duration
+	}{
+		{`cache`, false, "", 0},
+		{`cache {
+			persist /var/lib/coredns/cache
+		}`, false, "/var/lib/coredns/cache", defaultPersistInterval},
+		{`cache {
+			persist /var/lib/coredns/cache 30s
+		}`, false, "/var/lib/coredns/cache", 30 * time.Second},
+		// fails
+		{`cache {
+			persist
+		}`, true, "", 0},
+		{`cache {
+			persist /var/lib/coredns/cache 0s
+		}`, true, "", 0},
+		{`cache {
+			persist /var/lib/coredns/cache 30s 1m
+		}`, true, "", 0},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		ca, err := cacheParse(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %v: Expected error but found nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if ca.persistFile != test.file || ca.persistInterval != test.interval {
+			t.Errorf("Test %v: Expected persist %s %s but found: %s %s", i, test.file, test.interval, ca.persistFile, ca.persistInterval)
+		}
+	}
+}
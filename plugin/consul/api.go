@@ -0,0 +1,109 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// The parts of the Consul HTTP API responses we use.
+type (
+	healthEntry struct {
+		Node    healthNode
+		Service healthService
+		Checks  []healthCheck
+	}
+
+	healthNode struct {
+		Node    string
+		Address string
+	}
+
+	healthService struct {
+		ID      string
+		Service string
+		Tags    []string
+		Address string
+		Port    int
+	}
+
+	healthCheck struct {
+		CheckID string
+		Status  string
+	}
+)
+
+const (
+	// waitTime is how long a blocking query may wait for a change.
+	waitTime = 5 * time.Minute
+	// requestTimeout is the timeout of a query, on top of the wait time for blocking queries.
+	requestTimeout = 10 * time.Second
+)
+
+// catalogServices returns the names of all services and their tags. With a non-zero index this
+// blocks until the services change, or waitTime has passed.
+func (c *Consul) catalogServices(ctx context.Context, index uint64) (map[string][]string, uint64, error) {
+	services := map[string][]string{}
+	index, err := c.get(ctx, "/v1/catalog/services", url.Values{}, index, &services)
+	return services, index, err
+}
+
+// healthService returns the instances of service that pass all their health checks. With a
+// non-zero index this blocks until the instances change, or waitTime has passed.
+func (c *Consul) healthService(ctx context.Context, service string, index uint64) ([]healthEntry, uint64, error) {
+	var entries []healthEntry
+	index, err := c.get(ctx, "/v1/health/service/"+url.PathEscape(service), url.Values{"passing": {"true"}}, index, &entries)
+	return entries, index, err
+}
+
+// get queries the Consul HTTP API and decodes the JSON response into v. It returns the index of
+// the response, which is used to block the next query until something changed.
+func (c *Consul) get(ctx context.Context, path string, query url.Values, index uint64, v interface{}) (uint64, error) {
+	timeout := requestTimeout
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", fmt.Sprintf("%ds", int(c.wait/time.Second)))
+		timeout += c.wait
+	}
+	if c.datacenter != "" {
+		query.Set("dc", c.datacenter)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodGet, c.address+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%s: unexpected status: %s", path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return 0, fmt.Errorf("%s: %v", path, err)
+	}
+
+	next, err := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid X-Consul-Index: %q", path, resp.Header.Get("X-Consul-Index"))
+	}
+	// The index must be reset when it goes backwards and must not be zero, see
+	// https://www.consul.io/api/features/blocking.html.
+	if next < index || next == 0 {
+		next = 1
+	}
+	return next, nil
+}
@@ -0,0 +1,207 @@
+// Package consul implements a plugin that serves the services in the Consul catalog.
+package consul
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/etcd/msg"
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+	"github.com/coredns/coredns/plugin/pkg/fall"
+	"github.com/coredns/coredns/plugin/pkg/upstream"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	defaultAddress = "http://127.0.0.1:8500"
+	defaultTTL     = 30
+)
+
+var errNoItems = errors.New("no items found")
+
+// Consul is a plugin that answers queries for the services in the Consul catalog. Only instances
+// that pass their health checks are returned.
+type Consul struct {
+	Next     plugin.Handler
+	Fall     fall.F
+	Zones    []string
+	Upstream *upstream.Upstream
+
+	address    string
+	token      string
+	datacenter string
+	ttl        uint32
+	wait       time.Duration
+	client     *http.Client
+
+	mu       sync.RWMutex
+	services map[string][]instance // passing instances by lowercased service name
+	synced   bool
+
+	watches map[string]context.CancelFunc // only used by the catalog watch
+}
+
+// instance is a passing instance of a service.
+type instance struct {
+	node        string
+	nodeAddress string
+	address     string // the address of the service, if it differs from the node's
+	port        int
+	tags        []string
+}
+
+// New returns a new Consul plugin that talks to the Consul agent at address.
+func New(zones []string, address string) *Consul {
+	return &Consul{
+		Zones:    zones,
+		Upstream: upstream.New(),
+		address:  strings.TrimSuffix(address, "/"),
+		ttl:      defaultTTL,
+		wait:     waitTime,
+		client:   &http.Client{},
+		services: make(map[string][]instance),
+		watches:  make(map[string]context.CancelFunc),
+	}
+}
+
+// Services implements the ServiceBackend interface. Names are looked up as in Consul's DNS interface:
+//
+//	<service>.service.<zone>          all passing instances of service
+//	<tag>.<service>.service.<zone>    the instances with tag
+//	_<service>._<tag>.service.<zone>  as above, _tcp and _udp match every instance (RFC 2782)
+//	<node>.node.<zone>                the address of node
+//	<hex address>.addr.<zone>         the address itself, used as the target of SRV records
+func (c *Consul) Services(ctx context.Context, state request.Request, exact bool, opt plugin.Options) ([]msg.Service, error) {
+	name := state.Name()
+	zone := plugin.Zones(c.Zones).Matches(name)
+	if zone == "" {
+		return nil, errNoItems
+	}
+	labels := dns.SplitDomainName(name[:len(name)-len(zone)])
+	if len(labels) == 0 {
+		return nil, nil
+	}
+
+	kind := labels[len(labels)-1]
+	labels = labels[:len(labels)-1]
+	switch {
+	case len(labels) == 0 && (kind == "service" || kind == "node" || kind == "addr"):
+		return nil, nil
+
+	case kind == "service" && len(labels) <= 2:
+		service, tag := labels[len(labels)-1], ""
+		if len(labels) == 2 {
+			tag = labels[0]
+			if strings.HasPrefix(labels[0], "_") && strings.HasPrefix(labels[1], "_") {
+				service, tag = labels[0][1:], labels[1][1:]
+				if tag == "tcp" || tag == "udp" {
+					tag = ""
+				}
+			}
+		}
+		return c.service(zone, service, tag)
+
+	case kind == "node" && len(labels) == 1:
+		return c.node(zone, labels[0])
+
+	case kind == "addr" && len(labels) == 1:
+		ip, err := hex.DecodeString(labels[0])
+		if err != nil || (len(ip) != net.IPv4len && len(ip) != net.IPv6len) {
+			return nil, errNoItems
+		}
+		return []msg.Service{{Host: net.IP(ip).String(), TTL: c.ttl, Key: msg.Path(name, "consul")}}, nil
+	}
+	return nil, errNoItems
+}
+
+func (c *Consul) service(zone, service, tag string) ([]msg.Service, error) {
+	c.mu.RLock()
+	instances := c.services[strings.ToLower(service)]
+	c.mu.RUnlock()
+
+	var services []msg.Service
+	for _, i := range instances {
+		if tag != "" && !hasTag(i.tags, tag) {
+			continue
+		}
+		// The target of SRV records is the node, unless the service has its own address.
+		host, target := i.nodeAddress, dnsutil.Join(i.node, "node", zone)
+		if i.address != "" {
+			host, target = i.address, dnsutil.Join(addrLabel(i.address), "addr", zone)
+		}
+		services = append(services, msg.Service{Host: host, Port: i.port, Priority: 1, TTL: c.ttl, Key: msg.Path(target, "consul")})
+	}
+	if len(services) == 0 {
+		return nil, errNoItems
+	}
+	return services, nil
+}
+
+func (c *Consul) node(zone, node string) ([]msg.Service, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, instances := range c.services {
+		for _, i := range instances {
+			if strings.EqualFold(i.node, node) {
+				return []msg.Service{{Host: i.nodeAddress, TTL: c.ttl, Key: msg.Path(dnsutil.Join(node, "node", zone), "consul")}}, nil
+			}
+		}
+	}
+	return nil, errNoItems
+}
+
+// Reverse implements the ServiceBackend interface.
+func (c *Consul) Reverse(ctx context.Context, state request.Request, exact bool, opt plugin.Options) ([]msg.Service, error) {
+	return nil, errNoItems
+}
+
+// Lookup implements the ServiceBackend interface.
+func (c *Consul) Lookup(ctx context.Context, state request.Request, name string, typ uint16) (*dns.Msg, error) {
+	return c.Upstream.Lookup(ctx, state, name, typ)
+}
+
+// Records implements the ServiceBackend interface.
+func (c *Consul) Records(ctx context.Context, state request.Request, exact bool) ([]msg.Service, error) {
+	return c.Services(ctx, state, exact, plugin.Options{})
+}
+
+// IsNameError implements the ServiceBackend interface.
+func (c *Consul) IsNameError(err error) bool { return err == errNoItems }
+
+// Ready implements the ready.Readiness interface, it returns true once the catalog has been read.
+func (c *Consul) Ready() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.synced
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// addrLabel returns the hex encoding of the IP address in addr, or addr itself when it isn't an
+// IP address.
+func addrLabel(addr string) string {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return addr
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return hex.EncodeToString(ip)
+}
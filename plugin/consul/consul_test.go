@@ -0,0 +1,298 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+// fakeConsul implements the parts of the Consul HTTP API we use, including blocking queries.
+type fakeConsul struct {
+	sync.Mutex
+	index    uint64
+	changed  chan struct{} // closed on every change
+	services map[string][]healthEntry
+	headers  http.Header // of the last request
+	query    url.Values
+}
+
+func newFakeConsul() *fakeConsul {
+	return &fakeConsul{index: 1, changed: make(chan struct{}), services: map[string][]healthEntry{}}
+}
+
+func (f *fakeConsul) set(service string, entries ...healthEntry) {
+	f.Lock()
+	defer f.Unlock()
+	if entries == nil {
+		delete(f.services, service)
+	} else {
+		f.services[service] = entries
+	}
+	f.index++
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	f.headers, f.query = r.Header, r.URL.Query()
+	changed, current := f.changed, f.index
+	f.Unlock()
+
+	if index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); index >= current {
+		wait, _ := time.ParseDuration(r.URL.Query().Get("wait"))
+		select {
+		case <-changed:
+		case <-time.After(wait):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(f.index, 10))
+	switch {
+	case r.URL.Path == "/v1/catalog/services":
+		services := map[string][]string{}
+		for name, entries := range f.services {
+			services[name] = []string{}
+			for _, e := range entries {
+				services[name] = append(services[name], e.Service.Tags...)
+			}
+		}
+		json.NewEncoder(w).Encode(services)
+
+	case strings.HasPrefix(r.URL.Path, "/v1/health/service/"):
+		entries := []healthEntry{}
+		for _, e := range f.services[strings.TrimPrefix(r.URL.Path, "/v1/health/service/")] {
+			if r.URL.Query().Get("passing") == "true" && len(passing([]healthEntry{e})) == 0 {
+				continue
+			}
+			entries = append(entries, e)
+		}
+		json.NewEncoder(w).Encode(entries)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func entry(node, nodeAddress, address string, port int, status string, tags ...string) healthEntry {
+	return healthEntry{
+		Node:    healthNode{Node: node, Address: nodeAddress},
+		Service: healthService{ID: node, Address: address, Port: port, Tags: tags},
+		Checks:  []healthCheck{{CheckID: "serfHealth", Status: "passing"}, {CheckID: "service", Status: status}},
+	}
+}
+
+func newTestConsul(t *testing.T) (*Consul, *fakeConsul, func()) {
+	fake := newFakeConsul()
+	fake.set("web", entry("node1", "10.0.0.1", "", 8080, "passing", "primary"), entry("node2", "10.0.0.2", "", 8080, "warning"))
+	fake.set("db", entry("node3", "10.0.0.3", "10.0.0.10", 5432, "passing"))
+	fake.set("v6", entry("node4", "fd00::4", "", 80, "passing"))
+	srv := httptest.NewServer(fake)
+
+	c := New([]string{"consul."}, srv.URL)
+	c.wait = 1 * time.Second
+	ctx, cancel := context.WithCancel(context.Background())
+	c.Run(ctx)
+	return c, fake, func() {
+		cancel()
+		srv.Close()
+	}
+}
+
+func TestConsul(t *testing.T) {
+	c, _, cleanup := newTestConsul(t)
+	defer cleanup()
+
+	if !c.Ready() {
+		t.Fatal("Expected the plugin to be ready after reading the catalog")
+	}
+
+	tests := []test.Case{
+		{
+			Qname: "web.service.consul.", Qtype: dns.TypeA,
+			Answer: []dns.RR{test.A("web.service.consul. 30 IN A 10.0.0.1")},
+		},
+		{
+			Qname: "primary.web.service.consul.", Qtype: dns.TypeA,
+			Answer: []dns.RR{test.A("primary.web.service.consul. 30 IN A 10.0.0.1")},
+		},
+		{
+			Qname: "secondary.web.service.consul.", Qtype: dns.TypeA,
+			Rcode: dns.RcodeNameError,
+			Ns:    []dns.RR{test.SOA("consul. 30 IN SOA ns.dns.consul. hostmaster.consul. 0 7200 1800 86400 30")},
+		},
+		{
+			Qname: "web.service.consul.", Qtype: dns.TypeSRV,
+			Answer: []dns.RR{test.SRV("web.service.consul. 30 IN SRV 1 100 8080 node1.node.consul.")},
+			Extra:  []dns.RR{test.A("node1.node.consul. 30 IN A 10.0.0.1")},
+		},
+		{
+			Qname: "_db._tcp.service.consul.", Qtype: dns.TypeSRV,
+			Answer: []dns.RR{test.SRV("_db._tcp.service.consul. 30 IN SRV 1 100 5432 0a00000a.addr.consul.")},
+			Extra:  []dns.RR{test.A("0a00000a.addr.consul. 30 IN A 10.0.0.10")},
+		},
+		{
+			Qname: "_web._primary.service.consul.", Qtype: dns.TypeSRV,
+			Answer: []dns.RR{test.SRV("_web._primary.service.consul. 30 IN SRV 1 100 8080 node1.node.consul.")},
+			Extra:  []dns.RR{test.A("node1.node.consul. 30 IN A 10.0.0.1")},
+		},
+		{
+			Qname: "0a00000a.addr.consul.", Qtype: dns.TypeA,
+			Answer: []dns.RR{test.A("0a00000a.addr.consul. 30 IN A 10.0.0.10")},
+		},
+		{
+			Qname: "NODE3.node.consul.", Qtype: dns.TypeA,
+			Answer: []dns.RR{test.A("NODE3.node.consul. 30 IN A 10.0.0.3")},
+		},
+		{
+			Qname: "node2.node.consul.", Qtype: dns.TypeA,
+			Rcode: dns.RcodeNameError,
+			Ns:    []dns.RR{test.SOA("consul. 30 IN SOA ns.dns.consul. hostmaster.consul. 0 7200 1800 86400 30")},
+		},
+		{
+			Qname: "v6.service.consul.", Qtype: dns.TypeAAAA,
+			Answer: []dns.RR{test.AAAA("v6.service.consul. 30 IN AAAA fd00::4")},
+		},
+		{
+			Qname: "v6.service.consul.", Qtype: dns.TypeA,
+			Ns: []dns.RR{test.SOA("consul. 30 IN SOA ns.dns.consul. hostmaster.consul. 0 7200 1800 86400 30")},
+		},
+		{
+			Qname: "web.service.consul.", Qtype: dns.TypeTXT,
+			Ns: []dns.RR{test.SOA("consul. 30 IN SOA ns.dns.consul. hostmaster.consul. 0 7200 1800 86400 30")},
+		},
+		{
+			Qname: "nope.service.consul.", Qtype: dns.TypeA,
+			Rcode: dns.RcodeNameError,
+			Ns:    []dns.RR{test.SOA("consul. 30 IN SOA ns.dns.consul. hostmaster.consul. 0 7200 1800 86400 30")},
+		},
+		{
+			Qname: "service.consul.", Qtype: dns.TypeA,
+			Ns: []dns.RR{test.SOA("consul. 30 IN SOA ns.dns.consul. hostmaster.consul. 0 7200 1800 86400 30")},
+		},
+	}
+
+	ctx := context.TODO()
+	for i, tc := range tests {
+		m := tc.Msg()
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := c.ServeDNS(ctx, rec, m); err != nil {
+			t.Errorf("Test %d: expected no error, got %v", i, err)
+			continue
+		}
+		// The serial is the current time.
+		for _, rr := range rec.Msg.Ns {
+			if soa, ok := rr.(*dns.SOA); ok {
+				soa.Serial = 0
+			}
+		}
+		if err := test.SortAndCheck(rec.Msg, tc); err != nil {
+			t.Errorf("Test %d: %v", i, err)
+		}
+	}
+}
+
+func TestConsulWatch(t *testing.T) {
+	c, fake, cleanup := newTestConsul(t)
+	defer cleanup()
+
+	lookup := func(name string) []dns.RR {
+		m := new(dns.Msg)
+		m.SetQuestion(name, dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		c.ServeDNS(context.TODO(), rec, m)
+		return rec.Msg.Answer
+	}
+	eventually := func(f func() bool) bool {
+		for i := 0; i < 100; i++ {
+			if f() {
+				return true
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		return false
+	}
+
+	// node2 becomes healthy.
+	fake.set("web", entry("node1", "10.0.0.1", "", 8080, "passing"), entry("node2", "10.0.0.2", "", 8080, "passing"))
+	if !eventually(func() bool { return len(lookup("web.service.consul.")) == 2 }) {
+		t.Errorf("Expected 2 instances of web after node2 passes its checks, got %v", lookup("web.service.consul."))
+	}
+
+	// A new service.
+	fake.set("api", entry("node5", "10.0.0.5", "", 80, "passing"))
+	if !eventually(func() bool { return len(lookup("api.service.consul.")) == 1 }) {
+		t.Errorf("Expected the new api service")
+	}
+
+	// A removed service.
+	fake.set("db")
+	if !eventually(func() bool { return len(lookup("db.service.consul.")) == 0 }) {
+		t.Errorf("Expected the db service to be removed")
+	}
+	c.mu.RLock()
+	if _, ok := c.services["db"]; ok {
+		t.Errorf("Expected the instances of the db service to be removed")
+	}
+	c.mu.RUnlock()
+}
+
+func TestConsulRequest(t *testing.T) {
+	fake := newFakeConsul()
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	c := New([]string{"consul."}, srv.URL)
+	c.token = "secret"
+	c.datacenter = "dc2"
+	if _, _, err := c.catalogServices(context.TODO(), 0); err != nil {
+		t.Fatal(err)
+	}
+	if x := fake.headers.Get("X-Consul-Token"); x != "secret" {
+		t.Errorf("Expected token %q, got %q", "secret", x)
+	}
+	if x := fake.query.Get("dc"); x != "dc2" {
+		t.Errorf("Expected datacenter %q, got %q", "dc2", x)
+	}
+
+	c.address = srv.URL + "/nope"
+	if _, _, err := c.catalogServices(context.TODO(), 0); err == nil {
+		t.Errorf("Expected an error for a 404 reply")
+	}
+}
+
+func TestPassing(t *testing.T) {
+	entries := []healthEntry{
+		entry("node1", "10.0.0.1", "", 80, "passing", "a"),
+		entry("node2", "10.0.0.2", "10.0.0.2", 80, "passing"),
+		entry("node3", "10.0.0.3", "10.0.0.30", 80, "passing"),
+		entry("node4", "10.0.0.4", "", 80, "critical"),
+		entry("node5", "10.0.0.5", "", 80, "warning"),
+	}
+	instances := passing(entries)
+	if len(instances) != 3 {
+		t.Fatalf("Expected 3 passing instances, got %d", len(instances))
+	}
+	if instances[1].address != "" {
+		t.Errorf("Expected no service address when it's the node's address, got %s", instances[1].address)
+	}
+	if instances[2].address != "10.0.0.30" {
+		t.Errorf("Expected service address %s, got %s", "10.0.0.30", instances[2].address)
+	}
+}
@@ -0,0 +1,119 @@
+package consul
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	mwtls "github.com/coredns/coredns/plugin/pkg/tls"
+
+	"github.com/mholt/caddy"
+)
+
+var log = clog.NewWithPlugin("consul")
+
+func init() {
+	caddy.RegisterPlugin("consul", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	cs, err := consulParse(c)
+	if err != nil {
+		return plugin.Error("consul", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.OnStartup(func() error {
+		cs.Run(ctx)
+		return nil
+	})
+	c.OnShutdown(func() error {
+		cancel()
+		return nil
+	})
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		cs.Next = next
+		return cs
+	})
+
+	return nil
+}
+
+func consulParse(c *caddy.Controller) (*Consul, error) {
+	i := 0
+	var cs *Consul
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		zones := c.RemainingArgs()
+		if len(zones) == 0 {
+			zones = make([]string, len(c.ServerBlockKeys))
+			copy(zones, c.ServerBlockKeys)
+		}
+		for i := range zones {
+			zones[i] = plugin.Host(zones[i]).Normalize()
+		}
+		cs = New(zones, defaultAddress)
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "address":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				u, err := url.Parse(c.Val())
+				if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+					return nil, c.Errf("invalid address '%s'", c.Val())
+				}
+				cs.address = u.Scheme + "://" + u.Host
+			case "token":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				cs.token = c.Val()
+			case "datacenter":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				cs.datacenter = c.Val()
+			case "ttl":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				t, err := strconv.Atoi(c.Val())
+				if err != nil {
+					return nil, err
+				}
+				if t < 0 || t > 3600 {
+					return nil, c.Errf("ttl must be in range [0, 3600]: %d", t)
+				}
+				cs.ttl = uint32(t)
+			case "tls": // cert key cacertfile
+				tlsConfig, err := mwtls.NewTLSConfigFromArgs(c.RemainingArgs()...)
+				if err != nil {
+					return nil, err
+				}
+				cs.client = &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+			case "fallthrough":
+				cs.Fall.SetZonesFromArgs(c.RemainingArgs())
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+			if c.NextArg() {
+				return nil, c.ArgErr()
+			}
+		}
+	}
+	return cs, nil
+}
@@ -0,0 +1,98 @@
+package consul
+
+import (
+	"testing"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupConsul(t *testing.T) {
+	tests := []struct {
+		input      string
+		err        bool
+		zones      []string
+		address    string
+		token      string
+		datacenter string
+		ttl        uint32
+	}{
+		{`consul`, false, []string{"."}, defaultAddress, "", "", defaultTTL},
+		{`consul consul example.org`, false, []string{"consul.", "example.org."}, defaultAddress, "", "", defaultTTL},
+		{`consul {
+			address https://consul.example.org:8501/
+			token secret
+			datacenter dc2
+			ttl 60
+		}`, false, []string{"."}, "https://consul.example.org:8501", "secret", "dc2", 60},
+		{`consul {
+			tls
+			fallthrough
+		}`, false, []string{"."}, defaultAddress, "", "", defaultTTL},
+		// fails
+		{`consul {
+			address
+		}`, true, nil, "", "", "", 0},
+		{`consul {
+			address 127.0.0.1:8500
+		}`, true, nil, "", "", "", 0},
+		{`consul {
+			address ftp://127.0.0.1
+		}`, true, nil, "", "", "", 0},
+		{`consul {
+			token a b
+		}`, true, nil, "", "", "", 0},
+		{`consul {
+			ttl -1
+		}`, true, nil, "", "", "", 0},
+		{`consul {
+			ttl 3601
+		}`, true, nil, "", "", "", 0},
+		{`consul {
+			ttl x
+		}`, true, nil, "", "", "", 0},
+		{`consul {
+			tls /does/not/exist
+		}`, true, nil, "", "", "", 0},
+		{`consul {
+			blah
+		}`, true, nil, "", "", "", 0},
+		{`consul
+		consul`, true, nil, "", "", "", 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		c.ServerBlockKeys = []string{"."}
+		cs, err := consulParse(c)
+		if test.err {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			continue
+		}
+		if len(cs.Zones) != len(test.zones) {
+			t.Fatalf("Test %d: expected zones %v, got %v", i, test.zones, cs.Zones)
+		}
+		for j := range test.zones {
+			if cs.Zones[j] != test.zones[j] {
+				t.Errorf("Test %d: expected zone %s, got %s", i, test.zones[j], cs.Zones[j])
+			}
+		}
+		if cs.address != test.address {
+			t.Errorf("Test %d: expected address %s, got %s", i, test.address, cs.address)
+		}
+		if cs.token != test.token {
+			t.Errorf("Test %d: expected token %s, got %s", i, test.token, cs.token)
+		}
+		if cs.datacenter != test.datacenter {
+			t.Errorf("Test %d: expected datacenter %s, got %s", i, test.datacenter, cs.datacenter)
+		}
+		if cs.ttl != test.ttl {
+			t.Errorf("Test %d: expected ttl %d, got %d", i, test.ttl, cs.ttl)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package consul
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// retryInterval is how long we wait before querying Consul again after an error.
+var retryInterval = 5 * time.Second
+
+// Run reads the catalog and the passing instances of every service, and keeps them up to date
+// with blocking queries until ctx is done. A failure to read the catalog isn't fatal: it is logged
+// and retried.
+func (c *Consul) Run(ctx context.Context) {
+	names, index, err := c.catalogServices(ctx, 0)
+	if err != nil {
+		log.Warningf("Failed to read the catalog from %s: %v", c.address, err)
+		go c.watchCatalog(ctx, 0)
+		return
+	}
+
+	indexes := make(map[string]uint64, len(names))
+	for name := range names {
+		entries, i, err := c.healthService(ctx, name, 0)
+		if err != nil {
+			log.Warningf("Failed to read the instances of %q: %v", name, err)
+			continue
+		}
+		c.mu.Lock()
+		c.services[strings.ToLower(name)] = passing(entries)
+		c.mu.Unlock()
+		indexes[name] = i
+	}
+	c.mu.Lock()
+	c.synced = true
+	c.mu.Unlock()
+
+	c.update(ctx, names, indexes)
+	go c.watchCatalog(ctx, index)
+}
+
+// watchCatalog watches the list of services and starts and stops the watches of the services.
+func (c *Consul) watchCatalog(ctx context.Context, index uint64) {
+	for ctx.Err() == nil {
+		names, next, err := c.catalogServices(ctx, index)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Warningf("Failed to read the catalog from %s: %v", c.address, err)
+				sleep(ctx, retryInterval)
+			}
+			continue
+		}
+		c.update(ctx, names, nil)
+		c.mu.Lock()
+		c.synced = true
+		c.mu.Unlock()
+		index = next
+	}
+}
+
+// update starts a watch for the services in names that aren't watched yet, and stops the watches
+// (and forgets the instances) of the services that are gone. A watch starts at the index in
+// indexes, or 0 when there is none.
+func (c *Consul) update(ctx context.Context, names map[string][]string, indexes map[string]uint64) {
+	for name := range names {
+		if _, ok := c.watches[name]; ok {
+			continue
+		}
+		wctx, cancel := context.WithCancel(ctx)
+		c.watches[name] = cancel
+		go c.watchService(wctx, name, indexes[name])
+	}
+	for name, cancel := range c.watches {
+		if _, ok := names[name]; ok {
+			continue
+		}
+		delete(c.watches, name)
+		c.mu.Lock()
+		cancel()
+		delete(c.services, strings.ToLower(name))
+		c.mu.Unlock()
+	}
+}
+
+// watchService keeps the passing instances of service up to date.
+func (c *Consul) watchService(ctx context.Context, service string, index uint64) {
+	for ctx.Err() == nil {
+		entries, next, err := c.healthService(ctx, service, index)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Warningf("Failed to read the instances of %q: %v", service, err)
+				sleep(ctx, retryInterval)
+			}
+			continue
+		}
+		instances := passing(entries)
+		c.mu.Lock()
+		// The service may have been removed while we were waiting.
+		if ctx.Err() == nil {
+			c.services[strings.ToLower(service)] = instances
+		}
+		c.mu.Unlock()
+		index = next
+	}
+}
+
+// passing returns the instances of the entries that pass all their health checks.
+func passing(entries []healthEntry) []instance {
+	var instances []instance
+Entries:
+	for _, e := range entries {
+		for _, check := range e.Checks {
+			if check.Status != "passing" {
+				continue Entries
+			}
+		}
+		i := instance{
+			node:        e.Node.Node,
+			nodeAddress: e.Node.Address,
+			port:        e.Service.Port,
+			tags:        e.Service.Tags,
+		}
+		if e.Service.Address != "" && e.Service.Address != e.Node.Address {
+			i.address = e.Service.Address
+		}
+		instances = append(instances, i)
+	}
+	return instances
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
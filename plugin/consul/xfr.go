@@ -0,0 +1,25 @@
+package consul
+
+import (
+	"context"
+	"time"
+
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// Serial implements the Transferer interface.
+func (c *Consul) Serial(state request.Request) uint32 {
+	return uint32(time.Now().Unix())
+}
+
+// MinTTL implements the Transferer interface.
+func (c *Consul) MinTTL(state request.Request) uint32 {
+	return c.ttl
+}
+
+// Transfer implements the Transferer interface.
+func (c *Consul) Transfer(ctx context.Context, state request.Request) (int, error) {
+	return dns.RcodeServerFailure, nil
+}
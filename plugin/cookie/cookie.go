@@ -0,0 +1,96 @@
+// Package cookie implements DNS Cookies (RFC 7873) with the server cookies from RFC 9018.
+package cookie
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/plugin/pkg/edns"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// Cookie is a plugin that adds server cookies to responses and validates the server cookies in queries.
+type Cookie struct {
+	Next plugin.Handler
+
+	secrets *secrets
+	require bool // reply with BADCOOKIE to UDP queries without a valid server cookie
+
+	now func() time.Time
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (c *Cookie) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	client, server, err := edns.Cookie(r)
+	if err != nil {
+		return dns.RcodeFormatError, nil
+	}
+	if client == nil {
+		return plugin.NextOrFailure(c.Name(), c.Next, ctx, w, r)
+	}
+
+	state := request.Request{W: w, Req: r}
+	ip := net.ParseIP(state.IP())
+	now := c.now()
+	cur, prev := c.secrets.get(now)
+
+	cw := &ResponseWriter{ResponseWriter: w, client: client, server: serverCookie(cur, client, ip, now)}
+
+	if c.require && state.Proto() == "udp" && !valid(server, client, ip, now, cur, prev) {
+		BadCookieCount.WithLabelValues(metrics.WithServer(ctx)).Inc()
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeBadCookie)
+		state.SizeAndDo(m)
+		cw.WriteMsg(m)
+		return dns.RcodeBadCookie, nil
+	}
+
+	return plugin.NextOrFailure(c.Name(), c.Next, ctx, cw, r)
+}
+
+// Metadata implements the metadata.Provider interface.
+func (c *Cookie) Metadata(ctx context.Context, state request.Request) context.Context {
+	metadata.SetValueFunc(ctx, "cookie/valid", func() string {
+		client, server, err := edns.Cookie(state.Req)
+		if err != nil || server == nil {
+			return "false"
+		}
+		now := c.now()
+		cur, prev := c.secrets.get(now)
+		if valid(server, client, net.ParseIP(state.IP()), now, cur, prev) {
+			return "true"
+		}
+		return "false"
+	})
+	return ctx
+}
+
+// Name implements the Handler interface.
+func (c *Cookie) Name() string { return "cookie" }
+
+// ResponseWriter adds the client cookie and a fresh server cookie to the response.
+type ResponseWriter struct {
+	dns.ResponseWriter
+	client []byte
+	server []byte
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
+	o := res.IsEdns0()
+	if o == nil {
+		o = new(dns.OPT)
+		o.Hdr.Name = "."
+		o.Hdr.Rrtype = dns.TypeOPT
+		o.SetUDPSize(dns.MinMsgSize)
+		res.Extra = append(res.Extra, o)
+	}
+	edns.SetCookie(o, w.client, w.server)
+	return w.ResponseWriter.WriteMsg(res)
+}
@@ -0,0 +1,149 @@
+package cookie
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/edns"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+var testClient = []byte{1, 2, 3, 4, 5, 6, 7, 8}
+
+func newTestCookie(require bool) *Cookie {
+	now := time.Now()
+	return &Cookie{
+		Next:    reply(),
+		secrets: &secrets{current: []byte("0123456789abcdef"), previous: []byte("fedcba9876543210")},
+		require: require,
+		now:     func() time.Time { return now },
+	}
+}
+
+// reply returns a handler that replies to all queries with NOERROR, echoing the OPT record like most
+// plugins do.
+func reply() test.HandlerFunc {
+	return func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		state := request.Request{W: w, Req: r}
+		m := new(dns.Msg)
+		m.SetReply(r)
+		state.SizeAndDo(m)
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	}
+}
+
+func cookieMsg(cookie string) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.SetEdns0(4096, false)
+	if cookie != "" {
+		o := m.IsEdns0()
+		o.Option = append(o.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: cookie})
+	}
+	return m
+}
+
+func TestCookie(t *testing.T) {
+	c := newTestCookie(true)
+	now := c.now()
+	ip := net.ParseIP("10.240.0.1") // test.ResponseWriter's remote address
+
+	good := hex.EncodeToString(testClient) + hex.EncodeToString(serverCookie(c.secrets.current, testClient, ip, now.Add(-time.Minute)))
+	prev := hex.EncodeToString(testClient) + hex.EncodeToString(serverCookie(c.secrets.previous, testClient, ip, now.Add(-time.Minute)))
+	old := hex.EncodeToString(testClient) + hex.EncodeToString(serverCookie(c.secrets.current, testClient, ip, now.Add(-2*time.Hour)))
+	other := hex.EncodeToString(testClient) + hex.EncodeToString(serverCookie([]byte("not-our-secret!!"), testClient, ip, now))
+
+	tests := []struct {
+		cookie string
+		tcp    bool
+		rcode  int
+		reply  bool // expect a reply with a cookie
+	}{
+		{"", false, dns.RcodeSuccess, false},
+		{"0102", false, dns.RcodeFormatError, false},
+		{hex.EncodeToString(testClient), false, dns.RcodeBadCookie, true},
+		{hex.EncodeToString(testClient), true, dns.RcodeSuccess, true},
+		{good, false, dns.RcodeSuccess, true},
+		{prev, false, dns.RcodeSuccess, true},
+		{old, false, dns.RcodeBadCookie, true},
+		{other, false, dns.RcodeBadCookie, true},
+	}
+
+	for i, tc := range tests {
+		rec := dnstest.NewRecorder(&test.ResponseWriter{TCP: tc.tcp})
+		rcode, _ := c.ServeDNS(context.TODO(), rec, cookieMsg(tc.cookie))
+		if rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.rcode], dns.RcodeToString[rcode])
+		}
+		if rec.Msg == nil {
+			if tc.reply {
+				t.Errorf("Test %d: expected a reply", i)
+			}
+			continue
+		}
+		client, server, _ := edns.Cookie(rec.Msg)
+		if !tc.reply {
+			if client != nil {
+				t.Errorf("Test %d: expected no cookie, got %x", i, client)
+			}
+			continue
+		}
+		if string(client) != string(testClient) {
+			t.Errorf("Test %d: expected client cookie %x, got %x", i, testClient, client)
+		}
+		if !valid(server, client, ip, now, c.secrets.current) {
+			t.Errorf("Test %d: expected a valid server cookie, got %x", i, server)
+		}
+	}
+}
+
+func TestCookieNoRequire(t *testing.T) {
+	c := newTestCookie(false)
+
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	rcode, _ := c.ServeDNS(context.TODO(), rec, cookieMsg(hex.EncodeToString(testClient)))
+	if rcode != dns.RcodeSuccess {
+		t.Errorf("Expected rcode %s, got %s", dns.RcodeToString[dns.RcodeSuccess], dns.RcodeToString[rcode])
+	}
+	if _, server, _ := edns.Cookie(rec.Msg); server == nil {
+		t.Errorf("Expected a server cookie in the reply")
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	c := newTestCookie(false)
+	ip := net.ParseIP("10.240.0.1")
+	good := hex.EncodeToString(testClient) + hex.EncodeToString(serverCookie(c.secrets.current, testClient, ip, c.now()))
+
+	tests := []struct {
+		cookie string
+		valid  string
+	}{
+		{"", "false"},
+		{hex.EncodeToString(testClient), "false"},
+		{good, "true"},
+	}
+
+	for i, tc := range tests {
+		state := request.Request{W: &test.ResponseWriter{}, Req: cookieMsg(tc.cookie)}
+		ctx := metadata.ContextWithMetadata(context.TODO())
+		ctx = c.Metadata(ctx, state)
+
+		f := metadata.ValueFunc(ctx, "cookie/valid")
+		if f == nil {
+			t.Fatalf("Test %d: expected metadata cookie/valid to be set", i)
+		}
+		if v := f(); v != tc.valid {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.valid, v)
+		}
+	}
+}
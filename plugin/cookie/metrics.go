@@ -0,0 +1,15 @@
+package cookie
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// BadCookieCount is the counter of queries that were answered with BADCOOKIE.
+var BadCookieCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: "cookie",
+	Name:      "badcookie_count_total",
+	Help:      "Counter of queries answered with BADCOOKIE because they lacked a valid server cookie.",
+}, []string{"server"})
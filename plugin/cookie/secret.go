@@ -0,0 +1,112 @@
+package cookie
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+)
+
+// secrets holds the current and the previous secret used for the server cookies. Cookies are
+// always made with the current secret, but are accepted when made with either.
+type secrets struct {
+	sync.RWMutex
+	current  []byte
+	previous []byte
+
+	rotate  time.Duration // 0 disables rotation
+	rotated time.Time
+}
+
+// newSecrets returns secrets with a random current secret that is rotated every rotate.
+func newSecrets(rotate time.Duration, now time.Time) (*secrets, error) {
+	s := &secrets{rotate: rotate, rotated: now}
+	var err error
+	s.current, err = randomSecret()
+	return s, err
+}
+
+// get returns the current and previous secret, it rotates the secrets first when that is due.
+func (s *secrets) get(now time.Time) (current, previous []byte) {
+	s.RLock()
+	current, previous = s.current, s.previous
+	due := s.rotate > 0 && now.Sub(s.rotated) >= s.rotate
+	s.RUnlock()
+	if !due {
+		return current, previous
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if now.Sub(s.rotated) < s.rotate { // someone beat us to it
+		return s.current, s.previous
+	}
+	secret, err := randomSecret()
+	if err != nil {
+		log.Warningf("Failed to rotate the cookie secret: %s", err)
+		return s.current, s.previous
+	}
+	s.previous, s.current = s.current, secret
+	s.rotated = now
+	return s.current, s.previous
+}
+
+func randomSecret() ([]byte, error) {
+	b := make([]byte, secretLen)
+	_, err := rand.Read(b)
+	return b, err
+}
+
+// serverCookie returns the server cookie for the client cookie and client ip, made with secret
+// at time now. The format is the interoperable one from RFC 9018: a version, 3 reserved bytes,
+// a 32 bit timestamp and the SipHash-2-4 of the client cookie, the preceding fields and the client address.
+func serverCookie(secret, client []byte, ip net.IP, now time.Time) []byte {
+	b := make([]byte, 0, len(client)+serverCookieLen-hashLen+net.IPv6len)
+	b = append(b, client...)
+	b = append(b, version, 0, 0, 0)
+	b = append(b, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[len(b)-4:], uint32(now.Unix()))
+	if ip4 := ip.To4(); ip4 != nil {
+		b = append(b, ip4...)
+	} else {
+		b = append(b, ip.To16()...)
+	}
+
+	sc := make([]byte, serverCookieLen)
+	copy(sc, b[len(client):len(client)+serverCookieLen-hashLen])
+	binary.LittleEndian.PutUint64(sc[serverCookieLen-hashLen:], siphash(secret, b))
+	return sc
+}
+
+// valid returns true if server is a server cookie that was made by us, with one of the secrets,
+// for the client cookie and client ip, and is not older than an hour.
+func valid(server, client []byte, ip net.IP, now time.Time, secrets ...[]byte) bool {
+	if len(server) != serverCookieLen || server[0] != version {
+		return false
+	}
+	ts := time.Unix(int64(binary.BigEndian.Uint32(server[4:8])), 0)
+	if now.Sub(ts) > maxAge || ts.Sub(now) > maxSkew {
+		return false
+	}
+	for _, secret := range secrets {
+		if secret == nil {
+			continue
+		}
+		if subtle.ConstantTimeCompare(serverCookie(secret, client, ip, ts), server) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	version         = 1
+	secretLen       = 16
+	hashLen         = 8
+	serverCookieLen = 16
+
+	maxAge  = time.Hour       // server cookies older than this are not valid
+	maxSkew = 5 * time.Minute // server cookies from the future are valid within this skew
+)
@@ -0,0 +1,71 @@
+package cookie
+
+import (
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSiphash(t *testing.T) {
+	// Test vector from the SipHash paper.
+	k := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+	b := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}
+	if h := siphash(k, b); h != 0xa129ca6149be45e5 {
+		t.Errorf("Expected %x, got %x", uint64(0xa129ca6149be45e5), h)
+	}
+}
+
+func TestServerCookie(t *testing.T) {
+	// The first test vector is from RFC 9018, Appendix A.
+	tests := []struct {
+		client string
+		secret string
+		ip     string
+		ts     int64
+		server string
+	}{
+		{"2464c4abcf10c957", "e5e973e5a6b2a43f48e7dc849e37bfcf", "198.51.100.100", 1559731985, "010000005cf79f111f8130c3eee29480"},
+		{"22681ab97d52c298", "e5e973e5a6b2a43f48e7dc849e37bfcf", "2001:db8:220:1:59de:d0f4:8769:82b8", 1559734385, "010000005cf7a8717b0c32bc40cbdff7"},
+	}
+
+	for i, tc := range tests {
+		client, _ := hex.DecodeString(tc.client)
+		secret, _ := hex.DecodeString(tc.secret)
+		now := time.Unix(tc.ts, 0)
+
+		sc := serverCookie(secret, client, net.ParseIP(tc.ip), now)
+		if x := hex.EncodeToString(sc); x != tc.server {
+			t.Errorf("Test %d: expected server cookie %s, got %s", i, tc.server, x)
+		}
+		if !valid(sc, client, net.ParseIP(tc.ip), now.Add(30*time.Minute), secret) {
+			t.Errorf("Test %d: expected server cookie to be valid", i)
+		}
+		if valid(sc, client, net.ParseIP(tc.ip), now.Add(2*time.Hour), secret) {
+			t.Errorf("Test %d: expected expired server cookie to be invalid", i)
+		}
+		if valid(sc, client, net.ParseIP("192.0.2.1"), now, secret) {
+			t.Errorf("Test %d: expected server cookie for other address to be invalid", i)
+		}
+	}
+}
+
+func TestSecretsRotate(t *testing.T) {
+	now := time.Now()
+	s, err := newSecrets(time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := s.get(now)
+
+	cur, prev := s.get(now.Add(time.Hour))
+	if string(prev) != string(first) {
+		t.Errorf("Expected previous secret to be the first secret")
+	}
+	if string(cur) == string(first) {
+		t.Errorf("Expected a new current secret")
+	}
+	if cur2, _ := s.get(now.Add(time.Hour + time.Minute)); string(cur2) != string(cur) {
+		t.Errorf("Expected no rotation before the rotate interval")
+	}
+}
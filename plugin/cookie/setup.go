@@ -0,0 +1,125 @@
+package cookie
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/mholt/caddy"
+)
+
+var log = clog.NewWithPlugin("cookie")
+
+func init() {
+	caddy.RegisterPlugin("cookie", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	ck, err := parse(c)
+	if err != nil {
+		return plugin.Error("cookie", err)
+	}
+
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, BadCookieCount)
+		return nil
+	})
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		ck.Next = next
+		return ck
+	})
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (*Cookie, error) {
+	ck := &Cookie{now: time.Now}
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		if len(c.RemainingArgs()) > 0 {
+			return nil, c.ArgErr()
+		}
+
+		var (
+			secret, previous []byte
+			rotate           = defaultRotate
+			rotateSet        bool
+		)
+		for c.NextBlock() {
+			switch c.Val() {
+			case "secret":
+				args := c.RemainingArgs()
+				if len(args) == 0 || len(args) > 2 {
+					return nil, c.ArgErr()
+				}
+				var err error
+				if secret, err = parseSecret(args[0]); err != nil {
+					return nil, err
+				}
+				if len(args) == 2 {
+					if previous, err = parseSecret(args[1]); err != nil {
+						return nil, err
+					}
+				}
+			case "rotate":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(args[0])
+				if err != nil {
+					return nil, err
+				}
+				if d < maxAge {
+					return nil, fmt.Errorf("rotate must be at least %s: %s", maxAge, d)
+				}
+				rotate, rotateSet = d, true
+			case "require":
+				if len(c.RemainingArgs()) > 0 {
+					return nil, c.ArgErr()
+				}
+				ck.require = true
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+
+		if secret != nil {
+			if rotateSet {
+				return nil, fmt.Errorf("rotate can not be used with secret")
+			}
+			ck.secrets = &secrets{current: secret, previous: previous}
+			continue
+		}
+		var err error
+		if ck.secrets, err = newSecrets(rotate, ck.now()); err != nil {
+			return nil, err
+		}
+	}
+	return ck, nil
+}
+
+func parseSecret(s string) ([]byte, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != secretLen {
+		return nil, fmt.Errorf("secret must be %d hexadecimal characters: %q", 2*secretLen, s)
+	}
+	return b, nil
+}
+
+const defaultRotate = 24 * time.Hour
@@ -0,0 +1,79 @@
+package cookie
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		require   bool
+		rotate    time.Duration
+		previous  bool
+	}{
+		{`cookie`, false, false, defaultRotate, false},
+		{`cookie {
+			require
+			rotate 2h
+		}`, false, true, 2 * time.Hour, false},
+		{`cookie {
+			secret 000102030405060708090a0b0c0d0e0f
+		}`, false, false, 0, false},
+		{`cookie {
+			secret 000102030405060708090a0b0c0d0e0f 0f0e0d0c0b0a09080706050403020100
+		}`, false, false, 0, true},
+		// fails
+		{`cookie example.org`, true, false, 0, false},
+		{`cookie {
+			secret 0001
+		}`, true, false, 0, false},
+		{`cookie {
+			secret zz0102030405060708090a0b0c0d0e0f
+		}`, true, false, 0, false},
+		{`cookie {
+			rotate 1m
+		}`, true, false, 0, false},
+		{`cookie {
+			secret 000102030405060708090a0b0c0d0e0f
+			rotate 2h
+		}`, true, false, 0, false},
+		{`cookie {
+			require yes
+		}`, true, false, 0, false},
+		{`cookie {
+			blah
+		}`, true, false, 0, false},
+		{"cookie\ncookie", true, false, 0, false},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		ck, err := parse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if ck.require != tc.require {
+			t.Errorf("Test %d: expected require %t, got %t", i, tc.require, ck.require)
+		}
+		if ck.secrets.rotate != tc.rotate {
+			t.Errorf("Test %d: expected rotate %s, got %s", i, tc.rotate, ck.secrets.rotate)
+		}
+		if len(ck.secrets.current) != secretLen {
+			t.Errorf("Test %d: expected a secret of %d bytes, got %d", i, secretLen, len(ck.secrets.current))
+		}
+		if (ck.secrets.previous != nil) != tc.previous {
+			t.Errorf("Test %d: expected previous secret %t, got %t", i, tc.previous, ck.secrets.previous != nil)
+		}
+	}
+}
@@ -54,22 +54,14 @@ func (f File) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (i
 			w.WriteMsg(m)
 
 			log.Infof("Notify from %s for %s: checking transfer", state.IP(), zone)
-			ok, err := z.shouldTransfer()
-			if ok {
-				z.TransferIn()
-			} else {
-				log.Infof("Notify from %s for %s: no serial increase seen", state.IP(), zone)
-			}
-			if err != nil {
-				log.Warningf("Notify from %s for %s: failed primary check: %s", state.IP(), zone, err)
-			}
+			z.notifyUpdate()
 			return dns.RcodeSuccess, nil
 		}
 		log.Infof("Dropping notify from %s for %s", state.IP(), zone)
 		return dns.RcodeSuccess, nil
 	}
 
-	if z.Expired != nil && *z.Expired {
+	if z.isExpired() {
 		log.Errorf("Zone %s is expired", zone)
 		return dns.RcodeServerFailure, nil
 	}
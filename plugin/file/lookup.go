@@ -32,11 +32,11 @@ func (z *Zone) Lookup(ctx context.Context, state request.Request, qname string)
 	qtype := state.QType()
 	do := state.Do()
 
-	if 0 < z.ReloadInterval {
+	if z.replaceable() {
 		z.reloadMu.RLock()
 	}
 	defer func() {
-		if 0 < z.ReloadInterval {
+		if z.replaceable() {
 			z.reloadMu.RUnlock()
 		}
 	}()
@@ -1,21 +1,20 @@
 package file
 
 import (
+	"fmt"
 	"math/rand"
+	"strings"
 	"time"
 
 	"github.com/miekg/dns"
 )
 
-// TransferIn retrieves the zone from the masters, parses it and sets it live.
+// TransferIn retrieves the zone from the masters, parses it and sets it live. When we already
+// have the zone an incremental transfer (IXFR) is tried first, falling back to a full one (AXFR).
 func (z *Zone) TransferIn() error {
 	if len(z.TransferFrom) == 0 {
 		return nil
 	}
-	m := new(dns.Msg)
-	m.SetAxfr(z.origin)
-
-	z1 := z.CopyWithoutApex()
 	var (
 		Err error
 		tr  string
@@ -23,7 +22,27 @@ func (z *Zone) TransferIn() error {
 
 Transfer:
 	for _, tr = range z.TransferFrom {
+		if z.Apex.SOA != nil {
+			z1, err := z.transferIncremental(tr)
+			if err == nil {
+				if z1 == nil {
+					z.setExpired(false)
+					log.Infof("Zone `%s' is up to date with %s", z.origin, tr)
+					return nil
+				}
+				z.swap(z1)
+				log.Infof("Transferred: %s from %s (IXFR)", z.origin, tr)
+				return nil
+			}
+			log.Warningf("Failed to transfer `%s' incrementally from %q, trying AXFR: %v", z.origin, tr, err)
+		}
+
+		m := new(dns.Msg)
+		m.SetAxfr(z.origin)
+		z1 := z.CopyWithoutApex()
+
 		t := new(dns.Transfer)
+		t.TsigSecret = z.TransferKey.sign(m)
 		c, err := t.In(m, tr)
 		if err != nil {
 			log.Errorf("Failed to setup transfer `%s' with `%q': %v", z.origin, tr, err)
@@ -44,6 +63,12 @@ Transfer:
 				}
 			}
 		}
+		if z1.Apex.SOA == nil {
+			Err = fmt.Errorf("no SOA in transfer of `%s' from %q", z.origin, tr)
+			log.Error(Err)
+			continue Transfer
+		}
+		z.swap(z1)
 		Err = nil
 		break
 	}
@@ -51,11 +76,129 @@ Transfer:
 		return Err
 	}
 
+	log.Infof("Transferred: %s from %s", z.origin, tr)
+	return nil
+}
+
+// swap makes the records of z1 live in z and marks z as not expired.
+func (z *Zone) swap(z1 *Zone) {
+	z.reloadMu.Lock()
 	z.Tree = z1.Tree
 	z.Apex = z1.Apex
 	*z.Expired = false
-	log.Infof("Transferred: %s from %s", z.origin, tr)
-	return nil
+	z.reloadMu.Unlock()
+}
+
+// setExpired marks z as expired or not.
+func (z *Zone) setExpired(expired bool) {
+	z.reloadMu.Lock()
+	*z.Expired = expired
+	z.reloadMu.Unlock()
+}
+
+// isExpired returns true if z is marked as expired.
+func (z *Zone) isExpired() bool {
+	z.reloadMu.RLock()
+	defer z.reloadMu.RUnlock()
+	return z.Expired != nil && *z.Expired
+}
+
+// transferIncremental requests an IXFR from tr and returns a copy of z with the changes applied,
+// or nil if z is up to date.
+func (z *Zone) transferIncremental(tr string) (*Zone, error) {
+	m := new(dns.Msg)
+	m.SetIxfr(z.origin, z.Apex.SOA.Serial, z.Apex.SOA.Ns, z.Apex.SOA.Mbox)
+
+	t := new(dns.Transfer)
+	t.TsigSecret = z.TransferKey.sign(m)
+	c, err := t.In(m, tr)
+	if err != nil {
+		return nil, err
+	}
+	var rrs []dns.RR
+	for env := range c {
+		if env.Error != nil {
+			return nil, env.Error
+		}
+		rrs = append(rrs, env.RR...)
+	}
+	return z.applyIXFR(rrs)
+}
+
+// applyIXFR applies the records of an IXFR response (RFC 1995) to a copy of z and returns it. A
+// response holding only the SOA means z is up to date and returns nil. The primary may also reply
+// with the full zone, as in an AXFR.
+func (z *Zone) applyIXFR(rrs []dns.RR) (*Zone, error) {
+	if len(rrs) == 0 {
+		return nil, fmt.Errorf("empty IXFR response")
+	}
+	soa, ok := rrs[0].(*dns.SOA)
+	if !ok {
+		return nil, fmt.Errorf("IXFR response doesn't start with a SOA")
+	}
+	if len(rrs) == 1 {
+		return nil, nil
+	}
+	if last, ok := rrs[len(rrs)-1].(*dns.SOA); !ok || last.Serial != soa.Serial {
+		return nil, fmt.Errorf("IXFR response doesn't end with the SOA")
+	}
+
+	z1 := z.CopyWithoutApex()
+	if _, ok := rrs[1].(*dns.SOA); !ok {
+		// Full zone.
+		for _, rr := range rrs {
+			if err := z1.Insert(rr); err != nil {
+				return nil, err
+			}
+		}
+		return z1, nil
+	}
+
+	for _, rr := range z.All() {
+		if err := z1.Insert(rr); err != nil {
+			return nil, err
+		}
+	}
+	// Each change is the old SOA followed by the deleted records, then the new SOA followed by
+	// the added ones.
+	deleting := false
+	for _, rr := range rrs[1 : len(rrs)-1] {
+		if s, ok := rr.(*dns.SOA); ok {
+			deleting = !deleting
+			if !deleting {
+				z1.Apex.SOA = s
+			}
+			continue
+		}
+		if !deleting {
+			if err := z1.Insert(rr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		z1.remove(rr)
+	}
+	if deleting {
+		return nil, fmt.Errorf("IXFR response ends with deletions")
+	}
+	z1.Apex.SOA = soa
+	return z1, nil
+}
+
+// remove deletes rr from z, including from the apex NS records.
+func (z *Zone) remove(rr dns.RR) {
+	rr.Header().Name = strings.ToLower(rr.Header().Name)
+	if ns, ok := rr.(*dns.NS); ok && rr.Header().Name == z.origin {
+		ns.Ns = strings.ToLower(ns.Ns)
+		for i := range z.Apex.NS {
+			if dns.IsDuplicate(z.Apex.NS[i], rr) {
+				z.Apex.NS = append(z.Apex.NS[:i], z.Apex.NS[i+1:]...)
+				break
+			}
+		}
+		return
+	}
+	z.Delete(rr)
 }
 
 // shouldTransfer checks the primaries of zone, retrieves the SOA record, checks the current serial
@@ -65,6 +208,7 @@ func (z *Zone) shouldTransfer() (bool, error) {
 	c.Net = "tcp" // do this query over TCP to minimize spoofing
 	m := new(dns.Msg)
 	m.SetQuestion(z.origin, dns.TypeSOA)
+	c.TsigSecret = z.TransferKey.sign(m)
 
 	var Err error
 	serial := -1
@@ -101,88 +245,88 @@ func less(a, b uint32) bool {
 	return (a - b) > MaxSerialIncrement
 }
 
-// Update updates the secondary zone according to its SOA. It will run for the life time of the server
-// and uses the SOA parameters. Every refresh it will check for a new SOA number. If that fails (for all
-// server) it will retry every retry interval. If the zone failed to transfer before the expire, the zone
-// will be marked expired.
+// check checks the primaries for a newer serial and transfers the zone if there is one.
+func (z *Zone) check() error {
+	ok, err := z.shouldTransfer()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		z.setExpired(false)
+		return nil
+	}
+	return z.TransferIn()
+}
+
+// Update updates the secondary zone according to its SOA timers (RFC 1035, section 4.3.5). It will
+// run until the zone is shut down. Every refresh it will check for a new SOA serial. If that fails
+// (for all primaries) it will retry every retry interval. If no check succeeded for expire, the
+// zone is marked expired and queries for it get SERVFAIL until a check succeeds again. A NOTIFY
+// (RFC 1996) from a primary triggers a check right away.
 func (z *Zone) Update() error {
-	// If we don't have a SOA, we don't have a zone, wait for it to appear.
+	// If we don't have a SOA, we don't have a zone, keep trying to get it.
 	for z.Apex.SOA == nil {
-		time.Sleep(1 * time.Second)
+		select {
+		case <-z.updateShutdown:
+			return nil
+		case <-z.notify:
+		case <-time.After(initialRetry):
+		}
+		z.TransferIn()
 	}
-	retryActive := false
-
-Restart:
-	refresh := time.Second * time.Duration(z.Apex.SOA.Refresh)
-	retry := time.Second * time.Duration(z.Apex.SOA.Retry)
-	expire := time.Second * time.Duration(z.Apex.SOA.Expire)
-
-	refreshTicker := time.NewTicker(refresh)
-	retryTicker := time.NewTicker(retry)
-	expireTicker := time.NewTicker(expire)
 
+	lastCheck := time.Now()
+	retryActive := false
 	for {
-		select {
-		case <-expireTicker.C:
-			if !retryActive {
-				break
-			}
-			*z.Expired = true
-
-		case <-retryTicker.C:
-			if !retryActive {
-				break
-			}
-
-			time.Sleep(jitter(2000)) // 2s randomize
+		refresh := time.Second * time.Duration(z.Apex.SOA.Refresh)
+		retry := time.Second * time.Duration(z.Apex.SOA.Retry)
+		expire := time.Second * time.Duration(z.Apex.SOA.Expire)
 
-			ok, err := z.shouldTransfer()
-			if err != nil {
-				log.Warningf("Failed retry check %s", err)
-				continue
-			}
-
-			if ok {
-				if err := z.TransferIn(); err != nil {
-					// transfer failed, leave retryActive true
-					break
-				}
+		wait, jit := refresh, 5000 // 5s randomize
+		if retryActive {
+			wait, jit = retry, 2000 // 2s randomize
+		}
+		if wait < minInterval {
+			wait = minInterval
+		}
+		if retryActive {
+			if untilExpire := time.Until(lastCheck.Add(expire)); untilExpire < wait && !z.isExpired() {
+				wait, jit = untilExpire, 0
 			}
+		}
 
-			// no errors, stop timers and restart
-			retryActive = false
-			refreshTicker.Stop()
-			retryTicker.Stop()
-			expireTicker.Stop()
-			goto Restart
-
-		case <-refreshTicker.C:
-
-			time.Sleep(jitter(5000)) // 5s randomize
-
-			ok, err := z.shouldTransfer()
-			if err != nil {
-				log.Warningf("Failed refresh check %s", err)
-				retryActive = true
-				continue
+		timer := time.NewTimer(wait)
+		select {
+		case <-z.updateShutdown:
+			timer.Stop()
+			return nil
+		case <-z.notify:
+			timer.Stop()
+		case <-timer.C:
+			if retryActive && !z.isExpired() && time.Since(lastCheck) >= expire {
+				log.Errorf("Zone `%s' expired, no successful check in %s", z.origin, expire)
+				z.setExpired(true)
 			}
-
-			if ok {
-				if err := z.TransferIn(); err != nil {
-					// transfer failed
-					retryActive = true
-					break
-				}
+			if jit > 0 {
+				time.Sleep(jitter(jit))
 			}
+		}
 
-			// no errors, stop timers and restart
-			retryActive = false
-			refreshTicker.Stop()
-			retryTicker.Stop()
-			expireTicker.Stop()
-			goto Restart
-
+		if err := z.check(); err != nil {
+			log.Warningf("Failed to check `%s' with its primaries: %s", z.origin, err)
+			retryActive = true
+			continue
 		}
+		retryActive = false
+		lastCheck = time.Now()
+	}
+}
+
+// notifyUpdate makes Update check the primaries right away.
+func (z *Zone) notifyUpdate() {
+	select {
+	case z.notify <- struct{}{}:
+	default:
 	}
 }
 
@@ -196,3 +340,9 @@ func jitter(n int) time.Duration {
 // MaxSerialIncrement is the maximum difference between two serial numbers. If the difference between
 // two serials is greater than this number, the smaller one is considered greater.
 const MaxSerialIncrement uint32 = 2147483647
+
+// minInterval is the shortest refresh or retry interval, even if the SOA says otherwise.
+const minInterval = time.Second
+
+// initialRetry is the interval between attempts to transfer a zone we don't have yet.
+const initialRetry = 10 * time.Second
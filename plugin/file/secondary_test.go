@@ -2,7 +2,10 @@ package file
 
 import (
 	"fmt"
+	"net"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/coredns/coredns/plugin/test"
 	"github.com/coredns/coredns/request"
@@ -162,3 +165,200 @@ func newRequest(zone string, qtype uint16) request.Request {
 	m.SetEdns0(4097, true)
 	return request.Request{W: &test.ResponseWriter{}, Req: m}
 }
+
+func TestApplyIXFR(t *testing.T) {
+	z := NewZone(testZone, "stdin")
+	for _, rr := range []dns.RR{
+		test.SOA(fmt.Sprintf("%s IN SOA bla. bla. 1 0 0 0 0", testZone)),
+		test.NS(fmt.Sprintf("%s IN NS ns1.%s", testZone, testZone)),
+		test.NS(fmt.Sprintf("%s IN NS ns2.%s", testZone, testZone)),
+		test.A(fmt.Sprintf("www.%s IN A 127.0.0.1", testZone)),
+	} {
+		z.Insert(rr)
+	}
+
+	soa := func(serial int) dns.RR {
+		return test.SOA(fmt.Sprintf("%s IN SOA bla. bla. %d 0 0 0 0", testZone, serial))
+	}
+	rrs := []dns.RR{
+		soa(3),
+		soa(1),
+		test.A(fmt.Sprintf("www.%s IN A 127.0.0.1", testZone)),
+		test.NS(fmt.Sprintf("%s IN NS ns2.%s", testZone, testZone)),
+		soa(2),
+		test.A(fmt.Sprintf("www.%s IN A 127.0.0.2", testZone)),
+		soa(2),
+		soa(3),
+		test.A(fmt.Sprintf("mail.%s IN A 127.0.0.3", testZone)),
+		soa(3),
+	}
+	z1, err := z.applyIXFR(rrs)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if z1.Apex.SOA.Serial != 3 {
+		t.Errorf("Expected serial 3, got %d", z1.Apex.SOA.Serial)
+	}
+	if len(z1.Apex.NS) != 1 {
+		t.Errorf("Expected 1 apex NS record, got %d", len(z1.Apex.NS))
+	}
+	for name, expected := range map[string]string{"www.": "127.0.0.2", "mail.": "127.0.0.3"} {
+		e, ok := z1.Search(name + testZone)
+		if !ok {
+			t.Errorf("Expected %s%s to exist", name, testZone)
+			continue
+		}
+		a := e.Types(dns.TypeA)
+		if len(a) != 1 || a[0].(*dns.A).A.String() != expected {
+			t.Errorf("Expected %s%s to have A %s, got %v", name, testZone, expected, a)
+		}
+	}
+	// The original zone is unchanged.
+	if z.Apex.SOA.Serial != 1 || len(z.Apex.NS) != 2 {
+		t.Errorf("Expected the original zone to be unchanged")
+	}
+
+	// Up to date.
+	if z1, err := z.applyIXFR([]dns.RR{soa(1)}); z1 != nil || err != nil {
+		t.Errorf("Expected no changes and no error, got %v", err)
+	}
+	// Full zone.
+	z1, err = z.applyIXFR([]dns.RR{soa(4), test.A(fmt.Sprintf("%s IN A 127.0.0.4", testZone)), soa(4)})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if _, ok := z1.Search("www." + testZone); ok {
+		t.Errorf("Expected www.%s to be gone after a full transfer", testZone)
+	}
+	// Truncated.
+	if _, err := z.applyIXFR(rrs[:len(rrs)-1]); err == nil {
+		t.Errorf("Expected error for a truncated IXFR")
+	}
+}
+
+const (
+	testKeyName   = "transfer.key."
+	testKeySecret = "c2VjcmV0c2VjcmV0c2VjcmV0"
+)
+
+// primary is a primary server for testZone that requires TSIG and can change its serial.
+type primary struct {
+	sync.Mutex
+	serial uint32
+}
+
+func (p *primary) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+	if req.IsTsig() == nil || w.TsigStatus() != nil {
+		m.Rcode = dns.RcodeRefused
+		w.WriteMsg(m)
+		return
+	}
+	p.Lock()
+	soa := test.SOA(fmt.Sprintf("%s IN SOA bla. bla. %d 3600 1 1 0", testZone, p.serial))
+	p.Unlock()
+
+	switch req.Question[0].Qtype {
+	case dns.TypeSOA:
+		m.Answer = []dns.RR{soa}
+	case dns.TypeAXFR, dns.TypeIXFR:
+		m.Answer = []dns.RR{soa, test.A(fmt.Sprintf("%s IN A 127.0.0.1", testZone)), soa}
+	}
+	m.SetTsig(testKeyName, dns.HmacSHA256, 300, time.Now().Unix())
+	w.WriteMsg(m)
+}
+
+func (p *primary) setSerial(serial uint32) {
+	p.Lock()
+	p.serial = serial
+	p.Unlock()
+}
+
+func startPrimary(t *testing.T, p *primary) (*dns.Server, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %s", err)
+	}
+	started := make(chan struct{})
+	s := &dns.Server{
+		Listener:          l,
+		Handler:           p,
+		TsigSecret:        map[string]string{testKeyName: testKeySecret},
+		NotifyStartedFunc: func() { close(started) },
+	}
+	go s.ActivateAndServe()
+	<-started
+	return s, l.Addr().String()
+}
+
+func TestTransferInTsig(t *testing.T) {
+	p := &primary{serial: 10}
+	s, addr := startPrimary(t, p)
+	defer s.Shutdown()
+
+	z := NewZone(testZone, "stdin")
+	z.TransferFrom = []string{addr}
+	if err := z.TransferIn(); err == nil {
+		t.Fatalf("Expected an unsigned transfer to fail")
+	}
+
+	z.TransferKey = &TsigKey{Name: testKeyName, Algorithm: dns.HmacSHA256, Secret: testKeySecret}
+	if err := z.TransferIn(); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if z.Apex.SOA == nil || z.Apex.SOA.Serial != 10 {
+		t.Fatalf("Expected serial 10, got %v", z.Apex.SOA)
+	}
+
+	// A newer serial is transferred with IXFR.
+	p.setSerial(11)
+	if ok, err := z.shouldTransfer(); !ok || err != nil {
+		t.Fatalf("Expected a transfer to be needed, got %t, %v", ok, err)
+	}
+	if err := z.TransferIn(); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if z.Apex.SOA.Serial != 11 {
+		t.Fatalf("Expected serial 11, got %d", z.Apex.SOA.Serial)
+	}
+}
+
+func TestUpdateNotifyAndExpire(t *testing.T) {
+	p := &primary{serial: 10}
+	s, addr := startPrimary(t, p)
+
+	z := NewZone(testZone, "stdin")
+	z.TransferFrom = []string{addr}
+	z.TransferKey = &TsigKey{Name: testKeyName, Algorithm: dns.HmacSHA256, Secret: testKeySecret}
+	if err := z.TransferIn(); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	go z.Update()
+	defer z.OnShutdown()
+
+	// The refresh is an hour, only the notify makes the zone update.
+	p.setSerial(12)
+	z.notifyUpdate()
+	if !waitFor(func() bool { return z.SOASerialIfDefined() == 12 }) {
+		t.Fatalf("Expected serial 12 after notify, got %d", z.SOASerialIfDefined())
+	}
+
+	// Without primary the zone expires after a second.
+	s.Shutdown()
+	z.notifyUpdate()
+	if !waitFor(z.isExpired) {
+		t.Fatalf("Expected zone to expire")
+	}
+}
+
+// waitFor waits up to 5 seconds for cond to become true.
+func waitFor(cond func() bool) bool {
+	for i := 0; i < 50; i++ {
+		if cond() {
+			return true
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return false
+}
@@ -5,5 +5,8 @@ func (z *Zone) OnShutdown() error {
 	if 0 < z.ReloadInterval {
 		z.reloadShutdown <- true
 	}
+	if len(z.TransferFrom) > 0 && z.updateShutdown != nil {
+		close(z.updateShutdown)
+	}
 	return nil
 }
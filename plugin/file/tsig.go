@@ -0,0 +1,27 @@
+package file
+
+import (
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// TsigKey is a TSIG key (RFC 2845) used to sign the queries a secondary sends to its primaries.
+type TsigKey struct {
+	Name      string // Name of the key, in canonical form
+	Algorithm string // Algorithm, such as dns.HmacSHA256
+	Secret    string // Base64 encoded secret
+}
+
+// tsigFudge is the time in seconds a signed message is valid for.
+const tsigFudge = 300
+
+// sign adds a TSIG record for k to m and returns the secrets for the dns.Client or dns.Transfer
+// that sends it. A nil key leaves m unsigned.
+func (k *TsigKey) sign(m *dns.Msg) map[string]string {
+	if k == nil {
+		return nil
+	}
+	m.SetTsig(k.Name, k.Algorithm, tsigFudge, time.Now().Unix())
+	return map[string]string{k.Name: k.Secret}
+}
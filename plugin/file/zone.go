@@ -26,12 +26,15 @@ type Zone struct {
 	TransferTo   []string
 	StartupOnce  sync.Once
 	TransferFrom []string
+	TransferKey  *TsigKey // Key to sign the queries to the primaries, if any
 	Expired      *bool
 
 	ReloadInterval time.Duration
 	LastReloaded   time.Time
 	reloadMu       sync.RWMutex
 	reloadShutdown chan bool
+	notify         chan struct{} // Signals Update to check the primaries now
+	updateShutdown chan struct{}
 	Upstream       *upstream.Upstream // Upstream for looking up external names during the resolution process
 }
 
@@ -52,6 +55,8 @@ func NewZone(name, file string) *Zone {
 		Tree:           &tree.Tree{},
 		Expired:        new(bool),
 		reloadShutdown: make(chan bool),
+		notify:         make(chan struct{}, 1),
+		updateShutdown: make(chan struct{}),
 		LastReloaded:   time.Now(),
 	}
 	*z.Expired = false
@@ -64,6 +69,7 @@ func (z *Zone) Copy() *Zone {
 	z1 := NewZone(z.origin, z.file)
 	z1.TransferTo = z.TransferTo
 	z1.TransferFrom = z.TransferFrom
+	z1.TransferKey = z.TransferKey
 	z1.Expired = z.Expired
 
 	z1.Apex = z.Apex
@@ -75,6 +81,7 @@ func (z *Zone) CopyWithoutApex() *Zone {
 	z1 := NewZone(z.origin, z.file)
 	z1.TransferTo = z.TransferTo
 	z1.TransferFrom = z.TransferFrom
+	z1.TransferKey = z.TransferKey
 	z1.Expired = z.Expired
 
 	return z1
@@ -141,6 +148,10 @@ func (z *Zone) SetFile(path string) {
 	z.reloadMu.Unlock()
 }
 
+// replaceable returns true if the records of z can be replaced while it is serving, by reloading
+// its file or by a transfer from its primaries.
+func (z *Zone) replaceable() bool { return z.ReloadInterval > 0 || len(z.TransferFrom) > 0 }
+
 // TransferAllowed checks if incoming request for transferring the zone is allowed according to the ACLs.
 func (z *Zone) TransferAllowed(state request.Request) bool {
 	for _, t := range z.TransferTo {
@@ -164,7 +175,7 @@ func (z *Zone) TransferAllowed(state request.Request) bool {
 // All returns all records from the zone, the first record will be the SOA record,
 // otionally followed by all RRSIG(SOA)s.
 func (z *Zone) All() []dns.RR {
-	if z.ReloadInterval > 0 {
+	if z.replaceable() {
 		z.reloadMu.RLock()
 		defer z.reloadMu.RUnlock()
 	}
@@ -73,6 +73,10 @@ func (t *Transport) Dial(proto string) (*dns.Conn, bool, error) {
 func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options) (*dns.Msg, error) {
 	start := time.Now()
 
+	if p.doq != nil {
+		return p.connectQUIC(ctx, state, start)
+	}
+
 	proto := ""
 	switch {
 	case opts.forceTCP: // TCP flag has precedence over UDP flag
@@ -94,8 +98,14 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options
 		conn.UDPSize = 512
 	}
 
+	req := state.Req
+	if opts.tsig != nil {
+		req = opts.tsig.sign(req)
+	}
+	conn.TsigSecret = opts.tsig.secrets()
+
 	conn.SetWriteDeadline(time.Now().Add(maxTimeout))
-	if err := conn.WriteMsg(state.Req); err != nil {
+	if err := conn.WriteMsg(req); err != nil {
 		conn.Close() // not giving it back
 		if err == io.EOF && cached {
 			return nil, ErrCachedClosed
@@ -120,8 +130,36 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options
 		}
 	}
 
+	// The signature is verified by ReadMsg, but the reply must be signed at all.
+	if opts.tsig != nil {
+		if ret.IsTsig() == nil {
+			conn.Close() // not giving it back
+			return nil, errUnsigned
+		}
+		ret.Extra = ret.Extra[:len(ret.Extra)-1]
+	}
+
 	p.transport.Yield(conn)
 
+	p.report(ret, start)
+	return ret, nil
+}
+
+// connectQUIC sends the request to a DNS-over-QUIC upstream and waits for a response.
+func (p *Proxy) connectQUIC(ctx context.Context, state request.Request, start time.Time) (*dns.Msg, error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	ret, err := p.doq.Exchange(ctx, state.Req)
+	if err != nil {
+		return nil, err
+	}
+
+	p.report(ret, start)
+	return ret, nil
+}
+
+func (p *Proxy) report(ret *dns.Msg, start time.Time) {
 	rc, ok := dns.RcodeToString[ret.Rcode]
 	if !ok {
 		rc = strconv.Itoa(ret.Rcode)
@@ -130,8 +168,6 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options
 	RequestCount.WithLabelValues(p.addr).Add(1)
 	RcodeCount.WithLabelValues(rc, p.addr).Add(1)
 	RequestDuration.WithLabelValues(p.addr).Observe(time.Since(start).Seconds())
-
-	return ret, nil
 }
 
 const cumulativeAvgWeight = 4
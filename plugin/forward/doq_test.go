@@ -0,0 +1,73 @@
+package forward
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	pkgtls "github.com/coredns/coredns/plugin/pkg/tls"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+func TestProxyQUIC(t *testing.T) {
+	tlsConfig, err := pkgtls.NewTLSConfig("../tls/test_cert.pem", "../tls/test_key.pem", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &dnsserver.Config{Zone: ".", Transport: transport.QUIC, TLSConfig: tlsConfig}
+	cfg.AddPlugin(func(next plugin.Handler) plugin.Handler {
+		return test.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			ret := new(dns.Msg)
+			ret.SetReply(r)
+			ret.Answer = append(ret.Answer, test.A("example.org. IN A 127.0.0.1"))
+			w.WriteMsg(ret)
+			return dns.RcodeSuccess, nil
+		})
+	})
+	s, err := dnsserver.NewServerQUIC(transport.QUIC+"://127.0.0.1:0", []*dnsserver.Config{cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc, err := s.ListenPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.ServePacket(pc)
+	defer s.Stop()
+
+	p := NewProxy(pc.LocalAddr().String(), transport.QUIC)
+	p.SetTLSConfig(&tls.Config{InsecureSkipVerify: true})
+	defer p.close()
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	req.Id = 1234
+	state := request.Request{W: &test.ResponseWriter{}, Req: req}
+
+	ret, err := p.Connect(context.TODO(), state, options{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if ret.Id != req.Id {
+		t.Errorf("Expected ID %d, got %d", req.Id, ret.Id)
+	}
+	if len(ret.Answer) != 1 {
+		t.Fatalf("Expected 1 RR in answer section, got %d", len(ret.Answer))
+	}
+
+	if err := p.health.Check(p); err != nil {
+		t.Errorf("Expected healthy upstream, got %s", err)
+	}
+
+	// Setting the TLS config again replaces the client and closes the one in use.
+	p.SetTLSConfig(&tls.Config{InsecureSkipVerify: true})
+	if _, err := p.Connect(context.TODO(), state, options{}); err != nil {
+		t.Errorf("Expected no error after setting the TLS config again, got %s", err)
+	}
+}
@@ -13,10 +13,12 @@ import (
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/debug"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/trace"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
 	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
 )
 
 var log = clog.NewWithPlugin("forward")
@@ -98,7 +100,9 @@ func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 		}
 
 		if span != nil {
-			child = span.Tracer().StartSpan("connect", ot.ChildOf(span.Context()))
+			child = span.Tracer().StartSpan("connect", ot.ChildOf(span.Context()), ext.SpanKindRPCClient)
+			child.SetTag(trace.TagUpstream, proxy.addr)
+			trace.SetRequestTags(child, state)
 			ctx = ot.ContextWithSpan(ctx, child)
 		}
 
@@ -124,6 +128,11 @@ func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 		}
 
 		if child != nil {
+			if err != nil {
+				ext.Error.Set(child, true)
+			} else {
+				trace.SetRcodeTag(child, ret.Rcode)
+			}
 			child.Finish()
 		}
 		taperr := toDnstap(ctx, proxy.addr, f, state, ret, start)
@@ -215,6 +224,7 @@ const (
 type options struct {
 	forceTCP  bool
 	preferUDP bool
+	tsig      *tsigKey // key to sign the queries to the upstreams, if any
 }
 
 const defaultTimeout = 5 * time.Second
@@ -1,6 +1,7 @@
 package forward
 
 import (
+	"context"
 	"crypto/tls"
 	"sync/atomic"
 	"time"
@@ -29,6 +30,9 @@ func NewHealthChecker(trans string) HealthChecker {
 		c.WriteTimeout = 1 * time.Second
 
 		return &dnsHc{c: c}
+
+	case transport.QUIC:
+		return new(doqHc)
 	}
 
 	log.Warningf("No healthchecker for transport %q", trans)
@@ -71,3 +75,28 @@ func (h *dnsHc) send(addr string) error {
 
 	return err
 }
+
+// doqHc is a health checker for a DNS-over-QUIC endpoint, it uses the proxy's DoQ client.
+type doqHc struct{}
+
+func (h *doqHc) SetTLSConfig(cfg *tls.Config) {}
+
+// Check is used as the up.Func in the up.Probe.
+func (h *doqHc) Check(p *Proxy) error {
+	ping := new(dns.Msg)
+	ping.SetQuestion(".", dns.TypeNS)
+
+	ctx, cancel := context.WithTimeout(context.Background(), hcTimeout)
+	defer cancel()
+
+	if _, err := p.doq.Exchange(ctx, ping); err != nil {
+		HealthcheckFailureCount.WithLabelValues(p.addr).Add(1)
+		atomic.AddUint32(&p.fails, 1)
+		return err
+	}
+
+	atomic.StoreUint32(&p.fails, 0)
+	return nil
+}
+
+const hcTimeout = 1 * time.Second
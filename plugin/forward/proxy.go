@@ -6,6 +6,8 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/coredns/coredns/plugin/pkg/doq"
+	"github.com/coredns/coredns/plugin/pkg/transport"
 	"github.com/coredns/coredns/plugin/pkg/up"
 )
 
@@ -19,6 +21,9 @@ type Proxy struct {
 	expire    time.Duration
 	transport *Transport
 
+	// DNS-over-QUIC client, used instead of transport for quic:// upstreams
+	doq *doq.Client
+
 	// health checking
 	probe  *up.Probe
 	health HealthChecker
@@ -32,6 +37,9 @@ func NewProxy(addr, trans string) *Proxy {
 		probe:     up.New(),
 		transport: newTransport(addr),
 	}
+	if trans == transport.QUIC {
+		p.doq = doq.NewClient(addr, nil)
+	}
 	p.health = NewHealthChecker(trans)
 	runtime.SetFinalizer(p, (*Proxy).finalizer)
 	return p
@@ -39,6 +47,11 @@ func NewProxy(addr, trans string) *Proxy {
 
 // SetTLSConfig sets the TLS config in the lower p.transport and in the healthchecking client.
 func (p *Proxy) SetTLSConfig(cfg *tls.Config) {
+	if p.doq != nil {
+		p.doq.Close()
+		p.doq = doq.NewClient(p.addr, cfg)
+		return
+	}
 	p.transport.SetTLSConfig(cfg)
 	p.health.SetTLSConfig(cfg)
 }
@@ -68,8 +81,14 @@ func (p *Proxy) Down(maxfails uint32) bool {
 	return fails > maxfails
 }
 
-// close stops the health checking goroutine.
-func (p *Proxy) close()     { p.probe.Stop() }
+// close stops the health checking goroutine and closes the DoQ connection.
+func (p *Proxy) close() {
+	p.probe.Stop()
+	if p.doq != nil {
+		p.doq.Close()
+	}
+}
+
 func (p *Proxy) finalizer() { p.transport.Stop() }
 
 // start starts the proxy's healthchecking.
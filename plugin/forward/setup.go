@@ -125,7 +125,7 @@ func ParseForwardStanza(c *caddyfile.Dispenser) (*Forward, error) {
 	}
 	for i := range f.proxies {
 		// Only set this for proxies that need it.
-		if transports[i] == transport.TLS {
+		if transports[i] == transport.TLS || transports[i] == transport.QUIC {
 			f.proxies[i].SetTLSConfig(f.tlsConfig)
 		}
 		f.proxies[i].SetExpire(f.expire)
@@ -189,6 +189,16 @@ func parseBlock(c *caddyfile.Dispenser, f *Forward) error {
 			return err
 		}
 		f.tlsConfig = tlsConfig
+	case "tsig":
+		args := c.RemainingArgs()
+		if len(args) != 3 {
+			return c.ArgErr()
+		}
+		name, alg, err := parse.TsigKey(args[0], args[1], args[2])
+		if err != nil {
+			return err
+		}
+		f.opts.tsig = &tsigKey{name: name, algorithm: alg, secret: args[2]}
 	case "tls_servername":
 		if !c.NextArg() {
 			return c.ArgErr()
@@ -172,3 +172,43 @@ nameserver 10.10.255.253`), 0666); err != nil {
 		}
 	}
 }
+
+func TestSetupTsig(t *testing.T) {
+	tests := []struct {
+		input        string
+		shouldErr    bool
+		expectedName string
+		expectedAlg  string
+	}{
+		{"forward . 127.0.0.1 {\ntsig Transfer.Key hmac-sha256 c2VjcmV0\n}\n", false, "transfer.key.", "hmac-sha256."},
+		{"forward . 127.0.0.1 {\ntsig key. hmac-md5 c2VjcmV0\n}\n", false, "key.", "hmac-md5.sig-alg.reg.int."},
+		{"forward . 127.0.0.1 {\ntsig key. hmac-sha256\n}\n", true, "", ""},
+		{"forward . 127.0.0.1 {\ntsig key. hmac-foo c2VjcmV0\n}\n", true, "", ""},
+		{"forward . 127.0.0.1 {\ntsig key. hmac-sha256 !secret\n}\n", true, "", ""},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		f, err := parseForward(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none for input %s", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if f.opts.tsig == nil {
+			t.Errorf("Test %d: expected a TSIG key", i)
+			continue
+		}
+		if f.opts.tsig.name != test.expectedName {
+			t.Errorf("Test %d: expected key name %s, got %s", i, test.expectedName, f.opts.tsig.name)
+		}
+		if f.opts.tsig.algorithm != test.expectedAlg {
+			t.Errorf("Test %d: expected algorithm %s, got %s", i, test.expectedAlg, f.opts.tsig.algorithm)
+		}
+	}
+}
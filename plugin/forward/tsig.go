@@ -0,0 +1,39 @@
+package forward
+
+import (
+	"errors"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// tsigKey is the TSIG key used to sign the queries to the upstreams.
+type tsigKey struct {
+	name      string
+	algorithm string
+	secret    string
+}
+
+// tsigFudge is the time in seconds a signed query is valid for.
+const tsigFudge = 300
+
+// errUnsigned is returned when the reply to a signed query isn't signed.
+var errUnsigned = errors.New("reply to signed query is not signed")
+
+// sign returns a copy of req signed with k. The TSIG record of the client, if any, is removed.
+func (k *tsigKey) sign(req *dns.Msg) *dns.Msg {
+	m := req.Copy()
+	if m.IsTsig() != nil {
+		m.Extra = m.Extra[:len(m.Extra)-1]
+	}
+	m.SetTsig(k.name, k.algorithm, tsigFudge, time.Now().Unix())
+	return m
+}
+
+// secrets returns the secrets for the dns.Conn that sends the queries signed with k.
+func (k *tsigKey) secrets() map[string]string {
+	if k == nil {
+		return nil
+	}
+	return map[string]string{k.name: k.secret}
+}
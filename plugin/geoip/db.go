@@ -0,0 +1,226 @@
+package geoip
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/metadata"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// kind is the kind of data in a database, it determines the labels we publish.
+type kind int
+
+const (
+	kindCountry kind = iota
+	kindCity
+	kindASN
+)
+
+// record holds the fields of the MaxMind databases that we publish.
+type record struct {
+	Continent struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"continent"`
+	Country struct {
+		IsoCode           string            `maxminddb:"iso_code"`
+		Names             map[string]string `maxminddb:"names"`
+		IsInEuropeanUnion bool              `maxminddb:"is_in_european_union"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Subdivisions []struct {
+		IsoCode string `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
+	Location struct {
+		Latitude  *float64 `maxminddb:"latitude"`
+		Longitude *float64 `maxminddb:"longitude"`
+		TimeZone  string   `maxminddb:"time_zone"`
+	} `maxminddb:"location"`
+	Postal struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"postal"`
+
+	AutonomousSystemNumber       uint   `maxminddb:"autonomous_system_number"`
+	AutonomousSystemOrganization string `maxminddb:"autonomous_system_organization"`
+}
+
+// language is the language of the names we publish.
+const language = "en"
+
+var countryLabels = map[string]func(*record) string{
+	"geoip/continent/code":               func(r *record) string { return r.Continent.Code },
+	"geoip/country/code":                 func(r *record) string { return r.Country.IsoCode },
+	"geoip/country/name":                 func(r *record) string { return r.Country.Names[language] },
+	"geoip/country/is_in_european_union": func(r *record) string { return strconv.FormatBool(r.Country.IsInEuropeanUnion) },
+}
+
+var cityLabels = map[string]func(*record) string{
+	"geoip/city/name": func(r *record) string { return r.City.Names[language] },
+	"geoip/subdivisions/code": func(r *record) string {
+		codes := make([]string, len(r.Subdivisions))
+		for i, s := range r.Subdivisions {
+			codes[i] = s.IsoCode
+		}
+		return strings.Join(codes, ",")
+	},
+	"geoip/latitude":   func(r *record) string { return formatFloat(r.Location.Latitude) },
+	"geoip/longitude":  func(r *record) string { return formatFloat(r.Location.Longitude) },
+	"geoip/timezone":   func(r *record) string { return r.Location.TimeZone },
+	"geoip/postalcode": func(r *record) string { return r.Postal.Code },
+}
+
+var asnLabels = map[string]func(*record) string{
+	"geoip/asn": func(r *record) string {
+		if r.AutonomousSystemNumber == 0 {
+			return ""
+		}
+		return strconv.FormatUint(uint64(r.AutonomousSystemNumber), 10)
+	},
+	"geoip/asn/organization": func(r *record) string { return r.AutonomousSystemOrganization },
+}
+
+func formatFloat(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f, 'f', -1, 64)
+}
+
+// db is a MaxMind database that is read again when the file changes.
+type db struct {
+	path string
+
+	mu      sync.RWMutex
+	reader  *maxminddb.Reader
+	kind    kind
+	modTime time.Time
+	size    int64
+}
+
+// newDB opens the database in the file at path.
+func newDB(path string) (*db, error) {
+	d := &db{path: path}
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// load reads the database. The file is read into memory, so a reader that is replaced stays
+// valid for the lookups that still use it.
+func (d *db) load() error {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return err
+	}
+	buf, err := ioutil.ReadFile(d.path)
+	if err != nil {
+		return err
+	}
+	reader, err := maxminddb.FromBytes(buf)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %s", d.path, err)
+	}
+	k, err := kindOf(reader.Metadata.DatabaseType)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %s", d.path, err)
+	}
+
+	d.mu.Lock()
+	d.reader, d.kind = reader, k
+	d.modTime, d.size = info.ModTime(), info.Size()
+	d.mu.Unlock()
+	return nil
+}
+
+// reload reads the database again if the file changed since it was last read.
+func (d *db) reload() error {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return err
+	}
+	d.mu.RLock()
+	changed := !info.ModTime().Equal(d.modTime) || info.Size() != d.size
+	d.mu.RUnlock()
+	if !changed {
+		return nil
+	}
+	if err := d.load(); err != nil {
+		return err
+	}
+	log.Infof("Reloaded %s", d.path)
+	return nil
+}
+
+// kindOf returns the kind of data in a database of type typ, e.g. GeoLite2-City.
+func kindOf(typ string) (kind, error) {
+	switch {
+	case strings.Contains(typ, "City"), strings.Contains(typ, "Enterprise"):
+		return kindCity, nil
+	case strings.Contains(typ, "Country"):
+		return kindCountry, nil
+	case strings.Contains(typ, "ASN"), strings.Contains(typ, "ISP"):
+		return kindASN, nil
+	}
+	return 0, fmt.Errorf("unsupported database type %q", typ)
+}
+
+// metadata sets the labels for ip in ctx. The database is searched once, when the first label is
+// used. The labels are empty when ip isn't found.
+func (d *db) metadata(ctx context.Context, ip net.IP) {
+	d.mu.RLock()
+	reader, k := d.reader, d.kind
+	d.mu.RUnlock()
+
+	var (
+		once sync.Once
+		rec  *record
+	)
+	lookup := func() *record {
+		once.Do(func() {
+			r := new(record)
+			_, ok, err := reader.LookupNetwork(ip, r)
+			if err != nil {
+				log.Debugf("Failed to look up %s in %s: %s", ip, d.path, err)
+				return
+			}
+			if ok {
+				rec = r
+			}
+		})
+		return rec
+	}
+
+	set := func(labels map[string]func(*record) string) {
+		for label, value := range labels {
+			value := value
+			metadata.SetValueFunc(ctx, label, func() string {
+				r := lookup()
+				if r == nil {
+					return ""
+				}
+				return value(r)
+			})
+		}
+	}
+
+	switch k {
+	case kindCity:
+		set(countryLabels)
+		set(cityLabels)
+	case kindCountry:
+		set(countryLabels)
+	case kindASN:
+		set(asnLabels)
+	}
+}
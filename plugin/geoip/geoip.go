@@ -0,0 +1,65 @@
+// Package geoip implements a metadata provider that looks up the location and network of the
+// client in MaxMind databases.
+package geoip
+
+import (
+	"context"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// GeoIP publishes the location and network of the client, taken from MaxMind databases, as
+// metadata.
+type GeoIP struct {
+	Next plugin.Handler
+
+	dbs    []*db
+	reload time.Duration
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (g *GeoIP) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	return plugin.NextOrFailure(g.Name(), g.Next, ctx, w, r)
+}
+
+// Name implements the plugin.Handler interface.
+func (g *GeoIP) Name() string { return "geoip" }
+
+// Metadata implements the metadata.Provider interface. The databases are only searched when a
+// label is used.
+func (g *GeoIP) Metadata(ctx context.Context, state request.Request) context.Context {
+	ip := state.ClientIP()
+	if ip == nil {
+		return ctx
+	}
+	for _, d := range g.dbs {
+		d.metadata(ctx, ip)
+	}
+	return ctx
+}
+
+// Run reads the databases again when their files change, checking every reload interval, until
+// ctx is done.
+func (g *GeoIP) Run(ctx context.Context) {
+	if g.reload == 0 {
+		return
+	}
+	tick := time.NewTicker(g.reload)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			for _, d := range g.dbs {
+				if err := d.reload(); err != nil {
+					log.Warningf("Failed to reload %s: %s", d.path, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package geoip
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+var cityNetworks = map[string]map[string]interface{}{
+	"81.2.69.0/24": {
+		"continent": map[string]interface{}{"code": "EU"},
+		"country": map[string]interface{}{
+			"iso_code":             "GB",
+			"names":                map[string]interface{}{"en": "United Kingdom", "de": "Vereinigtes Königreich"},
+			"is_in_european_union": false,
+		},
+		"city":         map[string]interface{}{"names": map[string]interface{}{"en": "London"}},
+		"subdivisions": []interface{}{map[string]interface{}{"iso_code": "ENG"}, map[string]interface{}{"iso_code": "WND"}},
+		"location": map[string]interface{}{
+			"latitude":  51.5142,
+			"longitude": -0.0931,
+			"time_zone": "Europe/London",
+		},
+		"postal": map[string]interface{}{"code": "EC2V"},
+	},
+	"2001:db8::/32": {
+		"continent": map[string]interface{}{"code": "EU"},
+		"country": map[string]interface{}{
+			"iso_code":             "SE",
+			"names":                map[string]interface{}{"en": "Sweden"},
+			"is_in_european_union": true,
+		},
+	},
+}
+
+var asnNetworks = map[string]map[string]interface{}{
+	"81.2.69.0/24": {
+		"autonomous_system_number":       uint32(20712),
+		"autonomous_system_organization": "Andrews & Arnold Ltd",
+	},
+}
+
+func newTestGeoIP(t *testing.T) (*GeoIP, string) {
+	dir, err := ioutil.TempDir("", "coredns-geoip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeMMDB(t, filepath.Join(dir, "city.mmdb"), "GeoLite2-City", cityNetworks)
+	writeMMDB(t, filepath.Join(dir, "asn.mmdb"), "GeoLite2-ASN", asnNetworks)
+
+	g := &GeoIP{}
+	for _, name := range []string{"city.mmdb", "asn.mmdb"} {
+		d, err := newDB(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.dbs = append(g.dbs, d)
+	}
+	return g, dir
+}
+
+func TestMetadata(t *testing.T) {
+	g, dir := newTestGeoIP(t)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		remote   string
+		ecs      string
+		expected map[string]string
+	}{
+		{
+			remote: "81.2.69.142",
+			expected: map[string]string{
+				"geoip/continent/code":               "EU",
+				"geoip/country/code":                 "GB",
+				"geoip/country/name":                 "United Kingdom",
+				"geoip/country/is_in_european_union": "false",
+				"geoip/city/name":                    "London",
+				"geoip/subdivisions/code":            "ENG,WND",
+				"geoip/latitude":                     "51.5142",
+				"geoip/longitude":                    "-0.0931",
+				"geoip/timezone":                     "Europe/London",
+				"geoip/postalcode":                   "EC2V",
+				"geoip/asn":                          "20712",
+				"geoip/asn/organization":             "Andrews & Arnold Ltd",
+			},
+		},
+		{
+			remote: "2001:db8::1",
+			expected: map[string]string{
+				"geoip/continent/code":               "EU",
+				"geoip/country/code":                 "SE",
+				"geoip/country/name":                 "Sweden",
+				"geoip/country/is_in_european_union": "true",
+				"geoip/city/name":                    "",
+				"geoip/subdivisions/code":            "",
+				"geoip/latitude":                     "",
+				"geoip/longitude":                    "",
+				"geoip/asn":                          "",
+			},
+		},
+		{
+			// The client subnet is used when present.
+			remote: "10.240.0.1",
+			ecs:    "81.2.69.0",
+			expected: map[string]string{
+				"geoip/country/code": "GB",
+				"geoip/asn":          "20712",
+			},
+		},
+		{
+			// Not in the databases.
+			remote: "10.240.0.1",
+			expected: map[string]string{
+				"geoip/continent/code":               "",
+				"geoip/country/code":                 "",
+				"geoip/country/is_in_european_union": "",
+				"geoip/city/name":                    "",
+				"geoip/asn":                          "",
+				"geoip/asn/organization":             "",
+			},
+		},
+	}
+
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		if tc.ecs != "" {
+			m.SetEdns0(4096, false)
+			o := m.IsEdns0()
+			o.Option = append(o.Option, &dns.EDNS0_SUBNET{
+				Code:          dns.EDNS0SUBNET,
+				Family:        1,
+				SourceNetmask: 24,
+				Address:       net.ParseIP(tc.ecs).To4(),
+			})
+		}
+		state := request.Request{W: &test.ResponseWriter{RemoteIP: tc.remote}, Req: m}
+
+		ctx := g.Metadata(metadata.ContextWithMetadata(context.Background()), state)
+		for label, expected := range tc.expected {
+			f := metadata.ValueFunc(ctx, label)
+			if f == nil {
+				t.Errorf("Test %d: label %s not set", i, label)
+				continue
+			}
+			if x := f(); x != expected {
+				t.Errorf("Test %d: expected %q for %s, got %q", i, expected, label, x)
+			}
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	g, dir := newTestGeoIP(t)
+	defer os.RemoveAll(dir)
+
+	country := func() string {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		state := request.Request{W: &test.ResponseWriter{RemoteIP: "81.2.69.142"}, Req: m}
+		ctx := g.Metadata(metadata.ContextWithMetadata(context.Background()), state)
+		return metadata.ValueFunc(ctx, "geoip/country/code")()
+	}
+	if x := country(); x != "GB" {
+		t.Fatalf("Expected GB, got %q", x)
+	}
+
+	// A value that is looked up from a database that is replaced afterwards.
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	state := request.Request{W: &test.ResponseWriter{RemoteIP: "81.2.69.142"}, Req: m}
+	ctx := g.Metadata(metadata.ContextWithMetadata(context.Background()), state)
+
+	path := filepath.Join(dir, "city.mmdb")
+	writeMMDB(t, path, "GeoLite2-Country", map[string]map[string]interface{}{
+		"81.2.69.0/24": {"country": map[string]interface{}{"iso_code": "IE"}},
+	})
+	// Make sure the modification time changes.
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx2, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	g.reload = 10 * time.Millisecond
+	go g.Run(ctx2)
+
+	for i := 0; i < 100 && country() != "IE"; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if x := country(); x != "IE" {
+		t.Errorf("Expected IE after reloading, got %q", x)
+	}
+	if x := metadata.ValueFunc(ctx, "geoip/country/code")(); x != "GB" {
+		t.Errorf("Expected GB from the replaced database, got %q", x)
+	}
+
+	// The country database has no city labels.
+	ctx = g.Metadata(metadata.ContextWithMetadata(context.Background()), state)
+	if f := metadata.ValueFunc(ctx, "geoip/city/name"); f != nil {
+		t.Errorf("Expected no city labels for a country database, got %q", f())
+	}
+
+	// A broken file is not loaded, the previous database is kept.
+	if err := ioutil.WriteFile(path, []byte("not a database"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.dbs[0].reload(); err == nil {
+		t.Errorf("Expected an error for a broken database")
+	}
+	if x := country(); x != "IE" {
+		t.Errorf("Expected IE after a failed reload, got %q", x)
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		typ      string
+		expected kind
+		err      bool
+	}{
+		{"GeoLite2-City", kindCity, false},
+		{"GeoIP2-Enterprise", kindCity, false},
+		{"GeoIP2-Country", kindCountry, false},
+		{"DBIP-Country-Lite", kindCountry, false},
+		{"GeoLite2-ASN", kindASN, false},
+		{"GeoIP2-ISP", kindASN, false},
+		{"GeoIP2-Domain", 0, true},
+	}
+	for i, tc := range tests {
+		k, err := kindOf(tc.typ)
+		if tc.err {
+			if err == nil {
+				t.Errorf("Test %d: expected error for %s", i, tc.typ)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error for %s, got %v", i, tc.typ, err)
+			continue
+		}
+		if k != tc.expected {
+			t.Errorf("Test %d: expected kind %d for %s, got %d", i, tc.expected, tc.typ, k)
+		}
+	}
+}
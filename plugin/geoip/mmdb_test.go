@@ -0,0 +1,175 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"net"
+	"sort"
+	"testing"
+)
+
+// This file writes small MaxMind databases for the tests, see
+// https://maxmind.github.io/MaxMind-DB/ for the format. The databases are IPv6 databases with
+// 24 bit records, IPv4 networks are stored in ::/96.
+
+// node is a node in the search tree. A leaf holds the offset of its data.
+type node struct {
+	children [2]*node
+	leaf     bool
+	offset   int
+}
+
+// writeMMDB writes a database of type typ to path, with data for each network.
+func writeMMDB(t *testing.T, path, typ string, networks map[string]map[string]interface{}) {
+	root := &node{}
+	data := new(bytes.Buffer)
+
+	cidrs := make([]string, 0, len(networks))
+	for cidr := range networks {
+		cidrs = append(cidrs, cidr)
+	}
+	sort.Strings(cidrs)
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ip := n.IP.To16()
+		ones, _ := n.Mask.Size()
+		if n.IP.To4() != nil {
+			ip = append(make(net.IP, 12), n.IP.To4()...)
+			ones += 96
+		}
+
+		offset := data.Len()
+		encode(data, networks[cidr])
+
+		cur := root
+		for i := 0; i < ones; i++ {
+			bit := ip[i/8] >> uint(7-i%8) & 1
+			if cur.children[bit] == nil {
+				cur.children[bit] = &node{}
+			}
+			cur = cur.children[bit]
+		}
+		cur.leaf, cur.offset = true, offset
+	}
+
+	// Number the nodes, breadth first, the root is 0.
+	var nodes []*node
+	index := map[*node]int{}
+	for queue := []*node{root}; len(queue) > 0; queue = queue[1:] {
+		n := queue[0]
+		index[n] = len(nodes)
+		nodes = append(nodes, n)
+		for _, c := range n.children {
+			if c != nil && !c.leaf {
+				queue = append(queue, c)
+			}
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	count := len(nodes)
+	for _, n := range nodes {
+		for _, c := range n.children {
+			v := count // no data
+			switch {
+			case c == nil:
+			case c.leaf:
+				v = count + 16 + c.offset
+			default:
+				v = index[c]
+			}
+			buf.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+		}
+	}
+	buf.Write(make([]byte, 16))
+	buf.Write(data.Bytes())
+	buf.WriteString("\xAB\xCD\xEFMaxMind.com")
+	encode(buf, map[string]interface{}{
+		"node_count":                  uint32(count),
+		"record_size":                 uint16(24),
+		"ip_version":                  uint16(6),
+		"database_type":               typ,
+		"languages":                   []interface{}{"en"},
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(0),
+		"description":                 map[string]interface{}{"en": "test database"},
+	})
+
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// encode encodes v in the data section format.
+func encode(buf *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case string:
+		control(buf, 2, len(v))
+		buf.WriteString(v)
+	case float64:
+		control(buf, 3, 8)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+	case uint16:
+		unsigned(buf, 5, uint64(v))
+	case uint32:
+		unsigned(buf, 6, uint64(v))
+	case uint64:
+		unsigned(buf, 9, v)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		control(buf, 14, size)
+	case []interface{}:
+		control(buf, 11, len(v))
+		for _, x := range v {
+			encode(buf, x)
+		}
+	case map[string]interface{}:
+		control(buf, 7, len(v))
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			encode(buf, k)
+			encode(buf, v[k])
+		}
+	default:
+		panic("unsupported type")
+	}
+}
+
+func unsigned(buf *bytes.Buffer, typ int, v uint64) {
+	var b []byte
+	for ; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	control(buf, typ, len(b))
+	buf.Write(b)
+}
+
+// control writes the control byte(s) for a field of type typ and size. Sizes up to 284 are
+// supported, which is plenty for the tests.
+func control(buf *bytes.Buffer, typ, size int) {
+	var ext []byte
+	if size >= 29 {
+		ext = []byte{byte(size - 29)}
+		size = 29
+	}
+	if typ > 7 {
+		buf.WriteByte(byte(size))
+		buf.WriteByte(byte(typ - 7))
+	} else {
+		buf.WriteByte(byte(typ<<5 | size))
+	}
+	buf.Write(ext)
+}
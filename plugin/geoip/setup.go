@@ -0,0 +1,100 @@
+package geoip
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/mholt/caddy"
+)
+
+var log = clog.NewWithPlugin("geoip")
+
+func init() {
+	caddy.RegisterPlugin("geoip", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+// defaultReload is how often the database files are checked for changes.
+const defaultReload = time.Minute
+
+func setup(c *caddy.Controller) error {
+	g, err := geoipParse(c)
+	if err != nil {
+		return plugin.Error("geoip", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.OnStartup(func() error {
+		go g.Run(ctx)
+		return nil
+	})
+	c.OnShutdown(func() error {
+		cancel()
+		return nil
+	})
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		g.Next = next
+		return g
+	})
+
+	return nil
+}
+
+func geoipParse(c *caddy.Controller) (*GeoIP, error) {
+	config := dnsserver.GetConfig(c)
+
+	i := 0
+	g := &GeoIP{reload: defaultReload}
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		paths := c.RemainingArgs()
+		if len(paths) == 0 {
+			return nil, c.ArgErr()
+		}
+		for _, path := range paths {
+			if !filepath.IsAbs(path) && config.Root != "" {
+				path = filepath.Join(config.Root, path)
+			}
+			d, err := newDB(path)
+			if err != nil {
+				return nil, err
+			}
+			g.dbs = append(g.dbs, d)
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "reload":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(c.Val())
+				if err != nil {
+					return nil, c.Errf("invalid reload duration '%s'", c.Val())
+				}
+				if d < 0 {
+					return nil, c.Errf("reload duration must not be negative: %s", d)
+				}
+				g.reload = d
+				if c.NextArg() {
+					return nil, c.ArgErr()
+				}
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	return g, nil
+}
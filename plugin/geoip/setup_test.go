@@ -0,0 +1,73 @@
+package geoip
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupGeoIP(t *testing.T) {
+	_, dir := newTestGeoIP(t)
+	defer os.RemoveAll(dir)
+	city, asn := filepath.Join(dir, "city.mmdb"), filepath.Join(dir, "asn.mmdb")
+	notdb := filepath.Join(dir, "notdb.mmdb")
+	writeMMDB(t, notdb, "GeoIP2-Domain", nil)
+
+	tests := []struct {
+		input  string
+		err    bool
+		dbs    int
+		reload time.Duration
+	}{
+		{`geoip ` + city, false, 1, defaultReload},
+		{`geoip ` + city + ` ` + asn, false, 2, defaultReload},
+		{`geoip ` + city + ` {
+			reload 10s
+		}`, false, 1, 10 * time.Second},
+		{`geoip ` + city + ` {
+			reload 0
+		}`, false, 1, 0},
+		// fails
+		{`geoip`, true, 0, 0},
+		{`geoip /does/not/exist.mmdb`, true, 0, 0},
+		{`geoip ` + notdb, true, 0, 0},
+		{`geoip ` + city + ` {
+			reload
+		}`, true, 0, 0},
+		{`geoip ` + city + ` {
+			reload -1s
+		}`, true, 0, 0},
+		{`geoip ` + city + ` {
+			reload 1s 2s
+		}`, true, 0, 0},
+		{`geoip ` + city + ` {
+			blah
+		}`, true, 0, 0},
+		{`geoip ` + city + `
+		geoip ` + asn, true, 0, 0},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		g, err := geoipParse(c)
+		if tc.err {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none for input %s", i, tc.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %v for input %s", i, err, tc.input)
+			continue
+		}
+		if len(g.dbs) != tc.dbs {
+			t.Errorf("Test %d: expected %d databases, got %d", i, tc.dbs, len(g.dbs))
+		}
+		if g.reload != tc.reload {
+			t.Errorf("Test %d: expected reload %s, got %s", i, tc.reload, g.reload)
+		}
+	}
+}
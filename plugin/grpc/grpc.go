@@ -7,10 +7,13 @@ import (
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/debug"
+	"github.com/coredns/coredns/plugin/pkg/trace"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
 	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"google.golang.org/grpc/metadata"
 )
 
 // GRPC represents a plugin instance that can proxy requests to another (DNS) server via gRPC protocol.
@@ -58,18 +61,30 @@ func (g *GRPC) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (
 		proxy := list[i]
 		i++
 
+		qctx := ctx
 		if span != nil {
-			child = span.Tracer().StartSpan("query", ot.ChildOf(span.Context()))
-			ctx = ot.ContextWithSpan(ctx, child)
+			child = span.Tracer().StartSpan("query", ot.ChildOf(span.Context()), ext.SpanKindRPCClient)
+			child.SetTag(trace.TagUpstream, proxy.addr)
+			trace.SetRequestTags(child, state)
+			qctx = ot.ContextWithSpan(qctx, child)
+			// Propagate the trace context to the upstream, so it can continue the trace.
+			if tp, err := trace.Inject(child); err == nil {
+				qctx = metadata.AppendToOutgoingContext(qctx, trace.TraceParent, tp)
+			}
 		}
 
-		ret, err = proxy.query(ctx, r)
+		ret, err = proxy.query(qctx, r)
 		if err != nil {
+			if child != nil {
+				ext.Error.Set(child, true)
+				child.Finish()
+			}
 			// Continue with the next proxy
 			continue
 		}
 
 		if child != nil {
+			trace.SetRcodeTag(child, ret.Rcode)
 			child.Finish()
 		}
 
@@ -44,19 +44,15 @@ func (h Hosts) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (
 			return plugin.NextOrFailure(h.Name(), h.Next, ctx, w, r)
 		}
 		answers = h.ptr(qname, h.options.ttl, names)
-	case dns.TypeA:
-		ips := h.LookupStaticHostV4(qname)
-		answers = a(qname, h.options.ttl, ips)
-	case dns.TypeAAAA:
-		ips := h.LookupStaticHostV6(qname)
-		answers = aaaa(qname, h.options.ttl, ips)
+	default:
+		answers = h.resolve(qname, state.QType())
 	}
 
 	if len(answers) == 0 {
 		if h.Fall.Through(qname) {
 			return plugin.NextOrFailure(h.Name(), h.Next, ctx, w, r)
 		}
-		if !h.otherRecordsExist(state.QType(), qname) {
+		if !h.Exists(qname) {
 			return dns.RcodeNameError, nil
 		}
 	}
@@ -70,28 +66,35 @@ func (h Hosts) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (
 	return dns.RcodeSuccess, nil
 }
 
-func (h Hosts) otherRecordsExist(qtype uint16, qname string) bool {
-	switch qtype {
-	case dns.TypeA:
-		if len(h.LookupStaticHostV6(qname)) > 0 {
-			return true
-		}
-	case dns.TypeAAAA:
-		if len(h.LookupStaticHostV4(qname)) > 0 {
-			return true
-		}
-	default:
-		if len(h.LookupStaticHostV4(qname)) > 0 {
-			return true
+// resolve returns the records of type qtype for qname. When qname is an alias, the CNAME records
+// are followed through the hosts data and the records of the canonical name are added.
+func (h Hosts) resolve(qname string, qtype uint16) []dns.RR {
+	answers := []dns.RR{}
+	name := qname
+	for i := 0; i < maxCNAMEChain; i++ {
+		target := h.LookupStaticCNAME(name)
+		if target == "" {
+			break
 		}
-		if len(h.LookupStaticHostV6(qname)) > 0 {
-			return true
+		answers = append(answers, cname(name, h.TTL(name), target))
+		if qtype == dns.TypeCNAME {
+			return answers
 		}
+		name = target
 	}
-	return false
 
+	switch qtype {
+	case dns.TypeA:
+		answers = append(answers, a(name, h.TTL(name), h.LookupStaticHostV4(name))...)
+	case dns.TypeAAAA:
+		answers = append(answers, aaaa(name, h.TTL(name), h.LookupStaticHostV6(name))...)
+	}
+	return answers
 }
 
+// maxCNAMEChain is the maximum number of CNAME records we follow.
+const maxCNAMEChain = 8
+
 // Name implements the plugin.Handle interface.
 func (h Hosts) Name() string { return "hosts" }
 
@@ -121,6 +124,15 @@ func aaaa(zone string, ttl uint32, ips []net.IP) []dns.RR {
 	return answers
 }
 
+// cname returns a CNAME RR for alias pointing to target.
+func cname(alias string, ttl uint32, target string) dns.RR {
+	r := new(dns.CNAME)
+	r.Hdr = dns.RR_Header{Name: alias, Rrtype: dns.TypeCNAME,
+		Class: dns.ClassINET, Ttl: ttl}
+	r.Target = target
+	return r
+}
+
 // ptr takes a slice of host names and filters out the ones that aren't in Origins, if specified, and returns a slice of PTR RRs.
 func (h *Hosts) ptr(zone string, ttl uint32, names []string) []dns.RR {
 	answers := []dns.RR{}
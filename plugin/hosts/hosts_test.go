@@ -102,3 +102,132 @@ const hostsExample = `
 reload 5s
 timeout 3600
 `
+
+func TestLookupExtended(t *testing.T) {
+	h := Hosts{
+		Next: test.ErrorHandler(),
+		Hostsfile: &Hostsfile{
+			Origins: []string{"example.org."},
+			hmap:    newHostsMap(),
+			options: newOptions(),
+		},
+	}
+	h.parseReader(strings.NewReader(hostsExtendedExample))
+
+	ctx := context.TODO()
+
+	for _, tc := range hostsExtendedTestCases {
+		m := tc.Msg()
+
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		rcode, err := h.ServeDNS(ctx, rec, m)
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		resp := rec.Msg
+		if resp == nil {
+			// NXDOMAIN is returned as rcode, the server writes the reply.
+			if rcode != tc.Rcode {
+				t.Errorf("Expected rcode %d for %s, got %d", tc.Rcode, tc.Qname, rcode)
+			}
+			continue
+		}
+		if err := test.SortAndCheck(resp, tc); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+var hostsExtendedTestCases = []test.Case{
+	{
+		Qname: "www.example.org.", Qtype: dns.TypeA,
+		Answer: []dns.RR{
+			test.A("web.example.org. 300 IN A 10.0.0.1"),
+			test.A("web.example.org. 300 IN A 10.0.0.4"),
+			test.CNAME("www.example.org. 60 IN CNAME web.example.org."),
+		},
+	},
+	{
+		Qname: "www.example.org.", Qtype: dns.TypeCNAME,
+		Answer: []dns.RR{
+			test.CNAME("www.example.org. 60 IN CNAME web.example.org."),
+		},
+	},
+	{
+		// Chain of aliases.
+		Qname: "shop.example.org.", Qtype: dns.TypeAAAA,
+		Answer: []dns.RR{
+			test.CNAME("shop.example.org. 3600 IN CNAME www.example.org."),
+			test.AAAA("web.example.org. 300 IN AAAA ::1"),
+			test.CNAME("www.example.org. 60 IN CNAME web.example.org."),
+		},
+	},
+	{
+		// Alias pointing outside of the hosts data.
+		Qname: "cdn.example.org.", Qtype: dns.TypeA,
+		Answer: []dns.RR{
+			test.CNAME("cdn.example.org. 3600 IN CNAME example.net."),
+		},
+	},
+	{
+		// Alias loop.
+		Qname: "loop1.example.org.", Qtype: dns.TypeA,
+		Answer: []dns.RR{
+			test.CNAME("loop1.example.org. 3600 IN CNAME loop2.example.org."),
+			test.CNAME("loop1.example.org. 3600 IN CNAME loop2.example.org."),
+			test.CNAME("loop1.example.org. 3600 IN CNAME loop2.example.org."),
+			test.CNAME("loop1.example.org. 3600 IN CNAME loop2.example.org."),
+			test.CNAME("loop2.example.org. 3600 IN CNAME loop1.example.org."),
+			test.CNAME("loop2.example.org. 3600 IN CNAME loop1.example.org."),
+			test.CNAME("loop2.example.org. 3600 IN CNAME loop1.example.org."),
+			test.CNAME("loop2.example.org. 3600 IN CNAME loop1.example.org."),
+		},
+	},
+	{
+		Qname: "a.b.dev.example.org.", Qtype: dns.TypeA,
+		Answer: []dns.RR{
+			test.A("a.b.dev.example.org. 3600 IN A 10.0.0.2"),
+		},
+	},
+	{
+		// More specific name wins over the wildcard.
+		Qname: "api.dev.example.org.", Qtype: dns.TypeA,
+		Answer: []dns.RR{
+			test.A("api.dev.example.org. 3600 IN A 10.0.0.3"),
+		},
+	},
+	{
+		// The wildcard doesn't match the name it is defined under.
+		Qname: "dev.example.org.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+	},
+	{
+		Qname: "x.ads.example.org.", Qtype: dns.TypeA,
+		Answer: []dns.RR{
+			test.A("sink.example.org. 3600 IN A 0.0.0.0"),
+			test.CNAME("x.ads.example.org. 3600 IN CNAME sink.example.org."),
+		},
+	},
+	{
+		// Wildcard entries don't get reverse entries.
+		Qname: "2.0.0.10.in-addr.arpa.", Qtype: dns.TypePTR,
+		Rcode: dns.RcodeServerFailure,
+	},
+}
+
+const hostsExtendedExample = `
+10.0.0.1 web.example.org # ttl=600
+10.0.0.4 web.example.org # ttl=300 the lowest TTL is used
+::1 web.example.org
+www.example.org CNAME web.example.org # ttl=60
+shop.example.org CNAME www.example.org
+cdn.example.org CNAME example.net
+loop1.example.org CNAME loop2.example.org
+loop2.example.org CNAME loop1.example.org
+10.0.0.2 *.dev.example.org
+10.0.0.3 api.dev.example.org
+0.0.0.0 sink.example.org
+*.ads.example.org CNAME sink.example.org
+`
@@ -10,13 +10,18 @@ import (
 	"bufio"
 	"bytes"
 	"io"
+	"io/ioutil"
 	"net"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/coredns/coredns/plugin"
+
+	"github.com/miekg/dns"
 )
 
 func parseLiteralIP(addr string) net.IP {
@@ -42,6 +47,10 @@ type options struct {
 
 	// The time between two reload of the configuration
 	reload time.Duration
+
+	// When the path is a directory, only the files matching one of these patterns are read.
+	// An empty list reads all files.
+	files []string
 }
 
 func newOptions() *options {
@@ -64,6 +73,12 @@ type hostsMap struct {
 	// including IPv6 address with zone identifier.
 	// We don't support old-classful IP address notation.
 	byAddr map[string][]string
+
+	// Key is an alias, the value its canonical name.
+	cname map[string]string
+
+	// Key is a host name or alias that has a TTL annotation, the value the lowest TTL found.
+	ttl map[string]uint32
 }
 
 const (
@@ -76,7 +91,61 @@ func newHostsMap() *hostsMap {
 		byNameV4: make(map[string][]net.IP),
 		byNameV6: make(map[string][]net.IP),
 		byAddr:   make(map[string][]string),
+		cname:    make(map[string]string),
+		ttl:      make(map[string]uint32),
+	}
+}
+
+// merge adds all entries of m to h.
+func (h *hostsMap) merge(m *hostsMap) {
+	for name, ips := range m.byNameV4 {
+		h.byNameV4[name] = append(h.byNameV4[name], ips...)
+	}
+	for name, ips := range m.byNameV6 {
+		h.byNameV6[name] = append(h.byNameV6[name], ips...)
+	}
+	for addr, names := range m.byAddr {
+		h.byAddr[addr] = append(h.byAddr[addr], names...)
+	}
+	for alias, target := range m.cname {
+		h.cname[alias] = target
+	}
+	for name, ttl := range m.ttl {
+		h.setTTL(name, ttl)
+	}
+}
+
+// setTTL records ttl for name, keeping the lowest when name has several annotations.
+func (h *hostsMap) setTTL(name string, ttl uint32) {
+	if t, ok := h.ttl[name]; !ok || ttl < t {
+		h.ttl[name] = ttl
+	}
+}
+
+// exists returns true if h has an entry for name.
+func (h *hostsMap) exists(name string) bool {
+	if _, ok := h.byNameV4[name]; ok {
+		return true
+	}
+	if _, ok := h.byNameV6[name]; ok {
+		return true
+	}
+	_, ok := h.cname[name]
+	return ok
+}
+
+// key returns the key under which the entries for name are stored: name itself, or the closest
+// wildcard that covers it. The empty string is returned when there are no entries for name.
+func (h *hostsMap) key(name string) string {
+	if h.exists(name) {
+		return name
+	}
+	for i, end := dns.NextLabel(name, 0); !end; i, end = dns.NextLabel(name, i) {
+		if w := "*." + name[i:]; h.exists(w) {
+			return w
+		}
 	}
+	return ""
 }
 
 // Len returns the total number of addresses in the hostmap, this includes
@@ -109,44 +178,106 @@ type Hostsfile struct {
 	// We need a copy here as we want to use it to initialize the maps for parse.
 	inline *hostsMap
 
-	// path to the hosts file
+	// path to the hosts file, or a directory of hosts files
 	path string
 
-	// mtime and size are only read and modified by a single goroutine
-	mtime time.Time
-	size  int64
+	// stamps identify the files the current data was read from, they are only read and modified by a
+	// single goroutine.
+	stamps []stamp
 
 	options *options
 }
 
-// readHosts determines if the cached data needs to be updated based on the size and modification time of the hostsfile.
+// stamp identifies the version of a file.
+type stamp struct {
+	path  string
+	mtime time.Time
+	size  int64
+}
+
+// readHosts determines if the cached data needs to be updated based on the size and modification
+// time of the hostsfile, or of the files in the directory.
 func (h *Hostsfile) readHosts() {
-	file, err := os.Open(h.path)
+	stamps, err := h.stat()
 	if err != nil {
 		// We already log a warning if the file doesn't exist or can't be opened on setup. No need to return the error here.
 		return
 	}
-	defer file.Close()
-
-	stat, err := file.Stat()
-	h.RLock()
-	size := h.size
-	h.RUnlock()
-	if err == nil && h.mtime.Equal(stat.ModTime()) && size == stat.Size() {
+	if equalStamps(stamps, h.stamps) {
 		return
 	}
 
-	newMap := h.parse(file)
-	log.Debugf("Parsed hosts file into %d entries", newMap.Len())
+	newMap := newHostsMap()
+	if h.inline != nil {
+		newMap.merge(h.inline)
+	}
+	for _, st := range stamps {
+		file, err := os.Open(st.path)
+		if err != nil {
+			log.Warningf("Failed to read hosts file %q: %s", st.path, err)
+			continue
+		}
+		newMap.merge(h.parse(file))
+		file.Close()
+	}
+	log.Debugf("Parsed %d hosts files into %d entries", len(stamps), newMap.Len())
 
 	h.Lock()
-
 	h.hmap = newMap
+	h.Unlock()
+
 	// Update the data cache.
-	h.mtime = stat.ModTime()
-	h.size = stat.Size()
+	h.stamps = stamps
+}
 
-	h.Unlock()
+// stat returns the stamps of the files to read: the hosts file, or the files in the directory
+// that match the configured patterns. Hidden files and subdirectories are skipped.
+func (h *Hostsfile) stat() ([]stamp, error) {
+	info, err := os.Stat(h.path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []stamp{{path: h.path, mtime: info.ModTime(), size: info.Size()}}, nil
+	}
+
+	infos, err := ioutil.ReadDir(h.path)
+	if err != nil {
+		return nil, err
+	}
+	stamps := []stamp{}
+	for _, fi := range infos {
+		if fi.IsDir() || strings.HasPrefix(fi.Name(), ".") || !h.matchFile(fi.Name()) {
+			continue
+		}
+		stamps = append(stamps, stamp{path: filepath.Join(h.path, fi.Name()), mtime: fi.ModTime(), size: fi.Size()})
+	}
+	return stamps, nil
+}
+
+// matchFile returns true if name matches one of the file patterns, or when there are none.
+func (h *Hostsfile) matchFile(name string) bool {
+	if len(h.options.files) == 0 {
+		return true
+	}
+	for _, pattern := range h.options.files {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func equalStamps(a, b []stamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].path != b[i].path || !a[i].mtime.Equal(b[i].mtime) || a[i].size != b[i].size {
+			return false
+		}
+	}
+	return true
 }
 
 func (h *Hostsfile) initInline(inline []string) {
@@ -158,21 +289,39 @@ func (h *Hostsfile) initInline(inline []string) {
 	*h.hmap = *h.inline
 }
 
-// Parse reads the hostsfile and populates the byName and byAddr maps.
+// Parse reads the hostsfile and populates the byName and byAddr maps. Besides address lines, a
+// line can define an alias as "ALIAS CNAME TARGET". Names may be wildcards, such as
+// "*.example.org", these don't get reverse entries. A "ttl=SECONDS" annotation in the comment of a
+// line sets the TTL of its names.
 func (h *Hostsfile) parse(r io.Reader) *hostsMap {
 	hmap := newHostsMap()
 
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		line := scanner.Bytes()
+		var comment []byte
 		if i := bytes.Index(line, []byte{'#'}); i >= 0 {
 			// Discard comments.
-			line = line[0:i]
+			line, comment = line[0:i], line[i+1:]
 		}
 		f := bytes.Fields(line)
 		if len(f) < 2 {
 			continue
 		}
+		ttl, hasTTL := parseTTL(comment)
+
+		if len(f) == 3 && strings.EqualFold(string(f[1]), "CNAME") {
+			alias, target := absDomainName(string(f[0])), absDomainName(string(f[2]))
+			if plugin.Zones(h.Origins).Matches(alias) == "" {
+				continue
+			}
+			hmap.cname[alias] = target
+			if hasTTL {
+				hmap.setTTL(alias, ttl)
+			}
+			continue
+		}
+
 		addr := parseLiteralIP(string(f[0]))
 		if addr == nil {
 			continue
@@ -192,25 +341,32 @@ func (h *Hostsfile) parse(r io.Reader) *hostsMap {
 			default:
 				continue
 			}
-			if !h.options.autoReverse {
+			if hasTTL {
+				hmap.setTTL(name, ttl)
+			}
+			if !h.options.autoReverse || strings.HasPrefix(name, "*.") {
 				continue
 			}
 			hmap.byAddr[addr.String()] = append(hmap.byAddr[addr.String()], name)
 		}
 	}
 
-	for name := range h.hmap.byNameV4 {
-		hmap.byNameV4[name] = append(hmap.byNameV4[name], h.hmap.byNameV4[name]...)
-	}
-	for name := range h.hmap.byNameV4 {
-		hmap.byNameV6[name] = append(hmap.byNameV6[name], h.hmap.byNameV6[name]...)
-	}
+	return hmap
+}
 
-	for addr := range h.hmap.byAddr {
-		hmap.byAddr[addr] = append(hmap.byAddr[addr], h.hmap.byAddr[addr]...)
+// parseTTL returns the TTL from a "ttl=SECONDS" annotation in comment.
+func parseTTL(comment []byte) (uint32, bool) {
+	for _, f := range bytes.Fields(comment) {
+		if !bytes.HasPrefix(f, []byte("ttl=")) {
+			continue
+		}
+		ttl, err := strconv.ParseUint(string(f[len("ttl="):]), 10, 32)
+		if err != nil {
+			return 0, false
+		}
+		return uint32(ttl), true
 	}
-
-	return hmap
+	return 0, false
 }
 
 // ipVersion returns what IP version was used textually
@@ -229,17 +385,18 @@ func ipVersion(s string) int {
 }
 
 // LookupStaticHost looks up the IP addresses for the given host from the hosts file.
-func (h *Hostsfile) lookupStaticHost(hmapByName map[string][]net.IP, host string) []net.IP {
+func (h *Hostsfile) lookupStaticHost(hmapByName func(*hostsMap) map[string][]net.IP, host string) []net.IP {
 	fqhost := absDomainName(host)
 
 	h.RLock()
 	defer h.RUnlock()
 
-	if len(hmapByName) == 0 {
+	byName := hmapByName(h.hmap)
+	if len(byName) == 0 {
 		return nil
 	}
 
-	ips, ok := hmapByName[fqhost]
+	ips, ok := byName[h.hmap.key(fqhost)]
 	if !ok {
 		return nil
 	}
@@ -250,12 +407,12 @@ func (h *Hostsfile) lookupStaticHost(hmapByName map[string][]net.IP, host string
 
 // LookupStaticHostV4 looks up the IPv4 addresses for the given host from the hosts file.
 func (h *Hostsfile) LookupStaticHostV4(host string) []net.IP {
-	return h.lookupStaticHost(h.hmap.byNameV4, host)
+	return h.lookupStaticHost(func(m *hostsMap) map[string][]net.IP { return m.byNameV4 }, host)
 }
 
 // LookupStaticHostV6 looks up the IPv6 addresses for the given host from the hosts file.
 func (h *Hostsfile) LookupStaticHostV6(host string) []net.IP {
-	return h.lookupStaticHost(h.hmap.byNameV6, host)
+	return h.lookupStaticHost(func(m *hostsMap) map[string][]net.IP { return m.byNameV6 }, host)
 }
 
 // LookupStaticAddr looks up the hosts for the given address from the hosts file.
@@ -277,3 +434,30 @@ func (h *Hostsfile) LookupStaticAddr(addr string) []string {
 	copy(hostsCp, hosts)
 	return hostsCp
 }
+
+// LookupStaticCNAME looks up the canonical name for the given alias from the hosts file. It
+// returns the empty string when host is not an alias.
+func (h *Hostsfile) LookupStaticCNAME(host string) string {
+	h.RLock()
+	defer h.RUnlock()
+	return h.hmap.cname[h.hmap.key(absDomainName(host))]
+}
+
+// TTL returns the TTL for the records of host: the TTL annotation of its entries, or the
+// configured TTL.
+func (h *Hostsfile) TTL(host string) uint32 {
+	h.RLock()
+	defer h.RUnlock()
+	if ttl, ok := h.hmap.ttl[h.hmap.key(absDomainName(host))]; ok {
+		return ttl
+	}
+	return h.options.ttl
+}
+
+// Exists returns true if the hosts file has any entry for host, including a wildcard that
+// covers it.
+func (h *Hostsfile) Exists(host string) bool {
+	h.RLock()
+	defer h.RUnlock()
+	return h.hmap.key(absDomainName(host)) != ""
+}
@@ -5,7 +5,10 @@
 package hosts
 
 import (
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -240,3 +243,61 @@ func TestHostCacheModification(t *testing.T) {
 	}
 	testStaticAddr(t, entip, h)
 }
+
+func TestReadHostsDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.hosts", "10.0.0.1 a.example.org\n")
+	write("b.hosts", "10.0.0.2 b.example.org\n")
+	write("c.txt", "10.0.0.3 c.example.org\n")
+	write(".d.hosts", "10.0.0.4 d.example.org\n")
+
+	h := &Hostsfile{
+		Origins: []string{"."},
+		hmap:    newHostsMap(),
+		path:    dir,
+		options: newOptions(),
+	}
+	h.options.files = []string{"*.hosts"}
+	h.initInline([]string{"10.0.0.5 inline.example.org"})
+	h.readHosts()
+
+	tests := []struct {
+		name   string
+		exists bool
+	}{
+		{"a.example.org", true},
+		{"b.example.org", true},
+		{"c.example.org", false},
+		{"d.example.org", false},
+		{"inline.example.org", true},
+	}
+	for _, tc := range tests {
+		if x := len(h.LookupStaticHostV4(tc.name)) > 0; x != tc.exists {
+			t.Errorf("Expected %s to exist: %t, got %t", tc.name, tc.exists, x)
+		}
+	}
+
+	// Removing a file removes its entries, and entries are not duplicated.
+	if err := os.Remove(filepath.Join(dir, "b.hosts")); err != nil {
+		t.Fatal(err)
+	}
+	h.readHosts()
+	if ips := h.LookupStaticHostV4("b.example.org"); len(ips) != 0 {
+		t.Errorf("Expected no entries for b.example.org, got %v", ips)
+	}
+	for _, name := range []string{"a.example.org", "inline.example.org"} {
+		if ips := h.LookupStaticHostV4(name); len(ips) != 1 {
+			t.Errorf("Expected 1 address for %s, got %v", name, ips)
+		}
+	}
+}
@@ -100,7 +100,7 @@ func hostsParse(c *caddy.Controller) (Hosts, error) {
 			if !filepath.IsAbs(h.path) && config.Root != "" {
 				h.path = filepath.Join(config.Root, h.path)
 			}
-			s, err := os.Stat(h.path)
+			_, err := os.Stat(h.path)
 			if err != nil {
 				if os.IsNotExist(err) {
 					log.Warningf("File does not exist: %s", h.path)
@@ -108,9 +108,6 @@ func hostsParse(c *caddy.Controller) (Hosts, error) {
 					return h, c.Errf("unable to access hosts file '%s': %v", h.path, err)
 				}
 			}
-			if s != nil && s.IsDir() {
-				log.Warningf("Hosts file %q is a directory", h.path)
-			}
 		}
 
 		origins := make([]string, len(c.ServerBlockKeys))
@@ -156,6 +153,17 @@ func hostsParse(c *caddy.Controller) (Hosts, error) {
 					return h, c.Errf("invalid negative duration for reload '%s'", remaining[0])
 				}
 				options.reload = reload
+			case "files":
+				remaining := c.RemainingArgs()
+				if len(remaining) == 0 {
+					return h, c.ArgErr()
+				}
+				for _, pattern := range remaining {
+					if _, err := filepath.Match(pattern, ""); err != nil {
+						return h, c.Errf("invalid pattern '%s': %v", pattern, err)
+					}
+				}
+				options.files = append(options.files, remaining...)
 			default:
 				if len(h.Fall.Zones) == 0 {
 					line := strings.Join(append([]string{c.Val()}, c.RemainingArgs()...), " ")
@@ -1,6 +1,7 @@
 package hosts
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/coredns/coredns/plugin/pkg/fall"
@@ -168,3 +169,37 @@ func TestHostsInlineParse(t *testing.T) {
 	}
 
 }
+
+func TestHostsFilesParse(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		files     []string
+	}{
+		{`hosts /etc/hosts.d {
+			files *.hosts *.txt
+		}`, false, []string{"*.hosts", "*.txt"}},
+		{`hosts /etc/hosts.d {
+			files
+		}`, true, nil},
+		{`hosts /etc/hosts.d {
+			files [
+		}`, true, nil},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		h, err := hostsParse(c)
+		if err == nil && test.shouldErr {
+			t.Fatalf("Test %d expected errors, but got no error", i)
+		} else if err != nil && !test.shouldErr {
+			t.Fatalf("Test %d expected no errors, but got '%v'", i, err)
+		}
+		if test.shouldErr {
+			continue
+		}
+		if !reflect.DeepEqual(h.options.files, test.files) {
+			t.Errorf("Test %d expected files %v, got %v", i, test.files, h.options.files)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Package https configures the DNS-over-HTTPS server of a server block.
+package https
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+)
+
+func init() {
+	caddy.RegisterPlugin("https", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	h, err := parse(c)
+	if err != nil {
+		return plugin.Error("https", err)
+	}
+	dnsserver.GetConfig(c).HTTPS = h
+	return nil
+}
+
+func parse(c *caddy.Controller) (*dnsserver.HTTPSConfig, error) {
+	h := new(dnsserver.HTTPSConfig)
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		if len(c.RemainingArgs()) > 0 {
+			return nil, c.ArgErr()
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "path":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, p := range args {
+					if !strings.HasPrefix(p, "/") {
+						return nil, fmt.Errorf("path must start with '/': %q", p)
+					}
+				}
+				h.Paths = append(h.Paths, args...)
+			case "json":
+				if len(c.RemainingArgs()) > 0 {
+					return nil, c.ArgErr()
+				}
+				h.JSON = true
+			case "max_request_size":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				n, err := strconv.ParseInt(args[0], 10, 64)
+				if err != nil {
+					return nil, err
+				}
+				if n < dns.MinMsgSize || n > dns.MaxMsgSize {
+					return nil, fmt.Errorf("max_request_size must be between %d and %d: %d", dns.MinMsgSize, dns.MaxMsgSize, n)
+				}
+				h.MaxRequestSize = n
+			case "read_timeout", "write_timeout", "idle_timeout":
+				opt := c.Val()
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(args[0])
+				if err != nil {
+					return nil, err
+				}
+				if d <= 0 {
+					return nil, fmt.Errorf("%s must be positive: %s", opt, d)
+				}
+				switch opt {
+				case "read_timeout":
+					h.ReadTimeout = d
+				case "write_timeout":
+					h.WriteTimeout = d
+				case "idle_timeout":
+					h.IdleTimeout = d
+				}
+			case "max_concurrent_streams":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				n, err := strconv.Atoi(args[0])
+				if err != nil {
+					return nil, err
+				}
+				if n <= 0 {
+					return nil, fmt.Errorf("max_concurrent_streams must be positive: %d", n)
+				}
+				h.MaxConcurrentStreams = n
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	return h, nil
+}
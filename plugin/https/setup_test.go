@@ -0,0 +1,89 @@
+package https
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		paths     []string
+		json      bool
+		size      int64
+		read      time.Duration
+		streams   int
+	}{
+		{`https`, false, nil, false, 0, 0, 0},
+		{`https {
+			path /dns-query /resolve
+			json
+			max_request_size 4096
+			read_timeout 2s
+			max_concurrent_streams 100
+		}`, false, []string{"/dns-query", "/resolve"}, true, 4096, 2 * time.Second, 100},
+		{`https {
+			path /a
+			path /b
+		}`, false, []string{"/a", "/b"}, false, 0, 0, 0},
+		// fails
+		{`https example.org`, true, nil, false, 0, 0, 0},
+		{`https {
+			path
+		}`, true, nil, false, 0, 0, 0},
+		{`https {
+			path dns-query
+		}`, true, nil, false, 0, 0, 0},
+		{`https {
+			max_request_size 100
+		}`, true, nil, false, 0, 0, 0},
+		{`https {
+			max_request_size 70000
+		}`, true, nil, false, 0, 0, 0},
+		{`https {
+			idle_timeout -1s
+		}`, true, nil, false, 0, 0, 0},
+		{`https {
+			max_concurrent_streams 0
+		}`, true, nil, false, 0, 0, 0},
+		{`https {
+			bogus
+		}`, true, nil, false, 0, 0, 0},
+		{`https
+		https`, true, nil, false, 0, 0, 0},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		h, err := parse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(h.Paths, tc.paths) {
+			t.Errorf("Test %d: expected paths %v, got %v", i, tc.paths, h.Paths)
+		}
+		if h.JSON != tc.json {
+			t.Errorf("Test %d: expected json %t, got %t", i, tc.json, h.JSON)
+		}
+		if h.MaxRequestSize != tc.size {
+			t.Errorf("Test %d: expected max_request_size %d, got %d", i, tc.size, h.MaxRequestSize)
+		}
+		if h.ReadTimeout != tc.read {
+			t.Errorf("Test %d: expected read_timeout %s, got %s", i, tc.read, h.ReadTimeout)
+		}
+		if h.MaxConcurrentStreams != tc.streams {
+			t.Errorf("Test %d: expected max_concurrent_streams %d, got %d", i, tc.streams, h.MaxConcurrentStreams)
+		}
+	}
+}
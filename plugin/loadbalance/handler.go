@@ -5,6 +5,7 @@ import (
 	"context"
 
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
 )
@@ -22,3 +23,24 @@ func (rr RoundRobin) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.
 
 // Name implements the Handler interface.
 func (rr RoundRobin) Name() string { return "loadbalance" }
+
+// LoadBalance is the plugin when it does more than round robin: weighted ordering, ordering by
+// the subnet of the client and returning a subset of the addresses.
+type LoadBalance struct {
+	Next plugin.Handler
+
+	policy   string
+	weights  *weights // only with a weights file
+	sortlist []sortRule
+	subset   int
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (lb *LoadBalance) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	lw := &LoadBalanceResponseWriter{ResponseWriter: w, lb: lb, preferred: lb.preferred(state.ClientIP())}
+	return plugin.NextOrFailure(lb.Name(), lb.Next, ctx, lw, r)
+}
+
+// Name implements the Handler interface.
+func (lb *LoadBalance) Name() string { return "loadbalance" }
@@ -2,6 +2,9 @@
 package loadbalance
 
 import (
+	"net"
+	"strings"
+
 	"github.com/miekg/dns"
 )
 
@@ -79,3 +82,62 @@ func (r *RoundRobinResponseWriter) Write(buf []byte) (int, error) {
 	n, err := r.ResponseWriter.Write(buf)
 	return n, err
 }
+
+// LoadBalanceResponseWriter is a response writer that orders the records according to the
+// policy of LoadBalance, the networks preferred for the client, and limits the number of addresses.
+type LoadBalanceResponseWriter struct {
+	dns.ResponseWriter
+	lb        *LoadBalance
+	preferred []*net.IPNet
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (l *LoadBalanceResponseWriter) WriteMsg(res *dns.Msg) error {
+	if res.Rcode != dns.RcodeSuccess {
+		return l.ResponseWriter.WriteMsg(res)
+	}
+
+	if res.Question[0].Qtype == dns.TypeAXFR || res.Question[0].Qtype == dns.TypeIXFR {
+		return l.ResponseWriter.WriteMsg(res)
+	}
+
+	order := roundRobin
+	if l.lb.policy == policyWeighted {
+		order = l.lb.weighted
+	}
+	res.Answer = subset(sortAddresses(order(res.Answer), l.preferred), l.lb.subset)
+	res.Ns = order(res.Ns)
+	res.Extra = sortAddresses(order(res.Extra), l.preferred)
+
+	return l.ResponseWriter.WriteMsg(res)
+}
+
+// Write implements the dns.ResponseWriter interface.
+func (l *LoadBalanceResponseWriter) Write(buf []byte) (int, error) {
+	log.Warning("LoadBalance called with Write: not ordering records")
+	return l.ResponseWriter.Write(buf)
+}
+
+// subset returns in with at most n records of each A and AAAA RRset. If n is 0 in is returned.
+func subset(in []dns.RR, n int) []dns.RR {
+	if n <= 0 {
+		return in
+	}
+	type key struct {
+		name  string
+		qtype uint16
+	}
+	count := map[key]int{}
+	out := in[:0]
+	for _, rr := range in {
+		if address(rr) != nil {
+			k := key{strings.ToLower(rr.Header().Name), rr.Header().Rrtype}
+			if count[k] >= n {
+				continue
+			}
+			count[k]++
+		}
+		out = append(out, rr)
+	}
+	return out
+}
@@ -2,6 +2,11 @@ package loadbalance
 
 import (
 	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
@@ -19,32 +24,197 @@ func init() {
 	})
 }
 
+// Policies of the plugin.
+const (
+	policyRoundRobin = "round_robin"
+	policyWeighted   = "weighted"
+)
+
+// defaultReload is the default interval to check the weights file for changes.
+const defaultReload = 30 * time.Second
+
 func setup(c *caddy.Controller) error {
-	err := parse(c)
+	lb, err := parse(c)
 	if err != nil {
 		return plugin.Error("loadbalance", err)
 	}
 
+	if lb.policy == policyRoundRobin && len(lb.sortlist) == 0 && lb.subset == 0 {
+		dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+			return RoundRobin{Next: next}
+		})
+		return nil
+	}
+
+	if ws := lb.weights; ws != nil && ws.reload > 0 {
+		stop := make(chan struct{})
+		c.OnStartup(func() error {
+			go func() {
+				tick := time.NewTicker(ws.reload)
+				defer tick.Stop()
+				for {
+					select {
+					case <-stop:
+						return
+					case <-tick.C:
+						if err := ws.read(); err != nil {
+							log.Warningf("Failed to reload weights: %s", err)
+						}
+					}
+				}
+			}()
+			return nil
+		})
+		c.OnShutdown(func() error {
+			close(stop)
+			return nil
+		})
+	}
+
 	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
-		return RoundRobin{Next: next}
+		lb.Next = next
+		return lb
 	})
 
 	return nil
 }
 
-func parse(c *caddy.Controller) error {
+func parse(c *caddy.Controller) (*LoadBalance, error) {
+	config := dnsserver.GetConfig(c)
+	lb := &LoadBalance{policy: policyRoundRobin}
+	reload := defaultReload
+
+	i := 0
 	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
 		args := c.RemainingArgs()
-		switch len(args) {
-		case 0:
-			return nil
-		case 1:
-			if args[0] != "round_robin" {
-				return fmt.Errorf("unknown policy: %s", args[0])
+		if len(args) > 0 {
+			switch args[0] {
+			case policyWeighted:
+				if len(args) > 2 {
+					return nil, c.ArgErr()
+				}
+				lb.policy = policyWeighted
+				if len(args) == 2 {
+					path := args[1]
+					if !filepath.IsAbs(path) && config.Root != "" {
+						path = filepath.Join(config.Root, path)
+					}
+					lb.weights = &weights{path: path}
+				}
+			default:
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				if args[0] != policyRoundRobin {
+					return nil, fmt.Errorf("unknown policy: %s", args[0])
+				}
+			}
+		}
 
+		for c.NextBlock() {
+			switch c.Val() {
+			case "reload":
+				if lb.weights == nil {
+					return nil, fmt.Errorf("reload requires a weights file")
+				}
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(c.Val())
+				if err != nil {
+					return nil, fmt.Errorf("invalid reload duration %q: %s", c.Val(), err)
+				}
+				if d < 0 {
+					return nil, fmt.Errorf("reload can not be negative: %s", d)
+				}
+				reload = d
+				if c.NextArg() {
+					return nil, c.ArgErr()
+				}
+			case "subset":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				n, err := strconv.Atoi(c.Val())
+				if err != nil || n <= 0 {
+					return nil, fmt.Errorf("invalid subset size %q", c.Val())
+				}
+				lb.subset = n
+				if c.NextArg() {
+					return nil, c.ArgErr()
+				}
+			case "sortlist":
+				r, err := parseSortRule(c.RemainingArgs())
+				if err != nil {
+					return nil, err
+				}
+				lb.sortlist = append(lb.sortlist, r)
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
 			}
-			return nil
 		}
 	}
-	return c.ArgErr()
+	if i == 0 {
+		return nil, c.ArgErr()
+	}
+
+	if lb.weights != nil {
+		lb.weights.reload = reload
+		if err := lb.weights.read(); err != nil {
+			return nil, err
+		}
+	}
+	return lb, nil
+}
+
+// parseSortRule parses the arguments of sortlist: nothing, to prefer the client's own subnet, or
+// the networks of the clients followed by the preferred networks.
+func parseSortRule(args []string) (sortRule, error) {
+	r := sortRule{}
+	if len(args) == 0 {
+		return r, nil
+	}
+	if len(args) == 1 {
+		return r, fmt.Errorf("sortlist needs the client network and at least one preferred network")
+	}
+	if args[0] != "any" {
+		n, err := parseNet(args[0])
+		if err != nil {
+			return r, err
+		}
+		r.client = n
+	}
+	for _, a := range args[1:] {
+		n, err := parseNet(a)
+		if err != nil {
+			return r, err
+		}
+		r.preferred = append(r.preferred, n)
+	}
+	return r, nil
+}
+
+// parseNet parses a network in CIDR notation or a single address.
+func parseNet(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid network %q", s)
+		}
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid network %q", s)
+	}
+	return n, nil
 }
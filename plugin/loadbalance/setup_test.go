@@ -1,13 +1,27 @@
 package loadbalance
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mholt/caddy"
 )
 
 func TestSetup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loadbalance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	weightsFile := filepath.Join(dir, "weights")
+	if err := ioutil.WriteFile(weightsFile, []byte("www.example.org\n192.0.2.1 10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
 	tests := []struct {
 		input              string
 		shouldErr          bool
@@ -17,14 +31,47 @@ func TestSetup(t *testing.T) {
 		// positive
 		{`loadbalance`, false, "round_robin", ""},
 		{`loadbalance round_robin`, false, "round_robin", ""},
+		{`loadbalance weighted`, false, "weighted", ""},
+		{`loadbalance weighted ` + weightsFile, false, "weighted", ""},
+		{`loadbalance weighted ` + weightsFile + ` {
+			reload 10s
+		}`, false, "weighted", ""},
+		{`loadbalance {
+			subset 2
+			sortlist
+			sortlist 10.0.0.0/8 10.1.0.0/16 10.0.0.0/8
+			sortlist any 192.0.2.1 2001:db8::/32
+		}`, false, "round_robin", ""},
 		// negative
 		{`loadbalance fleeb`, true, "", "unknown policy"},
 		{`loadbalance a b`, true, "", "argument count or unexpected line"},
+		{`loadbalance weighted a b`, true, "", "argument count or unexpected line"},
+		{`loadbalance weighted /does/not/exist`, true, "", "no such file"},
+		{`loadbalance {
+			reload 10s
+		}`, true, "", "requires a weights file"},
+		{`loadbalance weighted ` + weightsFile + ` {
+			reload -1s
+		}`, true, "", "negative"},
+		{`loadbalance {
+			subset 0
+		}`, true, "", "invalid subset"},
+		{`loadbalance {
+			sortlist 10.0.0.0/8
+		}`, true, "", "preferred network"},
+		{`loadbalance {
+			sortlist any 10.0.0.0/33
+		}`, true, "", "invalid network"},
+		{`loadbalance {
+			blaat
+		}`, true, "", "unknown property"},
+		{`loadbalance
+		loadbalance`, true, "", "this plugin"},
 	}
 
 	for i, test := range tests {
 		c := caddy.NewTestController("dns", test.input)
-		err := parse(c)
+		lb, err := parse(c)
 
 		if test.shouldErr && err == nil {
 			t.Errorf("Test %d: Expected error but found %s for input %s", i, err, test.input)
@@ -38,6 +85,61 @@ func TestSetup(t *testing.T) {
 			if !strings.Contains(err.Error(), test.expectedErrContent) {
 				t.Errorf("Test %d: Expected error to contain: %v, found error: %v, input: %s", i, test.expectedErrContent, err, test.input)
 			}
+			continue
 		}
+
+		if lb.policy != test.expectedPolicy {
+			t.Errorf("Test %d: Expected policy %s, got %s", i, test.expectedPolicy, lb.policy)
+		}
+	}
+}
+
+func TestSetupWeightsReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loadbalance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	weightsFile := filepath.Join(dir, "weights")
+	if err := ioutil.WriteFile(weightsFile, []byte("www.example.org\n192.0.2.1 10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := caddy.NewTestController("dns", `loadbalance weighted `+weightsFile)
+	lb, err := parse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if lb.weights.reload != defaultReload {
+		t.Errorf("Expected reload %s, got %s", defaultReload, lb.weights.reload)
+	}
+	if w := lb.weights.lookup("WWW.example.org."); w["192.0.2.1"] != 10 {
+		t.Errorf("Expected weight 10, got %v", w)
+	}
+
+	if err := ioutil.WriteFile(weightsFile, []byte("www.example.org\n192.0.2.1 20\n192.0.2.2 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Make sure the modification time differs.
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(weightsFile, future, future)
+	if err := lb.weights.read(); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if w := lb.weights.lookup("www.example.org."); w["192.0.2.1"] != 20 || len(w) != 2 {
+		t.Errorf("Expected reloaded weights, got %v", w)
+	}
+
+	// A broken file keeps the current weights.
+	if err := ioutil.WriteFile(weightsFile, []byte("192.0.2.1 20\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	future = future.Add(time.Minute)
+	os.Chtimes(weightsFile, future, future)
+	if err := lb.weights.read(); err == nil {
+		t.Errorf("Expected error for broken weights file")
+	}
+	if w := lb.weights.lookup("www.example.org."); w["192.0.2.1"] != 20 {
+		t.Errorf("Expected current weights to be kept, got %v", w)
 	}
 }
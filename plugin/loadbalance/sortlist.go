@@ -0,0 +1,73 @@
+package loadbalance
+
+import (
+	"net"
+	"sort"
+
+	"github.com/miekg/dns"
+)
+
+// sortRule prefers the addresses in the networks of preferred for clients in client. A nil client
+// matches every client. Without preferred networks, the client's own subnet is preferred.
+type sortRule struct {
+	client    *net.IPNet
+	preferred []*net.IPNet
+}
+
+// Prefix lengths of the client's own subnet.
+const (
+	subnetV4 = 24
+	subnetV6 = 64
+)
+
+// preferred returns the networks preferred for client, or nil if no rule matches it.
+func (lb *LoadBalance) preferred(client net.IP) []*net.IPNet {
+	if client == nil {
+		return nil
+	}
+	for _, r := range lb.sortlist {
+		if r.client != nil && !r.client.Contains(client) {
+			continue
+		}
+		if len(r.preferred) > 0 {
+			return r.preferred
+		}
+		bits, size := subnetV6, 8*net.IPv6len
+		if client.To4() != nil {
+			client, bits, size = client.To4(), subnetV4, 8*net.IPv4len
+		}
+		mask := net.CIDRMask(bits, size)
+		return []*net.IPNet{{IP: client.Mask(mask), Mask: mask}}
+	}
+	return nil
+}
+
+// sortAddresses moves the addresses in the first network of nets to the front of in, followed by
+// those in the second network and so on. The order of other records is kept.
+func sortAddresses(in []dns.RR, nets []*net.IPNet) []dns.RR {
+	if len(nets) == 0 {
+		return in
+	}
+	pos := []int{}
+	addrs := []dns.RR{}
+	for i, rr := range in {
+		if ip := address(rr); ip != nil {
+			pos = append(pos, i)
+			addrs = append(addrs, rr)
+		}
+	}
+	rank := func(rr dns.RR) int {
+		ip := address(rr)
+		for i, n := range nets {
+			if n.Contains(ip) {
+				return i
+			}
+		}
+		return len(nets)
+	}
+	sort.SliceStable(addrs, func(i, j int) bool { return rank(addrs[i]) < rank(addrs[j]) })
+	for i, p := range pos {
+		in[p] = addrs[i]
+	}
+	return in
+}
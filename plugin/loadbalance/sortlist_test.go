@@ -0,0 +1,72 @@
+package loadbalance
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestSortlist(t *testing.T) {
+	rule, err := parseSortRule([]string{"10.0.0.0/8", "192.0.2.0/24", "198.51.100.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lb := &LoadBalance{
+		policy:   policyRoundRobin,
+		sortlist: []sortRule{rule, {}},
+		Next:     handler(),
+	}
+
+	answer := []dns.RR{
+		test.A("www.example.org.	300	IN	A	203.0.113.1"),
+		test.A("www.example.org.	300	IN	A	198.51.100.1"),
+		test.A("www.example.org.	300	IN	A	192.0.2.1"),
+		test.A("www.example.org.	300	IN	A	10.240.0.1"),
+	}
+
+	tests := []struct {
+		remote string // address of the client
+		ecs    string // address in the EDNS0 client subnet option, if not empty
+		first  []string
+	}{
+		{"10.1.1.1", "", []string{"192.0.2.1", "198.51.100.1"}}, // preferred networks
+		{"203.0.113.200", "", []string{"203.0.113.1"}},          // the client's subnet
+		{"192.0.2.200", "10.1.1.1", []string{"192.0.2.1", "198.51.100.1"}},
+		{"10.1.1.1", "203.0.113.200", []string{"203.0.113.1"}},
+		{"2001:db8::1", "", nil}, // nothing in the client's subnet
+	}
+
+	for i, tc := range tests {
+		rec := dnstest.NewRecorder(&test.ResponseWriter{RemoteIP: tc.remote})
+		req := new(dns.Msg)
+		req.SetQuestion("www.example.org.", dns.TypeA)
+		if tc.ecs != "" {
+			ip := net.ParseIP(tc.ecs)
+			family, mask := uint16(1), uint8(32)
+			if ip.To4() == nil {
+				family, mask = 2, 128
+			}
+			req.SetEdns0(4096, false)
+			opt := req.IsEdns0()
+			opt.Option = append(opt.Option, &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: family, SourceNetmask: mask, Address: ip})
+		}
+		req.Answer = append([]dns.RR{}, answer...)
+
+		if _, err := lb.ServeDNS(context.TODO(), rec, req); err != nil {
+			t.Fatalf("Test %d: expected no error, got %s", i, err)
+		}
+		if len(rec.Msg.Answer) != len(answer) {
+			t.Fatalf("Test %d: expected %d records, got %d", i, len(answer), len(rec.Msg.Answer))
+		}
+		for j, f := range tc.first {
+			if got := address(rec.Msg.Answer[j]).String(); got != f {
+				t.Errorf("Test %d: expected %s at %d, got %s", i, f, j, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package loadbalance
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// weights holds the weights of the addresses of names, read from a weights file.
+type weights struct {
+	path   string
+	reload time.Duration
+
+	sync.RWMutex
+	w map[string]map[string]int // name to address to weight
+
+	// Only read and written by a single goroutine.
+	mtime time.Time
+	size  int64
+}
+
+// read reads the weights file if it changed since the last read.
+func (ws *weights) read() error {
+	info, err := os.Stat(ws.path)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(ws.mtime) && info.Size() == ws.size {
+		return nil
+	}
+	f, err := os.Open(ws.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w, err := parseWeights(f)
+	if err != nil {
+		return fmt.Errorf("weights file %q: %s", ws.path, err)
+	}
+	ws.Lock()
+	ws.w = w
+	ws.Unlock()
+	ws.mtime, ws.size = info.ModTime(), info.Size()
+	log.Debugf("Read weights of %d names from %q", len(w), ws.path)
+	return nil
+}
+
+// lookup returns the weights of the addresses of name, or nil if the file has none for it.
+func (ws *weights) lookup(name string) map[string]int {
+	ws.RLock()
+	defer ws.RUnlock()
+	return ws.w[strings.ToLower(name)]
+}
+
+// parseWeights parses a weights file. A line with a single name is followed by lines with an
+// address and its weight, between 0 and 255. Empty lines and comments, starting with '#', are
+// skipped.
+func parseWeights(r io.Reader) (map[string]map[string]int, error) {
+	w := map[string]map[string]int{}
+	var current map[string]int
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		switch len(fields) {
+		case 0:
+			continue
+		case 1:
+			if _, ok := dns.IsDomainName(fields[0]); !ok || net.ParseIP(fields[0]) != nil {
+				return nil, fmt.Errorf("line %d: invalid name %q", line, fields[0])
+			}
+			name := strings.ToLower(dns.Fqdn(fields[0]))
+			if _, ok := w[name]; ok {
+				return nil, fmt.Errorf("line %d: name %q is listed more than once", line, name)
+			}
+			current = map[string]int{}
+			w[name] = current
+		case 2:
+			if current == nil {
+				return nil, fmt.Errorf("line %d: address without a name", line)
+			}
+			ip := net.ParseIP(fields[0])
+			if ip == nil {
+				return nil, fmt.Errorf("line %d: invalid address %q", line, fields[0])
+			}
+			weight, err := strconv.Atoi(fields[1])
+			if err != nil || weight < 0 || weight > 255 {
+				return nil, fmt.Errorf("line %d: invalid weight %q", line, fields[1])
+			}
+			current[ip.String()] = weight
+		default:
+			return nil, fmt.Errorf("line %d: too many fields", line)
+		}
+	}
+	return w, scanner.Err()
+}
+
+// weighted orders the records in in: addresses by the weights of their name, or else shuffled
+// like roundRobin, SRV records by priority and weight (RFC 2782) and MX records are shuffled.
+// CNAMEs are put first.
+func (lb *LoadBalance) weighted(in []dns.RR) []dns.RR {
+	cname := []dns.RR{}
+	srv := []dns.RR{}
+	mx := []dns.RR{}
+	rest := []dns.RR{}
+
+	owners := []string{}
+	address := map[string][]dns.RR{}
+	for _, r := range in {
+		switch r.Header().Rrtype {
+		case dns.TypeCNAME:
+			cname = append(cname, r)
+		case dns.TypeA, dns.TypeAAAA:
+			name := strings.ToLower(r.Header().Name)
+			if _, ok := address[name]; !ok {
+				owners = append(owners, name)
+			}
+			address[name] = append(address[name], r)
+		case dns.TypeSRV:
+			srv = append(srv, r)
+		case dns.TypeMX:
+			mx = append(mx, r)
+		default:
+			rest = append(rest, r)
+		}
+	}
+
+	roundRobinShuffle(mx)
+
+	out := append(cname, rest...)
+	out = append(out, orderSRV(srv)...)
+	for _, name := range owners {
+		var w map[string]int
+		if lb.weights != nil {
+			w = lb.weights.lookup(name)
+		}
+		if w == nil {
+			roundRobinShuffle(address[name])
+			out = append(out, address[name]...)
+			continue
+		}
+		out = append(out, orderAddresses(address[name], w)...)
+	}
+	out = append(out, mx...)
+	return out
+}
+
+// orderAddresses orders the address records by weighted random selection without replacement.
+// Addresses with weight 0 are put last. Addresses that aren't in w get weight 1.
+func orderAddresses(rrs []dns.RR, w map[string]int) []dns.RR {
+	weight := make([]int, len(rrs))
+	for i, rr := range rrs {
+		weight[i] = 1
+		if x, ok := w[address(rr).String()]; ok {
+			weight[i] = x
+		}
+	}
+	out := make([]dns.RR, 0, len(rrs))
+	out = append(out, pick(rrs, weight)...)
+	for i, rr := range rrs {
+		if weight[i] == 0 {
+			out = append(out, rr)
+		}
+	}
+	return out
+}
+
+// pick returns the records with a positive weight, repeatedly selecting one at random with a
+// probability proportional to its weight.
+func pick(rrs []dns.RR, weight []int) []dns.RR {
+	left := []int{}
+	total := 0
+	for i := range rrs {
+		if weight[i] > 0 {
+			left = append(left, i)
+			total += weight[i]
+		}
+	}
+	out := make([]dns.RR, 0, len(left))
+	for len(left) > 0 {
+		n := rand.Intn(total)
+		for j, i := range left {
+			if n < weight[i] {
+				out = append(out, rrs[i])
+				total -= weight[i]
+				left = append(left[:j], left[j+1:]...)
+				break
+			}
+			n -= weight[i]
+		}
+	}
+	return out
+}
+
+// orderSRV orders SRV records by priority, and records with the same priority by the weighted
+// selection of RFC 2782.
+func orderSRV(rrs []dns.RR) []dns.RR {
+	if len(rrs) < 2 {
+		return rrs
+	}
+	sort.SliceStable(rrs, func(i, j int) bool { return rrs[i].(*dns.SRV).Priority < rrs[j].(*dns.SRV).Priority })
+
+	out := make([]dns.RR, 0, len(rrs))
+	for i := 0; i < len(rrs); {
+		j := i
+		for j < len(rrs) && rrs[j].(*dns.SRV).Priority == rrs[i].(*dns.SRV).Priority {
+			j++
+		}
+		out = append(out, selectSRV(rrs[i:j])...)
+		i = j
+	}
+	return out
+}
+
+// selectSRV orders SRV records of the same priority as described in RFC 2782: records with weight
+// 0 go first, then a record is repeatedly selected at random using the running sum of the weights.
+func selectSRV(rrs []dns.RR) []dns.RR {
+	left := make([]dns.RR, 0, len(rrs))
+	for _, rr := range rrs {
+		if rr.(*dns.SRV).Weight == 0 {
+			left = append(left, rr)
+		}
+	}
+	for _, rr := range rrs {
+		if rr.(*dns.SRV).Weight > 0 {
+			left = append(left, rr)
+		}
+	}
+
+	out := make([]dns.RR, 0, len(rrs))
+	for len(left) > 0 {
+		total := 0
+		for _, rr := range left {
+			total += int(rr.(*dns.SRV).Weight)
+		}
+		n := rand.Intn(total + 1)
+		sum := 0
+		for j, rr := range left {
+			sum += int(rr.(*dns.SRV).Weight)
+			if sum >= n {
+				out = append(out, rr)
+				left = append(left[:j], left[j+1:]...)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// address returns the address of an A or AAAA record.
+func address(rr dns.RR) net.IP {
+	switch x := rr.(type) {
+	case *dns.A:
+		return x.A
+	case *dns.AAAA:
+		return x.AAAA
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package loadbalance
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestParseWeights(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		names     int
+	}{
+		{"", false, 0},
+		{`# comment
+www.example.org
+192.0.2.1 100 # primary
+192.0.2.2 0
+
+WWW.example.net.
+2001:db8::1 1
+`, false, 2},
+		{"192.0.2.1 1", true, 0},
+		{"www.example.org\n192.0.2.1", true, 0}, // an address is not a name
+		{"www.example.org\n192.0.2.1 256", true, 0},
+		{"www.example.org\n192.0.2.1 -1", true, 0},
+		{"www.example.org\nexample 1", true, 0},
+		{"www.example.org\n192.0.2.1 1 2", true, 0},
+		{"www.example.org\nwww.example.org", true, 0},
+	}
+
+	for i, tc := range tests {
+		w, err := parseWeights(strings.NewReader(tc.input))
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if len(w) != tc.names {
+			t.Errorf("Test %d: expected %d names, got %d", i, tc.names, len(w))
+		}
+	}
+}
+
+func TestWeighted(t *testing.T) {
+	lb := &LoadBalance{
+		policy: policyWeighted,
+		weights: &weights{w: map[string]map[string]int{
+			"www.example.org.": {"192.0.2.1": 0, "192.0.2.2": 5, "2001:db8::1": 0},
+		}},
+		Next: handler(),
+	}
+
+	answer := []dns.RR{
+		test.A("www.example.org.	300	IN	A	192.0.2.1"),
+		test.A("www.example.org.	300	IN	A	192.0.2.2"),
+		test.AAAA("www.example.org.	300	IN	AAAA	2001:db8::1"),
+		test.CNAME("example.org.	300	IN	CNAME	www.example.org."),
+	}
+
+	for i := 0; i < 20; i++ {
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		req := new(dns.Msg)
+		req.SetQuestion("example.org.", dns.TypeA)
+		req.Answer = append([]dns.RR{}, answer...)
+		if _, err := lb.ServeDNS(context.TODO(), rec, req); err != nil {
+			t.Fatalf("Expected no error, got %s", err)
+		}
+		got := rec.Msg.Answer
+		if len(got) != 4 {
+			t.Fatalf("Expected 4 records, got %d", len(got))
+		}
+		if got[0].Header().Rrtype != dns.TypeCNAME {
+			t.Errorf("Expected CNAME first, got %s", got[0])
+		}
+		// The only address with a positive weight is always first.
+		if address(got[1]).String() != "192.0.2.2" {
+			t.Errorf("Expected 192.0.2.2 first, got %s", got[1])
+		}
+	}
+}
+
+func TestOrderAddresses(t *testing.T) {
+	rrs := []dns.RR{
+		test.A("www.example.org.	300	IN	A	192.0.2.1"),
+		test.A("www.example.org.	300	IN	A	192.0.2.2"),
+		test.A("www.example.org.	300	IN	A	192.0.2.3"),
+	}
+	w := map[string]int{"192.0.2.1": 1, "192.0.2.2": 99}
+
+	first := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		out := orderAddresses(append([]dns.RR{}, rrs...), w)
+		if len(out) != 3 {
+			t.Fatalf("Expected 3 records, got %d", len(out))
+		}
+		first[address(out[0]).String()]++
+	}
+	// 192.0.2.2 has 99 of the 101 weight, it must be first most of the time.
+	if first["192.0.2.2"] < 900 {
+		t.Errorf("Expected 192.0.2.2 first in most responses, got %v", first)
+	}
+}
+
+func TestOrderSRV(t *testing.T) {
+	rrs := []dns.RR{
+		test.SRV("_http._tcp.example.org.	300	IN	SRV	20 0 80 c.example.org."),
+		test.SRV("_http._tcp.example.org.	300	IN	SRV	10 0 80 a.example.org."),
+		test.SRV("_http._tcp.example.org.	300	IN	SRV	10 100 80 b.example.org."),
+		test.SRV("_http._tcp.example.org.	300	IN	SRV	30 1 80 d.example.org."),
+	}
+
+	first := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		out := orderSRV(append([]dns.RR{}, rrs...))
+		if len(out) != 4 {
+			t.Fatalf("Expected 4 records, got %d", len(out))
+		}
+		for j, p := range []uint16{10, 10, 20, 30} {
+			if prio := out[j].(*dns.SRV).Priority; prio != p {
+				t.Fatalf("Expected priority %d at %d, got %d", p, j, prio)
+			}
+		}
+		first[out[0].(*dns.SRV).Target]++
+	}
+	// The weight 0 record has a small chance to be selected first.
+	if first["b.example.org."] < 900 {
+		t.Errorf("Expected b.example.org. first in most responses, got %v", first)
+	}
+}
+
+func TestSubset(t *testing.T) {
+	in := []dns.RR{
+		test.CNAME("example.org.	300	IN	CNAME	www.example.org."),
+		test.A("www.example.org.	300	IN	A	192.0.2.1"),
+		test.A("www.example.org.	300	IN	A	192.0.2.2"),
+		test.A("www.example.org.	300	IN	A	192.0.2.3"),
+		test.AAAA("www.example.org.	300	IN	AAAA	2001:db8::1"),
+		test.AAAA("www.example.org.	300	IN	AAAA	2001:db8::2"),
+		test.MX("example.org.	300	IN	MX	1 mx1.example.org."),
+		test.MX("example.org.	300	IN	MX	2 mx2.example.org."),
+	}
+
+	out := subset(append([]dns.RR{}, in...), 0)
+	if len(out) != len(in) {
+		t.Errorf("Expected all %d records, got %d", len(in), len(out))
+	}
+	out = subset(append([]dns.RR{}, in...), 1)
+	if len(out) != 5 {
+		t.Errorf("Expected 5 records, got %d", len(out))
+	}
+	if address(out[1]).String() != "192.0.2.1" || address(out[2]).String() != "2001:db8::1" {
+		t.Errorf("Expected the first address of each RRset, got %v", out)
+	}
+}
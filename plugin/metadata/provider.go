@@ -56,19 +56,21 @@ type Provider interface {
 // Func is the type of function in the metadata, when called they return the value of the label.
 type Func func() string
 
-// IsLabel checks that the provided name is a valid label name, i.e. two words separated by a slash.
+// IsLabel checks that the provided name is a valid label name, i.e. two or more words separated by
+// slashes, like plugin/LABEL or plugin/LABEL/SUB-LABEL.
 func IsLabel(label string) bool {
 	p := strings.Index(label, "/")
-	if p <= 0 || p >= len(label)-1 {
+	if p <= 0 {
 		// cannot accept namespace empty nor label empty
 		return false
 	}
-	if strings.LastIndex(label, "/") != p {
-		// several slash in the Label
-		return false
+	for _, s := range strings.Split(label[p+1:], "/") {
+		if s == "" {
+			// cannot accept label empty
+			return false
+		}
 	}
 	return true
-
 }
 
 // Labels returns all metadata keys stored in the context. These label names should be named
@@ -2,6 +2,9 @@ package metrics
 
 import (
 	"context"
+	"net"
+	"strconv"
+	"strings"
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/metrics/vars"
@@ -17,19 +20,63 @@ func (m *Metrics) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 	state := request.Request{W: w, Req: r}
 
 	qname := state.QName()
-	zone := plugin.Zones(m.ZoneNames()).Matches(qname)
+	zone := plugin.Zones(m.detailZones).Matches(qname)
+	if zone == "" {
+		zone = plugin.Zones(m.ZoneNames()).Matches(qname)
+	}
 	if zone == "" {
 		zone = "."
 	}
 
+	if m.labelPlugin {
+		ctx = plugin.WithWriter(ctx)
+	}
+
 	// Record response to get status code and size of the reply.
 	rw := dnstest.NewRecorder(w)
 	status, err := plugin.NextOrFailure(m.Name(), m.Next, ctx, rw, r)
 
-	vars.Report(WithServer(ctx), state, zone, rcode.ToString(rw.Rcode), rw.Len, rw.Start)
+	server := WithServer(ctx)
+	rc := rcode.ToString(rw.Rcode)
+	vars.Report(server, state, zone, rc, rw.Len, rw.Start)
+
+	if m.detail() {
+		pl, client, transport := "", "", ""
+		if m.labelPlugin {
+			pl = plugin.Writer(ctx)
+		}
+		if m.labelClient {
+			client = m.clientSubnet(state)
+		}
+		if m.labelTransport {
+			transport = transportOf(server)
+		}
+		vars.ReportDetail(server, zone, rc, pl, client, transport)
+	}
 
 	return status, err
 }
 
 // Name implements the Handler interface.
 func (m *Metrics) Name() string { return "prometheus" }
+
+// clientSubnet returns the network of the client address masked with the configured prefix length.
+func (m *Metrics) clientSubnet(state request.Request) string {
+	ip := net.ParseIP(state.IP())
+	if ip == nil {
+		return ""
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(m.v4Prefix, 32)).String() + "/" + strconv.Itoa(m.v4Prefix)
+	}
+	return ip.Mask(net.CIDRMask(m.v6Prefix, 128)).String() + "/" + strconv.Itoa(m.v6Prefix)
+}
+
+// transportOf returns the scheme of the server address, i.e. "dns" for "dns://:53".
+func transportOf(server string) string {
+	i := strings.Index(server, "://")
+	if i < 0 {
+		return ""
+	}
+	return server[:i]
+}
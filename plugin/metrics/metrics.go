@@ -30,14 +30,27 @@ type Metrics struct {
 	zoneNames []string
 	zoneMap   map[string]struct{}
 	zoneMu    sync.RWMutex
+
+	// Optional labels for coredns_dns_response_detail_count_total.
+	labelPlugin    bool
+	labelClient    bool
+	labelTransport bool
+	v4Prefix       int
+	v6Prefix       int
+
+	// detailZones is the allow-list of zones that get their own zone label; queries for
+	// other names are reported under the server block's zone.
+	detailZones []string
 }
 
 // New returns a new instance of Metrics with the given address.
 func New(addr string) *Metrics {
 	met := &Metrics{
-		Addr:    addr,
-		Reg:     prometheus.NewRegistry(),
-		zoneMap: make(map[string]struct{}),
+		Addr:     addr,
+		Reg:      prometheus.NewRegistry(),
+		zoneMap:  make(map[string]struct{}),
+		v4Prefix: defaultV4Prefix,
+		v6Prefix: defaultV6Prefix,
 	}
 	// Add the default collectors
 	met.MustRegister(prometheus.NewGoCollector())
@@ -53,6 +66,7 @@ func New(addr string) *Metrics {
 	met.MustRegister(vars.RequestType)
 	met.MustRegister(vars.ResponseSize)
 	met.MustRegister(vars.ResponseRcode)
+	met.MustRegister(vars.ResponseDetail)
 	met.MustRegister(vars.PluginEnabled)
 
 	return met
@@ -93,6 +107,9 @@ func (m *Metrics) ZoneNames() []string {
 	return s
 }
 
+// detail returns true when any of the optional labels is enabled.
+func (m *Metrics) detail() bool { return m.labelPlugin || m.labelClient || m.labelTransport }
+
 // OnStartup sets up the metrics on startup.
 func (m *Metrics) OnStartup() error {
 	ln, err := net.Listen("tcp", m.Addr)
@@ -162,6 +179,12 @@ var ListenAddr string
 // before erroring when it tries to close the metrics server
 const shutdownTimeout time.Duration = time.Second * 5
 
+// Default prefix lengths used to bucket client addresses.
+const (
+	defaultV4Prefix = 24
+	defaultV6Prefix = 56
+)
+
 var buildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 	Namespace: plugin.Namespace,
 	Name:      "build_info",
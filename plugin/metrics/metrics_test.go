@@ -80,3 +80,37 @@ func TestMetrics(t *testing.T) {
 		}
 	}
 }
+
+func TestMetricsDetail(t *testing.T) {
+	met := New("localhost:0")
+	if err := met.OnStartup(); err != nil {
+		t.Fatalf("Failed to start metrics handler: %s", err)
+	}
+	defer met.OnFinalShutdown()
+
+	met.AddZone(".")
+	met.detailZones = []string{"example.net."}
+	met.labelPlugin = true
+	met.labelClient = true
+	met.Next = test.ErrorHandler()
+
+	req := new(dns.Msg)
+	req.SetQuestion("a.example.net.", dns.TypeA)
+
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := met.ServeDNS(context.TODO(), rec, req); err != nil {
+		t.Fatalf("Expected no error, but got %s", err)
+	}
+
+	result := test.Scrape("http://" + ListenAddr + "/metrics")
+	got, labels := test.MetricValueLabel("coredns_dns_response_detail_count_total", "10.240.0.0/24", result)
+	if got != "1" {
+		t.Fatalf("Expected value 1 for detail metric, but got %q", got)
+	}
+	expected := map[string]string{"zone": "example.net.", "rcode": "SERVFAIL", "plugin": "handlerfunc", "client": "10.240.0.0/24", "transport": ""}
+	for k, v := range expected {
+		if labels[k] != v {
+			t.Errorf("Expected label %s to be %q, got %q", k, v, labels[k])
+		}
+	}
+}
@@ -3,6 +3,7 @@ package metrics
 import (
 	"net"
 	"runtime"
+	"strconv"
 
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/coremain"
@@ -102,9 +103,71 @@ func parse(c *caddy.Controller) (*Metrics, error) {
 		default:
 			return met, c.ArgErr()
 		}
+
+		for c.NextBlock() {
+			if err := parseBlock(c, met); err != nil {
+				return met, err
+			}
+		}
 	}
 	return met, nil
 }
 
+func parseBlock(c *caddy.Controller, met *Metrics) error {
+	switch c.Val() {
+	case "labels":
+		args := c.RemainingArgs()
+		if len(args) == 0 {
+			return c.ArgErr()
+		}
+		for _, a := range args {
+			switch a {
+			case "plugin":
+				met.labelPlugin = true
+			case "client":
+				met.labelClient = true
+			case "transport":
+				met.labelTransport = true
+			default:
+				return c.Errf("unknown label: %q", a)
+			}
+		}
+	case "client_prefix":
+		args := c.RemainingArgs()
+		if len(args) == 0 || len(args) > 2 {
+			return c.ArgErr()
+		}
+		v4, err := strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+		if v4 < 0 || v4 > 32 {
+			return c.Errf("invalid IPv4 prefix length: %d", v4)
+		}
+		met.v4Prefix = v4
+		if len(args) == 2 {
+			v6, err := strconv.Atoi(args[1])
+			if err != nil {
+				return err
+			}
+			if v6 < 0 || v6 > 128 {
+				return c.Errf("invalid IPv6 prefix length: %d", v6)
+			}
+			met.v6Prefix = v6
+		}
+	case "zones":
+		args := c.RemainingArgs()
+		if len(args) == 0 {
+			return c.ArgErr()
+		}
+		for _, z := range args {
+			met.detailZones = append(met.detailZones, plugin.Host(z).Normalize())
+		}
+	default:
+		return c.Errf("unknown property: %q", c.Val())
+	}
+	return nil
+}
+
 // defaultAddr is the address the where the metrics are exported by default.
 const defaultAddr = "localhost:9153"
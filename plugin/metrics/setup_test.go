@@ -40,3 +40,76 @@ func TestPrometheusParse(t *testing.T) {
 		}
 	}
 }
+
+func TestPrometheusParseBlock(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		plugin    bool
+		client    bool
+		transport bool
+		v4, v6    int
+		zones     []string
+	}{
+		// oks
+		{`prometheus {
+			labels plugin client
+		}`, false, true, true, false, 24, 56, nil},
+		{`prometheus localhost:53 {
+			labels transport
+			client_prefix 16 48
+			zones example.org example.net.
+		}`, false, false, false, true, 16, 48, []string{"example.org.", "example.net."}},
+		{`prometheus {
+			client_prefix 8
+		}`, false, false, false, false, 8, 56, nil},
+		// fails
+		{`prometheus {
+			labels
+		}`, true, false, false, false, 0, 0, nil},
+		{`prometheus {
+			labels qname
+		}`, true, false, false, false, 0, 0, nil},
+		{`prometheus {
+			client_prefix 33
+		}`, true, false, false, false, 0, 0, nil},
+		{`prometheus {
+			client_prefix 24 129
+		}`, true, false, false, false, 0, 0, nil},
+		{`prometheus {
+			zones
+		}`, true, false, false, false, 0, 0, nil},
+		{`prometheus {
+			blah
+		}`, true, false, false, false, 0, 0, nil},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		m, err := parse(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %v: Expected error but found nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if m.labelPlugin != test.plugin || m.labelClient != test.client || m.labelTransport != test.transport {
+			t.Errorf("Test %v: Unexpected labels: plugin %t, client %t, transport %t", i, m.labelPlugin, m.labelClient, m.labelTransport)
+		}
+		if m.v4Prefix != test.v4 || m.v6Prefix != test.v6 {
+			t.Errorf("Test %v: Expected prefixes %d/%d, got %d/%d", i, test.v4, test.v6, m.v4Prefix, m.v6Prefix)
+		}
+		if len(m.detailZones) != len(test.zones) {
+			t.Errorf("Test %v: Expected zones %v, got %v", i, test.zones, m.detailZones)
+			continue
+		}
+		for j := range test.zones {
+			if m.detailZones[j] != test.zones[j] {
+				t.Errorf("Test %v: Expected zones %v, got %v", i, test.zones, m.detailZones)
+			}
+		}
+	}
+}
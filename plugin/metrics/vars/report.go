@@ -39,6 +39,12 @@ func Report(server string, req request.Request, zone, rcode string, size int, st
 	ResponseRcode.WithLabelValues(server, zone, rcode).Inc()
 }
 
+// ReportDetail reports the response with the extra labels plugin, client and transport. Labels
+// that are not enabled should be given as the empty string, so they are not exported.
+func ReportDetail(server, zone, rcode, plugin, client, transport string) {
+	ResponseDetail.WithLabelValues(server, zone, rcode, plugin, client, transport).Inc()
+}
+
 var monitorType = map[uint16]struct{}{
 	dns.TypeAAAA:   {},
 	dns.TypeA:      {},
@@ -60,6 +60,13 @@ var (
 		Help:      "Counter of response status codes.",
 	}, []string{"server", "zone", "rcode"})
 
+	ResponseDetail = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: subsystem,
+		Name:      "response_detail_count_total",
+		Help:      "Counter of responses per zone and rcode, with optional plugin, client and transport labels.",
+	}, []string{"server", "zone", "rcode", "plugin", "client", "transport"})
+
 	Panic = prometheus.NewCounter(prometheus.CounterOpts{
 		Namespace: plugin.Namespace,
 		Name:      "panic_count_total",
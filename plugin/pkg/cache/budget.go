@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Budget limits the number of bytes held by the caches that share it. When it is exceeded,
+// elements are evicted from the cache that holds the most bytes.
+type Budget struct {
+	max  int64
+	used int64 // atomic
+
+	mu     sync.RWMutex
+	caches []*Cache
+}
+
+// NewBudget returns a budget of max bytes.
+func NewBudget(max int64) *Budget { return &Budget{max: max} }
+
+// Max returns the number of bytes of the budget.
+func (b *Budget) Max() int64 { return b.max }
+
+// Used returns the number of bytes held by the caches sharing the budget.
+func (b *Budget) Used() int64 { return atomic.LoadInt64(&b.used) }
+
+func (b *Budget) register(c *Cache) {
+	b.mu.Lock()
+	b.caches = append(b.caches, c)
+	b.mu.Unlock()
+}
+
+func (b *Budget) over() bool { return b.Used() > b.max }
+
+// shrink evicts elements until the caches are within the budget.
+func (b *Budget) shrink() {
+	for b.over() {
+		c := b.largest()
+		if c == nil || !c.evict() {
+			return
+		}
+	}
+}
+
+// largest returns the cache that holds the most bytes.
+func (b *Budget) largest() *Cache {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var largest *Cache
+	for _, c := range b.caches {
+		if largest == nil || c.Bytes() > largest.Bytes() {
+			largest = c
+		}
+	}
+	return largest
+}
@@ -1,11 +1,15 @@
 // Package cache implements a cache. The cache hold 256 shards, each shard
-// holds a cache: a map with a mutex. There is no fancy expunge algorithm, it
-// just randomly evicts elements when it gets full.
+// holds a cache: a map with a mutex. By default it randomly evicts elements
+// when a shard gets full, the LRU and TinyLFU policies keep the recently and
+// frequently used elements instead. Caches can share a Budget that limits the
+// bytes they hold together.
 package cache
 
 import (
+	"container/list"
 	"hash/fnv"
 	"sync"
+	"sync/atomic"
 )
 
 // Hash returns the FNV hash of what.
@@ -18,18 +22,40 @@ func Hash(what []byte) uint64 {
 // Cache is cache.
 type Cache struct {
 	shards [shardSize]*shard
+
+	budget *Budget
+	bytes  int64  // bytes held, atomic
+	next   uint32 // shard to evict from when over budget, atomic
+	clock  uint64 // ticks on every add and get, to compare the use of elements in different shards, atomic
 }
 
-// shard is a cache with random eviction.
-type shard struct {
-	items map[uint64]interface{}
-	size  int
+// Sizer is implemented by elements that know the number of bytes they take up.
+type Sizer interface {
+	Size() int
+}
 
-	sync.RWMutex
+// Option configures a Cache.
+type Option func(*Cache)
+
+// WithPolicy sets the eviction policy of the cache.
+func WithPolicy(p Policy) Option {
+	return func(c *Cache) {
+		for _, s := range c.shards {
+			s.setPolicy(p)
+		}
+	}
+}
+
+// WithBudget limits the bytes held by the cache, together with the other caches sharing b.
+func WithBudget(b *Budget) Option {
+	return func(c *Cache) {
+		c.budget = b
+		b.register(c)
+	}
 }
 
 // New returns a new cache.
-func New(size int) *Cache {
+func New(size int, opts ...Option) *Cache {
 	ssize := size / shardSize
 	if ssize < 4 {
 		ssize = 4
@@ -40,14 +66,25 @@ func New(size int) *Cache {
 	// Initialize all the shards
 	for i := 0; i < shardSize; i++ {
 		c.shards[i] = newShard(ssize)
+		c.shards[i].clock = &c.clock
+	}
+	for _, o := range opts {
+		o(c)
 	}
 	return c
 }
 
 // Add adds a new element to the cache. If the element already exists it is overwritten.
+// An element may be refused by the TinyLFU policy, when it is used less than the element
+// it would replace.
 func (c *Cache) Add(key uint64, el interface{}) {
 	shard := key & (shardSize - 1)
-	c.shards[shard].Add(key, el)
+	over := c.budget != nil && c.budget.over()
+	c.account(c.shards[shard].add(key, el, over))
+
+	if c.budget != nil {
+		c.budget.shrink()
+	}
 }
 
 // Get looks up element index under key.
@@ -59,7 +96,7 @@ func (c *Cache) Get(key uint64) (interface{}, bool) {
 // Remove removes the element indexed with key.
 func (c *Cache) Remove(key uint64) {
 	shard := key & (shardSize - 1)
-	c.shards[shard].Remove(key)
+	c.account(-c.shards[shard].remove(key))
 }
 
 // Len returns the number of elements in the cache.
@@ -71,56 +108,234 @@ func (c *Cache) Len() int {
 	return l
 }
 
+// Walk calls f for every element in the cache, until f returns false. A shard is locked while
+// its elements are walked, so f should not take long, nor use the cache.
+func (c *Cache) Walk(f func(key uint64, el interface{}) bool) {
+	for _, s := range c.shards {
+		if !s.walk(f) {
+			return
+		}
+	}
+}
+
+// Bytes returns the number of bytes held by the cache: the size of the elements that
+// implement Sizer, plus a fixed overhead per element.
+func (c *Cache) Bytes() int64 { return atomic.LoadInt64(&c.bytes) }
+
+func (c *Cache) account(delta int64) {
+	if delta == 0 {
+		return
+	}
+	atomic.AddInt64(&c.bytes, delta)
+	if c.budget != nil {
+		atomic.AddInt64(&c.budget.used, delta)
+	}
+}
+
+// evict evicts an element and returns false if the cache is empty. Of the first evictSamples
+// shards that aren't empty, starting with the next in turn, the one whose victim was used least
+// recently is evicted from.
+func (c *Cache) evict() bool {
+	start := int(atomic.AddUint32(&c.next, evictSamples))
+	var (
+		victim *shard
+		oldest uint64
+		n      int
+	)
+	for i := 0; i < shardSize && n < evictSamples; i++ {
+		s := c.shards[(start+i)&(shardSize-1)]
+		tick, ok := s.oldest()
+		if !ok {
+			continue
+		}
+		n++
+		if victim == nil || tick < oldest {
+			victim, oldest = s, tick
+		}
+	}
+	if victim == nil {
+		return false
+	}
+	c.account(-victim.Evict())
+	return true
+}
+
+// evictSamples is the number of shards compared when evicting to stay within the budget.
+const evictSamples = 8
+
+// shard is a cache with an eviction policy, random by default.
+type shard struct {
+	items map[uint64]*entry
+	size  int
+
+	lru    *list.List // of *entry, most recently used in front; for LRU and TinyLFU
+	sketch *sketch    // access frequencies, for TinyLFU
+	clock  *uint64
+
+	sync.RWMutex
+}
+
+type entry struct {
+	key   uint64
+	value interface{}
+	bytes int64
+	elem  *list.Element
+	tick  uint64 // clock of the last use
+}
+
+// entryOverhead is the number of bytes we count for each element, on top of its size.
+const entryOverhead = 64
+
 // newShard returns a new shard with size.
-func newShard(size int) *shard { return &shard{items: make(map[uint64]interface{}), size: size} }
+func newShard(size int) *shard { return &shard{items: make(map[uint64]*entry), size: size} }
+
+func (s *shard) setPolicy(p Policy) {
+	if p == LRU || p == TinyLFU {
+		s.lru = list.New()
+	}
+	if p == TinyLFU {
+		s.sketch = newSketch(s.size)
+	}
+}
 
 // Add adds element indexed by key into the cache. Any existing element is overwritten
-func (s *shard) Add(key uint64, el interface{}) {
-	l := s.Len()
-	if l+1 > s.size {
-		s.Evict()
+func (s *shard) Add(key uint64, el interface{}) { s.add(key, el, false) }
+
+// add adds the element and returns the change in bytes held by the shard. With full the
+// shard is considered to be at capacity.
+func (s *shard) add(key uint64, el interface{}, full bool) int64 {
+	e := &entry{key: key, value: el, bytes: entryOverhead, tick: s.tick()}
+	if sz, ok := el.(Sizer); ok {
+		e.bytes += int64(sz.Size())
 	}
 
 	s.Lock()
-	s.items[key] = el
-	s.Unlock()
+	defer s.Unlock()
+
+	if s.sketch != nil {
+		s.sketch.increment(key)
+	}
+
+	var delta int64
+	if old, ok := s.items[key]; ok {
+		delta -= s.delete(old)
+	} else if full || len(s.items)+1 > s.size {
+		victim := s.victim()
+		if victim != nil && s.sketch != nil && s.sketch.estimate(key) <= s.sketch.estimate(victim.key) {
+			// TinyLFU admission: keep the element that is used more.
+			return 0
+		}
+		if victim != nil && len(s.items)+1 > s.size {
+			delta -= s.delete(victim)
+		}
+	}
+
+	s.items[key] = e
+	if s.lru != nil {
+		e.elem = s.lru.PushFront(e)
+	}
+	return delta + e.bytes
 }
 
 // Remove removes the element indexed by key from the cache.
-func (s *shard) Remove(key uint64) {
+func (s *shard) Remove(key uint64) { s.remove(key) }
+
+func (s *shard) remove(key uint64) int64 {
 	s.Lock()
-	delete(s.items, key)
-	s.Unlock()
+	defer s.Unlock()
+	if e, ok := s.items[key]; ok {
+		return s.delete(e)
+	}
+	return 0
 }
 
-// Evict removes a random element from the cache.
-func (s *shard) Evict() {
-	hasKey := false
-	var key uint64
-
-	s.RLock()
-	for k := range s.items {
-		key = k
-		hasKey = true
-		break
+// delete deletes e and returns its size, the lock must be held.
+func (s *shard) delete(e *entry) int64 {
+	delete(s.items, e.key)
+	if e.elem != nil {
+		s.lru.Remove(e.elem)
 	}
-	s.RUnlock()
+	return e.bytes
+}
 
-	if !hasKey {
-		// empty cache
-		return
+// victim returns the element the policy evicts first, the lock must be held.
+func (s *shard) victim() *entry {
+	if s.lru != nil {
+		if back := s.lru.Back(); back != nil {
+			return back.Value.(*entry)
+		}
+		return nil
+	}
+	for _, e := range s.items {
+		return e
 	}
+	return nil
+}
 
-	// If this item is gone between the RUnlock and Lock race we don't care.
-	s.Remove(key)
+// Evict removes an element from the cache, picked by the eviction policy. It returns the
+// number of bytes freed.
+func (s *shard) Evict() int64 {
+	s.Lock()
+	defer s.Unlock()
+	if e := s.victim(); e != nil {
+		return s.delete(e)
+	}
+	return 0
 }
 
 // Get looks up the element indexed under key.
 func (s *shard) Get(key uint64) (interface{}, bool) {
+	if s.lru == nil {
+		s.RLock()
+		e, found := s.items[key]
+		s.RUnlock()
+		if !found {
+			return nil, false
+		}
+		return e.value, true
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if s.sketch != nil {
+		s.sketch.increment(key)
+	}
+	e, found := s.items[key]
+	if !found {
+		return nil, false
+	}
+	s.lru.MoveToFront(e.elem)
+	e.tick = s.tick()
+	return e.value, true
+}
+
+func (s *shard) tick() uint64 {
+	if s.clock == nil {
+		return 0
+	}
+	return atomic.AddUint64(s.clock, 1)
+}
+
+// oldest returns the clock of the last use of the element the policy evicts first, or false
+// if the shard is empty.
+func (s *shard) oldest() (uint64, bool) {
 	s.RLock()
-	el, found := s.items[key]
-	s.RUnlock()
-	return el, found
+	defer s.RUnlock()
+	if e := s.victim(); e != nil {
+		return e.tick, true
+	}
+	return 0, false
+}
+
+func (s *shard) walk(f func(key uint64, el interface{}) bool) bool {
+	s.RLock()
+	defer s.RUnlock()
+	for k, e := range s.items {
+		if !f(k, e.value) {
+			return false
+		}
+	}
+	return true
 }
 
 // Len returns the current length of the cache.
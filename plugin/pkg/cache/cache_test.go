@@ -39,3 +39,73 @@ func BenchmarkCache(b *testing.B) {
 		c.Get(1)
 	}
 }
+
+type sized int
+
+func (s sized) Size() int { return int(s) }
+
+func TestCacheBudget(t *testing.T) {
+	b := NewBudget(10 * (entryOverhead + 100))
+	c1 := New(1024, WithBudget(b), WithPolicy(LRU))
+	c2 := New(1024, WithBudget(b), WithPolicy(LRU))
+
+	for i := uint64(0); i < 8; i++ {
+		c1.Add(i, sized(100))
+	}
+	if c1.Bytes() != 8*(entryOverhead+100) {
+		t.Fatalf("Expected %d bytes, got %d", 8*(entryOverhead+100), c1.Bytes())
+	}
+	for i := uint64(0); i < 8; i++ {
+		c2.Add(i, sized(100))
+	}
+	// The budget holds 10 elements, evicted from the cache that holds the most.
+	if used := b.Used(); used > b.Max() {
+		t.Errorf("Expected at most %d bytes used, got %d", b.Max(), used)
+	}
+	if l := c1.Len() + c2.Len(); l != 10 {
+		t.Errorf("Expected 10 elements, got %d", l)
+	}
+	if l := c2.Len(); l != 5 {
+		t.Errorf("Expected 5 elements in the second cache, got %d", l)
+	}
+
+	c2.Remove(7)
+	if used := b.Used(); used != 9*(entryOverhead+100) {
+		t.Errorf("Expected %d bytes used, got %d", 9*(entryOverhead+100), used)
+	}
+}
+
+func TestPolicyFromString(t *testing.T) {
+	for _, p := range []Policy{Random, LRU, TinyLFU} {
+		if x, err := PolicyFromString(p.String()); err != nil || x != p {
+			t.Errorf("Expected policy %s, got %s: %v", p, x, err)
+		}
+	}
+	if _, err := PolicyFromString("fifo"); err == nil {
+		t.Error("Expected error for unknown policy")
+	}
+}
+
+func TestCacheWalk(t *testing.T) {
+	c := New(1024)
+	for i := uint64(0); i < 10; i++ {
+		c.Add(i, int(i))
+	}
+	sum := 0
+	c.Walk(func(key uint64, el interface{}) bool {
+		sum += el.(int)
+		return true
+	})
+	if sum != 45 {
+		t.Errorf("Expected the walk to visit all elements, got sum %d", sum)
+	}
+
+	n := 0
+	c.Walk(func(key uint64, el interface{}) bool {
+		n++
+		return n < 3
+	})
+	if n != 3 {
+		t.Errorf("Expected the walk to stop after 3 elements, got %d", n)
+	}
+}
@@ -0,0 +1,30 @@
+package cache
+
+import "fmt"
+
+// Policy is the eviction policy of a cache.
+type Policy int
+
+const (
+	// Random evicts a random element.
+	Random Policy = iota
+	// LRU evicts the least recently used element.
+	LRU
+	// TinyLFU evicts the least recently used element, but only admits a new element when it is
+	// used more often than the element it replaces.
+	TinyLFU
+)
+
+var policyToString = map[Policy]string{Random: "random", LRU: "lru", TinyLFU: "tinylfu"}
+
+func (p Policy) String() string { return policyToString[p] }
+
+// PolicyFromString returns the policy named s.
+func PolicyFromString(s string) (Policy, error) {
+	for p, name := range policyToString {
+		if name == s {
+			return p, nil
+		}
+	}
+	return Random, fmt.Errorf("unknown eviction policy: %q", s)
+}
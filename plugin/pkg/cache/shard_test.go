@@ -58,3 +58,51 @@ func TestShardLenEvict(t *testing.T) {
 		t.Fatalf("Shard size should %d, got %d", 4, l)
 	}
 }
+
+func TestShardEvictLRU(t *testing.T) {
+	s := newShard(2)
+	s.setPolicy(LRU)
+	s.Add(1, 1)
+	s.Add(2, 2)
+	s.Get(1)
+	s.Add(3, 3)
+	// 2 is the least recently used
+
+	if _, found := s.Get(2); found {
+		t.Fatal("Found item that should have been evicted")
+	}
+	for _, k := range []uint64{1, 3} {
+		if _, found := s.Get(k); !found {
+			t.Fatalf("Failed to find item %d", k)
+		}
+	}
+}
+
+func TestShardAdmitTinyLFU(t *testing.T) {
+	s := newShard(2)
+	s.setPolicy(TinyLFU)
+	s.Add(1, 1)
+	s.Add(2, 2)
+	for i := 0; i < 5; i++ {
+		s.Get(1)
+		s.Get(2)
+	}
+
+	// Seen once, less than the elements in the shard.
+	s.Add(3, 3)
+	if _, found := s.Get(3); found {
+		t.Fatal("Found item that should not have been admitted")
+	}
+
+	// Seen often enough, replaces the least recently used.
+	for i := 0; i < 10; i++ {
+		s.Get(4)
+	}
+	s.Add(4, 4)
+	if _, found := s.Get(4); !found {
+		t.Fatal("Failed to find item that should have been admitted")
+	}
+	if l := s.Len(); l != 2 {
+		t.Fatalf("Shard size should %d, got %d", 2, l)
+	}
+}
@@ -0,0 +1,68 @@
+package cache
+
+// sketch is a count-min sketch that estimates how often keys are seen, with 4 bit counters that
+// are halved periodically, so the estimates favor recent use.
+type sketch struct {
+	rows    [depth][]uint8
+	mask    uint64
+	samples int
+	reset   int // halve the counters after this many samples
+}
+
+const (
+	depth      = 4
+	maxCounter = 15
+)
+
+// seeds for the hashes of the rows.
+var seeds = [depth]uint64{0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325}
+
+func newSketch(size int) *sketch {
+	width := 16
+	for width < size {
+		width <<= 1
+	}
+	s := &sketch{mask: uint64(width - 1), reset: 10 * width}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *sketch) index(key uint64, row int) uint64 {
+	h := (key ^ seeds[row]) * 0x9e3779b97f4a7c15
+	return (h ^ h>>32) & s.mask
+}
+
+// increment counts key.
+func (s *sketch) increment(key uint64) {
+	for i := range s.rows {
+		if j := s.index(key, i); s.rows[i][j] < maxCounter {
+			s.rows[i][j]++
+		}
+	}
+	s.samples++
+	if s.samples >= s.reset {
+		s.halve()
+	}
+}
+
+// estimate returns how often key has been seen.
+func (s *sketch) estimate(key uint64) uint8 {
+	min := uint8(maxCounter)
+	for i := range s.rows {
+		if c := s.rows[i][s.index(key, i)]; c < min {
+			min = c
+		}
+	}
+	return min
+}
+
+func (s *sketch) halve() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.samples /= 2
+}
@@ -19,12 +19,22 @@ type Server struct {
 // finished, to shut it down.
 func NewServer(f dns.HandlerFunc) *Server {
 	dns.HandleFunc(".", f)
+	return newServer(nil)
+}
+
+// NewMultipleServer starts and returns a new Server that, unlike NewServer, serves
+// f with its own handler, so that several servers with different handlers can run
+// at the same time. The caller should call Close when finished, to shut it down.
+func NewMultipleServer(f dns.HandlerFunc) *Server {
+	return newServer(f)
+}
 
+func newServer(h dns.Handler) *Server {
 	ch1 := make(chan bool)
 	ch2 := make(chan bool)
 
-	s1 := &dns.Server{} // udp
-	s2 := &dns.Server{} // tcp
+	s1 := &dns.Server{Handler: h} // udp
+	s2 := &dns.Server{Handler: h} // tcp
 
 	for i := 0; i < 5; i++ { // 5 attempts
 		s2.Listener, _ = net.Listen("tcp", ":0")
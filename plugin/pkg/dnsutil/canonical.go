@@ -0,0 +1,44 @@
+package dnsutil
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Compare compares the domain names a and b in canonical order (RFC 4034, section 6.1). The
+// result is negative if a sorts before b, zero if they are equal and positive otherwise.
+func Compare(a, b string) int {
+	la := dns.SplitDomainName(strings.ToLower(a))
+	lb := dns.SplitDomainName(strings.ToLower(b))
+	for i := 1; i <= len(la) && i <= len(lb); i++ {
+		if c := strings.Compare(unescape(la[len(la)-i]), unescape(lb[len(lb)-i])); c != 0 {
+			return c
+		}
+	}
+	return len(la) - len(lb)
+}
+
+// unescape returns the label with the \X and \DDD escapes replaced by the octets they stand for.
+func unescape(label string) string {
+	if strings.IndexByte(label, '\\') < 0 {
+		return label
+	}
+	b := make([]byte, 0, len(label))
+	for i := 0; i < len(label); i++ {
+		if label[i] != '\\' || i+1 == len(label) {
+			b = append(b, label[i])
+			continue
+		}
+		if i+3 < len(label) && isDigit(label[i+1]) && isDigit(label[i+2]) && isDigit(label[i+3]) {
+			b = append(b, (label[i+1]-'0')*100+(label[i+2]-'0')*10+(label[i+3]-'0'))
+			i += 3
+			continue
+		}
+		b = append(b, label[i+1])
+		i++
+	}
+	return string(b)
+}
+
+func isDigit(b byte) bool { return b >= '0' && b <= '9' }
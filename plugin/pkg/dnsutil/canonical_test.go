@@ -0,0 +1,16 @@
+package dnsutil
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	// Canonical order from RFC 4034, section 6.1.
+	names := []string{"example.", "a.example.", "yljkjljk.a.example.", "Z.a.example.", "zABC.a.EXAMPLE.", "z.example.", "\\001.z.example.", "*.z.example.", "\\200.z.example."}
+	for i := 1; i < len(names); i++ {
+		if c := Compare(names[i-1], names[i]); c >= 0 {
+			t.Errorf("Expected %s to sort before %s, got %d", names[i-1], names[i], c)
+		}
+	}
+	if c := Compare("Example.org.", "example.ORG."); c != 0 {
+		t.Errorf("Expected names to be equal, got %d", c)
+	}
+}
@@ -0,0 +1,22 @@
+package dnsutil
+
+import "github.com/miekg/dns"
+
+// MaxIterations is the highest number of NSEC3 iterations that is accepted. Zones using more are
+// treated as insecure (RFC 9276), hashing names for them is too expensive.
+const MaxIterations = 150
+
+// HasType returns true if t is in the type bitmap of an NSEC or NSEC3 record.
+func HasType(bitmap []uint16, t uint16) bool {
+	for _, b := range bitmap {
+		if b == t {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDelegation returns true if bitmap is of a name with a delegation: NS records and no SOA.
+func IsDelegation(bitmap []uint16) bool {
+	return HasType(bitmap, dns.TypeNS) && !HasType(bitmap, dns.TypeSOA)
+}
@@ -0,0 +1,28 @@
+package dnsutil
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestIsDelegation(t *testing.T) {
+	tests := []struct {
+		bitmap   []uint16
+		expected bool
+	}{
+		{[]uint16{dns.TypeNS, dns.TypeDS, dns.TypeRRSIG, dns.TypeNSEC}, true},
+		{[]uint16{dns.TypeNS}, true},
+		{[]uint16{dns.TypeNS, dns.TypeSOA, dns.TypeRRSIG, dns.TypeNSEC, dns.TypeDNSKEY}, false},
+		{[]uint16{dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC}, false},
+		{nil, false},
+	}
+	for i, tc := range tests {
+		if x := IsDelegation(tc.bitmap); x != tc.expected {
+			t.Errorf("Test %d: expected %t for %v, got %t", i, tc.expected, tc.bitmap, x)
+		}
+	}
+	if !HasType([]uint16{dns.TypeA, dns.TypeMX}, dns.TypeMX) || HasType([]uint16{dns.TypeA}, dns.TypeMX) {
+		t.Errorf("Expected HasType to find only the types in the bitmap")
+	}
+}
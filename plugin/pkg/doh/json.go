@@ -0,0 +1,121 @@
+package doh
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// MimeTypeJSON is the mimetype of the JSON API, as used by Google and Cloudflare.
+const MimeTypeJSON = "application/dns-json"
+
+// IsJSON returns true if req is a request for the JSON API: it is a GET request that asks for
+// MimeTypeJSON in the Accept header or the ct parameter, or that has a name parameter and no dns
+// parameter.
+func IsJSON(req *http.Request) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+	values := req.URL.Query()
+	if values.Get("ct") == MimeTypeJSON || strings.Contains(req.Header.Get("accept"), MimeTypeJSON) {
+		return true
+	}
+	_, name := values["name"]
+	_, wire := values["dns"]
+	return name && !wire
+}
+
+// RequestJSONToMsg converts a JSON API request to a dns message. The name parameter holds the query
+// name and type the query type, as a name or a number, which defaults to A. The do and cd parameters
+// set the DO and CD bits.
+func RequestJSONToMsg(req *http.Request) (*dns.Msg, error) {
+	values := req.URL.Query()
+	name := values.Get("name")
+	if name == "" {
+		return nil, fmt.Errorf("no 'name' query parameter found")
+	}
+	if _, ok := dns.IsDomainName(name); !ok {
+		return nil, fmt.Errorf("invalid 'name' query parameter: %q", name)
+	}
+
+	qtype := dns.TypeA
+	if t := values.Get("type"); t != "" {
+		if n, err := strconv.ParseUint(t, 10, 16); err == nil {
+			qtype = uint16(n)
+		} else if n, ok := dns.StringToType[strings.ToUpper(t)]; ok {
+			qtype = n
+		} else {
+			return nil, fmt.Errorf("invalid 'type' query parameter: %q", t)
+		}
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.CheckingDisabled = isTrue(values.Get("cd"))
+	m.SetEdns0(dns.DefaultMsgSize, isTrue(values.Get("do")))
+	return m, nil
+}
+
+// MsgToJSON converts a dns message to the JSON API format.
+func MsgToJSON(m *dns.Msg) ([]byte, error) {
+	j := jsonMsg{
+		Status: m.Rcode,
+		TC:     m.Truncated,
+		RD:     m.RecursionDesired,
+		RA:     m.RecursionAvailable,
+		AD:     m.AuthenticatedData,
+		CD:     m.CheckingDisabled,
+	}
+	for _, q := range m.Question {
+		j.Question = append(j.Question, jsonQuestion{Name: q.Name, Type: q.Qtype})
+	}
+	j.Answer = toJSONRRs(m.Answer)
+	j.Authority = toJSONRRs(m.Ns)
+	j.Additional = toJSONRRs(m.Extra)
+
+	return json.Marshal(j)
+}
+
+func toJSONRRs(rrs []dns.RR) []jsonRR {
+	var j []jsonRR
+	for _, rr := range rrs {
+		hdr := rr.Header()
+		if hdr.Rrtype == dns.TypeOPT {
+			continue
+		}
+		data := strings.TrimPrefix(rr.String(), hdr.String())
+		j = append(j, jsonRR{Name: hdr.Name, Type: hdr.Rrtype, TTL: hdr.Ttl, Data: data})
+	}
+	return j
+}
+
+func isTrue(s string) bool { return s == "1" || s == "true" }
+
+type jsonMsg struct {
+	Status     int
+	TC         bool
+	RD         bool
+	RA         bool
+	AD         bool
+	CD         bool
+	Question   []jsonQuestion
+	Answer     []jsonRR `json:",omitempty"`
+	Authority  []jsonRR `json:",omitempty"`
+	Additional []jsonRR `json:",omitempty"`
+}
+
+type jsonQuestion struct {
+	Name string `json:"name"`
+	Type uint16 `json:"type"`
+}
+
+type jsonRR struct {
+	Name string `json:"name"`
+	Type uint16 `json:"type"`
+	TTL  uint32 `json:"TTL"`
+	Data string `json:"data"`
+}
@@ -0,0 +1,96 @@
+package doh
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestIsJSON(t *testing.T) {
+	tests := []struct {
+		method string
+		url    string
+		accept string
+		json   bool
+	}{
+		{http.MethodGet, "/dns-query?name=example.org", "", true},
+		{http.MethodGet, "/dns-query?name=example.org&type=AAAA", MimeTypeJSON, true},
+		{http.MethodGet, "/dns-query?ct=application/dns-json&name=example.org", "", true},
+		{http.MethodGet, "/dns-query?dns=AAABAAABAAAAAAAAB2V4YW1wbGUDb3JnAAABAAE", "", false},
+		{http.MethodGet, "/dns-query?dns=AAABAAABAAAAAAAAB2V4YW1wbGUDb3JnAAABAAE", MimeType, false},
+		{http.MethodPost, "/dns-query?name=example.org", MimeTypeJSON, false},
+	}
+
+	for i, tc := range tests {
+		req := httptest.NewRequest(tc.method, tc.url, nil)
+		if tc.accept != "" {
+			req.Header.Set("accept", tc.accept)
+		}
+		if x := IsJSON(req); x != tc.json {
+			t.Errorf("Test %d: expected %t, got %t", i, tc.json, x)
+		}
+	}
+}
+
+func TestRequestJSONToMsg(t *testing.T) {
+	tests := []struct {
+		url       string
+		qname     string
+		qtype     uint16
+		do        bool
+		cd        bool
+		shouldErr bool
+	}{
+		{"/dns-query?name=example.org", "example.org.", dns.TypeA, false, false, false},
+		{"/dns-query?name=example.org.&type=mx", "example.org.", dns.TypeMX, false, false, false},
+		{"/dns-query?name=example.org&type=28&do=1&cd=true", "example.org.", dns.TypeAAAA, true, true, false},
+		{"/dns-query?type=A", "", 0, false, false, true},
+		{"/dns-query?name=example.org&type=BOGUS", "", 0, false, false, true},
+		{"/dns-query?name=example..org", "", 0, false, false, true},
+	}
+
+	for i, tc := range tests {
+		m, err := RequestJSONToMsg(httptest.NewRequest(http.MethodGet, tc.url, nil))
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if q := m.Question[0]; q.Name != tc.qname || q.Qtype != tc.qtype {
+			t.Errorf("Test %d: expected %s/%d, got %s/%d", i, tc.qname, tc.qtype, q.Name, q.Qtype)
+		}
+		if do := m.IsEdns0().Do(); do != tc.do {
+			t.Errorf("Test %d: expected DO %t, got %t", i, tc.do, do)
+		}
+		if m.CheckingDisabled != tc.cd {
+			t.Errorf("Test %d: expected CD %t, got %t", i, tc.cd, m.CheckingDisabled)
+		}
+	}
+}
+
+func TestMsgToJSON(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.Response, m.RecursionAvailable = true, true
+	m.Answer = []dns.RR{test.A("example.org. 300 IN A 127.0.0.1")}
+	m.SetEdns0(4096, false)
+
+	buf, err := MsgToJSON(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"Status":0,"TC":false,"RD":true,"RA":true,"AD":false,"CD":false,"Question":[{"name":"example.org.","type":1}],` +
+		`"Answer":[{"name":"example.org.","type":1,"TTL":300,"data":"127.0.0.1"}]}`
+	if string(buf) != expected {
+		t.Errorf("Expected %s, got %s", expected, buf)
+	}
+}
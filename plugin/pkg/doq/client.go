@@ -0,0 +1,125 @@
+package doq
+
+import (
+	"context"
+	"crypto/tls"
+	"sync"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// Client is a DoQ client for a single upstream. It keeps a connection open and sends each query on
+// a new stream; when the connection is gone a new one is dialed.
+type Client struct {
+	addr      string
+	tlsConfig *tls.Config
+
+	mu   sync.Mutex
+	conn quic.Connection
+}
+
+// NewClient returns a new client for the DoQ server at addr. The TLS config is cloned and set up
+// for DoQ.
+func NewClient(addr string, tlsConfig *tls.Config) *Client {
+	var cfg *tls.Config
+	if tlsConfig != nil {
+		cfg = tlsConfig.Clone()
+	} else {
+		cfg = new(tls.Config)
+	}
+	cfg.NextProtos = []string{ALPN}
+	cfg.MinVersion = tls.VersionTLS13
+
+	return &Client{addr: addr, tlsConfig: cfg}
+}
+
+// Exchange sends m to the server and returns the reply. The message ID is set to zero on the wire,
+// the reply gets the ID of m.
+func (c *Client) Exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	buf, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+	buf[0], buf[1] = 0, 0
+
+	ret, err := c.exchange(ctx, buf, true)
+	if err != nil {
+		return nil, err
+	}
+	ret.Id = m.Id
+	return ret, nil
+}
+
+func (c *Client) exchange(ctx context.Context, buf []byte, retry bool) (*dns.Msg, error) {
+	conn, cached, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		c.drop(conn)
+		if cached && retry {
+			// The connection may have timed out in the meantime, try a fresh one.
+			return c.exchange(ctx, buf, false)
+		}
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline)
+	}
+
+	if err := WriteMsg(s, buf); err != nil {
+		s.CancelRead(quic.StreamErrorCode(InternalError))
+		s.CancelWrite(quic.StreamErrorCode(InternalError))
+		return nil, err
+	}
+	// Signal that there are no more queries on this stream.
+	s.Close()
+
+	ret, err := ReadMsg(s)
+	if err != nil {
+		s.CancelRead(quic.StreamErrorCode(InternalError))
+	}
+	return ret, err
+}
+
+// dial returns the current connection, or dials a new one. The returned bool is true when the connection was already open.
+func (c *Client) dial(ctx context.Context) (quic.Connection, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != nil {
+		return c.conn, true, nil
+	}
+	conn, err := quic.DialAddr(ctx, c.addr, c.tlsConfig, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	c.conn = conn
+	return conn, false, nil
+}
+
+// drop closes conn and forgets about it, if it is still the current connection.
+func (c *Client) drop(conn quic.Connection) {
+	c.mu.Lock()
+	if c.conn == conn {
+		c.conn = nil
+	}
+	c.mu.Unlock()
+	conn.CloseWithError(quic.ApplicationErrorCode(NoError), "")
+}
+
+// Close closes the connection of the client.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.CloseWithError(quic.ApplicationErrorCode(NoError), "")
+	c.conn = nil
+	return err
+}
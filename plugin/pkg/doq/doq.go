@@ -0,0 +1,57 @@
+// Package doq contains functions for DNS-over-QUIC (RFC 9250).
+package doq
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/miekg/dns"
+)
+
+// ALPN is the TLS application protocol that identifies DoQ.
+const ALPN = "doq"
+
+// Error codes used when closing a DoQ connection or resetting a stream.
+const (
+	NoError          uint64 = 0x0
+	InternalError    uint64 = 0x1
+	ProtocolError    uint64 = 0x2
+	RequestCancelled uint64 = 0x3
+	ExcessiveLoad    uint64 = 0x4
+)
+
+// ErrID is returned by ReadMsg when the message ID is not zero, as required by RFC 9250.
+var ErrID = errors.New("DoQ message with non-zero ID")
+
+// WriteMsg writes the packed message buf to w, prefixed with its 2 octet length.
+func WriteMsg(w io.Writer, buf []byte) error {
+	if len(buf) > dns.MaxMsgSize {
+		return dns.ErrBuf
+	}
+	b := make([]byte, 2, 2+len(buf))
+	binary.BigEndian.PutUint16(b, uint16(len(buf)))
+	_, err := w.Write(append(b, buf...))
+	return err
+}
+
+// ReadMsg reads a length prefixed message from r. When the message ID is not zero it returns the
+// message together with ErrID.
+func ReadMsg(r io.Reader) (*dns.Msg, error) {
+	var l [2]byte
+	if _, err := io.ReadFull(r, l[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	m := new(dns.Msg)
+	if err := m.Unpack(buf); err != nil {
+		return nil, err
+	}
+	if m.Id != 0 {
+		return m, ErrID
+	}
+	return m, nil
+}
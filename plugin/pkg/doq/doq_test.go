@@ -0,0 +1,50 @@
+package doq
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestReadWriteMsg(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.Id = 0
+	buf, _ := m.Pack()
+
+	b := new(bytes.Buffer)
+	if err := WriteMsg(b, buf); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if b.Len() != len(buf)+2 {
+		t.Errorf("Expected %d bytes, got %d", len(buf)+2, b.Len())
+	}
+
+	ret, err := ReadMsg(b)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if ret.Question[0].Name != "example.org." {
+		t.Errorf("Expected example.org., got %s", ret.Question[0].Name)
+	}
+}
+
+func TestReadMsgID(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.Id = 42
+	buf, _ := m.Pack()
+
+	b := new(bytes.Buffer)
+	WriteMsg(b, buf)
+	if _, err := ReadMsg(b); err != ErrID {
+		t.Errorf("Expected %s, got %v", ErrID, err)
+	}
+}
+
+func TestReadMsgShort(t *testing.T) {
+	if _, err := ReadMsg(bytes.NewReader([]byte{0, 10, 1, 2})); err == nil {
+		t.Errorf("Expected error for a short message, got none")
+	}
+}
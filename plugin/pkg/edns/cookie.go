@@ -0,0 +1,54 @@
+package edns
+
+import (
+	"encoding/hex"
+	"errors"
+
+	"github.com/miekg/dns"
+)
+
+// ErrCookie is returned by Cookie when the COOKIE option is malformed.
+var ErrCookie = errors.New("malformed EDNS0 COOKIE")
+
+// Cookie returns the client and server cookie (RFC 7873) from the OPT record in m. If m has no COOKIE
+// option, both are nil. The server cookie is nil when only a client cookie is present. ErrCookie
+// is returned when the option has an invalid length.
+func Cookie(m *dns.Msg) (client, server []byte, err error) {
+	o := m.IsEdns0()
+	if o == nil {
+		return nil, nil, nil
+	}
+	for _, opt := range o.Option {
+		e, ok := opt.(*dns.EDNS0_COOKIE)
+		if !ok {
+			continue
+		}
+		b, err := hex.DecodeString(e.Cookie)
+		if err != nil {
+			return nil, nil, ErrCookie
+		}
+		switch l := len(b); {
+		case l == 8:
+			return b, nil, nil
+		case l >= 16 && l <= 40:
+			return b[:8], b[8:], nil
+		}
+		return nil, nil, ErrCookie
+	}
+	return nil, nil, nil
+}
+
+// SetCookie sets the COOKIE option in o to the client cookie followed by the server cookie. Any
+// existing COOKIE option is replaced.
+func SetCookie(o *dns.OPT, client, server []byte) {
+	e := &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: hex.EncodeToString(client) + hex.EncodeToString(server)}
+
+	// Build a new slice, o may share its options with the request.
+	opts := make([]dns.EDNS0, 0, len(o.Option)+1)
+	for _, opt := range o.Option {
+		if opt.Option() != dns.EDNS0COOKIE {
+			opts = append(opts, opt)
+		}
+	}
+	o.Option = append(opts, e)
+}
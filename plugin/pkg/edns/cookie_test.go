@@ -0,0 +1,65 @@
+package edns
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestCookie(t *testing.T) {
+	tests := []struct {
+		cookie string
+		client []byte
+		server []byte
+		err    error
+	}{
+		{"", nil, nil, nil}, // no option at all
+		{"0102030405060708", []byte{1, 2, 3, 4, 5, 6, 7, 8}, nil, nil},
+		{"0102030405060708" + "1112131415161718", []byte{1, 2, 3, 4, 5, 6, 7, 8}, []byte{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18}, nil},
+		{"01020304", nil, nil, ErrCookie},
+		{"0102030405060708" + "1112", nil, nil, ErrCookie},
+		{"zz", nil, nil, ErrCookie},
+	}
+
+	for i, tc := range tests {
+		m := ednsMsg()
+		if tc.cookie != "" {
+			o := m.Extra[0].(*dns.OPT)
+			o.Option = append(o.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: tc.cookie})
+		}
+		client, server, err := Cookie(m)
+		if err != tc.err {
+			t.Errorf("Test %d: expected error %v, got %v", i, tc.err, err)
+		}
+		if !bytes.Equal(client, tc.client) {
+			t.Errorf("Test %d: expected client cookie %x, got %x", i, tc.client, client)
+		}
+		if !bytes.Equal(server, tc.server) {
+			t.Errorf("Test %d: expected server cookie %x, got %x", i, tc.server, server)
+		}
+	}
+}
+
+func TestSetCookie(t *testing.T) {
+	m := ednsMsg()
+	o := m.Extra[0].(*dns.OPT)
+	o.Option = []dns.EDNS0{
+		&dns.EDNS0_NSID{Code: dns.EDNS0NSID},
+		&dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: "0102030405060708"},
+	}
+	orig := o.Option
+
+	SetCookie(o, []byte{1, 2, 3, 4, 5, 6, 7, 8}, bytes.Repeat([]byte{0xff}, 16))
+
+	if len(o.Option) != 2 {
+		t.Fatalf("Expected 2 options, got %d", len(o.Option))
+	}
+	_, server, _ := Cookie(m)
+	if !bytes.Equal(server, bytes.Repeat([]byte{0xff}, 16)) {
+		t.Errorf("Expected server cookie to be set, got %x", server)
+	}
+	if orig[1].(*dns.EDNS0_COOKIE).Cookie != "0102030405060708" {
+		t.Errorf("Expected original options to be left alone")
+	}
+}
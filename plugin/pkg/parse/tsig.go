@@ -0,0 +1,33 @@
+package parse
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var tsigAlgorithms = map[string]string{
+	"hmac-md5":    dns.HmacMD5,
+	"hmac-sha1":   dns.HmacSHA1,
+	"hmac-sha256": dns.HmacSHA256,
+	"hmac-sha512": dns.HmacSHA512,
+}
+
+// TsigKey checks the name, algorithm and base64 encoded secret of a TSIG key. It returns the name
+// in canonical form and the fully qualified name of the algorithm, which is one of hmac-md5,
+// hmac-sha1, hmac-sha256 or hmac-sha512.
+func TsigKey(name, algorithm, secret string) (string, string, error) {
+	if _, ok := dns.IsDomainName(name); !ok {
+		return "", "", fmt.Errorf("invalid TSIG key name %q", name)
+	}
+	alg, ok := tsigAlgorithms[strings.TrimSuffix(strings.ToLower(algorithm), ".")]
+	if !ok {
+		return "", "", fmt.Errorf("unsupported TSIG algorithm %q", algorithm)
+	}
+	if _, err := base64.StdEncoding.DecodeString(secret); err != nil {
+		return "", "", fmt.Errorf("invalid TSIG secret for key %q: %s", name, err)
+	}
+	return strings.ToLower(dns.Fqdn(name)), alg, nil
+}
@@ -0,0 +1,41 @@
+package parse
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestTsigKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		secret    string
+		shouldErr bool
+		expName   string
+		expAlg    string
+	}{
+		{"Transfer.Example.org", "hmac-sha256", "c2VjcmV0", false, "transfer.example.org.", dns.HmacSHA256},
+		{"key.", "HMAC-SHA512.", "c2VjcmV0", false, "key.", dns.HmacSHA512},
+		{"key", "hmac-md5", "c2VjcmV0", false, "key.", dns.HmacMD5},
+		{"key", "hmac-sha3", "c2VjcmV0", true, "", ""},
+		{"key", "hmac-sha256", "not base64!", true, "", ""},
+		{"key..", "hmac-sha256", "c2VjcmV0", true, "", ""},
+	}
+	for i, tc := range tests {
+		name, alg, err := TsigKey(tc.name, tc.algorithm, tc.secret)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if name != tc.expName || alg != tc.expAlg {
+			t.Errorf("Test %d: expected %s %s, got %s %s", i, tc.expName, tc.expAlg, name, alg)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	ot "github.com/opentracing/opentracing-go"
+)
+
+// TraceParent is the W3C trace context header (and gRPC metadata key) that carries the trace context.
+const TraceParent = "traceparent"
+
+// The B3 keys used by the Zipkin tracer when (de)serializing a span context to a TextMap. We use
+// these to convert between W3C trace context and the tracer's native format.
+const (
+	b3TraceID = "x-b3-traceid"
+	b3SpanID  = "x-b3-spanid"
+	b3Sampled = "x-b3-sampled"
+)
+
+// ErrTraceParent is returned when a traceparent value can not be parsed or created.
+var ErrTraceParent = errors.New("invalid traceparent")
+
+// Inject returns the W3C traceparent value for span. This only works for tracers that propagate
+// using B3 keys, which includes the zipkin and otlp tracers.
+func Inject(span ot.Span) (string, error) {
+	carrier := ot.TextMapCarrier{}
+	if err := span.Tracer().Inject(span.Context(), ot.TextMap, carrier); err != nil {
+		return "", err
+	}
+	traceID, spanID := carrier[b3TraceID], carrier[b3SpanID]
+	if traceID == "" || spanID == "" {
+		return "", ErrTraceParent
+	}
+	if len(traceID) < 32 {
+		traceID = strings.Repeat("0", 32-len(traceID)) + traceID
+	}
+	flags := "00"
+	if carrier[b3Sampled] == "1" {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", traceID, spanID, flags), nil
+}
+
+// Extract parses the W3C traceparent value and returns the span context as understood by tracer.
+func Extract(tracer ot.Tracer, traceparent string) (ot.SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return nil, ErrTraceParent
+	}
+	// Version ff is forbidden, and version 00 must have exactly 4 fields.
+	if parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return nil, ErrTraceParent
+	}
+	if !isHex(parts[1]) || !isHex(parts[2]) || !isHex(parts[3]) ||
+		parts[1] == strings.Repeat("0", 32) || parts[2] == strings.Repeat("0", 16) {
+		return nil, ErrTraceParent
+	}
+
+	sampled := "0"
+	if parts[3][1]&1 == 1 { // the sampled flag is the lowest bit, '1', '3', .. 'f' all have it set.
+		sampled = "1"
+	}
+	carrier := ot.TextMapCarrier{
+		b3TraceID: strings.ToLower(parts[1]),
+		b3SpanID:  strings.ToLower(parts[2]),
+		b3Sampled: sampled,
+	}
+	return tracer.Extract(ot.TextMap, carrier)
+}
+
+// Sampled returns true if the sampled flag of the span context sc, as understood by tracer, is set.
+// Like Inject, this only works for tracers that propagate using B3 keys.
+func Sampled(tracer ot.Tracer, sc ot.SpanContext) bool {
+	carrier := ot.TextMapCarrier{}
+	if err := tracer.Inject(sc, ot.TextMap, carrier); err != nil {
+		return false
+	}
+	return carrier[b3Sampled] == "1"
+}
+
+type remoteParentKey struct{}
+
+// WithRemoteParent returns a context carrying the span context received from a client. The trace
+// plugin uses this as the parent of the span it creates for the request.
+func WithRemoteParent(ctx context.Context, sc ot.SpanContext) context.Context {
+	return context.WithValue(ctx, remoteParentKey{}, sc)
+}
+
+// RemoteParent returns the span context set with WithRemoteParent, or nil if there is none.
+func RemoteParent(ctx context.Context) ot.SpanContext {
+	sc, _ := ctx.Value(remoteParentKey{}).(ot.SpanContext)
+	return sc
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package trace
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	zipkin "github.com/openzipkin/zipkin-go-opentracing"
+)
+
+func TestInjectExtract(t *testing.T) {
+	tracer, err := zipkin.NewTracer(zipkin.NewInMemoryRecorder(), zipkin.TraceID128Bit(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	span := tracer.StartSpan("test")
+	tp, err := Inject(span)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	parts := strings.Split(tp, "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || parts[3] != "01" {
+		t.Fatalf("Unexpected traceparent: %s", tp)
+	}
+
+	sc, err := Extract(tracer, tp)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	zsc := sc.(zipkin.SpanContext)
+	orig := span.Context().(zipkin.SpanContext)
+	if zsc.TraceID != orig.TraceID || zsc.SpanID != orig.SpanID || !zsc.Sampled {
+		t.Errorf("Expected extracted context %+v, got %+v", orig, zsc)
+	}
+
+	ctx := WithRemoteParent(context.TODO(), sc)
+	if RemoteParent(ctx) == nil {
+		t.Errorf("Expected remote parent in context")
+	}
+	if RemoteParent(context.TODO()) != nil {
+		t.Errorf("Expected no remote parent in context")
+	}
+}
+
+func TestExtract(t *testing.T) {
+	tracer, err := zipkin.NewTracer(zipkin.NewInMemoryRecorder())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		tp      string
+		valid   bool
+		sampled bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-03-extra", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01", false, false},
+		{"", false, false},
+	}
+
+	for i, tc := range tests {
+		sc, err := Extract(tracer, tc.tp)
+		if !tc.valid {
+			if err == nil {
+				t.Errorf("Test %d: expected error for %q", i, tc.tp)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error for %q, got %s", i, tc.tp, err)
+			continue
+		}
+		zsc := sc.(zipkin.SpanContext)
+		if zsc.TraceID.ToHex() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("Test %d: unexpected trace ID %s", i, zsc.TraceID.ToHex())
+		}
+		if zsc.Sampled != tc.sampled {
+			t.Errorf("Test %d: expected sampled %t, got %t", i, tc.sampled, zsc.Sampled)
+		}
+	}
+}
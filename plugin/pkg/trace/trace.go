@@ -2,6 +2,9 @@ package trace
 
 import (
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/rcode"
+	"github.com/coredns/coredns/request"
+
 	ot "github.com/opentracing/opentracing-go"
 )
 
@@ -10,3 +13,22 @@ type Trace interface {
 	plugin.Handler
 	Tracer() ot.Tracer
 }
+
+// Tags set on spans to describe the DNS request and response.
+const (
+	TagName     = "coredns.io/name"
+	TagType     = "coredns.io/type"
+	TagRcode    = "coredns.io/rcode"
+	TagUpstream = "coredns.io/upstream"
+)
+
+// SetRequestTags sets the qname and qtype of state on span.
+func SetRequestTags(span ot.Span, state request.Request) {
+	span.SetTag(TagName, state.Name())
+	span.SetTag(TagType, state.Type())
+}
+
+// SetRcodeTag sets the response code on span.
+func SetRcodeTag(span ot.Span, rc int) {
+	span.SetTag(TagRcode, rcode.ToString(rc))
+}
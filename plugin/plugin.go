@@ -76,7 +76,11 @@ func NextOrFailure(name string, next Handler, ctx context.Context, w dns.Respons
 			defer child.Finish()
 			ctx = ot.ContextWithSpan(ctx, child)
 		}
-		return next.ServeDNS(ctx, w, r)
+		rcode, err := next.ServeDNS(ctx, w, r)
+		if t, ok := ctx.Value(writerKey{}).(*writerTracker); ok {
+			t.track(next.Name(), rcode)
+		}
+		return rcode, err
 	}
 
 	return dns.RcodeServerFailure, Error(name, errors.New("no next plugin found"))
@@ -98,6 +102,48 @@ func ClientWrite(rcode int) bool {
 	return true
 }
 
+// WithWriter returns a context in which NextOrFailure records which handler was responsible
+// for the response. Use Writer to retrieve the name after the chain has returned.
+func WithWriter(ctx context.Context) context.Context {
+	return context.WithValue(ctx, writerKey{}, &writerTracker{})
+}
+
+// Writer returns the name of the handler that wrote the response to the client, i.e. the first one
+// that returned an rcode for which ClientWrite is true. If no handler wrote a response, the deepest
+// handler in the chain is returned, as that one is responsible for the failure. The empty string is
+// returned if ctx was not created with WithWriter or no handler was called.
+func Writer(ctx context.Context) string {
+	t, ok := ctx.Value(writerKey{}).(*writerTracker)
+	if !ok {
+		return ""
+	}
+	if t.written != "" {
+		return t.written
+	}
+	return t.failed
+}
+
+type writerKey struct{}
+
+// writerTracker is filled in by NextOrFailure. Handlers return bottom-up, so the first
+// recorded name is the deepest handler in the chain.
+type writerTracker struct {
+	written string
+	failed  string
+}
+
+func (t *writerTracker) track(name string, rcode int) {
+	if ClientWrite(rcode) {
+		if t.written == "" {
+			t.written = name
+		}
+		return
+	}
+	if t.failed == "" {
+		t.failed = name
+	}
+}
+
 // Namespace is the namespace used for the metrics.
 const Namespace = "coredns"
 
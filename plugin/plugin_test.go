@@ -0,0 +1,47 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+type namedHandler struct {
+	name  string
+	rcode int
+	next  Handler
+}
+
+func (n namedHandler) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	if n.next != nil {
+		NextOrFailure(n.name, n.next, ctx, w, r)
+	}
+	return n.rcode, nil
+}
+
+func (n namedHandler) Name() string { return n.name }
+
+func TestWriter(t *testing.T) {
+	tests := []struct {
+		chain    Handler
+		expected string
+	}{
+		{namedHandler{name: "a", rcode: dns.RcodeSuccess}, "a"},
+		{namedHandler{name: "a", rcode: dns.RcodeSuccess, next: namedHandler{name: "b", rcode: dns.RcodeNameError}}, "b"},
+		{namedHandler{name: "a", rcode: dns.RcodeSuccess, next: namedHandler{name: "b", rcode: dns.RcodeServerFailure}}, "a"},
+		{namedHandler{name: "a", rcode: dns.RcodeServerFailure, next: namedHandler{name: "b", rcode: dns.RcodeServerFailure}}, "b"},
+	}
+
+	for i, tc := range tests {
+		ctx := WithWriter(context.TODO())
+		NextOrFailure("test", tc.chain, ctx, nil, new(dns.Msg))
+		if got := Writer(ctx); got != tc.expected {
+			t.Errorf("Test %d: expected writer %q, got %q", i, tc.expected, got)
+		}
+	}
+
+	if got := Writer(context.TODO()); got != "" {
+		t.Errorf("Expected empty writer without tracking, got %q", got)
+	}
+}
@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// account is a token bucket as used by BIND's response rate limiting: each response debits one
+// token, tokens are credited at rate per second up to burst. The balance may go negative, down
+// to -rate*window, so a client that keeps sending stays limited until it has been quiet for
+// some time.
+type account struct {
+	sync.Mutex
+	balance float64
+	last    time.Time
+	limited int // number of responses limited, used for slipping
+}
+
+func newAccount(burst float64, now time.Time) *account {
+	return &account{balance: burst, last: now}
+}
+
+// debit takes a token from a and returns true if that is allowed.
+func (a *account) debit(rate, burst float64, window time.Duration, now time.Time) bool {
+	a.Lock()
+	defer a.Unlock()
+
+	elapsed := now.Sub(a.last).Seconds()
+	if elapsed > 0 {
+		a.balance += elapsed * rate
+		if a.balance > burst {
+			a.balance = burst
+		}
+		a.last = now
+	}
+
+	a.balance--
+	if min := -rate * window.Seconds(); a.balance < min {
+		a.balance = min
+	}
+	if a.balance >= 0 {
+		a.limited = 0
+		return true
+	}
+	a.limited++
+	return false
+}
+
+// slip returns true if this limited response should be sent truncated instead of dropped.
+// Every slip-th limited response is sent truncated.
+func (a *account) slip(slip int) bool {
+	if slip <= 0 {
+		return false
+	}
+	a.Lock()
+	defer a.Unlock()
+	return a.limited%slip == 0
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAccountDebit(t *testing.T) {
+	now := time.Now()
+	a := newAccount(2, now)
+
+	// Burst of 2, then limited.
+	for i, expected := range []bool{true, true, false, false} {
+		if got := a.debit(2, 2, 15*time.Second, now); got != expected {
+			t.Errorf("Debit %d: expected %t, got %t", i, expected, got)
+		}
+	}
+
+	// Balance is -2 now, after 1.5 seconds it is 1, so one is allowed.
+	now = now.Add(1500 * time.Millisecond)
+	if !a.debit(2, 2, 15*time.Second, now) {
+		t.Errorf("Expected debit to be allowed after refill")
+	}
+	if a.debit(2, 2, 15*time.Second, now) {
+		t.Errorf("Expected debit to be limited")
+	}
+
+	// The balance is capped at -rate*window, so shortly after window seconds we're allowed again.
+	for i := 0; i < 1000; i++ {
+		a.debit(2, 2, 15*time.Second, now)
+	}
+	now = now.Add(16 * time.Second)
+	if !a.debit(2, 2, 15*time.Second, now) {
+		t.Errorf("Expected debit to be allowed after window")
+	}
+}
+
+func TestAccountSlip(t *testing.T) {
+	now := time.Now()
+	a := newAccount(0, now)
+
+	slipped := 0
+	for i := 0; i < 10; i++ {
+		if a.debit(1, 1, time.Second, now) {
+			t.Fatalf("Expected debit to be limited")
+		}
+		if a.slip(2) {
+			slipped++
+		}
+	}
+	if slipped != 5 {
+		t.Errorf("Expected 5 slipped responses, got %d", slipped)
+	}
+	if a.slip(0) {
+		t.Errorf("Expected slip 0 to never slip")
+	}
+}
+
+func TestTableAccount(t *testing.T) {
+	tb := newTable(1024)
+	now := time.Now()
+
+	accounts := make(chan *account, 100)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(accounts); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			accounts <- tb.account([]byte("192.0.2.1"), 5, now)
+		}()
+	}
+	wg.Wait()
+	close(accounts)
+
+	first := <-accounts
+	for a := range accounts {
+		if a != first {
+			t.Fatalf("Expected concurrent calls to return the same account")
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package ratelimit
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Variables declared for monitoring.
+var (
+	DroppedCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "ratelimit",
+		Name:      "dropped_count_total",
+		Help:      "Counter of queries and responses dropped because they were over the limit.",
+	}, []string{"server", "reason"})
+
+	SlippedCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "ratelimit",
+		Name:      "slipped_count_total",
+		Help:      "Counter of responses that were over the limit and sent truncated.",
+	}, []string{"server", "reason"})
+)
+
+// Reasons used as label values in the metrics.
+const (
+	reasonQPS       = "qps"
+	reasonResponses = "responses"
+	reasonNXDomains = "nxdomains"
+	reasonNoData    = "nodata"
+	reasonErrors    = "errors"
+)
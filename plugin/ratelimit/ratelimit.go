@@ -0,0 +1,193 @@
+// Package ratelimit implements per client query rate limiting and response rate limiting (RRL).
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/plugin/pkg/response"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// RateLimit is a plugin that limits the rate of queries per client and the rate of responses per
+// client and response.
+type RateLimit struct {
+	Next  plugin.Handler
+	Zones []string
+
+	qps      float64 // queries per second per client, 0 disables
+	qpsBurst float64
+
+	responses float64 // responses per second per client and response, 0 disables RRL
+	nxdomains float64
+	nodata    float64
+	errors    float64
+	window    time.Duration
+	slip      int
+
+	v4Prefix int
+	v6Prefix int
+	exempt   []*net.IPNet
+
+	queries *table
+	rrl     *table
+
+	now func() time.Time
+}
+
+// New returns a new RateLimit with the defaults set.
+func New() *RateLimit {
+	return &RateLimit{
+		window:   defaultWindow,
+		slip:     defaultSlip,
+		v4Prefix: defaultV4Prefix,
+		v6Prefix: defaultV6Prefix,
+		queries:  newTable(defaultTableSize),
+		rrl:      newTable(defaultTableSize),
+		now:      time.Now,
+	}
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (rl *RateLimit) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+
+	zone := plugin.Zones(rl.Zones).Matches(state.Name())
+	if zone == "" {
+		return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, w, r)
+	}
+
+	ip := net.ParseIP(state.IP())
+	if ip == nil || rl.isExempt(ip) {
+		return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, w, r)
+	}
+	prefix := rl.prefix(ip)
+	server := metrics.WithServer(ctx)
+
+	if rl.qps > 0 {
+		a := rl.queries.account([]byte(prefix), rl.qpsBurst, rl.now())
+		if !a.debit(rl.qps, rl.qpsBurst, rl.window, rl.now()) {
+			DroppedCount.WithLabelValues(server, reasonQPS).Inc()
+			// Over TCP the source address is verified, tell the client to go away.
+			if state.Proto() == "tcp" {
+				return dns.RcodeRefused, nil
+			}
+			// Returning success without writing a reply drops the query.
+			return dns.RcodeSuccess, nil
+		}
+	}
+
+	// Response rate limiting only makes sense for UDP, with TCP or a valid server cookie the source
+	// can't be spoofed.
+	if rl.responses == 0 || state.Proto() == "tcp" || validCookie(ctx) {
+		return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, w, r)
+	}
+
+	rw := &ResponseWriter{ResponseWriter: w, rl: rl, state: state, prefix: prefix, server: server}
+	return plugin.NextOrFailure(rl.Name(), rl.Next, ctx, rw, r)
+}
+
+// Name implements the Handler interface.
+func (rl *RateLimit) Name() string { return "ratelimit" }
+
+func (rl *RateLimit) isExempt(ip net.IP) bool {
+	for _, n := range rl.exempt {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// validCookie returns true if the cookie plugin has found a valid server cookie in the query.
+func validCookie(ctx context.Context) bool {
+	f := metadata.ValueFunc(ctx, "cookie/valid")
+	return f != nil && f() == "true"
+}
+
+// prefix returns the client's network as a string, this is used to group clients.
+func (rl *RateLimit) prefix(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(rl.v4Prefix, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(rl.v6Prefix, 128)).String()
+}
+
+// bucket returns the RRL bucket of the response, the rate that applies to it and the reason
+// used in the metrics. Similar to BIND, positive responses are bucketed by the query name and
+// type, NXDOMAIN and NODATA responses by the zone, and errors by the rcode, so a flood of
+// random names still ends up in a single bucket.
+func (rl *RateLimit) bucket(state request.Request, res *dns.Msg) (string, float64, string) {
+	mt, _ := response.Typify(res, rl.now().UTC())
+	switch mt {
+	case response.NoError:
+		return "r/" + state.Name() + "/" + state.Type(), rl.responses, reasonResponses
+	case response.NameError:
+		return "nx/" + soaOwner(res, state.Name()), rl.nxdomains, reasonNXDomains
+	case response.NoData, response.Delegation:
+		return "nd/" + soaOwner(res, state.Name()), rl.nodata, reasonNoData
+	}
+	return "e/" + dns.RcodeToString[res.Rcode], rl.errors, reasonErrors
+}
+
+// soaOwner returns the owner name of the first SOA or NS record in the authority section, or name if
+// there is none.
+func soaOwner(res *dns.Msg, name string) string {
+	for _, rr := range res.Ns {
+		switch rr.Header().Rrtype {
+		case dns.TypeSOA, dns.TypeNS:
+			return strings.ToLower(rr.Header().Name)
+		}
+	}
+	return name
+}
+
+// ResponseWriter applies response rate limiting to the response written.
+type ResponseWriter struct {
+	dns.ResponseWriter
+	rl     *RateLimit
+	state  request.Request
+	prefix string
+	server string
+}
+
+// WriteMsg implements the dns.ResponseWriter interface. A response that is over the limit is
+// dropped, or sent truncated if it slips through, which tells a legitimate client to retry over TCP.
+func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
+	bucket, rate, reason := w.rl.bucket(w.state, res)
+	if rate == 0 {
+		return w.ResponseWriter.WriteMsg(res)
+	}
+
+	now := w.rl.now()
+	a := w.rl.rrl.account([]byte(w.prefix+"/"+bucket), rate, now)
+	if a.debit(rate, rate, w.rl.window, now) {
+		return w.ResponseWriter.WriteMsg(res)
+	}
+
+	if a.slip(w.rl.slip) {
+		SlippedCount.WithLabelValues(w.server, reason).Inc()
+		tc := new(dns.Msg)
+		tc.SetReply(w.state.Req)
+		tc.Truncated = true
+		return w.ResponseWriter.WriteMsg(tc)
+	}
+
+	DroppedCount.WithLabelValues(w.server, reason).Inc()
+	return nil
+}
+
+const (
+	defaultWindow    = 15 * time.Second
+	defaultSlip      = 2
+	defaultV4Prefix  = 24
+	defaultV6Prefix  = 56
+	defaultTableSize = 100000
+)
@@ -0,0 +1,153 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func newTestRateLimit() *RateLimit {
+	rl := New()
+	rl.Zones = []string{"example.org."}
+	now := time.Now()
+	rl.now = func() time.Time { return now }
+	return rl
+}
+
+// answer returns a handler that answers all queries with rcode.
+func answer(rcode int) test.HandlerFunc {
+	return func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetRcode(r, rcode)
+		if rcode == dns.RcodeSuccess {
+			m.Answer = []dns.RR{test.A(r.Question[0].Name + " 300 IN A 127.0.0.1")}
+		} else {
+			m.Ns = []dns.RR{test.SOA("example.org. 300 IN SOA ns.example.org. hostmaster.example.org. 1 7200 3600 1209600 300")}
+		}
+		w.WriteMsg(m)
+		return rcode, nil
+	}
+}
+
+func TestQPS(t *testing.T) {
+	rl := newTestRateLimit()
+	rl.qps, rl.qpsBurst = 2, 2
+	rl.Next = answer(dns.RcodeSuccess)
+
+	tests := []struct {
+		remote  string
+		tcp     bool
+		written bool
+		rcode   int
+	}{
+		{"10.0.0.1", false, true, dns.RcodeSuccess},
+		{"10.0.0.2", false, true, dns.RcodeSuccess}, // same /24
+		{"10.0.0.3", false, false, dns.RcodeSuccess},
+		{"10.0.0.3", true, false, dns.RcodeRefused},
+		{"10.0.1.1", false, true, dns.RcodeSuccess}, // other /24
+	}
+
+	for i, tc := range tests {
+		req := new(dns.Msg)
+		req.SetQuestion("www.example.org.", dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{RemoteIP: tc.remote, TCP: tc.tcp})
+
+		rcode, _ := rl.ServeDNS(context.TODO(), rec, req)
+		if rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %d, got %d", i, tc.rcode, rcode)
+		}
+		if written := rec.Msg != nil; written != tc.written {
+			t.Errorf("Test %d: expected written %t, got %t", i, tc.written, written)
+		}
+	}
+}
+
+func TestRRL(t *testing.T) {
+	rl := newTestRateLimit()
+	rl.responses, rl.nxdomains, rl.nodata, rl.errors = 1, 1, 1, 1
+	rl.slip = 2
+
+	tests := []struct {
+		qname     string
+		next      test.HandlerFunc
+		tcp       bool
+		written   bool
+		truncated bool
+	}{
+		{"a.example.org.", answer(dns.RcodeSuccess), false, true, false},
+		{"a.example.org.", answer(dns.RcodeSuccess), false, false, false}, // limited, dropped
+		{"a.example.org.", answer(dns.RcodeSuccess), false, true, true},   // limited, slipped
+		{"a.example.org.", answer(dns.RcodeSuccess), true, true, false},   // TCP is not limited
+		{"b.example.org.", answer(dns.RcodeSuccess), false, true, false},  // other bucket
+		// NXDOMAIN for random names all end up in the zone's bucket.
+		{"x1.example.org.", answer(dns.RcodeNameError), false, true, false},
+		{"x2.example.org.", answer(dns.RcodeNameError), false, false, false},
+		{"x3.example.org.", answer(dns.RcodeNameError), false, true, true},
+		{"a.example.net.", answer(dns.RcodeSuccess), false, true, false}, // not our zone
+		{"a.example.net.", answer(dns.RcodeSuccess), false, true, false},
+	}
+
+	for i, tc := range tests {
+		rl.Next = tc.next
+		req := new(dns.Msg)
+		req.SetQuestion(tc.qname, dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{TCP: tc.tcp})
+
+		rl.ServeDNS(context.TODO(), rec, req)
+		if written := rec.Msg != nil; written != tc.written {
+			t.Errorf("Test %d: expected written %t, got %t", i, tc.written, written)
+			continue
+		}
+		if tc.written && rec.Msg.Truncated != tc.truncated {
+			t.Errorf("Test %d: expected truncated %t, got %t", i, tc.truncated, rec.Msg.Truncated)
+		}
+		if tc.truncated && len(rec.Msg.Answer) != 0 {
+			t.Errorf("Test %d: expected no answer in truncated response", i)
+		}
+	}
+}
+
+func TestExempt(t *testing.T) {
+	rl := newTestRateLimit()
+	rl.qps, rl.qpsBurst = 1, 1
+	_, n, _ := net.ParseCIDR("10.240.0.0/16")
+	rl.exempt = []*net.IPNet{n}
+	rl.Next = answer(dns.RcodeSuccess)
+
+	for i := 0; i < 5; i++ {
+		req := new(dns.Msg)
+		req.SetQuestion("www.example.org.", dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		rl.ServeDNS(context.TODO(), rec, req)
+		if rec.Msg == nil {
+			t.Fatalf("Query %d: expected exempt client to get a response", i)
+		}
+	}
+}
+
+func TestValidCookie(t *testing.T) {
+	rl := newTestRateLimit()
+	rl.responses = 1
+	rl.slip = 0
+	rl.Next = answer(dns.RcodeSuccess)
+
+	for i := 0; i < 5; i++ {
+		ctx := metadata.ContextWithMetadata(context.TODO())
+		metadata.SetValueFunc(ctx, "cookie/valid", func() string { return "true" })
+
+		req := new(dns.Msg)
+		req.SetQuestion("www.example.org.", dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		rl.ServeDNS(ctx, rec, req)
+		if rec.Msg == nil {
+			t.Fatalf("Query %d: expected client with a valid cookie to get a response", i)
+		}
+	}
+}
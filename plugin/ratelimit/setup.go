@@ -0,0 +1,211 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+
+	"github.com/mholt/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("ratelimit", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	rl, err := parse(c)
+	if err != nil {
+		return plugin.Error("ratelimit", err)
+	}
+
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, DroppedCount, SlippedCount)
+		return nil
+	})
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		rl.Next = next
+		return rl
+	})
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (*RateLimit, error) {
+	rl := New()
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		rl.Zones = make([]string, len(c.ServerBlockKeys))
+		copy(rl.Zones, c.ServerBlockKeys)
+		if args := c.RemainingArgs(); len(args) > 0 {
+			rl.Zones = args
+		}
+		for j := range rl.Zones {
+			rl.Zones[j] = plugin.Host(rl.Zones[j]).Normalize()
+		}
+
+		nxdomains, nodata, errors := -1.0, -1.0, -1.0
+		size := defaultTableSize
+		for c.NextBlock() {
+			var err error
+			switch c.Val() {
+			case "qps":
+				args := c.RemainingArgs()
+				if len(args) == 0 || len(args) > 2 {
+					return nil, c.ArgErr()
+				}
+				if rl.qps, err = parseRate(args[0]); err != nil {
+					return nil, err
+				}
+				rl.qpsBurst = rl.qps
+				if len(args) == 2 {
+					if rl.qpsBurst, err = parseRate(args[1]); err != nil {
+						return nil, err
+					}
+				}
+			case "responses_per_second":
+				rl.responses, err = parseRateArg(c)
+			case "nxdomains_per_second":
+				nxdomains, err = parseRateArg(c)
+			case "nodata_per_second":
+				nodata, err = parseRateArg(c)
+			case "errors_per_second":
+				errors, err = parseRateArg(c)
+			case "window":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				w, err := strconv.Atoi(args[0])
+				if err != nil {
+					return nil, err
+				}
+				if w < 1 || w > 3600 {
+					return nil, fmt.Errorf("window must be between 1 and 3600 seconds: %d", w)
+				}
+				rl.window = time.Duration(w) * time.Second
+			case "slip":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				if rl.slip, err = strconv.Atoi(args[0]); err != nil {
+					return nil, err
+				}
+				if rl.slip < 0 || rl.slip > 10 {
+					return nil, fmt.Errorf("slip must be between 0 and 10: %d", rl.slip)
+				}
+			case "ipv4_prefix_length":
+				rl.v4Prefix, err = parsePrefix(c, 32)
+			case "ipv6_prefix_length":
+				rl.v6Prefix, err = parsePrefix(c, 128)
+			case "exempt":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, a := range args {
+					if !strings.Contains(a, "/") {
+						if ip := net.ParseIP(a); ip != nil && ip.To4() != nil {
+							a += "/32"
+						} else {
+							a += "/128"
+						}
+					}
+					_, n, err := net.ParseCIDR(a)
+					if err != nil {
+						return nil, err
+					}
+					rl.exempt = append(rl.exempt, n)
+				}
+			case "max_table_size":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				if size, err = strconv.Atoi(args[0]); err != nil {
+					return nil, err
+				}
+				if size < 1 {
+					return nil, fmt.Errorf("max_table_size must be positive: %d", size)
+				}
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// Like BIND, the other rates default to responses_per_second.
+		rl.nxdomains, rl.nodata, rl.errors = rl.responses, rl.responses, rl.responses
+		if nxdomains >= 0 {
+			rl.nxdomains = nxdomains
+		}
+		if nodata >= 0 {
+			rl.nodata = nodata
+		}
+		if errors >= 0 {
+			rl.errors = errors
+		}
+		if rl.responses == 0 && (rl.nxdomains > 0 || rl.nodata > 0 || rl.errors > 0) {
+			return nil, fmt.Errorf("responses_per_second must be set to limit nxdomains, nodata or errors")
+		}
+		if rl.qps == 0 && rl.responses == 0 {
+			return nil, fmt.Errorf("at least one of qps or responses_per_second must be set")
+		}
+
+		rl.queries = newTable(size)
+		rl.rrl = newTable(size)
+	}
+	return rl, nil
+}
+
+func parseRateArg(c *caddy.Controller) (float64, error) {
+	args := c.RemainingArgs()
+	if len(args) != 1 {
+		return 0, c.ArgErr()
+	}
+	return parseRate(args[0])
+}
+
+func parseRate(s string) (float64, error) {
+	r, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if r < 0 {
+		return 0, fmt.Errorf("rate can not be negative: %s", s)
+	}
+	return r, nil
+}
+
+func parsePrefix(c *caddy.Controller, max int) (int, error) {
+	args := c.RemainingArgs()
+	if len(args) != 1 {
+		return 0, c.ArgErr()
+	}
+	p, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, err
+	}
+	if p < 0 || p > max {
+		return 0, fmt.Errorf("prefix length must be between 0 and %d: %d", max, p)
+	}
+	return p, nil
+}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		qps       float64
+		responses float64
+		nxdomains float64
+		errors    float64
+		slip      int
+		window    time.Duration
+		zones     []string
+		exempt    int
+	}{
+		{`ratelimit {
+			qps 100
+		}`, false, 100, 0, 0, 0, defaultSlip, defaultWindow, nil, 0},
+		{`ratelimit example.org {
+			responses_per_second 5
+			nxdomains_per_second 2
+			slip 0
+			window 5
+			exempt 127.0.0.1 10.0.0.0/8
+		}`, false, 0, 5, 2, 5, 0, 5 * time.Second, []string{"example.org."}, 2},
+		{`ratelimit {
+			qps 100 200
+			responses_per_second 5
+			errors_per_second 0
+			ipv4_prefix_length 32
+			ipv6_prefix_length 64
+			max_table_size 1000
+		}`, false, 100, 5, 5, 0, defaultSlip, defaultWindow, nil, 0},
+		// fails
+		{`ratelimit`, true, 0, 0, 0, 0, 0, 0, nil, 0},
+		{`ratelimit {
+			nxdomains_per_second 2
+		}`, true, 0, 0, 0, 0, 0, 0, nil, 0},
+		{`ratelimit {
+			qps -1
+		}`, true, 0, 0, 0, 0, 0, 0, nil, 0},
+		{`ratelimit {
+			qps 10
+			slip 11
+		}`, true, 0, 0, 0, 0, 0, 0, nil, 0},
+		{`ratelimit {
+			qps 10
+			window 0
+		}`, true, 0, 0, 0, 0, 0, 0, nil, 0},
+		{`ratelimit {
+			qps 10
+			ipv4_prefix_length 33
+		}`, true, 0, 0, 0, 0, 0, 0, nil, 0},
+		{`ratelimit {
+			qps 10
+			exempt 10.0.0.0/33
+		}`, true, 0, 0, 0, 0, 0, 0, nil, 0},
+		{`ratelimit {
+			qps 10
+			blah
+		}`, true, 0, 0, 0, 0, 0, 0, nil, 0},
+		{`ratelimit {
+			qps 10
+		}
+		ratelimit {
+			qps 10
+		}`, true, 0, 0, 0, 0, 0, 0, nil, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		rl, err := parse(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			continue
+		}
+		if rl.qps != test.qps || rl.responses != test.responses || rl.nxdomains != test.nxdomains || rl.errors != test.errors {
+			t.Errorf("Test %d: unexpected rates qps %f, responses %f, nxdomains %f, errors %f", i, rl.qps, rl.responses, rl.nxdomains, rl.errors)
+		}
+		if rl.slip != test.slip {
+			t.Errorf("Test %d: expected slip %d, got %d", i, test.slip, rl.slip)
+		}
+		if rl.window != test.window {
+			t.Errorf("Test %d: expected window %s, got %s", i, test.window, rl.window)
+		}
+		if test.zones != nil && (len(rl.Zones) != 1 || rl.Zones[0] != test.zones[0]) {
+			t.Errorf("Test %d: expected zones %v, got %v", i, test.zones, rl.Zones)
+		}
+		if len(rl.exempt) != test.exempt {
+			t.Errorf("Test %d: expected %d exempt networks, got %d", i, test.exempt, len(rl.exempt))
+		}
+	}
+}
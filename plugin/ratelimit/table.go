@@ -0,0 +1,39 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/cache"
+)
+
+// locks is the number of locks that serialize the creation of accounts.
+const locks = 256
+
+// table holds the accounts. It is backed by a cache, so its memory usage stays bounded when
+// we are flooded from many addresses: accounts are evicted at random when it is full.
+type table struct {
+	c  *cache.Cache
+	mu [locks]sync.Mutex
+}
+
+func newTable(size int) *table { return &table{c: cache.New(size)} }
+
+// account returns the account for key, creating it with a full bucket when it does not exist.
+// Concurrent calls for a new key return the same account, so the burst is only granted once.
+func (t *table) account(key []byte, burst float64, now time.Time) *account {
+	h := cache.Hash(key)
+	if a, ok := t.c.Get(h); ok {
+		return a.(*account)
+	}
+
+	mu := &t.mu[h%locks]
+	mu.Lock()
+	defer mu.Unlock()
+	if a, ok := t.c.Get(h); ok {
+		return a.(*account)
+	}
+	a := newAccount(burst, now)
+	t.c.Add(h, a)
+	return a
+}
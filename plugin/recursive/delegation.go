@@ -0,0 +1,152 @@
+package recursive
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// delegation is a zone and the name servers it is delegated to.
+type delegation struct {
+	zone   string
+	ns     []string            // names of the name servers
+	glue   map[string][]string // name server to its addresses
+	expire time.Time           // zero for the root hints, which don't expire
+}
+
+// host holds the addresses of a name server that we resolved ourselves.
+type host struct {
+	addrs  []string
+	expire time.Time
+}
+
+// delegations caches the delegations and name server addresses learned while resolving. When
+// full, expired entries are removed, and if that isn't enough, arbitrary ones.
+type delegations struct {
+	sync.RWMutex
+	roots *delegation
+	zones map[string]*delegation
+	hosts map[string]host
+	size  int
+}
+
+func newDelegations(roots *delegation, size int) *delegations {
+	return &delegations{roots: roots, zones: map[string]*delegation{}, hosts: map[string]host{}, size: size}
+}
+
+// closest returns the cached delegation closest to name, falling back to the root hints.
+func (d *delegations) closest(name string, now time.Time) *delegation {
+	d.RLock()
+	defer d.RUnlock()
+	for off, end := 0, false; !end; off, end = dns.NextLabel(name, off) {
+		if del, ok := d.zones[name[off:]]; ok && now.Before(del.expire) {
+			return del
+		}
+	}
+	return d.roots
+}
+
+// add caches del.
+func (d *delegations) add(del *delegation) {
+	d.Lock()
+	defer d.Unlock()
+	if _, ok := d.zones[del.zone]; !ok && len(d.zones) >= d.size {
+		now := time.Now()
+		for z, x := range d.zones {
+			if !now.Before(x.expire) || len(d.zones) >= d.size {
+				delete(d.zones, z)
+			}
+		}
+	}
+	d.zones[del.zone] = del
+}
+
+// addresses returns the addresses of name server ns: its glue in del, or else the addresses we
+// resolved for it.
+func (d *delegations) addresses(del *delegation, ns string, now time.Time) []string {
+	if addrs := del.glue[ns]; len(addrs) > 0 {
+		return addrs
+	}
+	d.RLock()
+	defer d.RUnlock()
+	if h, ok := d.hosts[ns]; ok && now.Before(h.expire) {
+		return h.addrs
+	}
+	return nil
+}
+
+// addHost caches the addresses of name server ns.
+func (d *delegations) addHost(ns string, addrs []string, expire time.Time) {
+	d.Lock()
+	defer d.Unlock()
+	if _, ok := d.hosts[ns]; !ok && len(d.hosts) >= d.size {
+		now := time.Now()
+		for n, h := range d.hosts {
+			if !now.Before(h.expire) || len(d.hosts) >= d.size {
+				delete(d.hosts, n)
+			}
+		}
+	}
+	d.hosts[ns] = host{addrs: addrs, expire: expire}
+}
+
+// newDelegation returns the delegation to zone found in the authority section of a referral,
+// with the glue from the additional section. Glue is only accepted for names below parent, the
+// zone of the server that sent the referral.
+func newDelegation(m *dns.Msg, zone, parent string, now time.Time) *delegation {
+	del := &delegation{zone: zone, glue: map[string][]string{}}
+	ttl := uint32(0)
+	for _, rr := range m.Ns {
+		ns, ok := rr.(*dns.NS)
+		if !ok || !strings.EqualFold(ns.Hdr.Name, zone) {
+			continue
+		}
+		name := strings.ToLower(ns.Ns)
+		del.ns = append(del.ns, name)
+		if ttl == 0 || ns.Hdr.Ttl < ttl {
+			ttl = ns.Hdr.Ttl
+		}
+	}
+	if len(del.ns) == 0 {
+		return nil
+	}
+	for _, rr := range m.Extra {
+		name := strings.ToLower(rr.Header().Name)
+		if !dns.IsSubDomain(parent, name) || !contains(del.ns, name) {
+			continue
+		}
+		if ip := address(rr); ip != "" {
+			del.glue[name] = append(del.glue[name], ip)
+		}
+	}
+	if ttl > maxTTL {
+		ttl = maxTTL
+	}
+	del.expire = now.Add(time.Duration(ttl) * time.Second)
+	return del
+}
+
+// address returns the address of an A or AAAA record, or the empty string for other records.
+func address(rr dns.RR) string {
+	switch x := rr.(type) {
+	case *dns.A:
+		return x.A.String()
+	case *dns.AAAA:
+		return x.AAAA.String()
+	}
+	return ""
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// maxTTL is the longest time a delegation or name server address is cached.
+const maxTTL = 24 * 3600
@@ -0,0 +1,89 @@
+package recursive
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestNewDelegation(t *testing.T) {
+	m := new(dns.Msg)
+	m.Ns = []dns.RR{
+		test.NS("example.org. 600 IN NS ns1.example.org."),
+		test.NS("example.org. 300 IN NS NS2.example.org."),
+		test.NS("example.org. 300 IN NS ns.example.net."),
+	}
+	m.Extra = []dns.RR{
+		test.A("ns1.example.org. 600 IN A 192.0.2.1"),
+		test.AAAA("ns1.example.org. 600 IN AAAA 2001:db8::1"),
+		test.A("ns2.example.org. 600 IN A 192.0.2.2"),
+		test.A("ns.example.net. 600 IN A 192.0.2.3"),  // out of bailiwick of org.
+		test.A("www.example.org. 600 IN A 192.0.2.4"), // not a name server
+	}
+
+	now := time.Now()
+	del := newDelegation(m, "example.org.", "org.", now)
+	if del == nil {
+		t.Fatal("Expected a delegation")
+	}
+	if len(del.ns) != 3 {
+		t.Errorf("Expected 3 name servers, got %v", del.ns)
+	}
+	if len(del.glue) != 2 || len(del.glue["ns1.example.org."]) != 2 || len(del.glue["ns2.example.org."]) != 1 {
+		t.Errorf("Expected glue for ns1 and ns2, got %v", del.glue)
+	}
+	if !del.expire.Equal(now.Add(300 * time.Second)) {
+		t.Errorf("Expected the lowest TTL to be used")
+	}
+
+	if del := newDelegation(m, "example.net.", "net.", now); del != nil {
+		t.Errorf("Expected no delegation, got %v", del)
+	}
+}
+
+func TestDelegations(t *testing.T) {
+	roots := &delegation{zone: "."}
+	d := newDelegations(roots, 2)
+	now := time.Now()
+
+	d.add(&delegation{zone: "org.", expire: now.Add(time.Hour)})
+	d.add(&delegation{zone: "example.org.", expire: now.Add(time.Hour)})
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"www.example.org.", "example.org."},
+		{"example.org.", "example.org."},
+		{"example2.org.", "org."},
+		{"example.net.", "."},
+	}
+	for i, tc := range tests {
+		if got := d.closest(tc.name, now).zone; got != tc.expected {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.expected, got)
+		}
+	}
+	if got := d.closest("www.example.org.", now.Add(2*time.Hour)).zone; got != "." {
+		t.Errorf("Expected expired delegations to be skipped, got %s", got)
+	}
+
+	d.add(&delegation{zone: "net.", expire: now.Add(time.Hour)})
+	if len(d.zones) > 2 {
+		t.Errorf("Expected at most 2 delegations, got %d", len(d.zones))
+	}
+	if got := d.closest("example.net.", now).zone; got != "net." {
+		t.Errorf("Expected the new delegation to be added, got %s", got)
+	}
+
+	del := &delegation{zone: "example.org.", ns: []string{"ns.example.net."}, glue: map[string][]string{}}
+	if a := d.addresses(del, "ns.example.net.", now); a != nil {
+		t.Errorf("Expected no addresses, got %v", a)
+	}
+	d.addHost("ns.example.net.", []string{"192.0.2.1"}, now.Add(time.Hour))
+	if a := d.addresses(del, "ns.example.net.", now); len(a) != 1 {
+		t.Errorf("Expected the resolved address, got %v", a)
+	}
+}
@@ -0,0 +1,24 @@
+package recursive
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Variables declared for monitoring.
+var (
+	UpstreamCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "recursive",
+		Name:      "upstream_requests_total",
+		Help:      "Counter of queries sent to authoritative servers.",
+	}, []string{"server"})
+	ResolveDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "recursive",
+		Name:      "resolve_duration_seconds",
+		Buckets:   plugin.TimeBuckets,
+		Help:      "Histogram of the time it took to resolve a query.",
+	}, []string{"server"})
+)
@@ -0,0 +1,51 @@
+// Package recursive implements a plugin that resolves queries iteratively, starting at the root
+// servers.
+package recursive
+
+import (
+	"context"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// Recursive is the recursive plugin.
+type Recursive struct {
+	Next  plugin.Handler
+	Zones []string
+
+	r *resolver
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (rc *Recursive) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	if plugin.Zones(rc.Zones).Matches(state.Name()) == "" || state.QClass() != dns.ClassINET {
+		return plugin.NextOrFailure(rc.Name(), rc.Next, ctx, w, r)
+	}
+
+	start := time.Now()
+	m, err := rc.r.Resolve(ctx, state.Name(), state.QType())
+	ResolveDuration.WithLabelValues(metrics.WithServer(ctx)).Observe(time.Since(start).Seconds())
+	if err != nil {
+		log.Debugf("Failed to resolve %s %s: %s", state.Name(), state.Type(), err)
+		return dns.RcodeServerFailure, err
+	}
+
+	reply := new(dns.Msg)
+	reply.SetReply(r)
+	reply.RecursionAvailable = true
+	reply.Rcode = m.Rcode
+	reply.Answer = m.Answer
+	reply.Ns = m.Ns
+	state.SizeAndDo(reply)
+	w.WriteMsg(reply)
+	return dns.RcodeSuccess, nil
+}
+
+// Name implements the plugin.Handler interface.
+func (rc *Recursive) Name() string { return "recursive" }
@@ -0,0 +1,57 @@
+package recursive
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestRecursive(t *testing.T) {
+	n := newNetwork(t)
+	defer n.close()
+	rc := &Recursive{Zones: []string{"org."}, r: n.resolver(t), Next: test.NextHandler(dns.RcodeRefused, nil)}
+	rc.r.rtt.update("10.0.0.3", time.Millisecond)
+
+	tests := []struct {
+		qname  string
+		rcode  int
+		answer int
+	}{
+		{"www.example.org.", dns.RcodeSuccess, 1},
+		{"nx.example.org.", dns.RcodeNameError, 0},
+		{"www.example.net.", dns.RcodeRefused, 0}, // not in our zones
+	}
+	for i, tc := range tests {
+		req := new(dns.Msg)
+		req.SetQuestion(tc.qname, dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		rcode, _ := rc.ServeDNS(context.TODO(), rec, req)
+		if tc.rcode == dns.RcodeRefused {
+			if rcode != dns.RcodeRefused {
+				t.Errorf("Test %d: expected the query to be passed on, got rcode %d", i, rcode)
+			}
+			continue
+		}
+		if rec.Msg == nil {
+			t.Fatalf("Test %d: expected a reply", i)
+		}
+		if rec.Msg.Rcode != tc.rcode || len(rec.Msg.Answer) != tc.answer {
+			t.Errorf("Test %d: expected rcode %d and %d answers, got %s", i, tc.rcode, tc.answer, rec.Msg)
+		}
+		if !rec.Msg.RecursionAvailable {
+			t.Errorf("Test %d: expected RA to be set", i)
+		}
+	}
+
+	req := new(dns.Msg)
+	req.SetQuestion("www.lame.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if rcode, err := rc.ServeDNS(context.TODO(), rec, req); rcode != dns.RcodeServerFailure || err == nil {
+		t.Errorf("Expected SERVFAIL for a lame delegation, got %d %v", rcode, err)
+	}
+}
@@ -0,0 +1,356 @@
+package recursive
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin/metrics"
+
+	"github.com/miekg/dns"
+)
+
+// resolver resolves names iteratively, starting at the root servers.
+type resolver struct {
+	delegations *delegations
+	rtt         *rtts
+
+	minimize bool          // QNAME minimisation (RFC 9156)
+	timeout  time.Duration // of a single query to a server
+	attempts int           // number of servers tried for a query
+	maxDepth int           // nesting of name server lookups
+
+	// exchange sends m to addr, a host:port, and returns the reply and the round trip time.
+	exchange func(ctx context.Context, m *dns.Msg, addr string) (*dns.Msg, time.Duration, error)
+	port     string
+}
+
+// Limits on the work done for a single query.
+const (
+	maxQueries   = 64 // queries sent to servers
+	maxReferrals = 32 // referrals followed while resolving a name
+	maxCNAME     = 8  // CNAMEs followed
+	maxNSLookups = 3  // name servers without glue resolved for a delegation
+)
+
+var (
+	errBudget    = errors.New("too many queries")
+	errDepth     = errors.New("maximum name server lookup depth reached")
+	errReferrals = errors.New("too many referrals")
+	errCNAME     = errors.New("too many CNAMEs")
+	errLame      = errors.New("lame delegation")
+	errNoServers = errors.New("no servers could be reached")
+)
+
+func newResolver(roots *delegation) *resolver {
+	r := &resolver{
+		delegations: newDelegations(roots, defaultCacheSize),
+		rtt:         newRTTs(defaultCacheSize),
+		minimize:    true,
+		timeout:     defaultTimeout,
+		attempts:    defaultAttempts,
+		maxDepth:    defaultMaxDepth,
+		port:        "53",
+	}
+	r.exchange = r.exchangeNet
+	return r
+}
+
+// budget counts the queries sent to servers while resolving a single query.
+type budget struct{ n int }
+
+func (b *budget) take() bool {
+	if b.n <= 0 {
+		return false
+	}
+	b.n--
+	return true
+}
+
+// Resolve resolves qname and qtype and returns a reply holding the answer, or for negative
+// answers the authority section, and the rcode.
+func (r *resolver) Resolve(ctx context.Context, qname string, qtype uint16) (*dns.Msg, error) {
+	return r.resolve(ctx, strings.ToLower(dns.Fqdn(qname)), qtype, 0, &budget{n: maxQueries})
+}
+
+func (r *resolver) resolve(ctx context.Context, qname string, qtype uint16, depth int, b *budget) (*dns.Msg, error) {
+	if depth > r.maxDepth {
+		return nil, errDepth
+	}
+
+	res := new(dns.Msg)
+	name := qname
+	for i := 0; i <= maxCNAME; i++ {
+		m, zone, err := r.iterate(ctx, name, qtype, depth, b)
+		if err != nil {
+			return nil, err
+		}
+		answer, target := follow(m.Answer, name, qtype, zone)
+		res.Answer = append(res.Answer, answer...)
+		res.Rcode = m.Rcode
+		if target == "" {
+			if len(answer) == 0 || m.Rcode != dns.RcodeSuccess {
+				res.Ns = inZone(m.Ns, zone)
+			}
+			return res, nil
+		}
+		name = target
+	}
+	return nil, errCNAME
+}
+
+// follow returns the records of answer that answer name, following the CNAMEs in it. Only records
+// in zone, the zone of the server that sent them, are used. If the chain ends in a CNAME, its target
+// is returned to be resolved.
+func follow(answer []dns.RR, name string, qtype uint16, zone string) ([]dns.RR, string) {
+	var out []dns.RR
+	for i := 0; i <= maxCNAME; i++ {
+		if !dns.IsSubDomain(zone, name) {
+			return out, name
+		}
+		var (
+			found  bool
+			target string
+		)
+		for _, rr := range answer {
+			if !strings.EqualFold(rr.Header().Name, name) {
+				continue
+			}
+			if rr.Header().Rrtype == qtype || qtype == dns.TypeANY {
+				out = append(out, rr)
+				found = true
+				continue
+			}
+			if c, ok := rr.(*dns.CNAME); ok && target == "" {
+				out = append(out, rr)
+				target = strings.ToLower(c.Target)
+			}
+		}
+		if found || target == "" {
+			return out, ""
+		}
+		name = target
+	}
+	return out, ""
+}
+
+// inZone returns the records of rrs that are in zone.
+func inZone(rrs []dns.RR, zone string) []dns.RR {
+	var out []dns.RR
+	for _, rr := range rrs {
+		if dns.IsSubDomain(zone, rr.Header().Name) {
+			out = append(out, rr)
+		}
+	}
+	return out
+}
+
+// iterate follows the referrals from the closest known delegation to the servers of the zone of
+// qname, and returns their reply and the zone.
+func (r *resolver) iterate(ctx context.Context, qname string, qtype uint16, depth int, b *budget) (*dns.Msg, string, error) {
+	del := r.delegations.closest(qname, time.Now())
+	minimize := r.minimize
+	extra := 1 // labels beyond the zone in a minimized query
+
+	for i := 0; i < maxReferrals; i++ {
+		qn, qt := qname, qtype
+		if minimize {
+			qn = minimized(qname, del.zone, extra)
+			if qn != qname {
+				qt = dns.TypeA
+			}
+		}
+
+		m, err := r.query(ctx, del, qn, qt, depth, b)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if child := referral(m, del.zone, qn); child != "" {
+			next := newDelegation(m, child, del.zone, time.Now())
+			if next == nil {
+				return nil, "", errLame
+			}
+			r.delegations.add(next)
+			del = next
+			extra = 1
+			continue
+		}
+		if !answer(m, del.zone) {
+			return nil, "", errLame
+		}
+		if qn == qname {
+			return m, del.zone, nil
+		}
+		// A minimized query that didn't end in a referral: there is no zone cut at qn. An
+		// NXDOMAIN should mean qname doesn't exist either, but not all servers get this right,
+		// ask for the full name then.
+		if m.Rcode == dns.RcodeNameError {
+			minimize = false
+			continue
+		}
+		extra++
+	}
+	return nil, "", errReferrals
+}
+
+// minimized returns the name to query for qname at the servers of zone: zone with n more labels
+// of qname.
+func minimized(qname, zone string, n int) string {
+	labels := dns.CountLabel(zone) + n
+	if labels >= dns.CountLabel(qname) {
+		return qname
+	}
+	idx := dns.Split(qname)
+	return qname[idx[len(idx)-labels]:]
+}
+
+// referral returns the zone m delegates to, if m is a referral from the servers of zone for qname.
+func referral(m *dns.Msg, zone, qname string) string {
+	if m.Rcode != dns.RcodeSuccess || len(m.Answer) > 0 {
+		return ""
+	}
+	for _, rr := range m.Ns {
+		if rr.Header().Rrtype != dns.TypeNS {
+			continue
+		}
+		child := strings.ToLower(rr.Header().Name)
+		if child != zone && dns.IsSubDomain(zone, child) && dns.IsSubDomain(child, qname) {
+			return child
+		}
+	}
+	return ""
+}
+
+// answer returns true if m is an answer from the servers of zone: records, NODATA or NXDOMAIN. A
+// non-authoritative reply without answer that holds NS records for zone or above it is a lame one.
+func answer(m *dns.Msg, zone string) bool {
+	if m.Authoritative || m.Rcode == dns.RcodeNameError || len(m.Answer) > 0 {
+		return true
+	}
+	for _, rr := range m.Ns {
+		if rr.Header().Rrtype == dns.TypeNS && dns.IsSubDomain(rr.Header().Name, zone) {
+			return false
+		}
+	}
+	return true
+}
+
+// query sends the query to the servers of del, fastest first, and returns the first usable reply.
+// Name servers without glue are resolved when no server with a known address answered.
+func (r *resolver) query(ctx context.Context, del *delegation, qname string, qtype uint16, depth int, b *budget) (*dns.Msg, error) {
+	req := new(dns.Msg)
+	req.SetQuestion(qname, qtype)
+	req.RecursionDesired = false
+	req.SetEdns0(ednsSize, false)
+
+	var (
+		addrs   []string
+		missing []string
+	)
+	now := time.Now()
+	for _, ns := range del.ns {
+		a := r.delegations.addresses(del, ns, now)
+		if len(a) == 0 {
+			missing = append(missing, ns)
+		}
+		addrs = append(addrs, a...)
+	}
+
+	tried := map[string]bool{}
+	attempts := 0
+	for round := 0; round < 2; round++ {
+		r.rtt.sort(addrs)
+		for _, a := range addrs {
+			if tried[a] || attempts >= r.attempts {
+				continue
+			}
+			tried[a] = true
+			attempts++
+			if !b.take() {
+				return nil, errBudget
+			}
+			if m := r.send(ctx, req, a); m != nil {
+				return m, nil
+			}
+		}
+		if attempts >= r.attempts || len(missing) == 0 {
+			break
+		}
+		addrs = r.lookupNS(ctx, missing, depth, b)
+	}
+	return nil, errNoServers
+}
+
+// send sends req to the server at addr and returns its reply, or nil if it didn't give a usable one.
+func (r *resolver) send(ctx context.Context, req *dns.Msg, addr string) *dns.Msg {
+	UpstreamCount.WithLabelValues(metrics.WithServer(ctx)).Inc()
+
+	m, rtt, err := r.exchange(ctx, req, net.JoinHostPort(addr, r.port))
+	if err != nil {
+		log.Debugf("Failed to query %s for %s %s: %s", addr, req.Question[0].Name, dns.TypeToString[req.Question[0].Qtype], err)
+		r.rtt.timeout(addr)
+		return nil
+	}
+	r.rtt.update(addr, rtt)
+	if len(m.Question) != 1 || !strings.EqualFold(m.Question[0].Name, req.Question[0].Name) || m.Question[0].Qtype != req.Question[0].Qtype {
+		return nil
+	}
+	if m.Rcode != dns.RcodeSuccess && m.Rcode != dns.RcodeNameError {
+		log.Debugf("Server %s replied %s for %s %s", addr, dns.RcodeToString[m.Rcode], req.Question[0].Name, dns.TypeToString[req.Question[0].Qtype])
+		return nil
+	}
+	return m
+}
+
+// lookupNS resolves the addresses of name servers without glue, and caches them.
+func (r *resolver) lookupNS(ctx context.Context, names []string, depth int, b *budget) []string {
+	var addrs []string
+	for i, ns := range names {
+		if i == maxNSLookups {
+			break
+		}
+		m, err := r.resolve(ctx, ns, dns.TypeA, depth+1, b)
+		if err != nil {
+			log.Debugf("Failed to resolve name server %s: %s", ns, err)
+			continue
+		}
+		var (
+			a   []string
+			ttl uint32
+		)
+		for _, rr := range m.Answer {
+			if ip := address(rr); ip != "" {
+				a = append(a, ip)
+				if ttl == 0 || rr.Header().Ttl < ttl {
+					ttl = rr.Header().Ttl
+				}
+			}
+		}
+		if len(a) == 0 {
+			continue
+		}
+		if ttl > maxTTL {
+			ttl = maxTTL
+		}
+		r.delegations.addHost(ns, a, time.Now().Add(time.Duration(ttl)*time.Second))
+		addrs = append(addrs, a...)
+	}
+	return addrs
+}
+
+// exchangeNet sends m over UDP, and retries over TCP when the reply is truncated.
+func (r *resolver) exchangeNet(ctx context.Context, m *dns.Msg, addr string) (*dns.Msg, time.Duration, error) {
+	c := &dns.Client{Net: "udp", Timeout: r.timeout, UDPSize: ednsSize}
+	ret, rtt, err := c.ExchangeContext(ctx, m, addr)
+	if err == nil && ret.Truncated {
+		c.Net = "tcp"
+		ret, rtt, err = c.ExchangeContext(ctx, m, addr)
+	}
+	return ret, rtt, err
+}
+
+// ednsSize is the EDNS0 buffer size we advertise, see https://dnsflagday.net/2020/.
+const ednsSize = 1232
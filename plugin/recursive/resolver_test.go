@@ -0,0 +1,341 @@
+package recursive
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/file"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+// The zones of the test network, served by the servers with the addresses in the keys.
+var testNetwork = map[string][]string{
+	"10.0.0.1": {`$TTL 3600
+.                   IN SOA a.root-servers.test. admin.root-servers.test. 1 3600 600 86400 60
+.                   IN NS  a.root-servers.test.
+a.root-servers.test. IN A  10.0.0.1
+org.                IN NS  ns1.org.
+ns1.org.            IN A   10.0.0.2
+net.                IN NS  ns.net.
+ns.net.             IN A   10.0.0.4
+`},
+	"10.0.0.2": {`$TTL 3600
+org.                IN SOA ns1.org. admin.org. 1 3600 600 86400 60
+org.                IN NS  ns1.org.
+ns1.org.            IN A   10.0.0.2
+example.org.        IN NS  ns.example.org.
+example.org.        IN NS  ns2.example.org.
+ns.example.org.     IN A   10.0.0.3
+ns2.example.org.    IN A   10.0.0.9
+glueless.org.       IN NS  ns.example.net.
+lame.org.           IN NS  ns.lame.org.
+ns.lame.org.        IN A   10.0.0.6
+`},
+	"10.0.0.3": {`$TTL 3600
+example.org.        IN SOA ns.example.org. admin.example.org. 1 3600 600 86400 60
+example.org.        IN NS  ns.example.org.
+example.org.        IN NS  ns2.example.org.
+ns.example.org.     IN A   10.0.0.3
+ns2.example.org.    IN A   10.0.0.9
+www.example.org.    IN A   192.0.2.1
+alias.example.org.  IN CNAME www.example.org.
+external.example.org. IN CNAME www.glueless.org.
+a.b.c.example.org.  IN A   192.0.2.3
+`},
+	"10.0.0.4": {`$TTL 3600
+net.                IN SOA ns.net. admin.net. 1 3600 600 86400 60
+net.                IN NS  ns.net.
+ns.net.             IN A   10.0.0.4
+example.net.        IN NS  ns.example.net.
+ns.example.net.     IN A   10.0.0.5
+`},
+	"10.0.0.5": {`$TTL 3600
+example.net.        IN SOA ns.example.net. admin.example.net. 1 3600 600 86400 60
+example.net.        IN NS  ns.example.net.
+ns.example.net.     IN A   10.0.0.5
+`, `$TTL 3600
+glueless.org.       IN SOA ns.example.net. admin.example.net. 1 3600 600 86400 60
+glueless.org.       IN NS  ns.example.net.
+www.glueless.org.   IN A   192.0.2.2
+`},
+	// Claims to be a server for lame.org. but only knows about the root.
+	"10.0.0.6": {`$TTL 3600
+.                   IN SOA a.root-servers.test. admin.root-servers.test. 1 3600 600 86400 60
+.                   IN NS  a.root-servers.test.
+a.root-servers.test. IN A  10.0.0.1
+org.                IN NS  ns1.org.
+ns1.org.            IN A   10.0.0.2
+`},
+}
+
+const testHints = `.   3600000 NS a.root-servers.test.
+a.root-servers.test. 3600000 A 10.0.0.1
+`
+
+// network runs the servers of testNetwork and logs the queries they get.
+type network struct {
+	servers map[string]*dnstest.Server // by address in the zones
+	dead    string                     // address where nothing listens
+
+	mu      sync.Mutex
+	queries []string // "address name type"
+}
+
+func newNetwork(t *testing.T) *network {
+	n := &network{servers: map[string]*dnstest.Server{}}
+	for addr, zones := range testNetwork {
+		f := file.File{Zones: file.Zones{Z: map[string]*file.Zone{}}}
+		for _, zone := range zones {
+			origin := strings.Fields(zone)[2]
+			z, err := file.Parse(strings.NewReader(zone), origin, "stdin", 0)
+			if err != nil {
+				t.Fatalf("Failed to parse zone %s: %s", origin, err)
+			}
+			f.Zones.Z[origin] = z
+			f.Zones.Names = append(f.Zones.Names, origin)
+		}
+		addr := addr
+		n.servers[addr] = dnstest.NewMultipleServer(func(w dns.ResponseWriter, r *dns.Msg) {
+			n.mu.Lock()
+			n.queries = append(n.queries, fmt.Sprintf("%s %s %s", addr, r.Question[0].Name, dns.TypeToString[r.Question[0].Qtype]))
+			n.mu.Unlock()
+			f.ServeDNS(context.TODO(), w, r)
+		})
+	}
+
+	// Find a port nothing listens on.
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.dead = l.LocalAddr().String()
+	l.Close()
+	return n
+}
+
+func (n *network) close() {
+	for _, s := range n.servers {
+		s.Close()
+	}
+}
+
+// resolver returns a resolver that sends its queries to the servers of the network.
+func (n *network) resolver(t *testing.T) *resolver {
+	roots, err := parseHints(strings.NewReader(testHints), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newResolver(roots)
+	r.timeout = 500 * time.Millisecond
+	r.exchange = func(ctx context.Context, m *dns.Msg, addr string) (*dns.Msg, time.Duration, error) {
+		host, _, _ := net.SplitHostPort(addr)
+		to := n.dead
+		if s, ok := n.servers[host]; ok {
+			to = s.Addr
+		}
+		return r.exchangeNet(ctx, m, to)
+	}
+	return r
+}
+
+// seen returns the queries the server at addr got.
+func (n *network) seen(addr string) []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var qs []string
+	for _, q := range n.queries {
+		if strings.HasPrefix(q, addr+" ") {
+			qs = append(qs, strings.TrimPrefix(q, addr+" "))
+		}
+	}
+	return qs
+}
+
+func (n *network) reset() {
+	n.mu.Lock()
+	n.queries = nil
+	n.mu.Unlock()
+}
+
+func TestResolve(t *testing.T) {
+	n := newNetwork(t)
+	defer n.close()
+	r := n.resolver(t)
+	// Make sure the working server for example.org is tried first.
+	r.rtt.update("10.0.0.3", time.Millisecond)
+
+	tests := []struct {
+		qname  string
+		qtype  uint16
+		rcode  int
+		answer []dns.RR
+		ns     []dns.RR
+	}{
+		{
+			qname: "www.example.org.", qtype: dns.TypeA,
+			answer: []dns.RR{test.A("www.example.org. 3600 IN A 192.0.2.1")},
+		},
+		{
+			qname: "WWW.Example.org.", qtype: dns.TypeA,
+			answer: []dns.RR{test.A("www.example.org. 3600 IN A 192.0.2.1")},
+		},
+		{
+			qname: "alias.example.org.", qtype: dns.TypeA,
+			answer: []dns.RR{
+				test.CNAME("alias.example.org. 3600 IN CNAME www.example.org."),
+				test.A("www.example.org. 3600 IN A 192.0.2.1"),
+			},
+		},
+		{
+			// Resolves the name server of glueless.org. itself.
+			qname: "external.example.org.", qtype: dns.TypeA,
+			answer: []dns.RR{
+				test.CNAME("external.example.org. 3600 IN CNAME www.glueless.org."),
+				test.A("www.glueless.org. 3600 IN A 192.0.2.2"),
+			},
+		},
+		{
+			qname: "a.b.c.example.org.", qtype: dns.TypeA,
+			answer: []dns.RR{test.A("a.b.c.example.org. 3600 IN A 192.0.2.3")},
+		},
+		{
+			qname: "nx.example.org.", qtype: dns.TypeA, rcode: dns.RcodeNameError,
+			ns: []dns.RR{test.SOA("example.org. 3600 IN SOA ns.example.org. admin.example.org. 1 3600 600 86400 60")},
+		},
+		{
+			qname: "www.example.org.", qtype: dns.TypeMX,
+			ns: []dns.RR{test.SOA("example.org. 3600 IN SOA ns.example.org. admin.example.org. 1 3600 600 86400 60")},
+		},
+	}
+
+	for i, tc := range tests {
+		m, err := r.Resolve(context.TODO(), tc.qname, tc.qtype)
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if m.Rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %d, got %d", i, tc.rcode, m.Rcode)
+		}
+		if err := test.Section(test.Case{Answer: tc.answer}, test.Answer, m.Answer); err != nil {
+			t.Errorf("Test %d: %s", i, err)
+		}
+		if err := test.Section(test.Case{Ns: tc.ns}, test.Ns, m.Ns); err != nil {
+			t.Errorf("Test %d: %s", i, err)
+		}
+	}
+}
+
+func TestResolveMinimization(t *testing.T) {
+	n := newNetwork(t)
+	defer n.close()
+	r := n.resolver(t)
+	r.rtt.update("10.0.0.3", time.Millisecond)
+
+	if _, err := r.Resolve(context.TODO(), "a.b.c.example.org.", dns.TypeA); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	expected := map[string][]string{
+		"10.0.0.1": {"org. A"},
+		"10.0.0.2": {"example.org. A"},
+		"10.0.0.3": {"c.example.org. A", "b.c.example.org. A", "a.b.c.example.org. A"},
+	}
+	for addr, qs := range expected {
+		if got := n.seen(addr); strings.Join(got, ",") != strings.Join(qs, ",") {
+			t.Errorf("Expected %s to get %v, got %v", addr, qs, got)
+		}
+	}
+
+	// The delegations are cached: the next query goes to the servers of example.org. right away.
+	n.reset()
+	if _, err := r.Resolve(context.TODO(), "www.example.org.", dns.TypeA); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if got := n.seen("10.0.0.1"); len(got) != 0 {
+		t.Errorf("Expected no queries for the root, got %v", got)
+	}
+	if got := n.seen("10.0.0.3"); strings.Join(got, ",") != "www.example.org. A" {
+		t.Errorf("Expected a single query for www.example.org., got %v", got)
+	}
+
+	// Without minimisation the full name is sent to every server.
+	n.reset()
+	r = n.resolver(t)
+	r.minimize = false
+	r.rtt.update("10.0.0.3", time.Millisecond)
+	if _, err := r.Resolve(context.TODO(), "www.example.org.", dns.TypeA); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if got := n.seen("10.0.0.1"); strings.Join(got, ",") != "www.example.org. A" {
+		t.Errorf("Expected the full name at the root, got %v", got)
+	}
+}
+
+func TestResolveRetry(t *testing.T) {
+	n := newNetwork(t)
+	defer n.close()
+	r := n.resolver(t)
+	r.timeout = 100 * time.Millisecond
+	// Make sure the dead server for example.org is tried first.
+	r.rtt.update("10.0.0.3", 5*time.Second)
+	r.rtt.update("10.0.0.9", time.Millisecond)
+
+	m, err := r.Resolve(context.TODO(), "www.example.org.", dns.TypeA)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if len(m.Answer) != 1 {
+		t.Errorf("Expected an answer, got %v", m.Answer)
+	}
+	if d := r.rtt.get("10.0.0.9"); d <= time.Millisecond {
+		t.Errorf("Expected the dead server to be penalized, got %s", d)
+	}
+
+	// With a single attempt the query fails.
+	r = n.resolver(t)
+	r.timeout = 100 * time.Millisecond
+	r.attempts = 1
+	r.rtt.update("10.0.0.3", 5*time.Second)
+	r.rtt.update("10.0.0.9", time.Millisecond)
+	if _, err := r.Resolve(context.TODO(), "www.example.org.", dns.TypeA); err == nil {
+		t.Errorf("Expected error with a single attempt")
+	}
+}
+
+func TestResolveLame(t *testing.T) {
+	n := newNetwork(t)
+	defer n.close()
+	r := n.resolver(t)
+
+	if _, err := r.Resolve(context.TODO(), "www.lame.org.", dns.TypeA); err == nil {
+		t.Errorf("Expected error for lame delegation")
+	}
+}
+
+func TestMinimized(t *testing.T) {
+	tests := []struct {
+		qname, zone string
+		n           int
+		expected    string
+	}{
+		{"www.example.org.", ".", 1, "org."},
+		{"www.example.org.", ".", 2, "example.org."},
+		{"www.example.org.", "org.", 1, "example.org."},
+		{"www.example.org.", "example.org.", 1, "www.example.org."},
+		{"www.example.org.", "example.org.", 5, "www.example.org."},
+		{"org.", ".", 1, "org."},
+	}
+	for i, tc := range tests {
+		if got := minimized(tc.qname, tc.zone, tc.n); got != tc.expected {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.expected, got)
+		}
+	}
+}
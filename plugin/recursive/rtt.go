@@ -0,0 +1,81 @@
+package recursive
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// rtts tracks the smoothed round trip time of the servers we query, so the fastest ones are
+// tried first. A server that times out gets its time doubled.
+type rtts struct {
+	sync.Mutex
+	m    map[string]time.Duration
+	size int
+}
+
+// Bounds of the round trip times. An unknown server gets initialRTT, which is low enough for it
+// to be tried before slow servers.
+const (
+	initialRTT = 300 * time.Millisecond
+	maxRTT     = 10 * time.Second
+)
+
+func newRTTs(size int) *rtts { return &rtts{m: map[string]time.Duration{}, size: size} }
+
+func (r *rtts) get(addr string) time.Duration {
+	r.Lock()
+	defer r.Unlock()
+	if d, ok := r.m[addr]; ok {
+		return d
+	}
+	return initialRTT
+}
+
+// update adds a measured round trip time for addr.
+func (r *rtts) update(addr string, d time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+	if old, ok := r.m[addr]; ok {
+		d = (7*old + 3*d) / 10
+	}
+	r.set(addr, d)
+}
+
+// timeout penalizes addr after it failed to answer.
+func (r *rtts) timeout(addr string) {
+	r.Lock()
+	defer r.Unlock()
+	d, ok := r.m[addr]
+	if !ok {
+		d = initialRTT
+	}
+	d *= 2
+	if d > maxRTT {
+		d = maxRTT
+	}
+	r.set(addr, d)
+}
+
+// set sets the time of addr, removing arbitrary entries when the map is full. Must be called
+// with the lock held.
+func (r *rtts) set(addr string, d time.Duration) {
+	if _, ok := r.m[addr]; !ok && len(r.m) >= r.size {
+		for a := range r.m {
+			delete(r.m, a)
+			break
+		}
+	}
+	r.m[addr] = d
+}
+
+// sort sorts addrs by round trip time, fastest first. Addresses with the same time are shuffled.
+func (r *rtts) sort(addrs []string) {
+	rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+	d := make(map[string]time.Duration, len(addrs))
+	for _, a := range addrs {
+		d[a] = r.get(a)
+	}
+	sort.SliceStable(addrs, func(i, j int) bool { return d[addrs[i]] < d[addrs[j]] })
+}
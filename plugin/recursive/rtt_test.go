@@ -0,0 +1,35 @@
+package recursive
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRTT(t *testing.T) {
+	r := newRTTs(10)
+
+	if d := r.get("192.0.2.1"); d != initialRTT {
+		t.Errorf("Expected %s for an unknown server, got %s", initialRTT, d)
+	}
+	r.update("192.0.2.1", 100*time.Millisecond)
+	r.update("192.0.2.1", 200*time.Millisecond)
+	if d := r.get("192.0.2.1"); d != 130*time.Millisecond {
+		t.Errorf("Expected smoothed time of 130ms, got %s", d)
+	}
+	r.timeout("192.0.2.1")
+	if d := r.get("192.0.2.1"); d != 260*time.Millisecond {
+		t.Errorf("Expected doubled time of 260ms, got %s", d)
+	}
+	for i := 0; i < 10; i++ {
+		r.timeout("192.0.2.2")
+	}
+	if d := r.get("192.0.2.2"); d != maxRTT {
+		t.Errorf("Expected time to be capped at %s, got %s", maxRTT, d)
+	}
+
+	addrs := []string{"192.0.2.2", "192.0.2.3", "192.0.2.1"}
+	r.sort(addrs)
+	if addrs[0] != "192.0.2.1" || addrs[1] != "192.0.2.3" || addrs[2] != "192.0.2.2" {
+		t.Errorf("Expected fastest first, got %v", addrs)
+	}
+}
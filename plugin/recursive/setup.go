@@ -0,0 +1,139 @@
+package recursive
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/mholt/caddy"
+)
+
+var log = clog.NewWithPlugin("recursive")
+
+func init() {
+	caddy.RegisterPlugin("recursive", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+// Defaults of the options.
+const (
+	defaultTimeout   = 2 * time.Second
+	defaultAttempts  = 3
+	defaultMaxDepth  = 5
+	defaultCacheSize = 10000
+)
+
+func setup(c *caddy.Controller) error {
+	rc, err := parse(c)
+	if err != nil {
+		return plugin.Error("recursive", err)
+	}
+
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, UpstreamCount, ResolveDuration)
+		return nil
+	})
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		rc.Next = next
+		return rc
+	})
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (*Recursive, error) {
+	config := dnsserver.GetConfig(c)
+	roots, err := parseHints(strings.NewReader(rootHints), "built-in root hints")
+	if err != nil {
+		return nil, err
+	}
+	rc := &Recursive{r: newResolver(roots)}
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		rc.Zones = make([]string, len(c.ServerBlockKeys))
+		copy(rc.Zones, c.ServerBlockKeys)
+		if args := c.RemainingArgs(); len(args) > 0 {
+			rc.Zones = args
+		}
+		for j := range rc.Zones {
+			rc.Zones[j] = plugin.Host(rc.Zones[j]).Normalize()
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "root_hints":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				fileName := args[0]
+				if !filepath.IsAbs(fileName) && config.Root != "" {
+					fileName = filepath.Join(config.Root, fileName)
+				}
+				f, err := os.Open(fileName)
+				if err != nil {
+					return nil, err
+				}
+				roots, err := parseHints(f, fileName)
+				f.Close()
+				if err != nil {
+					return nil, err
+				}
+				rc.r.delegations.roots = roots
+			case "no_qname_minimization":
+				if c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				rc.r.minimize = false
+			case "timeout":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(args[0])
+				if err != nil {
+					return nil, err
+				}
+				if d <= 0 {
+					return nil, fmt.Errorf("timeout must be positive: %s", d)
+				}
+				rc.r.timeout = d
+			case "attempts", "max_depth":
+				name := c.Val()
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				n, err := strconv.Atoi(args[0])
+				if err != nil || n <= 0 {
+					return nil, fmt.Errorf("invalid %s %q", name, args[0])
+				}
+				if name == "attempts" {
+					rc.r.attempts = n
+				} else {
+					rc.r.maxDepth = n
+				}
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	return rc, nil
+}
@@ -0,0 +1,87 @@
+package recursive
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupParse(t *testing.T) {
+	hints, err := ioutil.TempFile("", "hints")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(hints.Name())
+	hints.WriteString(testHints)
+	hints.Close()
+
+	tests := []struct {
+		input     string
+		shouldErr bool
+		zones     []string
+		minimize  bool
+		timeout   time.Duration
+		attempts  int
+		maxDepth  int
+		roots     int
+	}{
+		{`recursive`, false, []string{"."}, true, defaultTimeout, defaultAttempts, defaultMaxDepth, 13},
+		{`recursive example.org {
+			root_hints ` + hints.Name() + `
+			no_qname_minimization
+			timeout 1s
+			attempts 5
+			max_depth 3
+		}`, false, []string{"example.org."}, false, time.Second, 5, 3, 1},
+		// fails
+		{`recursive {
+			root_hints /does/not/exist
+		}`, true, nil, false, 0, 0, 0, 0},
+		{`recursive {
+			no_qname_minimization yes
+		}`, true, nil, false, 0, 0, 0, 0},
+		{`recursive {
+			timeout 0s
+		}`, true, nil, false, 0, 0, 0, 0},
+		{`recursive {
+			attempts 0
+		}`, true, nil, false, 0, 0, 0, 0},
+		{`recursive {
+			max_depth x
+		}`, true, nil, false, 0, 0, 0, 0},
+		{`recursive {
+			blaat
+		}`, true, nil, false, 0, 0, 0, 0},
+		{`recursive
+		recursive`, true, nil, false, 0, 0, 0, 0},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		c.ServerBlockKeys = []string{"."}
+		rc, err := parse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if len(rc.Zones) != len(tc.zones) || rc.Zones[0] != tc.zones[0] {
+			t.Errorf("Test %d: expected zones %v, got %v", i, tc.zones, rc.Zones)
+		}
+		r := rc.r
+		if r.minimize != tc.minimize || r.timeout != tc.timeout || r.attempts != tc.attempts || r.maxDepth != tc.maxDepth {
+			t.Errorf("Test %d: unexpected options %v %s %d %d", i, r.minimize, r.timeout, r.attempts, r.maxDepth)
+		}
+		if roots := len(r.delegations.roots.glue); roots != tc.roots {
+			t.Errorf("Test %d: expected %d root servers, got %d", i, tc.roots, roots)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// retryInterval is how long we wait before subscribing again after losing the connection.
+var retryInterval = 5 * time.Second
+
+// Run subscribes to the keyspace notifications of the hashes and to the change channel, and
+// removes the zones that change from the cache, until ctx is done.
+//
+// Keyspace notifications are only published when Redis is configured with notify-keyspace-events
+// including "Kh". Any client may publish the name of a changed zone on the change channel instead;
+// an empty message or "*" removes all zones.
+func (r *Redis) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		err := r.subscribe(ctx)
+		// We may have missed notifications.
+		r.invalidate("")
+		if ctx.Err() == nil {
+			log.Warningf("Lost the subscription to changes: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(retryInterval):
+			}
+		}
+	}
+}
+
+func (r *Redis) subscribe(ctx context.Context) error {
+	conn, err := r.dial()
+	if err != nil {
+		return err
+	}
+	psc := redis.PubSubConn{Conn: conn}
+	defer psc.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// Unblocks Receive.
+			psc.Close()
+		case <-done:
+		}
+	}()
+
+	keyspace := fmt.Sprintf("__keyspace@%d__:", r.db)
+	if err := psc.PSubscribe(keyspace + r.prefix + "*"); err != nil {
+		return err
+	}
+	if err := psc.Subscribe(r.channel); err != nil {
+		return err
+	}
+
+	for {
+		switch v := psc.Receive().(type) {
+		case redis.Message:
+			if v.Pattern != "" {
+				key := strings.TrimPrefix(v.Channel, keyspace)
+				r.invalidate(plugin.Name(strings.TrimPrefix(key, r.prefix)).Normalize())
+				continue
+			}
+			zone := string(v.Data)
+			if zone == "" || zone == "*" {
+				r.invalidate("")
+				continue
+			}
+			r.invalidate(plugin.Name(zone).Normalize())
+
+		case redis.Subscription:
+			// Zones loaded before we subscribed may have changed since.
+			r.invalidate("")
+
+		case error:
+			return v
+		}
+	}
+}
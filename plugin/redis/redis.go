@@ -0,0 +1,147 @@
+// Package redis implements a plugin that serves zones stored in Redis hashes.
+package redis
+
+import (
+	"context"
+	"sync"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/file"
+	"github.com/coredns/coredns/plugin/pkg/fall"
+	"github.com/coredns/coredns/plugin/pkg/upstream"
+	"github.com/coredns/coredns/request"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/miekg/dns"
+)
+
+// Redis is a plugin that serves zones from Redis. Every zone is a hash, whose fields hold the
+// record sets of the zone. The zones are cached in memory until they are changed in Redis.
+type Redis struct {
+	Next     plugin.Handler
+	Fall     fall.F
+	Zones    []string
+	Upstream *upstream.Upstream
+
+	pool    *redis.Pool
+	dial    func() (redis.Conn, error)
+	db      int
+	prefix  string // prefix of the keys of the hashes, the zone name follows it
+	channel string // pub/sub channel for change notifications
+	ttl     uint32 // TTL of the records that don't have one
+
+	mu    sync.RWMutex
+	zones map[string]*file.Zone // cached zones, nil when the zone doesn't exist
+	gen   uint64                // incremented on every invalidation
+
+	loadMu sync.Mutex
+}
+
+// New returns a new Redis plugin for zones that uses dial to connect to Redis.
+func New(zones []string, dial func() (redis.Conn, error)) *Redis {
+	r := &Redis{
+		Zones:    zones,
+		Upstream: upstream.New(),
+		dial:     dial,
+		prefix:   defaultPrefix,
+		channel:  defaultChannel,
+		ttl:      defaultTTL,
+		zones:    make(map[string]*file.Zone),
+	}
+	r.pool = &redis.Pool{Dial: dial, MaxIdle: 10, IdleTimeout: idleTimeout}
+	return r
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (r *Redis) ServeDNS(ctx context.Context, w dns.ResponseWriter, m *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: m}
+	qname := state.Name()
+
+	zone := plugin.Zones(r.Zones).Matches(qname)
+	if zone == "" {
+		return plugin.NextOrFailure(r.Name(), r.Next, ctx, w, m)
+	}
+
+	z, err := r.zone(zone)
+	if err != nil {
+		return dns.RcodeServerFailure, plugin.Error(r.Name(), err)
+	}
+	if z == nil {
+		return plugin.NextOrFailure(r.Name(), r.Next, ctx, w, m)
+	}
+
+	answer, ns, extra, result := z.Lookup(ctx, state, qname)
+	if result == file.NameError && r.Fall.Through(qname) {
+		return plugin.NextOrFailure(r.Name(), r.Next, ctx, w, m)
+	}
+
+	a := new(dns.Msg)
+	a.SetReply(m)
+	a.Authoritative = true
+	a.Answer, a.Ns, a.Extra = answer, ns, extra
+
+	switch result {
+	case file.Success:
+	case file.NoData:
+	case file.NameError:
+		a.Rcode = dns.RcodeNameError
+	case file.Delegation:
+		a.Authoritative = false
+	case file.ServerFailure:
+		return dns.RcodeServerFailure, nil
+	}
+
+	w.WriteMsg(a)
+	return dns.RcodeSuccess, nil
+}
+
+// Name implements the plugin.Handler interface.
+func (r *Redis) Name() string { return "redis" }
+
+// zone returns the zone name from the cache, or loads it from Redis. A zone that doesn't exist
+// in Redis is nil.
+func (r *Redis) zone(name string) (*file.Zone, error) {
+	r.mu.RLock()
+	z, ok := r.zones[name]
+	r.mu.RUnlock()
+	if ok {
+		return z, nil
+	}
+
+	// Only load a zone once when many queries for it arrive at the same time.
+	r.loadMu.Lock()
+	defer r.loadMu.Unlock()
+
+	r.mu.RLock()
+	z, ok = r.zones[name]
+	gen := r.gen
+	r.mu.RUnlock()
+	if ok {
+		return z, nil
+	}
+
+	z, err := r.load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	// Don't cache the zone if it changed while we were loading it.
+	if r.gen == gen {
+		r.zones[name] = z
+	}
+	r.mu.Unlock()
+	return z, nil
+}
+
+// invalidate removes zone from the cache, or all zones when zone is empty.
+func (r *Redis) invalidate(zone string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gen++
+	if zone == "" {
+		r.zones = make(map[string]*file.Zone)
+		return
+	}
+	delete(r.zones, zone)
+}
@@ -0,0 +1,230 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+	"github.com/miekg/dns"
+)
+
+func newTestRedis(t *testing.T) (*Redis, *miniredis.Miniredis) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.HSet("dns:example.org.",
+		"@/SOA", `{"ttl": 3600, "records": ["ns hostmaster 2019052401 7200 1800 86400 300"]}`,
+		"@/NS", `{"records": ["ns"]}`,
+		"ns/A", `{"records": ["192.0.2.53"]}`,
+		"www/A", `{"ttl": 60, "records": ["192.0.2.1", "192.0.2.2"]}`,
+		"www/AAAA", `{"records": ["2001:db8::1"]}`,
+		"*.dev/CNAME", `{"records": ["www"]}`,
+		"mail.example.org./MX", `{"records": ["10 www"]}`,
+		"bad/A", `{"records": ["not-an-address"]}`,
+		"json/A", `not json`,
+		"multi/A", `{"records": ["192.0.2.3\nevil 300 IN A 192.0.2.4"]}`,
+		"out.example.net./A", `{"records": ["192.0.2.5"]}`,
+		"nofield", `{"records": ["192.0.2.6"]}`,
+	)
+	dial := func() (redis.Conn, error) { return redis.Dial("tcp", s.Addr()) }
+	return New([]string{"example.org.", "example.net."}, dial), s
+}
+
+func TestRedis(t *testing.T) {
+	r, s := newTestRedis(t)
+	defer s.Close()
+
+	tests := []test.Case{
+		{
+			Qname: "www.example.org.", Qtype: dns.TypeA,
+			Answer: []dns.RR{
+				test.A("www.example.org. 60 IN A 192.0.2.1"),
+				test.A("www.example.org. 60 IN A 192.0.2.2"),
+			},
+			Ns: []dns.RR{test.NS("example.org. 300 IN NS ns.example.org.")},
+		},
+		{
+			Qname: "www.example.org.", Qtype: dns.TypeAAAA,
+			Answer: []dns.RR{test.AAAA("www.example.org. 300 IN AAAA 2001:db8::1")},
+			Ns:     []dns.RR{test.NS("example.org. 300 IN NS ns.example.org.")},
+		},
+		{
+			Qname: "host.dev.example.org.", Qtype: dns.TypeA,
+			Answer: []dns.RR{
+				test.CNAME("host.dev.example.org. 300 IN CNAME www.example.org."),
+				test.A("www.example.org. 60 IN A 192.0.2.1"),
+				test.A("www.example.org. 60 IN A 192.0.2.2"),
+			},
+			Ns: []dns.RR{test.NS("example.org. 300 IN NS ns.example.org.")},
+		},
+		{
+			Qname: "mail.example.org.", Qtype: dns.TypeMX,
+			Answer: []dns.RR{test.MX("mail.example.org. 300 IN MX 10 www.example.org.")},
+			Ns:     []dns.RR{test.NS("example.org. 300 IN NS ns.example.org.")},
+			Extra: []dns.RR{
+				test.AAAA("www.example.org. 300 IN AAAA 2001:db8::1"),
+				test.A("www.example.org. 60 IN A 192.0.2.1"),
+				test.A("www.example.org. 60 IN A 192.0.2.2"),
+			},
+		},
+		{
+			Qname: "example.org.", Qtype: dns.TypeSOA,
+			Answer: []dns.RR{test.SOA("example.org. 3600 IN SOA ns.example.org. hostmaster.example.org. 2019052401 7200 1800 86400 300")},
+			Ns:     []dns.RR{test.NS("example.org. 300 IN NS ns.example.org.")},
+		},
+		{
+			Qname: "bad.example.org.", Qtype: dns.TypeA,
+			Rcode: dns.RcodeNameError,
+			Ns:    []dns.RR{test.SOA("example.org. 3600 IN SOA ns.example.org. hostmaster.example.org. 2019052401 7200 1800 86400 300")},
+		},
+		{
+			Qname: "multi.example.org.", Qtype: dns.TypeA,
+			Rcode: dns.RcodeNameError,
+			Ns:    []dns.RR{test.SOA("example.org. 3600 IN SOA ns.example.org. hostmaster.example.org. 2019052401 7200 1800 86400 300")},
+		},
+		{
+			Qname: "evil.example.org.", Qtype: dns.TypeA,
+			Rcode: dns.RcodeNameError,
+			Ns:    []dns.RR{test.SOA("example.org. 3600 IN SOA ns.example.org. hostmaster.example.org. 2019052401 7200 1800 86400 300")},
+		},
+	}
+
+	ctx := context.TODO()
+	for i, tc := range tests {
+		m := tc.Msg()
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := r.ServeDNS(ctx, rec, m); err != nil {
+			t.Errorf("Test %d: expected no error, got %v", i, err)
+			continue
+		}
+		if err := test.SortAndCheck(rec.Msg, tc); err != nil {
+			t.Errorf("Test %d: %v", i, err)
+		}
+	}
+
+	// example.net. doesn't exist in Redis, and there is no next plugin.
+	m := new(dns.Msg)
+	m.SetQuestion("www.example.net.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if code, _ := r.ServeDNS(ctx, rec, m); code != dns.RcodeServerFailure {
+		t.Errorf("Expected SERVFAIL for a zone that doesn't exist, got %d", code)
+	}
+}
+
+func TestRedisCache(t *testing.T) {
+	r, s := newTestRedis(t)
+	defer s.Close()
+
+	lookup := func() []dns.RR {
+		m := new(dns.Msg)
+		m.SetQuestion("new.example.org.", dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := r.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Msg.Answer
+	}
+	eventually := func(f func() bool) bool {
+		for i := 0; i < 100; i++ {
+			if f() {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+	// Wait for the subscriptions.
+	if !eventually(func() bool { return s.PubSubNumPat() == 1 && s.PubSubNumSub(defaultChannel)[defaultChannel] == 1 }) {
+		t.Fatal("Expected a subscription to the change channel")
+	}
+
+	if x := lookup(); len(x) != 0 {
+		t.Fatalf("Expected no answer, got %v", x)
+	}
+
+	// Without a notification the cached zone is used.
+	s.HSet("dns:example.org.", "new/A", `{"records": ["192.0.2.7"]}`)
+	if x := lookup(); len(x) != 0 {
+		t.Fatalf("Expected no answer from the cached zone, got %v", x)
+	}
+
+	// A keyspace notification, as published by Redis with notify-keyspace-events "Kh".
+	s.Publish("__keyspace@0__:dns:example.org.", "hset")
+	if !eventually(func() bool { return len(lookup()) == 1 }) {
+		t.Errorf("Expected the new record after a keyspace notification")
+	}
+
+	// A notification on the change channel.
+	s.HDel("dns:example.org.", "new/A")
+	s.Publish(defaultChannel, "Example.org")
+	if !eventually(func() bool { return len(lookup()) == 0 }) {
+		t.Errorf("Expected the record to be removed after a notification on the change channel")
+	}
+
+	// Losing the connection removes all zones from the cache.
+	r.zone("example.org.")
+	s.Close()
+	if !eventually(func() bool {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return len(r.zones) == 0
+	}) {
+		t.Errorf("Expected an empty cache after losing the subscription")
+	}
+	s.Restart()
+}
+
+func TestParse(t *testing.T) {
+	r := New(nil, nil)
+	tests := []struct {
+		field, value string
+		expected     []string
+		err          bool
+	}{
+		{"www/A", `{"records": ["192.0.2.1"]}`, []string{"www.example.org.\t300\tIN\tA\t192.0.2.1"}, false},
+		{"/NS", `{"ttl": 60, "records": ["ns1", "ns2.example.net."]}`, []string{
+			"example.org.\t60\tIN\tNS\tns1.example.org.",
+			"example.org.\t60\tIN\tNS\tns2.example.net.",
+		}, false},
+		{"@/txt", `{"records": ["\"a b\" c"]}`, []string{"example.org.\t300\tIN\tTXT\t\"a b\" \"c\""}, false},
+		{"*/A", `{"records": ["192.0.2.1"]}`, []string{"*.example.org.\t300\tIN\tA\t192.0.2.1"}, false},
+		{"www/A", `{"records": []}`, nil, false},
+		{"www", `{"records": ["192.0.2.1"]}`, nil, true},
+		{"www/BOGUS", `{"records": ["192.0.2.1"]}`, nil, true},
+		{"www/A", `{"records": "192.0.2.1"}`, nil, true},
+		{"www/A", `{"records": ["192.0.2.1 x"]}`, nil, true},
+		{"www.example.net./A", `{"records": ["192.0.2.1"]}`, nil, true},
+	}
+	for i, tc := range tests {
+		rrs, err := r.parse("example.org.", tc.field, tc.value)
+		if tc.err {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got %v", i, rrs)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %v", i, err)
+			continue
+		}
+		if len(rrs) != len(tc.expected) {
+			t.Errorf("Test %d: expected %d records, got %d", i, len(tc.expected), len(rrs))
+			continue
+		}
+		for j, rr := range rrs {
+			if rr.String() != tc.expected[j] {
+				t.Errorf("Test %d: expected %q, got %q", i, tc.expected[j], rr.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/fall"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/mholt/caddy"
+)
+
+var log = clog.NewWithPlugin("redis")
+
+func init() {
+	caddy.RegisterPlugin("redis", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+const (
+	defaultAddress = "127.0.0.1:6379"
+	defaultPrefix  = "dns:"
+	defaultChannel = "dns:changes"
+	defaultTTL     = 300
+
+	connectTimeout = 5 * time.Second
+	ioTimeout      = 5 * time.Second
+	idleTimeout    = 5 * time.Minute
+)
+
+func setup(c *caddy.Controller) error {
+	r, err := redisParse(c)
+	if err != nil {
+		return plugin.Error("redis", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.OnStartup(func() error {
+		go r.Run(ctx)
+		return nil
+	})
+	c.OnShutdown(func() error {
+		cancel()
+		return r.pool.Close()
+	})
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		r.Next = next
+		return r
+	})
+
+	return nil
+}
+
+func redisParse(c *caddy.Controller) (*Redis, error) {
+	i := 0
+	var r *Redis
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		zones := c.RemainingArgs()
+		if len(zones) == 0 {
+			zones = make([]string, len(c.ServerBlockKeys))
+			copy(zones, c.ServerBlockKeys)
+		}
+		for i := range zones {
+			zones[i] = plugin.Host(zones[i]).Normalize()
+		}
+
+		var (
+			address  = defaultAddress
+			password string
+			db       int
+			prefix   = defaultPrefix
+			channel  = defaultChannel
+			ttl      = uint32(defaultTTL)
+			f        fall.F
+		)
+		for c.NextBlock() {
+			switch c.Val() {
+			case "address":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				address = c.Val()
+			case "password":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				password = c.Val()
+			case "database":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				n, err := strconv.Atoi(c.Val())
+				if err != nil || n < 0 {
+					return nil, c.Errf("invalid database '%s'", c.Val())
+				}
+				db = n
+			case "prefix":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				prefix = c.Val()
+			case "channel":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				channel = c.Val()
+			case "ttl":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				t, err := strconv.Atoi(c.Val())
+				if err != nil {
+					return nil, err
+				}
+				if t <= 0 || t > 3600 {
+					return nil, c.Errf("ttl must be in range [1, 3600]: %d", t)
+				}
+				ttl = uint32(t)
+			case "fallthrough":
+				f.SetZonesFromArgs(c.RemainingArgs())
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+			if c.NextArg() {
+				return nil, c.ArgErr()
+			}
+		}
+
+		dial := func() (redis.Conn, error) {
+			return redis.Dial("tcp", address,
+				redis.DialPassword(password),
+				redis.DialDatabase(db),
+				redis.DialConnectTimeout(connectTimeout),
+				redis.DialWriteTimeout(ioTimeout), // the read timeout is set per command, the subscription has none
+			)
+		}
+		r = New(zones, dial)
+		r.Fall = f
+		r.db, r.prefix, r.channel, r.ttl = db, prefix, channel, ttl
+	}
+	return r, nil
+}
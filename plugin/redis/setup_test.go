@@ -0,0 +1,91 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupRedis(t *testing.T) {
+	tests := []struct {
+		input   string
+		err     bool
+		zones   []string
+		db      int
+		prefix  string
+		channel string
+		ttl     uint32
+	}{
+		{`redis`, false, []string{"."}, 0, defaultPrefix, defaultChannel, defaultTTL},
+		{`redis example.org example.net`, false, []string{"example.org.", "example.net."}, 0, defaultPrefix, defaultChannel, defaultTTL},
+		{`redis {
+			address 10.0.0.1:6379
+			password secret
+			database 2
+			prefix zone:
+			channel zone:changes
+			ttl 60
+			fallthrough
+		}`, false, []string{"."}, 2, "zone:", "zone:changes", 60},
+		// fails
+		{`redis {
+			address
+		}`, true, nil, 0, "", "", 0},
+		{`redis {
+			database -1
+		}`, true, nil, 0, "", "", 0},
+		{`redis {
+			database x
+		}`, true, nil, 0, "", "", 0},
+		{`redis {
+			prefix a b
+		}`, true, nil, 0, "", "", 0},
+		{`redis {
+			ttl 0
+		}`, true, nil, 0, "", "", 0},
+		{`redis {
+			ttl 3601
+		}`, true, nil, 0, "", "", 0},
+		{`redis {
+			blah
+		}`, true, nil, 0, "", "", 0},
+		{`redis
+		redis`, true, nil, 0, "", "", 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		c.ServerBlockKeys = []string{"."}
+		r, err := redisParse(c)
+		if test.err {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			continue
+		}
+		if len(r.Zones) != len(test.zones) {
+			t.Fatalf("Test %d: expected zones %v, got %v", i, test.zones, r.Zones)
+		}
+		for j := range test.zones {
+			if r.Zones[j] != test.zones[j] {
+				t.Errorf("Test %d: expected zone %s, got %s", i, test.zones[j], r.Zones[j])
+			}
+		}
+		if r.db != test.db {
+			t.Errorf("Test %d: expected database %d, got %d", i, test.db, r.db)
+		}
+		if r.prefix != test.prefix {
+			t.Errorf("Test %d: expected prefix %q, got %q", i, test.prefix, r.prefix)
+		}
+		if r.channel != test.channel {
+			t.Errorf("Test %d: expected channel %q, got %q", i, test.channel, r.channel)
+		}
+		if r.ttl != test.ttl {
+			t.Errorf("Test %d: expected ttl %d, got %d", i, test.ttl, r.ttl)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package redis
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/file"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/miekg/dns"
+)
+
+// rrset is the value of a field in the hash of a zone.
+type rrset struct {
+	TTL     uint32   `json:"ttl,omitempty"`
+	Records []string `json:"records"`
+}
+
+// load reads zone from its hash in Redis. Record sets that can't be parsed are skipped. It
+// returns nil if the hash doesn't exist.
+func (r *Redis) load(zone string) (*file.Zone, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	fields, err := redis.StringMap(redis.DoWithTimeout(conn, ioTimeout, "HGETALL", r.prefix+zone))
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	z := file.NewZone(zone, "")
+	z.Upstream = r.Upstream
+	for field, value := range fields {
+		rrs, err := r.parse(zone, field, value)
+		if err != nil {
+			log.Warningf("Skipping field %q of zone %q: %v", field, zone, err)
+			continue
+		}
+		for _, rr := range rrs {
+			if err := z.Insert(rr); err != nil {
+				log.Warningf("Skipping field %q of zone %q: %v", field, zone, err)
+			}
+		}
+	}
+	if z.Apex.SOA == nil {
+		log.Warningf("Zone %q has no SOA record", zone)
+	}
+	return z, nil
+}
+
+// parse parses a field and its value into records. The field is the owner name followed by a
+// slash and the type, e.g. "www/A". The owner name is relative to zone unless it ends with a dot,
+// "@" is the apex. The value is a JSON object with the TTL and the rdata of the records in zone
+// file format, e.g. {"ttl": 300, "records": ["192.0.2.1", "192.0.2.2"]}.
+func (r *Redis) parse(zone, field, value string) ([]dns.RR, error) {
+	i := strings.LastIndex(field, "/")
+	if i < 0 {
+		return nil, fmt.Errorf("field is not NAME/TYPE")
+	}
+	name, typ := field[:i], field[i+1:]
+	if name == "" {
+		name = "@"
+	}
+	if _, ok := dns.StringToType[strings.ToUpper(typ)]; !ok {
+		return nil, fmt.Errorf("unknown type %q", typ)
+	}
+
+	set := rrset{}
+	if err := json.Unmarshal([]byte(value), &set); err != nil {
+		return nil, err
+	}
+	if set.TTL == 0 {
+		set.TTL = r.ttl
+	}
+
+	var b strings.Builder
+	for _, rdata := range set.Records {
+		if strings.ContainsAny(rdata, "\r\n") {
+			return nil, fmt.Errorf("record spans multiple lines")
+		}
+		fmt.Fprintf(&b, "%s %d IN %s %s\n", name, set.TTL, typ, rdata)
+	}
+	zp := dns.NewZoneParser(strings.NewReader(b.String()), zone, "")
+	var rrs []dns.RR
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		if !dns.IsSubDomain(zone, rr.Header().Name) {
+			return nil, fmt.Errorf("out of zone data")
+		}
+		rrs = append(rrs, rr)
+	}
+	if err := zp.Err(); err != nil {
+		return nil, err
+	}
+	return rrs, nil
+}
@@ -0,0 +1,15 @@
+package rpz
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HitCount is the counter of policy hits.
+var HitCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: "rpz",
+	Name:      "hits_total",
+	Help:      "Counter of queries that matched a policy, per zone, trigger and action.",
+}, []string{"server", "zone", "trigger", "action"})
@@ -0,0 +1,290 @@
+package rpz
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Trigger types, also used in logs and metrics.
+const (
+	triggerClientIP = "client-ip"
+	triggerQname    = "qname"
+	triggerIP       = "ip"
+	triggerNSDname  = "nsdname"
+)
+
+// Labels that select the trigger of a policy record, see draft-vixie-dnsop-dns-rpz.
+const (
+	labelClientIP = "rpz-client-ip"
+	labelIP       = "rpz-ip"
+	labelNSDname  = "rpz-nsdname"
+	labelNSIP     = "rpz-nsip"
+)
+
+// Actions of a policy rule.
+const (
+	actionNXDOMAIN  = "nxdomain"
+	actionNODATA    = "nodata"
+	actionPassthru  = "passthru"
+	actionDrop      = "drop"
+	actionLocalData = "local-data"
+)
+
+// rule is the action for a trigger, and for local-data the records to answer with.
+type rule struct {
+	action string
+	rrs    []dns.RR
+}
+
+// ipRule is a rule triggered by the addresses in a network.
+type ipRule struct {
+	net  *net.IPNet
+	rule *rule
+}
+
+// policy holds the rules of one policy zone.
+type policy struct {
+	origin string
+	serial int64
+	soa    *dns.SOA
+
+	qname     names
+	nsdname   names
+	ip        []ipRule
+	clientIP  []ipRule
+	unhandled int // records with triggers we don't support
+}
+
+// names holds the rules triggered by names. Wildcards are keyed by the name below which they
+// match.
+type names struct {
+	exact    map[string]*rule
+	wildcard map[string]*rule
+}
+
+func newNames() names {
+	return names{exact: map[string]*rule{}, wildcard: map[string]*rule{}}
+}
+
+// match returns the rule for name: an exact rule, or else the wildcard of the closest ancestor.
+func (n names) match(name string) *rule {
+	if r, ok := n.exact[name]; ok {
+		return r
+	}
+	for off, end := dns.NextLabel(name, 0); !end; off, end = dns.NextLabel(name, off) {
+		if r, ok := n.wildcard[name[off:]]; ok {
+			return r
+		}
+	}
+	return nil
+}
+
+func (n names) add(name string, r *rule) {
+	if strings.HasPrefix(name, "*.") {
+		n.wildcard[name[2:]] = r
+		return
+	}
+	n.exact[name] = r
+}
+
+// newPolicy compiles the records of the zone with origin into a policy.
+func newPolicy(origin string, serial int64, rrs []dns.RR) (*policy, error) {
+	p := &policy{origin: origin, serial: serial, qname: newNames(), nsdname: newNames()}
+
+	// Group the records by owner name, the records of a trigger form a single rule.
+	owners := []string{}
+	byOwner := map[string][]dns.RR{}
+	for _, rr := range rrs {
+		switch rr.Header().Rrtype {
+		case dns.TypeRRSIG, dns.TypeNSEC:
+			continue
+		}
+		name := strings.ToLower(rr.Header().Name)
+		if soa, ok := rr.(*dns.SOA); ok && name == origin {
+			p.soa = soa
+			continue
+		}
+		if !dns.IsSubDomain(origin, name) || name == origin {
+			continue
+		}
+		if _, ok := byOwner[name]; !ok {
+			owners = append(owners, name)
+		}
+		byOwner[name] = append(byOwner[name], rr)
+	}
+
+	for _, owner := range owners {
+		r, err := newRule(byOwner[owner])
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy for %s: %s", owner, err)
+		}
+		trigger := strings.TrimSuffix(owner, "."+origin)
+		switch {
+		case strings.HasSuffix(trigger, "."+labelClientIP):
+			n, err := parseIPTrigger(strings.TrimSuffix(trigger, "."+labelClientIP))
+			if err != nil {
+				return nil, fmt.Errorf("invalid client IP trigger %s: %s", owner, err)
+			}
+			p.clientIP = append(p.clientIP, ipRule{net: n, rule: r})
+		case strings.HasSuffix(trigger, "."+labelIP):
+			n, err := parseIPTrigger(strings.TrimSuffix(trigger, "."+labelIP))
+			if err != nil {
+				return nil, fmt.Errorf("invalid IP trigger %s: %s", owner, err)
+			}
+			p.ip = append(p.ip, ipRule{net: n, rule: r})
+		case strings.HasSuffix(trigger, "."+labelNSDname):
+			p.nsdname.add(strings.TrimSuffix(trigger, labelNSDname), r)
+		case strings.HasSuffix(trigger, "."+labelNSIP):
+			p.unhandled++
+		default:
+			p.qname.add(trigger+".", r)
+		}
+	}
+	return p, nil
+}
+
+// newRule returns the rule expressed by the records of a trigger.
+func newRule(rrs []dns.RR) (*rule, error) {
+	if len(rrs) == 1 {
+		if c, ok := rrs[0].(*dns.CNAME); ok {
+			switch strings.ToLower(c.Target) {
+			case ".":
+				return &rule{action: actionNXDOMAIN}, nil
+			case "*.":
+				return &rule{action: actionNODATA}, nil
+			case "rpz-passthru.":
+				return &rule{action: actionPassthru}, nil
+			case "rpz-drop.":
+				return &rule{action: actionDrop}, nil
+			}
+			if strings.HasPrefix(strings.ToLower(c.Target), "rpz-") {
+				return nil, fmt.Errorf("unsupported action %s", c.Target)
+			}
+		}
+	}
+	for _, rr := range rrs {
+		if _, ok := rr.(*dns.CNAME); ok && len(rrs) > 1 {
+			return nil, fmt.Errorf("CNAME and other data")
+		}
+	}
+	return &rule{action: actionLocalData, rrs: rrs}, nil
+}
+
+// parseIPTrigger parses the prefix length and reversed address of an IP trigger, such as
+// "24.0.2.0.192" for 192.0.2.0/24 or "48.zz.db8.2001" for 2001:db8::/48.
+func parseIPTrigger(s string) (*net.IPNet, error) {
+	labels := dns.SplitDomainName(s)
+	if len(labels) < 2 {
+		return nil, fmt.Errorf("too few labels")
+	}
+	prefix, err := strconv.Atoi(labels[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid prefix length %q", labels[0])
+	}
+	labels = labels[1:]
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+
+	bits := 8 * net.IPv6len
+	var ip net.IP
+	if len(labels) == net.IPv4len && !strings.Contains(s, "zz") {
+		ip = net.ParseIP(strings.Join(labels, ".")).To4()
+		bits = 8 * net.IPv4len
+	} else {
+		for i := range labels {
+			if labels[i] == "zz" {
+				labels[i] = ""
+			}
+		}
+		addr := strings.Join(labels, ":")
+		if strings.HasPrefix(addr, ":") {
+			addr = ":" + addr
+		}
+		if strings.HasSuffix(addr, ":") {
+			addr += ":"
+		}
+		ip = net.ParseIP(addr)
+		if ip != nil && ip.To4() != nil {
+			// Don't let an IPv4-mapped notation pass as IPv6 trigger.
+			ip = nil
+		}
+	}
+	if ip == nil {
+		return nil, fmt.Errorf("invalid address")
+	}
+	if prefix < 1 || prefix > bits {
+		return nil, fmt.Errorf("invalid prefix length %d", prefix)
+	}
+	mask := net.CIDRMask(prefix, bits)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}, nil
+}
+
+// matchIP returns the rule of the longest prefix that contains ip.
+func matchIP(rules []ipRule, ip net.IP) *rule {
+	var (
+		best    *rule
+		longest = -1
+	)
+	for _, r := range rules {
+		if !r.net.Contains(ip) {
+			continue
+		}
+		if ones, _ := r.net.Mask.Size(); ones > longest {
+			best, longest = r.rule, ones
+		}
+	}
+	return best
+}
+
+// hasResponseTriggers returns true if the policy has rules that need the response to match.
+func (p *policy) hasResponseTriggers() bool {
+	return len(p.ip) > 0 || len(p.nsdname.exact) > 0 || len(p.nsdname.wildcard) > 0
+}
+
+// matchQuery returns the rule for the query: a client IP trigger, or else a QNAME trigger.
+func (p *policy) matchQuery(qname string, client net.IP) (*rule, string) {
+	if client != nil {
+		if r := matchIP(p.clientIP, client); r != nil {
+			return r, triggerClientIP
+		}
+	}
+	if r := p.qname.match(qname); r != nil {
+		return r, triggerQname
+	}
+	return nil, ""
+}
+
+// matchResponse returns the rule for the response: an IP trigger matching an address in the
+// answer, or else an NSDNAME trigger matching a name server in the response. The delegation of the
+// query name isn't looked up, only the NS records in the answer and authority sections are checked.
+func (p *policy) matchResponse(m *dns.Msg) (*rule, string) {
+	for _, rr := range m.Answer {
+		var ip net.IP
+		switch x := rr.(type) {
+		case *dns.A:
+			ip = x.A
+		case *dns.AAAA:
+			ip = x.AAAA
+		default:
+			continue
+		}
+		if r := matchIP(p.ip, ip); r != nil {
+			return r, triggerIP
+		}
+	}
+	for _, rrs := range [][]dns.RR{m.Answer, m.Ns} {
+		for _, rr := range rrs {
+			if ns, ok := rr.(*dns.NS); ok {
+				if r := p.nsdname.match(strings.ToLower(ns.Ns)); r != nil {
+					return r, triggerNSDname
+				}
+			}
+		}
+	}
+	return nil, ""
+}
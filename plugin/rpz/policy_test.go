@@ -0,0 +1,148 @@
+package rpz
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/file"
+)
+
+const testZone = `$ORIGIN rpz.example.
+@	3600 IN	SOA ns.rpz.example. admin.rpz.example. 1 3600 600 86400 60
+@	3600 IN	NS  ns.rpz.example.
+
+; QNAME triggers
+nxdomain.example.org	CNAME .
+*.nxdomain.example.org	CNAME .
+nodata.example.org	CNAME *.
+*.example.net		CNAME rpz-drop.
+ok.example.net		CNAME rpz-passthru.
+local.example.org	A 192.0.2.53
+local.example.org	AAAA 2001:db8::53
+garden.example.org	CNAME *.walled.example.
+
+; response IP triggers
+24.0.2.0.198.rpz-ip	CNAME .
+32.1.2.0.198.rpz-ip	CNAME rpz-passthru.
+48.zz.db8.2001.rpz-ip	CNAME *.
+
+; NSDNAME triggers
+ns.evil.example.rpz-nsdname	CNAME .
+
+; client IP triggers
+32.1.113.0.203.rpz-client-ip	CNAME rpz-drop.
+
+; NSIP triggers are not supported
+32.1.2.0.192.rpz-nsip	CNAME .
+`
+
+func testPolicy(t *testing.T) *policy {
+	z, err := file.Parse(strings.NewReader(testZone), "rpz.example.", "stdin", 0)
+	if err != nil {
+		t.Fatalf("Failed to parse policy zone: %s", err)
+	}
+	p, err := newPolicy("rpz.example.", 1, z.All())
+	if err != nil {
+		t.Fatalf("Failed to compile policy zone: %s", err)
+	}
+	return p
+}
+
+func TestPolicyQname(t *testing.T) {
+	p := testPolicy(t)
+	if p.unhandled != 1 {
+		t.Errorf("Expected 1 unhandled trigger, got %d", p.unhandled)
+	}
+
+	tests := []struct {
+		qname  string
+		action string
+	}{
+		{"nxdomain.example.org.", actionNXDOMAIN},
+		{"a.b.nxdomain.example.org.", actionNXDOMAIN},
+		{"nodata.example.org.", actionNODATA},
+		{"www.nodata.example.org.", ""},
+		{"example.net.", ""},
+		{"www.example.net.", actionDrop},
+		{"ok.example.net.", actionPassthru},
+		{"local.example.org.", actionLocalData},
+		{"example.org.", ""},
+	}
+	for i, tc := range tests {
+		r, trigger := p.matchQuery(tc.qname, nil)
+		action := ""
+		if r != nil {
+			action = r.action
+			if trigger != triggerQname {
+				t.Errorf("Test %d: expected trigger %s, got %s", i, triggerQname, trigger)
+			}
+		}
+		if action != tc.action {
+			t.Errorf("Test %d: expected action %q for %s, got %q", i, tc.action, tc.qname, action)
+		}
+	}
+
+	r, trigger := p.matchQuery("example.org.", net.ParseIP("203.0.113.1"))
+	if r == nil || trigger != triggerClientIP || r.action != actionDrop {
+		t.Errorf("Expected client IP trigger with action %s", actionDrop)
+	}
+}
+
+func TestParseIPTrigger(t *testing.T) {
+	tests := []struct {
+		trigger   string
+		expected  string
+		shouldErr bool
+	}{
+		{"32.1.2.0.192", "192.0.2.1/32", false},
+		{"24.0.2.0.192", "192.0.2.0/24", false},
+		{"8.1.2.0.192", "192.0.0.0/8", false},
+		{"128.1.zz.db8.2001", "2001:db8::1/128", false},
+		{"48.zz.db8.2001", "2001:db8::/48", false},
+		{"128.zz.1", "1::/128", false},
+		{"128.1.zz", "::1/128", false},
+		{"128.8.7.6.5.4.3.2.1", "1:2:3:4:5:6:7:8/128", false},
+		// fails
+		{"33.1.2.0.192", "", true},
+		{"0.1.2.0.192", "", true},
+		{"32.1.2.0", "", true},
+		{"x.1.2.0.192", "", true},
+		{"32.1.2.0.256", "", true},
+		{"129.1.zz.db8.2001", "", true},
+		{"32", "", true},
+	}
+	for i, tc := range tests {
+		n, err := parseIPTrigger(tc.trigger)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error for %s, got %s", i, tc.trigger, n)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error for %s, got %s", i, tc.trigger, err)
+			continue
+		}
+		if n.String() != tc.expected {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.expected, n)
+		}
+	}
+}
+
+func TestNewPolicyErrors(t *testing.T) {
+	tests := []string{
+		"bad.example.org CNAME rpz-tcp-only.",
+		"bad.example.org CNAME example.org.\nbad.example.org A 192.0.2.1",
+		"33.1.2.0.192.rpz-ip CNAME .",
+	}
+	for i, tc := range tests {
+		z, err := file.Parse(strings.NewReader("$ORIGIN rpz.example.\n$TTL 60\n@ SOA ns admin 1 3600 600 86400 60\n"+tc), "rpz.example.", "stdin", 0)
+		if err != nil {
+			t.Fatalf("Test %d: failed to parse policy zone: %s", i, err)
+		}
+		if _, err := newPolicy("rpz.example.", 1, z.All()); err == nil {
+			t.Errorf("Test %d: expected error, got none", i)
+		}
+	}
+}
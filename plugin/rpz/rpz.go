@@ -0,0 +1,254 @@
+// Package rpz implements a plugin that applies DNS response policy zones (RPZ).
+package rpz
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/file"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/plugin/pkg/nonwriter"
+	"github.com/coredns/coredns/plugin/pkg/upstream"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// RPZ is the rpz plugin.
+type RPZ struct {
+	Next  plugin.Handler
+	Zones []string
+
+	policies []*policyZone
+	upstream *upstream.Upstream
+}
+
+// policyZone is a policy zone together with the policy compiled from its records.
+type policyZone struct {
+	*file.Zone
+	origin string
+
+	mu     sync.RWMutex
+	p      *policy
+	failed int64 // serial that failed to compile
+}
+
+// hit is a rule that matched a query.
+type hit struct {
+	zone    string
+	soa     *dns.SOA
+	trigger string
+	rule    *rule
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (r *RPZ) ServeDNS(ctx context.Context, w dns.ResponseWriter, req *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: req}
+	if plugin.Zones(r.Zones).Matches(state.Name()) == "" {
+		return plugin.NextOrFailure(r.Name(), r.Next, ctx, w, req)
+	}
+
+	policies := r.snapshot()
+
+	// Client IP and QNAME triggers are matched before the query is resolved. The first zone
+	// that matches wins, but earlier zones may still match on the response.
+	qname := strings.ToLower(state.Name())
+	client := net.ParseIP(state.IP())
+	var h *hit
+	i := 0
+	for ; i < len(policies); i++ {
+		if ru, trigger := policies[i].matchQuery(qname, client); ru != nil {
+			h = &hit{zone: policies[i].origin, soa: policies[i].soa, trigger: trigger, rule: ru}
+			break
+		}
+	}
+	needResponse := false
+	for _, p := range policies[:i] {
+		if p.hasResponseTriggers() {
+			needResponse = true
+			break
+		}
+	}
+
+	if !needResponse {
+		if h == nil {
+			return plugin.NextOrFailure(r.Name(), r.Next, ctx, w, req)
+		}
+		return r.apply(ctx, state, h, nil, dns.RcodeSuccess, nil)
+	}
+
+	nw := nonwriter.New(w)
+	rcode, err := plugin.NextOrFailure(r.Name(), r.Next, ctx, nw, req)
+	if nw.Msg == nil {
+		if h != nil {
+			return r.apply(ctx, state, h, nil, rcode, err)
+		}
+		return rcode, err
+	}
+	for _, p := range policies[:i] {
+		if ru, trigger := p.matchResponse(nw.Msg); ru != nil {
+			h = &hit{zone: p.origin, soa: p.soa, trigger: trigger, rule: ru}
+			break
+		}
+	}
+	if h == nil {
+		w.WriteMsg(nw.Msg)
+		return rcode, err
+	}
+	return r.apply(ctx, state, h, nw.Msg, rcode, err)
+}
+
+// apply carries out the action of h. If the query was already resolved, resp holds the response
+// and rcode and err the result of the next plugin.
+func (r *RPZ) apply(ctx context.Context, state request.Request, h *hit, resp *dns.Msg, rcode int, err error) (int, error) {
+	log.Infof("Policy hit in %q: %s trigger for %s %s from %s, action %s", h.zone, h.trigger, state.Name(), state.Type(), state.IP(), h.rule.action)
+	HitCount.WithLabelValues(metrics.WithServer(ctx), h.zone, h.trigger, h.rule.action).Inc()
+
+	switch h.rule.action {
+	case actionPassthru:
+		if resp == nil {
+			return plugin.NextOrFailure(r.Name(), r.Next, ctx, state.W, state.Req)
+		}
+		state.W.WriteMsg(resp)
+		return rcode, err
+	case actionDrop:
+		// Don't reply at all.
+		return dns.RcodeSuccess, nil
+	}
+
+	m := new(dns.Msg)
+	m.SetReply(state.Req)
+
+	switch h.rule.action {
+	case actionNXDOMAIN:
+		m.Rcode = dns.RcodeNameError
+	case actionLocalData:
+		m.Answer = r.localData(ctx, state, h.rule.rrs)
+	}
+	if len(m.Answer) == 0 && h.soa != nil {
+		// Like BIND, return the SOA of the policy zone so resolvers can cache the negative answer.
+		m.Ns = []dns.RR{negativeSOA(h.soa)}
+	}
+
+	state.W.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// negativeSOA returns a copy of soa with the TTL of a negative answer: the lower of its TTL and its
+// minimum TTL, see RFC 2308.
+func negativeSOA(soa *dns.SOA) dns.RR {
+	s := dns.Copy(soa).(*dns.SOA)
+	if s.Minttl < s.Hdr.Ttl {
+		s.Hdr.Ttl = s.Minttl
+	}
+	return s
+}
+
+// localData returns the records of rrs for the query. A CNAME is followed, a CNAME target
+// starting with "*." gets the query name prepended.
+func (r *RPZ) localData(ctx context.Context, state request.Request, rrs []dns.RR) []dns.RR {
+	qname, qtype := state.Name(), state.QType()
+	answer := []dns.RR{}
+	for _, rr := range rrs {
+		if rr.Header().Rrtype != qtype && rr.Header().Rrtype != dns.TypeCNAME {
+			continue
+		}
+		rr = dns.Copy(rr)
+		rr.Header().Name = qname
+		answer = append(answer, rr)
+
+		c, ok := rr.(*dns.CNAME)
+		if !ok || qtype == dns.TypeCNAME {
+			continue
+		}
+		if strings.HasPrefix(c.Target, "*.") {
+			c.Target = qname + c.Target[2:]
+		}
+		if strings.EqualFold(c.Target, qname) {
+			continue
+		}
+		m, err := r.upstream.Lookup(ctx, state, c.Target, qtype)
+		if err != nil || m == nil {
+			log.Debugf("Failed to resolve local data target %s: %v", c.Target, err)
+			continue
+		}
+		answer = append(answer, m.Answer...)
+	}
+	return answer
+}
+
+// snapshot returns the currently compiled policies, skipping zones that haven't loaded.
+func (r *RPZ) snapshot() []*policy {
+	policies := make([]*policy, 0, len(r.policies))
+	for _, pz := range r.policies {
+		pz.mu.RLock()
+		p := pz.p
+		pz.mu.RUnlock()
+		if p != nil {
+			policies = append(policies, p)
+		}
+	}
+	return policies
+}
+
+// Name implements the plugin.Handler interface.
+func (r *RPZ) Name() string { return "rpz" }
+
+// compile compiles the policy of the zone when its serial has changed.
+func (pz *policyZone) compile() error {
+	serial := pz.SOASerialIfDefined()
+	if serial < 0 {
+		// Not loaded yet.
+		return nil
+	}
+	pz.mu.RLock()
+	current, failed := pz.p, pz.failed
+	pz.mu.RUnlock()
+	if (current != nil && current.serial == serial) || failed == serial {
+		return nil
+	}
+
+	p, err := newPolicy(pz.origin, serial, pz.All())
+	if err != nil {
+		pz.mu.Lock()
+		pz.failed = serial
+		pz.mu.Unlock()
+		return err
+	}
+	pz.mu.Lock()
+	pz.p = p
+	pz.mu.Unlock()
+
+	log.Infof("Loaded policy zone %q with serial %d", pz.origin, serial)
+	if p.unhandled > 0 {
+		log.Warningf("Ignoring %d unsupported NSIP triggers in %q", p.unhandled, pz.origin)
+	}
+	return nil
+}
+
+// compileAll compiles the policies of all zones.
+func (r *RPZ) compileAll() {
+	for _, pz := range r.policies {
+		if err := pz.compile(); err != nil {
+			log.Errorf("Failed to compile policy zone %q: %s", pz.origin, err)
+		}
+	}
+}
+
+// run recompiles the policies when their zones change, until stop is closed.
+func (r *RPZ) run(stop chan struct{}) {
+	tick := time.NewTicker(file.TickTime)
+	defer tick.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick.C:
+			r.compileAll()
+		}
+	}
+}
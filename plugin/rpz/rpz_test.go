@@ -0,0 +1,151 @@
+package rpz
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/file"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+// backend answers with fixed responses, as if it resolved the query.
+func backend() plugin.Handler {
+	return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		switch r.Question[0].Name {
+		case "ip.example.com.":
+			m.Answer = []dns.RR{test.A("ip.example.com. 300 IN A 198.0.2.10")}
+		case "exempt.example.com.":
+			m.Answer = []dns.RR{test.A("exempt.example.com. 300 IN A 198.0.2.1")}
+		case "v6.example.com.":
+			m.Answer = []dns.RR{test.AAAA("v6.example.com. 300 IN AAAA 2001:db8::1")}
+		case "delegated.example.com.":
+			m.Ns = []dns.RR{test.NS("example.com. 300 IN NS ns.evil.example.")}
+		case "undelegated.example.com.":
+			// Served by ns.evil.example too, but the response doesn't say so.
+			m.Answer = []dns.RR{test.A("undelegated.example.com. 300 IN A 192.0.2.1")}
+		case "nxdomain.example.org.":
+			m.Answer = []dns.RR{test.A("nxdomain.example.org. 300 IN A 198.0.2.10")}
+		default:
+			m.Answer = []dns.RR{test.A(r.Question[0].Name + " 300 IN A 192.0.2.1")}
+		}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+}
+
+func newPolicyZone(t *testing.T, origin, zone string) *policyZone {
+	z, err := file.Parse(strings.NewReader(zone), origin, "stdin", 0)
+	if err != nil {
+		t.Fatalf("Failed to parse policy zone: %s", err)
+	}
+	return &policyZone{Zone: z, origin: origin}
+}
+
+func newTestRPZ(policies ...*policyZone) *RPZ {
+	r := &RPZ{Next: backend(), Zones: []string{"."}, policies: policies}
+	r.compileAll()
+	return r
+}
+
+func TestRPZ(t *testing.T) {
+	r := newTestRPZ(newPolicyZone(t, "rpz.example.", testZone))
+
+	soa := test.SOA("rpz.example. 60 IN SOA ns.rpz.example. admin.rpz.example. 1 3600 600 86400 60")
+	tests := []test.Case{
+		{Qname: "nxdomain.example.org.", Qtype: dns.TypeA, Rcode: dns.RcodeNameError, Ns: []dns.RR{soa}},
+		{Qname: "www.nxdomain.example.org.", Qtype: dns.TypeA, Rcode: dns.RcodeNameError, Ns: []dns.RR{soa}},
+		{Qname: "nodata.example.org.", Qtype: dns.TypeA, Rcode: dns.RcodeSuccess, Ns: []dns.RR{soa}},
+		{Qname: "ok.example.net.", Qtype: dns.TypeA, Rcode: dns.RcodeSuccess,
+			Answer: []dns.RR{test.A("ok.example.net. 300 IN A 192.0.2.1")}},
+		{Qname: "local.example.org.", Qtype: dns.TypeA, Rcode: dns.RcodeSuccess,
+			Answer: []dns.RR{test.A("local.example.org. 3600 IN A 192.0.2.53")}},
+		{Qname: "local.example.org.", Qtype: dns.TypeAAAA, Rcode: dns.RcodeSuccess,
+			Answer: []dns.RR{test.AAAA("local.example.org. 3600 IN AAAA 2001:db8::53")}},
+		{Qname: "local.example.org.", Qtype: dns.TypeMX, Rcode: dns.RcodeSuccess, Ns: []dns.RR{soa}},
+		{Qname: "garden.example.org.", Qtype: dns.TypeA, Rcode: dns.RcodeSuccess,
+			Answer: []dns.RR{test.CNAME("garden.example.org. 3600 IN CNAME garden.example.org.walled.example.")}},
+		// response triggers
+		{Qname: "ip.example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeNameError, Ns: []dns.RR{soa}},
+		{Qname: "exempt.example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeSuccess,
+			Answer: []dns.RR{test.A("exempt.example.com. 300 IN A 198.0.2.1")}},
+		{Qname: "v6.example.com.", Qtype: dns.TypeAAAA, Rcode: dns.RcodeSuccess, Ns: []dns.RR{soa}},
+		{Qname: "delegated.example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeNameError, Ns: []dns.RR{soa}},
+		// NSDNAME only matches the NS records in the response.
+		{Qname: "undelegated.example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeSuccess,
+			Answer: []dns.RR{test.A("undelegated.example.com. 300 IN A 192.0.2.1")}},
+		{Qname: "clean.example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeSuccess,
+			Answer: []dns.RR{test.A("clean.example.com. 300 IN A 192.0.2.1")}},
+	}
+
+	ctx := context.TODO()
+	for i, tc := range tests {
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := r.ServeDNS(ctx, rec, tc.Msg()); err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if rec.Msg == nil {
+			t.Errorf("Test %d: expected a reply for %s", i, tc.Qname)
+			continue
+		}
+		if err := test.SortAndCheck(rec.Msg, tc); err != nil {
+			t.Errorf("Test %d: %s", i, err)
+		}
+	}
+
+	// The drop action doesn't reply.
+	for _, tc := range []struct {
+		qname  string
+		client string
+	}{
+		{"www.example.net.", ""},
+		{"clean.example.com.", "203.0.113.1"},
+	} {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{RemoteIP: tc.client})
+		r.ServeDNS(ctx, rec, m)
+		if rec.Msg != nil {
+			t.Errorf("Expected no reply for %s from %q, got %s", tc.qname, tc.client, rec.Msg)
+		}
+	}
+}
+
+func TestRPZPrecedence(t *testing.T) {
+	first := `$ORIGIN first.example.
+@ 3600 IN SOA ns admin 1 3600 600 86400 60
+32.10.2.0.198.rpz-ip CNAME *.
+`
+	second := `$ORIGIN second.example.
+@ 3600 IN SOA ns admin 1 3600 600 86400 60
+nxdomain.example.org CNAME .
+passthru.example.org CNAME rpz-passthru.
+`
+	r := newTestRPZ(newPolicyZone(t, "first.example.", first), newPolicyZone(t, "second.example.", second))
+
+	tests := []test.Case{
+		// The IP trigger of the first zone wins over the QNAME trigger of the second.
+		{Qname: "nxdomain.example.org.", Qtype: dns.TypeA, Rcode: dns.RcodeSuccess,
+			Ns: []dns.RR{test.SOA("first.example. 60 IN SOA ns.first.example. admin.first.example. 1 3600 600 86400 60")}},
+		{Qname: "passthru.example.org.", Qtype: dns.TypeA, Rcode: dns.RcodeSuccess,
+			Answer: []dns.RR{test.A("passthru.example.org. 300 IN A 192.0.2.1")}},
+	}
+	for i, tc := range tests {
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		r.ServeDNS(context.TODO(), rec, tc.Msg())
+		if rec.Msg == nil {
+			t.Errorf("Test %d: expected a reply for %s", i, tc.Qname)
+			continue
+		}
+		if err := test.SortAndCheck(rec.Msg, tc); err != nil {
+			t.Errorf("Test %d: %s", i, err)
+		}
+	}
+}
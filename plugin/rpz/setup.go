@@ -0,0 +1,170 @@
+package rpz
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/file"
+	"github.com/coredns/coredns/plugin/metrics"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/parse"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/plugin/pkg/upstream"
+
+	"github.com/mholt/caddy"
+)
+
+var log = clog.NewWithPlugin("rpz")
+
+func init() {
+	caddy.RegisterPlugin("rpz", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	r, err := rpzParse(c)
+	if err != nil {
+		return plugin.Error("rpz", err)
+	}
+
+	stop := make(chan struct{})
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, HitCount)
+		for _, pz := range r.policies {
+			z := pz.Zone
+			z.StartupOnce.Do(func() {
+				if len(z.TransferFrom) > 0 {
+					z.TransferIn()
+					go z.Update()
+					return
+				}
+				z.Reload()
+			})
+		}
+		r.compileAll()
+		go r.run(stop)
+		return nil
+	})
+	c.OnShutdown(func() error {
+		close(stop)
+		for _, pz := range r.policies {
+			pz.OnShutdown()
+		}
+		return nil
+	})
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		r.Next = next
+		return r
+	})
+
+	return nil
+}
+
+func rpzParse(c *caddy.Controller) (*RPZ, error) {
+	config := dnsserver.GetConfig(c)
+	r := &RPZ{upstream: upstream.New()}
+	reload := time.Minute
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		r.Zones = make([]string, len(c.ServerBlockKeys))
+		copy(r.Zones, c.ServerBlockKeys)
+		if args := c.RemainingArgs(); len(args) > 0 {
+			r.Zones = args
+		}
+		for j := range r.Zones {
+			r.Zones[j] = plugin.Host(r.Zones[j]).Normalize()
+		}
+
+		origins := map[string]bool{}
+		for c.NextBlock() {
+			switch c.Val() {
+			case "file":
+				// file ORIGIN FILE
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return nil, c.ArgErr()
+				}
+				origin := plugin.Host(args[0]).Normalize()
+				fileName := args[1]
+				if !filepath.IsAbs(fileName) && config.Root != "" {
+					fileName = filepath.Join(config.Root, fileName)
+				}
+				reader, err := os.Open(fileName)
+				if err != nil {
+					return nil, err
+				}
+				z, err := file.Parse(reader, origin, fileName, 0)
+				reader.Close()
+				if err != nil {
+					return nil, err
+				}
+				if _, err := newPolicy(origin, 0, z.All()); err != nil {
+					return nil, fmt.Errorf("policy zone %q in %q: %s", origin, fileName, err)
+				}
+				if origins[origin] {
+					return nil, fmt.Errorf("policy zone %q is defined more than once", origin)
+				}
+				origins[origin] = true
+				r.policies = append(r.policies, &policyZone{Zone: z, origin: origin})
+			case "secondary":
+				// secondary ORIGIN ADDRESS...
+				args := c.RemainingArgs()
+				if len(args) < 2 {
+					return nil, c.ArgErr()
+				}
+				origin := plugin.Host(args[0]).Normalize()
+				z := file.NewZone(origin, "stdin")
+				for _, a := range args[1:] {
+					addr, err := parse.HostPort(a, transport.Port)
+					if err != nil {
+						return nil, err
+					}
+					z.TransferFrom = append(z.TransferFrom, addr)
+				}
+				if origins[origin] {
+					return nil, fmt.Errorf("policy zone %q is defined more than once", origin)
+				}
+				origins[origin] = true
+				r.policies = append(r.policies, &policyZone{Zone: z, origin: origin})
+			case "reload":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(args[0])
+				if err != nil {
+					return nil, err
+				}
+				if d < 0 {
+					return nil, fmt.Errorf("reload can not be negative: %s", d)
+				}
+				reload = d
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+		if len(r.policies) == 0 {
+			return nil, fmt.Errorf("at least one policy zone is required")
+		}
+	}
+
+	for _, pz := range r.policies {
+		if len(pz.TransferFrom) == 0 {
+			pz.ReloadInterval = reload
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,96 @@
+package rpz
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupParse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-rpz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	policy := filepath.Join(dir, "policy.rpz")
+	if err := ioutil.WriteFile(policy, []byte(testZone), 0644); err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(dir, "invalid.rpz")
+	if err := ioutil.WriteFile(invalid, []byte(testZone+"bad.example.org CNAME rpz-tcp-only.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input     string
+		shouldErr bool
+		policies  int
+		reload    time.Duration
+	}{
+		{`rpz {
+			file rpz.example. ` + policy + `
+		}`, false, 1, time.Minute},
+		{`rpz example.org {
+			secondary feed.example. 192.0.2.1 192.0.2.2:5300
+			file rpz.example. ` + policy + `
+			reload 10s
+		}`, false, 2, 10 * time.Second},
+		// fails
+		{`rpz`, true, 0, 0},
+		{`rpz {
+			file rpz.example.
+		}`, true, 0, 0},
+		{`rpz {
+			file rpz.example. /does/not/exist
+		}`, true, 0, 0},
+		{`rpz {
+			file rpz.example. ` + invalid + `
+		}`, true, 0, 0},
+		{`rpz {
+			secondary feed.example.
+		}`, true, 0, 0},
+		{`rpz {
+			file rpz.example. ` + policy + `
+			secondary rpz.example. 192.0.2.1
+		}`, true, 0, 0},
+		{`rpz {
+			file rpz.example. ` + policy + `
+			reload -1s
+		}`, true, 0, 0},
+		{`rpz {
+			file rpz.example. ` + policy + `
+			bogus
+		}`, true, 0, 0},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		c.ServerBlockKeys = []string{"."}
+		r, err := rpzParse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if len(r.policies) != tc.policies {
+			t.Errorf("Test %d: expected %d policy zones, got %d", i, tc.policies, len(r.policies))
+		}
+		for _, pz := range r.policies {
+			if len(pz.TransferFrom) > 0 {
+				continue
+			}
+			if pz.ReloadInterval != tc.reload {
+				t.Errorf("Test %d: expected reload %s, got %s", i, tc.reload, pz.ReloadInterval)
+			}
+		}
+	}
+}
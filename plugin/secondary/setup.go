@@ -36,6 +36,7 @@ func setup(c *caddy.Controller) error {
 				})
 				return nil
 			})
+			c.OnShutdown(z.OnShutdown)
 		}
 	}
 
@@ -69,7 +70,10 @@ func secondaryParse(c *caddy.Controller) (file.Zones, error) {
 			for c.NextBlock() {
 
 				t, f := []string{}, []string{}
-				var e error
+				var (
+					e   error
+					key *file.TsigKey
+				)
 
 				switch c.Val() {
 				case "transfer":
@@ -77,6 +81,16 @@ func secondaryParse(c *caddy.Controller) (file.Zones, error) {
 					if e != nil {
 						return file.Zones{}, e
 					}
+				case "tsig":
+					args := c.RemainingArgs()
+					if len(args) != 3 {
+						return file.Zones{}, c.ArgErr()
+					}
+					name, alg, err := parse.TsigKey(args[0], args[1], args[2])
+					if err != nil {
+						return file.Zones{}, err
+					}
+					key = &file.TsigKey{Name: name, Algorithm: alg, Secret: args[2]}
 				case "upstream":
 					c.RemainingArgs() // eat args
 				default:
@@ -90,6 +104,9 @@ func secondaryParse(c *caddy.Controller) (file.Zones, error) {
 					if f != nil {
 						z[origin].TransferFrom = append(z[origin].TransferFrom, f...)
 					}
+					if key != nil {
+						z[origin].TransferKey = key
+					}
 					z[origin].Upstream = upstr
 				}
 			}
@@ -63,3 +63,54 @@ func TestSecondaryParse(t *testing.T) {
 		}
 	}
 }
+
+func TestSecondaryParseTsig(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		keyName   string
+	}{
+		{`secondary example.org {
+			transfer from 127.0.0.1
+			tsig Transfer.Key hmac-sha256 c2VjcmV0
+		}`, false, "transfer.key."},
+		{`secondary example.org {
+			transfer from 127.0.0.1
+		}`, false, ""},
+		// fails
+		{`secondary example.org {
+			transfer from 127.0.0.1
+			tsig transfer.key hmac-sha256
+		}`, true, ""},
+		{`secondary example.org {
+			transfer from 127.0.0.1
+			tsig transfer.key hmac-foo c2VjcmV0
+		}`, true, ""},
+		{`secondary example.org {
+			transfer from 127.0.0.1
+			tsig transfer.key hmac-sha256 !secret
+		}`, true, ""},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		s, err := secondaryParse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		key := s.Z["example.org."].TransferKey
+		switch {
+		case tc.keyName == "" && key != nil:
+			t.Errorf("Test %d: expected no key, got %s", i, key.Name)
+		case tc.keyName != "" && (key == nil || key.Name != tc.keyName):
+			t.Errorf("Test %d: expected key %s, got %v", i, tc.keyName, key)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Package services implements a plugin that serves service definitions read from files, like
+// the etcd plugin does from etcd.
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/etcd/msg"
+	"github.com/coredns/coredns/plugin/pkg/fall"
+	"github.com/coredns/coredns/plugin/pkg/upstream"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	priority = 10  // default priority when nothing is set
+	ttl      = 300 // default ttl when nothing is set
+
+	// prefix is the first element of the keys of the services. It is stripped by msg.Domain.
+	prefix = "skydns"
+)
+
+var errKeyNotFound = errors.New("key not found")
+
+// Services is a plugin that serves the service definitions in the files below a directory. The
+// path of a file, without its extension, is used as the key of the service, mirroring the keys
+// in etcd: the service in local/skydns/staging/service.json is service.staging.skydns.local.
+type Services struct {
+	Next     plugin.Handler
+	Fall     fall.F
+	Zones    []string
+	Upstream *upstream.Upstream
+
+	directory      string
+	reloadInterval time.Duration
+
+	mu      sync.RWMutex
+	entries []entry // sorted by key
+	serial  uint32
+
+	stamps map[string]stamp // only used by reload
+}
+
+// entry is a service and the key under which it is stored.
+type entry struct {
+	key  string
+	serv *msg.Service
+}
+
+// New returns a new Services plugin that reads the service definitions below directory.
+func New(zones []string, directory string) *Services {
+	return &Services{Zones: zones, directory: directory, reloadInterval: defaultReloadInterval}
+}
+
+// Services implements the ServiceBackend interface.
+func (s *Services) Services(ctx context.Context, state request.Request, exact bool, opt plugin.Options) (services []msg.Service, err error) {
+	services, err = s.Records(ctx, state, exact)
+	if err != nil {
+		return
+	}
+
+	services = msg.Group(services)
+	return
+}
+
+// Reverse implements the ServiceBackend interface.
+func (s *Services) Reverse(ctx context.Context, state request.Request, exact bool, opt plugin.Options) (services []msg.Service, err error) {
+	return s.Services(ctx, state, exact, opt)
+}
+
+// Lookup implements the ServiceBackend interface.
+func (s *Services) Lookup(ctx context.Context, state request.Request, name string, typ uint16) (*dns.Msg, error) {
+	return s.Upstream.Lookup(ctx, state, name, typ)
+}
+
+// IsNameError implements the ServiceBackend interface.
+func (s *Services) IsNameError(err error) bool {
+	return err == errKeyNotFound
+}
+
+// Records looks up the services for the name in state. If exact is true, it will lookup just this
+// name, otherwise the services below it are returned as well.
+func (s *Services) Records(ctx context.Context, state request.Request, exact bool) ([]msg.Service, error) {
+	name := state.Name()
+
+	path, star := msg.PathWithWildcard(name, prefix)
+	entries, err := s.get(path, !exact)
+	if err != nil {
+		return nil, err
+	}
+	segments := strings.Split(msg.Path(name, prefix), "/")
+	return loopEntries(entries, segments, star, state.QType()), nil
+}
+
+// get returns the entry with key path and, when recursive is true, the entries below it.
+func (s *Services) get(path string, recursive bool) ([]entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := sort.Search(len(s.entries), func(i int) bool { return s.entries[i].key >= path })
+	var entries []entry
+	if i < len(s.entries) && s.entries[i].key == path {
+		entries = append(entries, s.entries[i])
+		i++
+	}
+	if recursive {
+		for ; i < len(s.entries) && strings.HasPrefix(s.entries[i].key, path); i++ {
+			if strings.HasPrefix(s.entries[i].key, path+"/") {
+				entries = append(entries, s.entries[i])
+			}
+		}
+	}
+	if len(entries) == 0 {
+		return nil, errKeyNotFound
+	}
+	return entries, nil
+}
+
+func loopEntries(entries []entry, nameParts []string, star bool, qType uint16) (sx []msg.Service) {
+	bx := make(map[msg.Service]struct{})
+Entries:
+	for _, e := range entries {
+		if star {
+			keyParts := strings.Split(e.key, "/")
+			for i, n := range nameParts {
+				if i > len(keyParts)-1 {
+					// name is longer than key
+					continue Entries
+				}
+				if n == "*" || n == "any" {
+					continue
+				}
+				if keyParts[i] != n {
+					continue Entries
+				}
+			}
+		}
+		serv := *e.serv
+		serv.Key = e.key
+		if _, ok := bx[serv]; ok {
+			continue
+		}
+		bx[serv] = struct{}{}
+
+		if serv.TTL == 0 {
+			serv.TTL = ttl
+		}
+		if serv.Priority == 0 {
+			serv.Priority = priority
+		}
+
+		if shouldInclude(&serv, qType) {
+			sx = append(sx, serv)
+		}
+	}
+	return sx
+}
+
+// shouldInclude returns true if the service should be included in a list of records, given the qType. For all the
+// currently supported lookup types, the only one to allow for an empty Host field in the service are TXT records.
+// Similarly, the TXT record in turn requires the Text field to be set.
+func shouldInclude(serv *msg.Service, qType uint16) bool {
+	if qType == dns.TypeTXT {
+		return serv.Text != ""
+	}
+	return serv.Host != ""
+}
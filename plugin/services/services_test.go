@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+var files = map[string]string{
+	"test/skydns/region1/prod/server1/a.json":   `{"host": "10.0.0.1", "port": 8080}`,
+	"test/skydns/region1/prod/server1/b.yaml":   "host: 10.0.0.2\nport: 8080\nttl: 60\n",
+	"test/skydns/region1/prod/server1/txt.json": `{"text": "sometext"}`,
+	"test/skydns/region1/prod/server6/b.yml":    "host: \"::1\"\nport: 8080\n",
+	"test/skydns/region1/dev/server1/a.json":    `{"host": "10.0.1.1", "port": 9090}`,
+	"test/skydns/mail/a.json":                   `{"host": "10.0.0.3", "mail": true, "priority": 20}`,
+	"test/skydns/dns/ns/a.json":                 `{"host": "10.0.0.53"}`,
+	// These are all skipped.
+	"test/skydns/broken.json":        `{"host": `,
+	"test/skydns/notes.txt":          `{"host": "10.0.0.4"}`,
+	"test/skydns/.hidden.json":       `{"host": "10.0.0.5"}`,
+	"test/skydns/.git/config/a.json": `{"host": "10.0.0.6"}`,
+}
+
+func newTestServices(t *testing.T) (*Services, string) {
+	dir, err := ioutil.TempDir("", "coredns-services")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		writeFile(t, filepath.Join(dir, name), content)
+	}
+
+	s := New([]string{"skydns.test."}, dir)
+	if err := s.reload(); err != nil {
+		t.Fatal(err)
+	}
+	return s, dir
+}
+
+func writeFile(t *testing.T, path, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServices(t *testing.T) {
+	s, dir := newTestServices(t)
+	defer os.RemoveAll(dir)
+
+	tests := []test.Case{
+		{
+			Qname: "a.server1.prod.region1.skydns.test.", Qtype: dns.TypeA,
+			Answer: []dns.RR{test.A("a.server1.prod.region1.skydns.test. 300 IN A 10.0.0.1")},
+		},
+		{
+			Qname: "b.server1.prod.region1.skydns.test.", Qtype: dns.TypeA,
+			Answer: []dns.RR{test.A("b.server1.prod.region1.skydns.test. 60 IN A 10.0.0.2")},
+		},
+		{
+			Qname: "server1.prod.region1.skydns.test.", Qtype: dns.TypeA,
+			Answer: []dns.RR{
+				test.A("server1.prod.region1.skydns.test. 300 IN A 10.0.0.1"),
+				test.A("server1.prod.region1.skydns.test. 60 IN A 10.0.0.2"),
+			},
+		},
+		{
+			Qname: "b.server6.prod.region1.skydns.test.", Qtype: dns.TypeAAAA,
+			Answer: []dns.RR{test.AAAA("b.server6.prod.region1.skydns.test. 300 IN AAAA ::1")},
+		},
+		{
+			Qname: "server1.prod.region1.skydns.test.", Qtype: dns.TypeSRV,
+			Answer: []dns.RR{
+				test.SRV("server1.prod.region1.skydns.test. 300 IN SRV 10 50 8080 a.server1.prod.region1.skydns.test."),
+				test.SRV("server1.prod.region1.skydns.test. 60 IN SRV 10 50 8080 b.server1.prod.region1.skydns.test."),
+			},
+			Extra: []dns.RR{
+				test.A("a.server1.prod.region1.skydns.test. 300 IN A 10.0.0.1"),
+				test.A("b.server1.prod.region1.skydns.test. 60 IN A 10.0.0.2"),
+			},
+		},
+		{
+			Qname: "txt.server1.prod.region1.skydns.test.", Qtype: dns.TypeTXT,
+			Answer: []dns.RR{test.TXT("txt.server1.prod.region1.skydns.test. 300 IN TXT \"sometext\"")},
+		},
+		{
+			// Wildcard.
+			Qname: "server1.*.region1.skydns.test.", Qtype: dns.TypeA,
+			Answer: []dns.RR{
+				test.A("server1.*.region1.skydns.test. 300 IN A 10.0.0.1"),
+				test.A("server1.*.region1.skydns.test. 300 IN A 10.0.1.1"),
+				test.A("server1.*.region1.skydns.test. 60 IN A 10.0.0.2"),
+			},
+		},
+		{
+			Qname: "mail.skydns.test.", Qtype: dns.TypeMX,
+			Answer: []dns.RR{test.MX("mail.skydns.test. 300 IN MX 20 a.mail.skydns.test.")},
+			Extra:  []dns.RR{test.A("a.mail.skydns.test. 300 IN A 10.0.0.3")},
+		},
+		{
+			Qname: "skydns.test.", Qtype: dns.TypeNS,
+			Answer: []dns.RR{test.NS("skydns.test. 300 IN NS a.ns.dns.skydns.test.")},
+			Extra:  []dns.RR{test.A("a.ns.dns.skydns.test. 300 IN A 10.0.0.53")},
+		},
+		{
+			// NODATA.
+			Qname: "a.server1.prod.region1.skydns.test.", Qtype: dns.TypeTXT,
+			Ns: []dns.RR{test.SOA("skydns.test. 30 IN SOA ns.dns.skydns.test. hostmaster.skydns.test. 0 7200 1800 86400 30")},
+		},
+		{
+			Qname: "doesnotexist.skydns.test.", Qtype: dns.TypeA,
+			Rcode: dns.RcodeNameError,
+			Ns:    []dns.RR{test.SOA("skydns.test. 30 IN SOA ns.dns.skydns.test. hostmaster.skydns.test. 0 7200 1800 86400 30")},
+		},
+		{
+			// Skipped files.
+			Qname: "broken.skydns.test.", Qtype: dns.TypeA,
+			Rcode: dns.RcodeNameError,
+			Ns:    []dns.RR{test.SOA("skydns.test. 30 IN SOA ns.dns.skydns.test. hostmaster.skydns.test. 0 7200 1800 86400 30")},
+		},
+		{
+			Qname: "notes.skydns.test.", Qtype: dns.TypeA,
+			Rcode: dns.RcodeNameError,
+			Ns:    []dns.RR{test.SOA("skydns.test. 30 IN SOA ns.dns.skydns.test. hostmaster.skydns.test. 0 7200 1800 86400 30")},
+		},
+		{
+			Qname: ".hidden.skydns.test.", Qtype: dns.TypeA,
+			Rcode: dns.RcodeNameError,
+			Ns:    []dns.RR{test.SOA("skydns.test. 30 IN SOA ns.dns.skydns.test. hostmaster.skydns.test. 0 7200 1800 86400 30")},
+		},
+	}
+
+	// The serial is the time of the reload, make it predictable.
+	s.serial = 0
+
+	ctx := context.TODO()
+	for i, tc := range tests {
+		m := tc.Msg()
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := s.ServeDNS(ctx, rec, m); err != nil {
+			t.Errorf("Test %d: expected no error, got %v", i, err)
+			continue
+		}
+		if err := test.SortAndCheck(rec.Msg, tc); err != nil {
+			t.Errorf("Test %d: %v", i, err)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	s, dir := newTestServices(t)
+	defer os.RemoveAll(dir)
+
+	lookup := func() []dns.RR {
+		m := new(dns.Msg)
+		m.SetQuestion("server1.prod.region1.skydns.test.", dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := s.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Msg.Answer
+	}
+
+	if x := lookup(); len(x) != 2 {
+		t.Fatalf("Expected 2 answers, got %d", len(x))
+	}
+
+	writeFile(t, filepath.Join(dir, "test/skydns/region1/prod/server1/c.json"), `{"host": "10.0.0.3"}`)
+	if err := s.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if x := lookup(); len(x) != 3 {
+		t.Errorf("Expected 3 answers after adding a file, got %d", len(x))
+	}
+
+	if err := os.Remove(filepath.Join(dir, "test/skydns/region1/prod/server1/a.json")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if x := lookup(); len(x) != 2 {
+		t.Errorf("Expected 2 answers after removing a file, got %d", len(x))
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.reload(); err == nil {
+		t.Errorf("Expected an error for a directory that doesn't exist")
+	}
+	if x := lookup(); len(x) != 2 {
+		t.Errorf("Expected the services to be kept after a failed reload, got %d answers", len(x))
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		rel, expected string
+	}{
+		{"local/skydns/staging/service.json", "/skydns/local/skydns/staging/service"},
+		{"local/skydns/Staging/service.yaml", "/skydns/local/skydns/staging/service"},
+	}
+	for i, tc := range tests {
+		if x := key(filepath.FromSlash(tc.rel)); x != tc.expected {
+			t.Errorf("Test %d: expected key %q, got %q", i, tc.expected, x)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/upstream"
+
+	"github.com/mholt/caddy"
+)
+
+var log = clog.NewWithPlugin("services")
+
+func init() {
+	caddy.RegisterPlugin("services", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	s, err := servicesParse(c)
+	if err != nil {
+		return plugin.Error("services", err)
+	}
+	if err := s.reload(); err != nil {
+		return plugin.Error("services", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.OnStartup(func() error {
+		go s.Run(ctx)
+		return nil
+	})
+	c.OnShutdown(func() error {
+		cancel()
+		return nil
+	})
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		s.Next = next
+		return s
+	})
+
+	return nil
+}
+
+func servicesParse(c *caddy.Controller) (*Services, error) {
+	config := dnsserver.GetConfig(c)
+
+	i := 0
+	var s *Services
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		if !c.NextArg() {
+			return nil, c.ArgErr()
+		}
+		dir := c.Val()
+		if !filepath.IsAbs(dir) && config.Root != "" {
+			dir = filepath.Join(config.Root, dir)
+		}
+		if _, err := os.Stat(dir); err != nil {
+			if os.IsNotExist(err) {
+				return nil, c.Errf("directory does not exist: %s", dir)
+			}
+			return nil, err
+		}
+
+		zones := c.RemainingArgs()
+		if len(zones) == 0 {
+			zones = make([]string, len(c.ServerBlockKeys))
+			copy(zones, c.ServerBlockKeys)
+		}
+		for i := range zones {
+			zones[i] = plugin.Host(zones[i]).Normalize()
+		}
+
+		s = New(zones, dir)
+		s.Upstream = upstream.New()
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "reload":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(c.Val())
+				if err != nil {
+					return nil, c.Errf("invalid reload duration '%s'", c.Val())
+				}
+				if d < 0 {
+					return nil, c.Errf("reload duration must not be negative: %s", d)
+				}
+				s.reloadInterval = d
+				if c.NextArg() {
+					return nil, c.ArgErr()
+				}
+			case "fallthrough":
+				s.Fall.SetZonesFromArgs(c.RemainingArgs())
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	return s, nil
+}
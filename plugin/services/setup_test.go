@@ -0,0 +1,78 @@
+package services
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupServices(t *testing.T) {
+	dir := os.TempDir()
+	tests := []struct {
+		input  string
+		err    bool
+		zones  []string
+		reload time.Duration
+	}{
+		{`services ` + dir, false, []string{"."}, defaultReloadInterval},
+		{`services ` + dir + ` skydns.local example.org`, false, []string{"skydns.local.", "example.org."}, defaultReloadInterval},
+		{`services ` + dir + ` {
+			reload 10s
+			fallthrough
+		}`, false, []string{"."}, 10 * time.Second},
+		{`services ` + dir + ` {
+			reload 0
+		}`, false, []string{"."}, 0},
+		// fails
+		{`services`, true, nil, 0},
+		{`services /does/not/exist`, true, nil, 0},
+		{`services ` + dir + ` {
+			reload
+		}`, true, nil, 0},
+		{`services ` + dir + ` {
+			reload -1s
+		}`, true, nil, 0},
+		{`services ` + dir + ` {
+			reload 1s 2s
+		}`, true, nil, 0},
+		{`services ` + dir + ` {
+			blah
+		}`, true, nil, 0},
+		{`services ` + dir + `
+		services ` + dir, true, nil, 0},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		c.ServerBlockKeys = []string{"."}
+		s, err := servicesParse(c)
+		if tc.err {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none for input %s", i, tc.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %v for input %s", i, err, tc.input)
+			continue
+		}
+		if len(s.Zones) != len(tc.zones) {
+			t.Errorf("Test %d: expected zones %v, got %v", i, tc.zones, s.Zones)
+			continue
+		}
+		for j := range tc.zones {
+			if s.Zones[j] != tc.zones[j] {
+				t.Errorf("Test %d: expected zones %v, got %v", i, tc.zones, s.Zones)
+				break
+			}
+		}
+		if s.directory != dir {
+			t.Errorf("Test %d: expected directory %s, got %s", i, dir, s.directory)
+		}
+		if s.reloadInterval != tc.reload {
+			t.Errorf("Test %d: expected reload %s, got %s", i, tc.reload, s.reloadInterval)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin/etcd/msg"
+
+	"sigs.k8s.io/yaml"
+)
+
+// defaultReloadInterval is how often the directory is checked for changes.
+const defaultReloadInterval = time.Minute
+
+// stamp is what we remember of a file, to see if it changed.
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Run loads the services and reloads them every reload interval when the files change, until ctx
+// is done.
+func (s *Services) Run(ctx context.Context) {
+	if s.reloadInterval == 0 {
+		return
+	}
+	tick := time.NewTicker(s.reloadInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			if err := s.reload(); err != nil {
+				log.Warningf("Failed to reload services from %s: %s", s.directory, err)
+			}
+		}
+	}
+}
+
+// reload walks the directory and, when any file was added, removed or changed since the previous
+// call, parses all the files and replaces the services.
+func (s *Services) reload() error {
+	stamps := make(map[string]stamp)
+	err := filepath.Walk(s.directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(info.Name(), ".") && path != s.directory {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || !isServiceFile(path) {
+			return nil
+		}
+		stamps[path] = stamp{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.stamps != nil && equal(stamps, s.stamps) {
+		return nil
+	}
+
+	entries := make([]entry, 0, len(stamps))
+	for path := range stamps {
+		serv, err := parse(path)
+		if err != nil {
+			log.Warningf("Failed to parse %s: %s", path, err)
+			continue
+		}
+		rel, _ := filepath.Rel(s.directory, path)
+		entries = append(entries, entry{key: key(rel), serv: serv})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+	s.mu.Lock()
+	s.entries = entries
+	s.serial = uint32(time.Now().Unix())
+	s.mu.Unlock()
+
+	if s.stamps != nil {
+		log.Infof("Reloaded %d services from %s", len(entries), s.directory)
+	}
+	s.stamps = stamps
+	return nil
+}
+
+// isServiceFile returns true if the file at path holds a service.
+func isServiceFile(path string) bool {
+	switch filepath.Ext(path) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// key returns the key of the service in the file rel, relative to the directory. The key of
+// local/skydns/staging/service.json is /skydns/local/skydns/staging/service.
+func key(rel string) string {
+	rel = strings.TrimSuffix(rel, filepath.Ext(rel))
+	return "/" + prefix + "/" + strings.ToLower(filepath.ToSlash(rel))
+}
+
+// parse parses the service in the file at path. YAML is converted to JSON first, so both use the
+// JSON field names of msg.Service.
+func parse(path string) (*msg.Service, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Ext(path) != ".json" {
+		if buf, err = yaml.YAMLToJSON(buf); err != nil {
+			return nil, err
+		}
+	}
+	serv := new(msg.Service)
+	if err := json.Unmarshal(buf, serv); err != nil {
+		return nil, err
+	}
+	return serv, nil
+}
+
+func equal(a, b map[string]stamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, st := range a {
+		if x, ok := b[path]; !ok || !x.modTime.Equal(st.modTime) || x.size != st.size {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"context"
+
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// Serial implements the Transferer interface. It returns the time the services were last loaded.
+func (s *Services) Serial(state request.Request) uint32 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.serial
+}
+
+// MinTTL implements the Transferer interface.
+func (s *Services) MinTTL(state request.Request) uint32 {
+	return 30
+}
+
+// Transfer implements the Transferer interface.
+func (s *Services) Transfer(ctx context.Context, state request.Request) (int, error) {
+	return dns.RcodeServerFailure, nil
+}
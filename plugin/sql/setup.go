@@ -0,0 +1,153 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/parse"
+
+	"github.com/mholt/caddy"
+	_ "modernc.org/sqlite" // the sqlite driver, in Go so it doesn't need cgo
+)
+
+var log = clog.NewWithPlugin("sql")
+
+func init() {
+	caddy.RegisterPlugin("sql", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	s, err := sqlParse(c)
+	if err != nil {
+		return plugin.Error("sql", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.OnStartup(func() error {
+		if err := s.Run(ctx); err != nil {
+			return plugin.Error("sql", err)
+		}
+		return nil
+	})
+	c.OnShutdown(func() error {
+		cancel()
+		return s.db.Close()
+	})
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		s.Next = next
+		return s
+	})
+
+	return nil
+}
+
+// defaultInterval is how often the changes table is checked when no poll interval is given.
+const defaultInterval = 1 * time.Minute
+
+func sqlParse(c *caddy.Controller) (*SQL, error) {
+	config := dnsserver.GetConfig(c)
+
+	i := 0
+	var s *SQL
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		// sql DRIVER DSN [ZONES...]
+		args := c.RemainingArgs()
+		if len(args) < 2 {
+			return nil, c.ArgErr()
+		}
+		driver, dsn := args[0], args[1]
+		if (driver == "sqlite3" || driver == "sqlite") && !filepath.IsAbs(dsn) && !strings.HasPrefix(dsn, "file:") && dsn != ":memory:" && config.Root != "" {
+			dsn = filepath.Join(config.Root, dsn)
+		}
+
+		origins := make([]string, len(c.ServerBlockKeys))
+		copy(origins, c.ServerBlockKeys)
+		if len(args) > 2 {
+			origins = args[2:]
+		}
+		for i := range origins {
+			origins[i] = plugin.Host(origins[i]).Normalize()
+		}
+
+		db, err := sql.Open(driver, dsn)
+		if err != nil {
+			return nil, err
+		}
+		s = New(db, origins)
+
+		poll := false
+		for c.NextBlock() {
+			switch c.Val() {
+			case "poll":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(c.Val())
+				if err != nil {
+					return nil, c.Errf("invalid poll interval '%s': %v", c.Val(), err)
+				}
+				if d <= 0 {
+					return nil, c.Errf("poll interval must be positive: %s", d)
+				}
+				s.interval = d
+				poll = true
+			case "changes":
+				s.changes = "changes"
+				if c.NextArg() {
+					s.changes = c.Val()
+				}
+				if !validTable(s.changes) {
+					return nil, c.Errf("invalid table name '%s'", s.changes)
+				}
+			case "transfer":
+				t, _, err := parse.Transfer(c, false)
+				if err != nil {
+					return nil, err
+				}
+				s.transferTo = append(s.transferTo, t...)
+			case "fallthrough":
+				s.Fall.SetZonesFromArgs(c.RemainingArgs())
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+			if c.NextArg() {
+				return nil, c.ArgErr()
+			}
+		}
+		if s.changes != "" && !poll {
+			s.interval = defaultInterval
+		}
+	}
+	return s, nil
+}
+
+// validTable returns true if name can be used as a table name in a query: it may only contain
+// letters, digits, underscores and a dot to separate the schema.
+func validTable(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
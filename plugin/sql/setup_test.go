@@ -0,0 +1,95 @@
+package sql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetupSQL(t *testing.T) {
+	tests := []struct {
+		input    string
+		err      bool
+		origins  []string
+		changes  string
+		interval time.Duration
+		to       []string
+	}{
+		{`sql sqlite /var/lib/coredns/dns.db`, false, []string{"."}, "", 0, nil},
+		{`sql sqlite /var/lib/coredns/dns.db example.org example.net`, false, []string{"example.org.", "example.net."}, "", 0, nil},
+		{`sql sqlite /var/lib/coredns/dns.db {
+			poll 30s
+		}`, false, []string{"."}, "", 30 * time.Second, nil},
+		{`sql sqlite /var/lib/coredns/dns.db {
+			changes
+		}`, false, []string{"."}, "changes", defaultInterval, nil},
+		{`sql sqlite /var/lib/coredns/dns.db {
+			changes dns.zone_changes
+			poll 10s
+			transfer to 10.0.0.1
+		}`, false, []string{"."}, "dns.zone_changes", 10 * time.Second, []string{"10.0.0.1:53"}},
+		{`sql sqlite /var/lib/coredns/dns.db {
+			fallthrough
+		}`, false, []string{"."}, "", 0, nil},
+		// fails
+		{`sql`, true, nil, "", 0, nil},
+		{`sql sqlite`, true, nil, "", 0, nil},
+		{`sql nosuchdriver dsn`, true, nil, "", 0, nil},
+		{`sql sqlite /var/lib/coredns/dns.db {
+			poll
+		}`, true, nil, "", 0, nil},
+		{`sql sqlite /var/lib/coredns/dns.db {
+			poll -1s
+		}`, true, nil, "", 0, nil},
+		{`sql sqlite /var/lib/coredns/dns.db {
+			changes changes;drop
+		}`, true, nil, "", 0, nil},
+		{`sql sqlite /var/lib/coredns/dns.db {
+			changes a b
+		}`, true, nil, "", 0, nil},
+		{`sql sqlite /var/lib/coredns/dns.db {
+			transfer from 10.0.0.1
+		}`, true, nil, "", 0, nil},
+		{`sql sqlite /var/lib/coredns/dns.db {
+			blah
+		}`, true, nil, "", 0, nil},
+		{`sql sqlite /var/lib/coredns/dns.db
+		sql sqlite /var/lib/coredns/other.db`, true, nil, "", 0, nil},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		c.ServerBlockKeys = []string{"."}
+		s, err := sqlParse(c)
+		if test.err {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			continue
+		}
+		s.db.Close()
+
+		if len(s.origins) != len(test.origins) {
+			t.Fatalf("Test %d: expected origins %v, got %v", i, test.origins, s.origins)
+		}
+		for j := range test.origins {
+			if s.origins[j] != test.origins[j] {
+				t.Errorf("Test %d: expected origin %s, got %s", i, test.origins[j], s.origins[j])
+			}
+		}
+		if s.changes != test.changes {
+			t.Errorf("Test %d: expected changes table %q, got %q", i, test.changes, s.changes)
+		}
+		if s.interval != test.interval {
+			t.Errorf("Test %d: expected interval %s, got %s", i, test.interval, s.interval)
+		}
+		if len(s.transferTo) != len(test.to) || (len(test.to) > 0 && s.transferTo[0] != test.to[0]) {
+			t.Errorf("Test %d: expected transfer to %v, got %v", i, test.to, s.transferTo)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+// Package sql implements a plugin that serves zones from a SQL database.
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/file"
+	"github.com/coredns/coredns/plugin/pkg/fall"
+	"github.com/coredns/coredns/plugin/pkg/upstream"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// SQL is a plugin that serves zones stored in a SQL database. The zones are read into memory
+// and kept up to date by polling the database.
+type SQL struct {
+	Next plugin.Handler
+	Fall fall.F
+
+	db         *sql.DB
+	origins    []string
+	changes    string        // table whose highest id signals changes, if any
+	interval   time.Duration // how often the database is checked for changes, zero disables it
+	transferTo []string
+	upstream   *upstream.Upstream
+
+	changeID int64 // highest id seen in the changes table, only used by update
+
+	zMu   sync.RWMutex
+	zones file.Zones
+}
+
+// New returns a new SQL plugin that serves the zones in db that are equal to or below one of the origins.
+func New(db *sql.DB, origins []string) *SQL {
+	return &SQL{db: db, origins: origins, upstream: upstream.New()}
+}
+
+// defaultTTL is used for records that have a NULL ttl.
+const defaultTTL = 3600
+
+// ServeDNS implements the plugin.Handler interface.
+func (s *SQL) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	qname := state.Name()
+
+	s.zMu.RLock()
+	zones := s.zones
+	s.zMu.RUnlock()
+
+	zone := plugin.Zones(zones.Names).Matches(qname)
+	if zone == "" {
+		return plugin.NextOrFailure(s.Name(), s.Next, ctx, w, r)
+	}
+	z := zones.Z[zone]
+
+	if state.QType() == dns.TypeAXFR || state.QType() == dns.TypeIXFR {
+		xfr := file.Xfr{Zone: z}
+		return xfr.ServeDNS(ctx, w, r)
+	}
+
+	answer, ns, extra, result := z.Lookup(ctx, state, qname)
+	if result == file.NameError && s.Fall.Through(qname) {
+		return plugin.NextOrFailure(s.Name(), s.Next, ctx, w, r)
+	}
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+	m.Answer, m.Ns, m.Extra = answer, ns, extra
+
+	switch result {
+	case file.Success:
+	case file.NoData:
+	case file.NameError:
+		m.Rcode = dns.RcodeNameError
+	case file.Delegation:
+		m.Authoritative = false
+	case file.ServerFailure:
+		return dns.RcodeServerFailure, nil
+	}
+
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// Name implements the plugin.Handler interface.
+func (s *SQL) Name() string { return "sql" }
+
+// Run loads the zones and checks the database for changes every interval, until ctx is done.
+// It returns an error if the first load fails.
+func (s *SQL) Run(ctx context.Context) error {
+	if err := s.update(ctx, true); err != nil {
+		return err
+	}
+	if s.interval == 0 {
+		return nil
+	}
+	go func() {
+		tick := time.NewTicker(s.interval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+				if err := s.update(ctx, false); err != nil && ctx.Err() == nil {
+					log.Errorf("Failed to update zones: %v", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// update reloads the zones. With a changes table and without force, the zones are only
+// reloaded when a row was added to that table since the last update.
+func (s *SQL) update(ctx context.Context, force bool) error {
+	id := int64(0)
+	if s.changes != "" {
+		// Read the id before the zones, so changes made while loading trigger the next update.
+		if err := s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM "+s.changes).Scan(&id); err != nil {
+			return fmt.Errorf("failed to read changes from %s: %v", s.changes, err)
+		}
+		if !force && id == s.changeID {
+			return nil
+		}
+	}
+
+	zones, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.zMu.Lock()
+	old := s.zones
+	s.zones = zones
+	s.zMu.Unlock()
+	s.changeID = id
+
+	for _, name := range zones.Names {
+		z := zones.Z[name]
+		if o, ok := old.Z[name]; ok && o.Apex.SOA.Serial == z.Apex.SOA.Serial {
+			continue
+		}
+		log.Infof("Loaded zone %q with serial %d", name, z.Apex.SOA.Serial)
+		if len(z.TransferTo) > 0 {
+			z.Notify()
+		}
+	}
+	return nil
+}
+
+// load reads the zones and their records from the database. Records that can't be parsed are
+// skipped, as are zones without a SOA record.
+func (s *SQL) load(ctx context.Context) (file.Zones, error) {
+	z := make(map[string]*file.Zone)
+
+	rows, err := s.db.QueryContext(ctx, "SELECT name FROM zones")
+	if err != nil {
+		return file.Zones{}, fmt.Errorf("failed to read zones: %v", err)
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return file.Zones{}, fmt.Errorf("failed to read zones: %v", err)
+		}
+		origin := plugin.Name(name).Normalize()
+		if plugin.Zones(s.origins).Matches(origin) == "" {
+			continue
+		}
+		zone := file.NewZone(origin, "")
+		zone.TransferTo = s.transferTo
+		zone.Upstream = s.upstream
+		z[origin] = zone
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return file.Zones{}, fmt.Errorf("failed to read zones: %v", err)
+	}
+
+	rows, err = s.db.QueryContext(ctx, "SELECT zone, name, type, ttl, content FROM records")
+	if err != nil {
+		return file.Zones{}, fmt.Errorf("failed to read records: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			zone, name, typ, content string
+			ttl                      sql.NullInt64
+		)
+		if err := rows.Scan(&zone, &name, &typ, &ttl, &content); err != nil {
+			return file.Zones{}, fmt.Errorf("failed to read records: %v", err)
+		}
+		origin := plugin.Name(zone).Normalize()
+		zz, ok := z[origin]
+		if !ok {
+			continue
+		}
+		if !ttl.Valid {
+			ttl.Int64 = defaultTTL
+		}
+		rr, err := newRR(origin, name, typ, ttl.Int64, content)
+		if err != nil {
+			log.Warningf("Skipping record %q in zone %q: %v", name, origin, err)
+			continue
+		}
+		if err := zz.Insert(rr); err != nil {
+			log.Warningf("Skipping record %q in zone %q: %v", name, origin, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return file.Zones{}, fmt.Errorf("failed to read records: %v", err)
+	}
+
+	names := make([]string, 0, len(z))
+	for origin, zone := range z {
+		if zone.Apex.SOA == nil {
+			log.Warningf("Zone %q has no SOA record, not serving it", origin)
+			delete(z, origin)
+			continue
+		}
+		names = append(names, origin)
+	}
+	return file.Zones{Z: z, Names: names}, nil
+}
+
+// newRR parses a record from the records table. The name and the names in content are relative to
+// origin unless they end with a dot, an empty name is the apex.
+func newRR(origin, name, typ string, ttl int64, content string) (dns.RR, error) {
+	if name == "" {
+		name = "@"
+	}
+	zp := dns.NewZoneParser(strings.NewReader(fmt.Sprintf("%s %d IN %s %s", name, ttl, typ, content)), origin, "")
+	rr, ok := zp.Next()
+	if err := zp.Err(); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("no record")
+	}
+	if !dns.IsSubDomain(origin, rr.Header().Name) {
+		return nil, fmt.Errorf("out of zone data")
+	}
+	return rr, nil
+}
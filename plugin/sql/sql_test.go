@@ -0,0 +1,249 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+const schema = `
+CREATE TABLE zones (name TEXT PRIMARY KEY);
+CREATE TABLE records (
+	id      INTEGER PRIMARY KEY,
+	zone    TEXT NOT NULL,
+	name    TEXT NOT NULL,
+	type    TEXT NOT NULL,
+	ttl     INTEGER,
+	content TEXT NOT NULL
+);
+CREATE TABLE changes (id INTEGER PRIMARY KEY, zone TEXT NOT NULL);
+
+INSERT INTO zones VALUES ('example.org.'), ('example.net.');
+INSERT INTO records (zone, name, type, ttl, content) VALUES
+	('example.org.', '@', 'SOA', 3600, 'ns.example.org. hostmaster 2019052401 7200 1800 86400 300'),
+	('example.org.', '@', 'NS', 3600, 'ns'),
+	('example.org.', 'ns', 'A', 3600, '192.0.2.53'),
+	('example.org.', 'www', 'A', NULL, '192.0.2.1'),
+	('example.org.', 'alias', 'CNAME', 300, 'www'),
+	('example.org.', 'mail.example.org.', 'MX', 300, '10 www.example.org.'),
+	('example.org.', 'bad', 'A', 300, 'not-an-address'),
+	('example.org.', 'other.example.com.', 'A', 300, '192.0.2.2'),
+	('example.net.', 'www', 'A', 300, '192.0.2.3');
+`
+
+func newTestSQL(t *testing.T) (*SQL, func()) {
+	dir, err := ioutil.TempDir("", "coredns-sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", filepath.Join(dir, "dns.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatal(err)
+	}
+	s := New(db, []string{"."})
+	s.changes = "changes"
+	s.transferTo = []string{"*"}
+	return s, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSQL(t *testing.T) {
+	s, cleanup := newTestSQL(t)
+	defer cleanup()
+	if err := s.update(context.TODO(), true); err != nil {
+		t.Fatal(err)
+	}
+	// example.net. has no SOA record.
+	if len(s.zones.Names) != 1 || s.zones.Names[0] != "example.org." {
+		t.Fatalf("Expected only example.org. to be loaded, got %v", s.zones.Names)
+	}
+
+	tests := []test.Case{
+		{
+			Qname: "www.example.org.", Qtype: dns.TypeA,
+			Answer: []dns.RR{test.A("www.example.org. 3600 IN A 192.0.2.1")},
+			Ns:     []dns.RR{test.NS("example.org. 3600 IN NS ns.example.org.")},
+		},
+		{
+			Qname: "alias.example.org.", Qtype: dns.TypeA,
+			Answer: []dns.RR{
+				test.CNAME("alias.example.org. 300 IN CNAME www.example.org."),
+				test.A("www.example.org. 3600 IN A 192.0.2.1"),
+			},
+			Ns: []dns.RR{test.NS("example.org. 3600 IN NS ns.example.org.")},
+		},
+		{
+			Qname: "mail.example.org.", Qtype: dns.TypeMX,
+			Answer: []dns.RR{test.MX("mail.example.org. 300 IN MX 10 www.example.org.")},
+			Ns:     []dns.RR{test.NS("example.org. 3600 IN NS ns.example.org.")},
+			Extra:  []dns.RR{test.A("www.example.org. 3600 IN A 192.0.2.1")},
+		},
+		{
+			Qname: "bad.example.org.", Qtype: dns.TypeA,
+			Rcode: dns.RcodeNameError,
+			Ns:    []dns.RR{test.SOA("example.org. 3600 IN SOA ns.example.org. hostmaster.example.org. 2019052401 7200 1800 86400 300")},
+		},
+		{
+			Qname: "www.example.org.", Qtype: dns.TypeAAAA,
+			Ns: []dns.RR{test.SOA("example.org. 3600 IN SOA ns.example.org. hostmaster.example.org. 2019052401 7200 1800 86400 300")},
+		},
+	}
+
+	ctx := context.TODO()
+	for i, tc := range tests {
+		m := tc.Msg()
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := s.ServeDNS(ctx, rec, m); err != nil {
+			t.Errorf("Test %d: expected no error, got %v", i, err)
+			continue
+		}
+		if err := test.SortAndCheck(rec.Msg, tc); err != nil {
+			t.Errorf("Test %d: %v", i, err)
+		}
+	}
+
+	// Not one of our zones.
+	m := new(dns.Msg)
+	m.SetQuestion("www.example.net.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if code, _ := s.ServeDNS(ctx, rec, m); code != dns.RcodeServerFailure {
+		t.Errorf("Expected SERVFAIL without a next plugin, got %d", code)
+	}
+}
+
+func TestSQLTransfer(t *testing.T) {
+	s, cleanup := newTestSQL(t)
+	defer cleanup()
+	if err := s.update(context.TODO(), true); err != nil {
+		t.Fatal(err)
+	}
+
+	m := new(dns.Msg)
+	m.SetAxfr("example.org.")
+	w := &xfrWriter{msgs: make(chan *dns.Msg, 10)}
+	if _, err := s.ServeDNS(context.TODO(), w, m); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []dns.RR
+	for len(records) < 2 || records[len(records)-1].Header().Rrtype != dns.TypeSOA {
+		select {
+		case r := <-w.msgs:
+			records = append(records, r.Answer...)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timeout waiting for the transfer, got %d records", len(records))
+		}
+	}
+	// SOA, NS, 2 x A, CNAME, MX and the SOA again.
+	if x := len(records); x != 7 {
+		t.Fatalf("Expected 7 records in the transfer, got %d", x)
+	}
+	if records[0].Header().Rrtype != dns.TypeSOA {
+		t.Errorf("Expected the transfer to start with the SOA record")
+	}
+}
+
+type xfrWriter struct {
+	test.ResponseWriter
+	msgs chan *dns.Msg
+}
+
+func (w *xfrWriter) WriteMsg(m *dns.Msg) error {
+	w.msgs <- m
+	return nil
+}
+
+func TestSQLChanges(t *testing.T) {
+	s, cleanup := newTestSQL(t)
+	defer cleanup()
+	ctx := context.TODO()
+	if err := s.update(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	before := s.zones.Z["example.org."]
+
+	// A record without a row in the changes table isn't picked up.
+	if _, err := s.db.Exec(`INSERT INTO records (zone, name, type, ttl, content) VALUES ('example.org.', 'new', 'A', 300, '192.0.2.4')`); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.update(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	if s.zones.Z["example.org."] != before {
+		t.Fatal("Expected the zone not to be reloaded without changes")
+	}
+
+	if _, err := s.db.Exec(`INSERT INTO changes (zone) VALUES ('example.org.')`); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.update(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	if s.changeID != 1 {
+		t.Errorf("Expected change id 1, got %d", s.changeID)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("new.example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	s.ServeDNS(ctx, rec, m)
+	if len(rec.Msg.Answer) != 1 || rec.Msg.Answer[0].(*dns.A).A.String() != "192.0.2.4" {
+		t.Errorf("Expected the new record after the change, got %v", rec.Msg.Answer)
+	}
+
+	// Without a changes table every update reloads the zones.
+	s.changes = ""
+	before = s.zones.Z["example.org."]
+	if err := s.update(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	if s.zones.Z["example.org."] == before {
+		t.Error("Expected the zone to be reloaded when polling")
+	}
+}
+
+func TestNewRR(t *testing.T) {
+	tests := []struct {
+		name, typ, content string
+		expected           string
+		err                bool
+	}{
+		{"www", "A", "192.0.2.1", "www.example.org.\t300\tIN\tA\t192.0.2.1", false},
+		{"", "NS", "ns", "example.org.\t300\tIN\tNS\tns.example.org.", false},
+		{"@", "MX", "10 mx.example.net.", "example.org.\t300\tIN\tMX\t10 mx.example.net.", false},
+		{"_sip._tcp", "SRV", "10 20 5060 sip", "_sip._tcp.example.org.\t300\tIN\tSRV\t10 20 5060 sip.example.org.", false},
+		{"www", "A", "example", "", true},
+		{"www", "BOGUS", "1", "", true},
+		{"www.example.net.", "A", "192.0.2.1", "", true},
+	}
+	for i, tc := range tests {
+		rr, err := newRR("example.org.", tc.name, tc.typ, 300, tc.content)
+		if tc.err {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got %s", i, rr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %v", i, err)
+			continue
+		}
+		if rr.String() != tc.expected {
+			t.Errorf("Test %d: expected %q, got %q", i, tc.expected, rr.String())
+		}
+	}
+}
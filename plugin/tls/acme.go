@@ -0,0 +1,103 @@
+package tls
+
+import (
+	ctls "crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// acmeConfig holds the settings for obtaining certificates through ACME.
+type acmeConfig struct {
+	domains     []string
+	ca          string
+	roots       *x509.CertPool
+	email       string
+	storage     string
+	renewBefore time.Duration
+}
+
+// issuer obtains and renews certificates through ACME. Challenges are answered with TLS-ALPN-01
+// (RFC 8737) on the listener that uses the certificate.
+type issuer struct {
+	manager *autocert.Manager
+	domains []string
+}
+
+func newIssuer(a *acmeConfig) *issuer {
+	client := &acme.Client{DirectoryURL: a.ca}
+	if a.roots != nil {
+		client.HTTPClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &ctls.Config{RootCAs: a.roots},
+			},
+		}
+	}
+
+	m := &autocert.Manager{
+		Prompt:      autocert.AcceptTOS,
+		Cache:       autocert.DirCache(a.storage),
+		HostPolicy:  autocert.HostWhitelist(a.domains...),
+		RenewBefore: a.renewBefore,
+		Client:      client,
+		Email:       a.email,
+	}
+	return &issuer{manager: m, domains: a.domains}
+}
+
+// GetCertificate returns the certificate for the name in hello, obtaining it when needed. Clients
+// that don't send a server name, such as most DNS clients that connect to an IP address, get the
+// certificate of the first domain.
+func (i *issuer) GetCertificate(hello *ctls.ClientHelloInfo) (*ctls.Certificate, error) {
+	if hello.ServerName == "" {
+		h := *hello
+		h.ServerName = i.domains[0]
+		hello = &h
+	}
+	cert, err := i.manager.GetCertificate(hello)
+	if err != nil {
+		return nil, err
+	}
+	if cert.Leaf != nil {
+		observeExpiry(cert.Leaf)
+	}
+	return cert, nil
+}
+
+// GetConfigForClient returns a config that only speaks the TLS-ALPN-01 protocol when the client is
+// an ACME server validating a challenge, and nil otherwise. This keeps the application protocols
+// of the servers unchanged.
+func (i *issuer) GetConfigForClient(base *ctls.Config) func(*ctls.ClientHelloInfo) (*ctls.Config, error) {
+	return func(hello *ctls.ClientHelloInfo) (*ctls.Config, error) {
+		for _, p := range hello.SupportedProtos {
+			if p == acme.ALPNProto {
+				c := base.Clone()
+				c.NextProtos = []string{acme.ALPNProto}
+				c.GetConfigForClient = nil
+				// The ACME server doesn't present a client certificate.
+				c.ClientAuth = ctls.NoClientCert
+				c.ClientCAs = nil
+				return c, nil
+			}
+		}
+		return nil, nil
+	}
+}
+
+// obtain makes sure the (ECDSA) certificates for all domains are present, so the first client
+// doesn't wait for the issuance. Failures are logged, the next handshake tries again.
+func (i *issuer) obtain() {
+	for _, d := range i.domains {
+		hello := &ctls.ClientHelloInfo{
+			ServerName:   d,
+			CipherSuites: []uint16{ctls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+		}
+		if _, err := i.GetCertificate(hello); err != nil {
+			log.Warningf("Failed to obtain certificate for %s: %s", d, err)
+		}
+	}
+}
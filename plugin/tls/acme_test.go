@@ -0,0 +1,401 @@
+package tls
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	ctls "crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+func TestGetConfigForClient(t *testing.T) {
+	i := newIssuer(&acmeConfig{domains: []string{"example.org"}, storage: defaultStorage})
+	base := &ctls.Config{NextProtos: []string{"h2"}, ClientAuth: ctls.RequireAndVerifyClientCert, ClientCAs: x509.NewCertPool()}
+	base.GetConfigForClient = i.GetConfigForClient(base)
+
+	c, err := base.GetConfigForClient(&ctls.ClientHelloInfo{SupportedProtos: []string{"h2", "http/1.1"}})
+	if err != nil || c != nil {
+		t.Errorf("Expected no config for a normal client, got %v, %v", c, err)
+	}
+
+	c, err = base.GetConfigForClient(&ctls.ClientHelloInfo{SupportedProtos: []string{acme.ALPNProto}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.NextProtos) != 1 || c.NextProtos[0] != acme.ALPNProto {
+		t.Errorf("Expected only %s, got %v", acme.ALPNProto, c.NextProtos)
+	}
+	if c.ClientAuth != ctls.NoClientCert || c.ClientCAs != nil {
+		t.Errorf("Expected no client authentication for the ACME server, got %d", c.ClientAuth)
+	}
+	if base.NextProtos[0] != "h2" || base.ClientAuth != ctls.RequireAndVerifyClientCert {
+		t.Errorf("Expected the base config to be unchanged, got %v", base.NextProtos)
+	}
+}
+
+func TestObtain(t *testing.T) {
+	storage, err := ioutil.TempDir("", "coredns-acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storage)
+
+	ca := newFakeCA(t)
+	defer ca.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Certificate())
+
+	i := newIssuer(&acmeConfig{
+		domains: []string{"example.org"},
+		ca:      ca.URL + "/directory",
+		roots:   roots,
+		storage: storage,
+	})
+
+	// The listener that serves the certificate, it requires client certificates, like the tls
+	// plugin does with client_auth.
+	base := &ctls.Config{GetCertificate: i.GetCertificate, ClientAuth: ctls.RequireAndVerifyClientCert}
+	base.GetConfigForClient = i.GetConfigForClient(base)
+	ln, err := ctls.Listen("tcp", "127.0.0.1:0", base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn.(*ctls.Conn).Handshake()
+				conn.Close()
+			}()
+		}
+	}()
+	ca.addr = ln.Addr().String()
+
+	i.obtain()
+
+	if n := ca.validations(); n != 1 {
+		t.Fatalf("Expected the challenge to be validated once, got %d", n)
+	}
+	if _, err := i.manager.Cache.Get(context.TODO(), "example.org"); err != nil {
+		t.Fatalf("Expected the certificate in the storage, got %s", err)
+	}
+
+	cert, err := i.GetCertificate(&ctls.ClientHelloInfo{CipherSuites: []uint16{ctls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cert.Leaf.CheckSignatureFrom(ca.cert); err != nil {
+		t.Errorf("Expected a certificate issued by the CA, got %s", err)
+	}
+	if err := cert.Leaf.VerifyHostname("example.org"); err != nil {
+		t.Errorf("Expected a certificate for example.org: %s", err)
+	}
+	if n := ca.validations(); n != 1 {
+		t.Errorf("Expected the certificate to be obtained once, got %d validations", n)
+	}
+}
+
+// fakeCA is an ACME server (RFC 8555) that handles a single order at a time. It validates the
+// TLS-ALPN-01 challenge on addr and signs the certificate with its own key.
+type fakeCA struct {
+	*httptest.Server
+	addr string
+
+	key  *ecdsa.PrivateKey
+	cert *x509.Certificate
+
+	mu         sync.Mutex
+	nonce      int
+	accountKey crypto.PublicKey
+	domain     string
+	token      string
+	status     string // of the order
+	chain      []byte
+	validated  int
+}
+
+// idPeACMEIdentifier is the OID of the acmeIdentifier extension of TLS-ALPN-01 (RFC 8737).
+var idPeACMEIdentifier = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 31}
+
+func newFakeCA(t *testing.T) *fakeCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Fake ACME CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := &fakeCA{key: key, cert: cert}
+	ca.Server = httptest.NewTLSServer(http.HandlerFunc(ca.ServeHTTP))
+	return ca
+}
+
+func (ca *fakeCA) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	ca.nonce++
+	w.Header().Set("Replay-Nonce", strconv.Itoa(ca.nonce))
+
+	if r.URL.Path == "/directory" {
+		ca.reply(w, http.StatusOK, map[string]string{
+			"newNonce":   ca.URL + "/nonce",
+			"newAccount": ca.URL + "/account",
+			"newOrder":   ca.URL + "/order",
+			"revokeCert": ca.URL + "/revoke",
+			"keyChange":  ca.URL + "/key-change",
+		})
+		return
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	var jws struct{ Protected, Payload string }
+	if err := json.NewDecoder(r.Body).Decode(&jws); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(jws.Payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/account":
+		if err := ca.register(jws.Protected); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Location", ca.URL+"/account/1")
+		ca.reply(w, http.StatusCreated, map[string]string{"status": acme.StatusValid})
+
+	case "/order":
+		var req struct{ Identifiers []struct{ Value string } }
+		if err := json.Unmarshal(payload, &req); err != nil || len(req.Identifiers) != 1 {
+			http.Error(w, "bad order", http.StatusBadRequest)
+			return
+		}
+		ca.domain, ca.token = req.Identifiers[0].Value, strconv.Itoa(ca.nonce)
+		ca.status, ca.chain = acme.StatusPending, nil
+		w.Header().Set("Location", ca.URL+"/order/1")
+		ca.reply(w, http.StatusCreated, ca.order())
+
+	case "/order/1":
+		w.Header().Set("Location", ca.URL+"/order/1")
+		ca.reply(w, http.StatusOK, ca.order())
+
+	case "/authz/1":
+		status := acme.StatusValid
+		switch ca.status {
+		case acme.StatusPending, acme.StatusInvalid:
+			status = ca.status
+		}
+		ca.reply(w, http.StatusOK, map[string]interface{}{
+			"status":     status,
+			"identifier": map[string]string{"type": "dns", "value": ca.domain},
+			"challenges": []interface{}{ca.challenge(status)},
+		})
+
+	case "/challenge/1":
+		if ca.status == acme.StatusPending {
+			ca.status = acme.StatusReady
+			if err := ca.validate(); err != nil {
+				ca.status = acme.StatusInvalid
+			} else {
+				ca.validated++
+			}
+		}
+		status := acme.StatusValid
+		if ca.status == acme.StatusInvalid {
+			status = acme.StatusInvalid
+		}
+		ca.reply(w, http.StatusOK, ca.challenge(status))
+
+	case "/finalize/1":
+		var req struct{ CSR string }
+		if err := json.Unmarshal(payload, &req); err != nil || ca.status != acme.StatusReady {
+			http.Error(w, "bad finalize", http.StatusForbidden)
+			return
+		}
+		csr, err := base64.RawURLEncoding.DecodeString(req.CSR)
+		if err == nil {
+			ca.chain, err = ca.sign(csr)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ca.status = acme.StatusValid
+		w.Header().Set("Location", ca.URL+"/order/1")
+		ca.reply(w, http.StatusOK, ca.order())
+
+	case "/certificate/1":
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		w.Write(ca.chain)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (ca *fakeCA) validations() int {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	return ca.validated
+}
+
+func (ca *fakeCA) reply(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (ca *fakeCA) order() map[string]interface{} {
+	o := map[string]interface{}{
+		"status":         ca.status,
+		"identifiers":    []map[string]string{{"type": "dns", "value": ca.domain}},
+		"authorizations": []string{ca.URL + "/authz/1"},
+		"finalize":       ca.URL + "/finalize/1",
+	}
+	if ca.chain != nil {
+		o["certificate"] = ca.URL + "/certificate/1"
+	}
+	return o
+}
+
+func (ca *fakeCA) challenge(status string) map[string]string {
+	return map[string]string{
+		"type":   "tls-alpn-01",
+		"url":    ca.URL + "/challenge/1",
+		"token":  ca.token,
+		"status": status,
+	}
+}
+
+// register remembers the account key from the JWK in the protected header.
+func (ca *fakeCA) register(protected string) error {
+	b, err := base64.RawURLEncoding.DecodeString(protected)
+	if err != nil {
+		return err
+	}
+	var hdr struct {
+		JWK struct{ Kty, Crv, X, Y string }
+	}
+	if err := json.Unmarshal(b, &hdr); err != nil {
+		return err
+	}
+	if hdr.JWK.Kty != "EC" || hdr.JWK.Crv != "P-256" {
+		return errors.New("unsupported account key")
+	}
+	x, err := base64.RawURLEncoding.DecodeString(hdr.JWK.X)
+	if err != nil {
+		return err
+	}
+	y, err := base64.RawURLEncoding.DecodeString(hdr.JWK.Y)
+	if err != nil {
+		return err
+	}
+	ca.accountKey = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	return nil
+}
+
+// validate connects to addr and checks the key authorization in the challenge certificate. It uses
+// TLS 1.2, because with TLS 1.3 a server that wants a client certificate only fails the handshake
+// after the client finished it.
+func (ca *fakeCA) validate() error {
+	conn, err := ctls.Dial("tcp", ca.addr, &ctls.Config{
+		ServerName:         ca.domain,
+		NextProtos:         []string{acme.ALPNProto},
+		MaxVersion:         ctls.VersionTLS12,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	thumbprint, err := acme.JWKThumbprint(ca.accountKey)
+	if err != nil {
+		return err
+	}
+	want := sha256.Sum256([]byte(ca.token + "." + thumbprint))
+	for _, e := range conn.ConnectionState().PeerCertificates[0].Extensions {
+		if !e.Id.Equal(idPeACMEIdentifier) {
+			continue
+		}
+		var got []byte
+		if _, err := asn1.Unmarshal(e.Value, &got); err != nil {
+			return err
+		}
+		if !bytes.Equal(got, want[:]) {
+			return errors.New("wrong key authorization")
+		}
+		return nil
+	}
+	return errors.New("no acmeIdentifier extension")
+}
+
+// sign returns the PEM encoded chain of the certificate for csr.
+func (ca *fakeCA) sign(der []byte) ([]byte, error) {
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(int64(ca.nonce)),
+		Subject:      csr.Subject,
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	leaf, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, csr.PublicKey, ca.key)
+	if err != nil {
+		return nil, err
+	}
+	chain := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf})
+	return append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})...), nil
+}
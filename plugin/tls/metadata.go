@@ -0,0 +1,69 @@
+package tls
+
+import (
+	"context"
+	"crypto/x509"
+	"strconv"
+	"strings"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// TLS publishes the certificate of the client as metadata. It is only added to the plugin chain
+// when client certificates are requested.
+type TLS struct {
+	Next plugin.Handler
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (t TLS) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	return plugin.NextOrFailure(t.Name(), t.Next, ctx, w, r)
+}
+
+// Name implements the plugin.Handler interface.
+func (t TLS) Name() string { return "tls" }
+
+// Metadata implements the metadata.Provider interface.
+func (t TLS) Metadata(ctx context.Context, state request.Request) context.Context {
+	cert, verified := clientCert(state.W)
+	if cert == nil {
+		return ctx
+	}
+	metadata.SetValueFunc(ctx, "tls/client_subject", func() string { return cert.Subject.String() })
+	metadata.SetValueFunc(ctx, "tls/client_common_name", func() string { return cert.Subject.CommonName })
+	metadata.SetValueFunc(ctx, "tls/client_san", func() string { return strings.Join(sans(cert), ",") })
+	metadata.SetValueFunc(ctx, "tls/client_verified", func() string { return strconv.FormatBool(verified) })
+	return ctx
+}
+
+// clientCert returns the certificate the client presented on the connection of w, and whether it
+// was verified against the CA. It returns nil when the connection isn't using TLS or the client
+// didn't send a certificate.
+func clientCert(w dns.ResponseWriter) (*x509.Certificate, bool) {
+	cs, ok := w.(dns.ConnectionStater)
+	if !ok {
+		return nil, false
+	}
+	state := cs.ConnectionState()
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil, false
+	}
+	return state.PeerCertificates[0], len(state.VerifiedChains) > 0
+}
+
+// sans returns the subject alternative names of cert.
+func sans(cert *x509.Certificate) []string {
+	names := append([]string{}, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	for _, u := range cert.URIs {
+		names = append(names, u.String())
+	}
+	return names
+}
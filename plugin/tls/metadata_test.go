@@ -0,0 +1,75 @@
+package tls
+
+import (
+	"context"
+	ctls "crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+type tlsWriter struct {
+	test.ResponseWriter
+	state *ctls.ConnectionState
+}
+
+func (w *tlsWriter) ConnectionState() *ctls.ConnectionState { return w.state }
+
+func TestMetadata(t *testing.T) {
+	cert := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "alice", Organization: []string{"Example"}},
+		DNSNames:       []string{"alice.example.org"},
+		EmailAddresses: []string{"alice@example.org"},
+		IPAddresses:    []net.IP{net.ParseIP("192.0.2.1")},
+	}
+
+	tests := []struct {
+		w        dns.ResponseWriter
+		expected map[string]string
+	}{
+		{&test.ResponseWriter{}, nil},
+		{&tlsWriter{}, nil},
+		{&tlsWriter{state: &ctls.ConnectionState{}}, nil},
+		{&tlsWriter{state: &ctls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}}, map[string]string{
+			"tls/client_subject":     "CN=alice,O=Example",
+			"tls/client_common_name": "alice",
+			"tls/client_san":         "alice.example.org,alice@example.org,192.0.2.1",
+			"tls/client_verified":    "false",
+		}},
+		{&tlsWriter{state: &ctls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{cert},
+			VerifiedChains:   [][]*x509.Certificate{{cert}},
+		}}, map[string]string{"tls/client_verified": "true"}},
+	}
+
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		ctx := metadata.ContextWithMetadata(context.Background())
+		ctx = TLS{}.Metadata(ctx, request.Request{W: tc.w, Req: m})
+
+		if tc.expected == nil {
+			if labels := metadata.Labels(ctx); len(labels) != 0 {
+				t.Errorf("Test %d: expected no metadata, got %v", i, labels)
+			}
+			continue
+		}
+		for label, expected := range tc.expected {
+			f := metadata.ValueFunc(ctx, label)
+			if f == nil {
+				t.Errorf("Test %d: expected metadata %s, got none", i, label)
+				continue
+			}
+			if v := f(); v != expected {
+				t.Errorf("Test %d: expected %s to be %q, got %q", i, label, expected, v)
+			}
+		}
+	}
+}
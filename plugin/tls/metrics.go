@@ -0,0 +1,27 @@
+package tls
+
+import (
+	"crypto/x509"
+	"strings"
+
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CertExpiry is the expiry time of the served certificates, as a unix timestamp.
+var CertExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: "tls",
+	Name:      "certificate_expiry_timestamp_seconds",
+	Help:      "Gauge of the expiry time of the served certificates, as a unix timestamp.",
+}, []string{"names"})
+
+// observeExpiry records the expiry time of leaf.
+func observeExpiry(leaf *x509.Certificate) {
+	names := leaf.DNSNames
+	if len(names) == 0 {
+		names = []string{leaf.Subject.CommonName}
+	}
+	CertExpiry.WithLabelValues(strings.Join(names, ",")).Set(float64(leaf.NotAfter.Unix()))
+}
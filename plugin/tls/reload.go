@@ -0,0 +1,108 @@
+package tls
+
+import (
+	"bytes"
+	ctls "crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// reloader serves a certificate and key from disk and reloads them when the files change.
+type reloader struct {
+	certPath string
+	keyPath  string
+	interval time.Duration
+
+	mu   sync.RWMutex
+	cert *ctls.Certificate
+	// certPEM and keyPEM are the contents the current certificate was loaded from.
+	certPEM []byte
+	keyPEM  []byte
+
+	quit chan struct{}
+}
+
+func newReloader(certPath, keyPath string, interval time.Duration) (*reloader, error) {
+	r := &reloader{certPath: certPath, keyPath: keyPath, interval: interval, quit: make(chan struct{})}
+	if _, err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, it implements tls.Config.GetCertificate.
+func (r *reloader) GetCertificate(*ctls.ClientHelloInfo) (*ctls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// load reads the certificate and key files and replaces the current certificate when they changed.
+// It returns true when the certificate was replaced.
+func (r *reloader) load() (bool, error) {
+	certPEM, err := ioutil.ReadFile(r.certPath)
+	if err != nil {
+		return false, fmt.Errorf("could not load TLS cert: %s", err)
+	}
+	keyPEM, err := ioutil.ReadFile(r.keyPath)
+	if err != nil {
+		return false, fmt.Errorf("could not load TLS key: %s", err)
+	}
+
+	r.mu.RLock()
+	same := bytes.Equal(certPEM, r.certPEM) && bytes.Equal(keyPEM, r.keyPEM)
+	r.mu.RUnlock()
+	if same {
+		return false, nil
+	}
+
+	cert, err := ctls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, fmt.Errorf("could not load TLS cert: %s", err)
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return false, fmt.Errorf("could not parse TLS cert: %s", err)
+	}
+	observeExpiry(cert.Leaf)
+
+	r.mu.Lock()
+	r.cert, r.certPEM, r.keyPEM = &cert, certPEM, keyPEM
+	r.mu.Unlock()
+	return true, nil
+}
+
+// run checks the files every interval until stop is called. A certificate that fails to load, for
+// instance because only one of the files has been written yet, is ignored and the current one is
+// kept.
+func (r *reloader) run() {
+	if r.interval == 0 {
+		return
+	}
+	go func() {
+		tick := time.NewTicker(r.interval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				ok, err := r.load()
+				if err != nil {
+					log.Warningf("Failed to reload certificate %s: %s", r.certPath, err)
+					continue
+				}
+				if ok {
+					log.Infof("Reloaded certificate %s", r.certPath)
+				}
+			case <-r.quit:
+				return
+			}
+		}
+	}()
+}
+
+func (r *reloader) stop() error {
+	close(r.quit)
+	return nil
+}
@@ -0,0 +1,99 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	ctls "crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// writeCert writes a self signed certificate for name, that expires at notAfter, to dir.
+func writeCert(t *testing.T, dir, name string, notAfter time.Time) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath
+}
+
+func TestReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	expiry := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	certPath, keyPath := writeCert(t, dir, "a.example.org", expiry)
+
+	r, err := newReloader(certPath, keyPath, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := r.GetCertificate(&ctls.ClientHelloInfo{})
+	if cert.Leaf.Subject.CommonName != "a.example.org" {
+		t.Errorf("Expected certificate for a.example.org, got %s", cert.Leaf.Subject.CommonName)
+	}
+	if x := testutil.ToFloat64(CertExpiry.WithLabelValues("a.example.org")); x != float64(expiry.Unix()) {
+		t.Errorf("Expected expiry %d, got %f", expiry.Unix(), x)
+	}
+
+	if ok, err := r.load(); ok || err != nil {
+		t.Errorf("Expected no reload for unchanged files, got %t, %v", ok, err)
+	}
+
+	// A half written update keeps the current certificate.
+	if err := ioutil.WriteFile(certPath, []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.load(); err == nil {
+		t.Errorf("Expected error for invalid certificate")
+	}
+	cert, _ = r.GetCertificate(&ctls.ClientHelloInfo{})
+	if cert.Leaf.Subject.CommonName != "a.example.org" {
+		t.Errorf("Expected certificate for a.example.org, got %s", cert.Leaf.Subject.CommonName)
+	}
+
+	writeCert(t, dir, "b.example.org", expiry)
+	if ok, err := r.load(); !ok || err != nil {
+		t.Errorf("Expected reload, got %t, %v", ok, err)
+	}
+	cert, _ = r.GetCertificate(&ctls.ClientHelloInfo{})
+	if cert.Leaf.Subject.CommonName != "b.example.org" {
+		t.Errorf("Expected certificate for b.example.org, got %s", cert.Leaf.Subject.CommonName)
+	}
+}
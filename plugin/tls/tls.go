@@ -2,14 +2,19 @@ package tls
 
 import (
 	ctls "crypto/tls"
+	"time"
 
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
 	"github.com/coredns/coredns/plugin/pkg/tls"
 
 	"github.com/mholt/caddy"
 )
 
+var log = clog.NewWithPlugin("tls")
+
 func init() {
 	caddy.RegisterPlugin("tls", caddy.Plugin{
 		ServerType: "dns",
@@ -34,10 +39,16 @@ func parseTLS(c *caddy.Controller) error {
 
 	for c.Next() {
 		args := c.RemainingArgs()
-		if len(args) < 2 || len(args) > 3 {
+		useACME := len(args) > 0 && args[0] == "acme"
+		if useACME && len(args) < 2 {
+			return plugin.Error("tls", c.ArgErr())
+		}
+		if !useACME && (len(args) < 2 || len(args) > 3) {
 			return plugin.Error("tls", c.ArgErr())
 		}
 		clientAuth := ctls.NoClientCert
+		reload := defaultReload
+		a := &acmeConfig{domains: args[1:], storage: defaultStorage}
 		for c.NextBlock() {
 			switch c.Val() {
 			case "client_auth":
@@ -59,11 +70,39 @@ func parseTLS(c *caddy.Controller) error {
 				default:
 					return c.Errf("unknown authentication type '%s'", authTypeArgs[0])
 				}
+			case "reload":
+				if useACME {
+					return c.Errf("option '%s' can not be used with acme", c.Val())
+				}
+				if !c.NextArg() {
+					return c.ArgErr()
+				}
+				d, err := time.ParseDuration(c.Val())
+				if err != nil || d < 0 {
+					return c.Errf("invalid duration '%s'", c.Val())
+				}
+				reload = d
+			case "ca", "ca_root", "email", "storage", "renew_before":
+				if !useACME {
+					return c.Errf("option '%s' can only be used with acme", c.Val())
+				}
+				if err := parseACME(c, a); err != nil {
+					return err
+				}
 			default:
 				return c.Errf("unknown option '%s'", c.Val())
 			}
 		}
-		tls, err := tls.NewTLSConfigFromArgs(args...)
+
+		var (
+			tls *ctls.Config
+			err error
+		)
+		if useACME {
+			tls, err = newACMEConfig(c, a)
+		} else {
+			tls, err = newFileConfig(c, args, reload)
+		}
 		if err != nil {
 			return err
 		}
@@ -71,6 +110,92 @@ func parseTLS(c *caddy.Controller) error {
 		// NewTLSConfigFromArgs only sets RootCAs, so we need to let ClientCAs refer to it.
 		tls.ClientCAs = tls.RootCAs
 		config.TLSConfig = tls
+
+		if clientAuth != ctls.NoClientCert {
+			config.AddPlugin(func(next plugin.Handler) plugin.Handler {
+				return TLS{Next: next}
+			})
+		}
 	}
+
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, CertExpiry)
+		return nil
+	})
 	return nil
 }
+
+func parseACME(c *caddy.Controller, a *acmeConfig) error {
+	opt := c.Val()
+	if !c.NextArg() {
+		return c.ArgErr()
+	}
+	switch opt {
+	case "ca":
+		a.ca = c.Val()
+	case "ca_root":
+		roots, err := tls.NewTLSClientConfig(c.Val())
+		if err != nil {
+			return err
+		}
+		a.roots = roots.RootCAs
+	case "email":
+		a.email = c.Val()
+	case "storage":
+		a.storage = c.Val()
+	case "renew_before":
+		d, err := time.ParseDuration(c.Val())
+		if err != nil || d <= 0 {
+			return c.Errf("invalid duration '%s'", c.Val())
+		}
+		a.renewBefore = d
+	}
+	if c.NextArg() {
+		return c.ArgErr()
+	}
+	return nil
+}
+
+// newFileConfig returns a TLS config that serves the certificate and key from disk, reloading them
+// when they change.
+func newFileConfig(c *caddy.Controller, args []string, reload time.Duration) (*ctls.Config, error) {
+	tls, err := tls.NewTLSConfigFromArgs(args...)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newReloader(args[0], args[1], reload)
+	if err != nil {
+		return nil, err
+	}
+	tls.Certificates = nil
+	tls.GetCertificate = r.GetCertificate
+
+	c.OnStartup(func() error {
+		r.run()
+		return nil
+	})
+	c.OnShutdown(r.stop)
+	return tls, nil
+}
+
+// newACMEConfig returns a TLS config that serves certificates obtained through ACME.
+func newACMEConfig(c *caddy.Controller, a *acmeConfig) (*ctls.Config, error) {
+	tls, err := tls.NewTLSConfigFromArgs()
+	if err != nil {
+		return nil, err
+	}
+	i := newIssuer(a)
+	tls.GetCertificate = i.GetCertificate
+	tls.GetConfigForClient = i.GetConfigForClient(tls)
+
+	c.OnStartup(func() error {
+		go i.obtain()
+		return nil
+	})
+	return tls, nil
+}
+
+const (
+	defaultReload  = time.Minute
+	defaultStorage = "acme"
+)
@@ -24,6 +24,14 @@ func TestTLS(t *testing.T) {
 		{"tls test_cert.pem test_key.pem test_ca.pem {\nclient_auth\n}", true, "", "Wrong argument"},
 		{"tls test_cert.pem test_key.pem test_ca.pem {\nclient_auth none bogus\n}", true, "", "Wrong argument"},
 		{"tls test_cert.pem test_key.pem test_ca.pem {\nclient_auth bogus\n}", true, "", "unknown authentication type"},
+		{"tls test_cert.pem test_key.pem {\nreload\n}", true, "", "Wrong argument"},
+		{"tls test_cert.pem test_key.pem {\nreload -1s\n}", true, "", "invalid duration"},
+		{"tls test_cert.pem test_key.pem {\nemail admin@example.org\n}", true, "", "can only be used with acme"},
+		{"tls acme", true, "", "Wrong argument"},
+		{"tls acme example.org {\nreload 1m\n}", true, "", "can not be used with acme"},
+		{"tls acme example.org {\nrenew_before 0s\n}", true, "", "invalid duration"},
+		{"tls acme example.org {\nca_root missing.pem\n}", true, "", "error reading"},
+		{"tls acme example.org {\nemail\n}", true, "", "Wrong argument"},
 	}
 
 	for i, test := range tests {
@@ -47,6 +55,37 @@ func TestTLS(t *testing.T) {
 	}
 }
 
+func TestTLSSetup(t *testing.T) {
+	tests := []struct {
+		input string
+		acme  bool
+	}{
+		{"tls test_cert.pem test_key.pem", false},
+		{"tls test_cert.pem test_key.pem test_ca.pem {\nreload 10s\n}", false},
+		{"tls test_cert.pem test_key.pem {\nreload 0\n}", false},
+		{"tls acme example.org", true},
+		{"tls acme example.org www.example.org {\nca https://localhost:14000/dir\nca_root test_ca.pem\nemail admin@example.org\nstorage /var/lib/coredns/acme\nrenew_before 720h\n}", true},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		if err := setup(c); err != nil {
+			t.Errorf("Test %d: Expected no error but found one for input %s. Error was: %v", i, test.input, err)
+			continue
+		}
+		cfg := dnsserver.GetConfig(c).TLSConfig
+		if cfg.GetCertificate == nil {
+			t.Errorf("Test %d: Expected GetCertificate to be set", i)
+		}
+		if len(cfg.Certificates) != 0 {
+			t.Errorf("Test %d: Expected no static certificates, got %d", i, len(cfg.Certificates))
+		}
+		if acme := cfg.GetConfigForClient != nil; acme != test.acme {
+			t.Errorf("Test %d: Expected acme %t, got %t", i, test.acme, acme)
+		}
+	}
+}
+
 func TestTLSClientAuthentication(t *testing.T) {
 	// Invalid configurations are tested in the general test case.  In this test we only look into specific details of valid client_auth options.
 	tests := []struct {
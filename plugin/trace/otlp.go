@@ -0,0 +1,356 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/coremain"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/opentracing/opentracing-go/ext"
+	zipkin "github.com/openzipkin/zipkin-go-opentracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+// otlpRecorder is a zipkin.SpanRecorder that batches finished spans and exports them to an
+// OpenTelemetry collector using OTLP, either over gRPC or over HTTP. We encode the protobuf
+// messages by hand to avoid pulling in the OpenTelemetry SDK.
+type otlpRecorder struct {
+	endpoint    string
+	serviceName string
+
+	conn   *grpc.ClientConn // set when exporting over gRPC
+	client *http.Client     // set when exporting over HTTP
+
+	mu    sync.Mutex
+	spans []zipkin.RawSpan
+
+	stopCh chan struct{}
+	done   chan struct{}
+}
+
+func newOTLPRecorder(endpoint, serviceName string) (*otlpRecorder, error) {
+	r := &otlpRecorder{serviceName: serviceName, stopCh: make(chan struct{}), done: make(chan struct{})}
+
+	switch {
+	case strings.HasPrefix(endpoint, "grpc://"):
+		r.endpoint = endpoint[len("grpc://"):]
+		conn, err := grpc.Dial(r.endpoint, grpc.WithInsecure())
+		if err != nil {
+			return nil, err
+		}
+		r.conn = conn
+	case strings.HasPrefix(endpoint, "http://"), strings.HasPrefix(endpoint, "https://"):
+		r.endpoint = endpoint
+		r.client = &http.Client{Timeout: otlpTimeout}
+	default:
+		return nil, fmt.Errorf("unsupported otlp endpoint: %s", endpoint)
+	}
+	return r, nil
+}
+
+// RecordSpan implements zipkin.SpanRecorder.
+func (r *otlpRecorder) RecordSpan(span zipkin.RawSpan) {
+	if !span.Context.Sampled {
+		return
+	}
+	r.mu.Lock()
+	if len(r.spans) < otlpMaxQueue {
+		r.spans = append(r.spans, span)
+	}
+	r.mu.Unlock()
+}
+
+func (r *otlpRecorder) start() {
+	go func() {
+		defer close(r.done)
+		tick := time.NewTicker(otlpInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				if err := r.flush(); err != nil {
+					log.Warningf("Failed to export spans: %s", err)
+				}
+			case <-r.stopCh:
+				if err := r.flush(); err != nil {
+					log.Warningf("Failed to export spans: %s", err)
+				}
+				return
+			}
+		}
+	}()
+}
+
+func (r *otlpRecorder) stop() {
+	close(r.stopCh)
+	<-r.done
+	if r.conn != nil {
+		r.conn.Close()
+	}
+}
+
+// flush exports all queued spans.
+func (r *otlpRecorder) flush() error {
+	r.mu.Lock()
+	spans := r.spans
+	r.spans = nil
+	r.mu.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+	return r.export(encodeExportRequest(r.serviceName, spans))
+}
+
+func (r *otlpRecorder) export(buf []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), otlpTimeout)
+	defer cancel()
+
+	if r.conn != nil {
+		reply := []byte{}
+		return r.conn.Invoke(ctx, otlpExportMethod, buf, &reply, grpc.ForceCodec(rawCodec{}))
+	}
+
+	req, err := http.NewRequest(http.MethodPost, r.endpoint, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	resp, err := r.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status from %s: %s", r.endpoint, resp.Status)
+	}
+	return nil
+}
+
+// rawCodec passes the already encoded protobuf messages through to gRPC.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T", v)
+	}
+	return b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unexpected type %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string { return "proto" }
+
+var _ encoding.Codec = rawCodec{}
+
+// Field numbers and enum values from opentelemetry/proto/{collector/trace,trace,common,resource}/v1.
+const (
+	fieldResourceSpans = 1 // ExportTraceServiceRequest.resource_spans
+
+	fieldResource   = 1 // ResourceSpans.resource
+	fieldScopeSpans = 2 // ResourceSpans.scope_spans
+
+	fieldResourceAttributes = 1 // Resource.attributes
+
+	fieldScope = 1 // ScopeSpans.scope
+	fieldSpans = 2 // ScopeSpans.spans
+
+	fieldScopeName    = 1 // InstrumentationScope.name
+	fieldScopeVersion = 2 // InstrumentationScope.version
+
+	fieldTraceID      = 1  // Span.trace_id
+	fieldSpanID       = 2  // Span.span_id
+	fieldParentSpanID = 4  // Span.parent_span_id
+	fieldName         = 5  // Span.name
+	fieldKind         = 6  // Span.kind
+	fieldStartTime    = 7  // Span.start_time_unix_nano
+	fieldEndTime      = 8  // Span.end_time_unix_nano
+	fieldAttributes   = 9  // Span.attributes
+	fieldStatus       = 15 // Span.status
+
+	fieldStatusCode = 3 // Status.code
+
+	fieldKey   = 1 // KeyValue.key
+	fieldValue = 2 // KeyValue.value
+
+	fieldStringValue = 1 // AnyValue.string_value
+	fieldBoolValue   = 2 // AnyValue.bool_value
+	fieldIntValue    = 3 // AnyValue.int_value
+	fieldDoubleValue = 4 // AnyValue.double_value
+
+	kindInternal = 1
+	kindServer   = 2
+	kindClient   = 3
+
+	statusError = 2
+
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+// encodeExportRequest returns the protobuf encoded ExportTraceServiceRequest for spans.
+func encodeExportRequest(serviceName string, spans []zipkin.RawSpan) []byte {
+	resource := proto.NewBuffer(nil)
+	encodeMessage(resource, fieldResourceAttributes, encodeKeyValue("service.name", serviceName))
+
+	scope := proto.NewBuffer(nil)
+	encodeString(scope, fieldScopeName, "coredns")
+	encodeString(scope, fieldScopeVersion, coremain.CoreVersion)
+
+	scopeSpans := proto.NewBuffer(nil)
+	encodeMessage(scopeSpans, fieldScope, scope.Bytes())
+	for _, s := range spans {
+		encodeMessage(scopeSpans, fieldSpans, encodeSpan(s))
+	}
+
+	resourceSpans := proto.NewBuffer(nil)
+	encodeMessage(resourceSpans, fieldResource, resource.Bytes())
+	encodeMessage(resourceSpans, fieldScopeSpans, scopeSpans.Bytes())
+
+	req := proto.NewBuffer(nil)
+	encodeMessage(req, fieldResourceSpans, resourceSpans.Bytes())
+	return req.Bytes()
+}
+
+func encodeSpan(s zipkin.RawSpan) []byte {
+	b := proto.NewBuffer(nil)
+
+	traceID := make([]byte, 16)
+	putUint64(traceID[:8], s.Context.TraceID.High)
+	putUint64(traceID[8:], s.Context.TraceID.Low)
+	encodeBytes(b, fieldTraceID, traceID)
+
+	spanID := make([]byte, 8)
+	putUint64(spanID, s.Context.SpanID)
+	encodeBytes(b, fieldSpanID, spanID)
+
+	if s.Context.ParentSpanID != nil {
+		parentID := make([]byte, 8)
+		putUint64(parentID, *s.Context.ParentSpanID)
+		encodeBytes(b, fieldParentSpanID, parentID)
+	}
+
+	encodeString(b, fieldName, s.Operation)
+
+	kind := uint64(kindInternal)
+	switch s.Tags[string(ext.SpanKind)] {
+	case ext.SpanKindRPCServerEnum, string(ext.SpanKindRPCServerEnum):
+		kind = kindServer
+	case ext.SpanKindRPCClientEnum, string(ext.SpanKindRPCClientEnum):
+		kind = kindClient
+	}
+	b.EncodeVarint(uint64(fieldKind<<3 | wireVarint))
+	b.EncodeVarint(kind)
+
+	b.EncodeVarint(uint64(fieldStartTime<<3 | wireFixed64))
+	b.EncodeFixed64(uint64(s.Start.UnixNano()))
+	b.EncodeVarint(uint64(fieldEndTime<<3 | wireFixed64))
+	b.EncodeFixed64(uint64(s.Start.Add(s.Duration).UnixNano()))
+
+	// Sort the tags to get a stable encoding.
+	keys := make([]string, 0, len(s.Tags))
+	for k := range s.Tags {
+		if k == string(ext.SpanKind) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		encodeMessage(b, fieldAttributes, encodeKeyValue(k, s.Tags[k]))
+	}
+
+	if e, ok := s.Tags[string(ext.Error)].(bool); ok && e {
+		status := proto.NewBuffer(nil)
+		status.EncodeVarint(uint64(fieldStatusCode<<3 | wireVarint))
+		status.EncodeVarint(statusError)
+		encodeMessage(b, fieldStatus, status.Bytes())
+	}
+
+	return b.Bytes()
+}
+
+// encodeKeyValue returns an encoded KeyValue, v is converted to the matching AnyValue.
+func encodeKeyValue(k string, v interface{}) []byte {
+	value := proto.NewBuffer(nil)
+	switch x := v.(type) {
+	case bool:
+		value.EncodeVarint(uint64(fieldBoolValue<<3 | wireVarint))
+		if x {
+			value.EncodeVarint(1)
+		} else {
+			value.EncodeVarint(0)
+		}
+	case int:
+		encodeInt(value, int64(x))
+	case int32:
+		encodeInt(value, int64(x))
+	case int64:
+		encodeInt(value, x)
+	case uint16:
+		encodeInt(value, int64(x))
+	case uint32:
+		encodeInt(value, int64(x))
+	case float64:
+		value.EncodeVarint(uint64(fieldDoubleValue<<3 | wireFixed64))
+		value.EncodeFixed64(math.Float64bits(x))
+	case string:
+		encodeString(value, fieldStringValue, x)
+	default:
+		encodeString(value, fieldStringValue, fmt.Sprint(x))
+	}
+
+	kv := proto.NewBuffer(nil)
+	encodeString(kv, fieldKey, k)
+	encodeMessage(kv, fieldValue, value.Bytes())
+	return kv.Bytes()
+}
+
+func encodeInt(b *proto.Buffer, i int64) {
+	b.EncodeVarint(uint64(fieldIntValue<<3 | wireVarint))
+	b.EncodeVarint(uint64(i))
+}
+
+func encodeString(b *proto.Buffer, field int, s string) {
+	b.EncodeVarint(uint64(field<<3 | wireBytes))
+	b.EncodeStringBytes(s)
+}
+
+func encodeBytes(b *proto.Buffer, field int, p []byte) {
+	b.EncodeVarint(uint64(field<<3 | wireBytes))
+	b.EncodeRawBytes(p)
+}
+
+func encodeMessage(b *proto.Buffer, field int, msg []byte) { encodeBytes(b, field, msg) }
+
+func putUint64(b []byte, v uint64) {
+	for i := 7; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+}
+
+const (
+	otlpExportMethod = "/opentelemetry.proto.collector.trace.v1.TraceService/Export"
+	otlpInterval     = 1 * time.Second
+	otlpTimeout      = 5 * time.Second
+	otlpMaxQueue     = 10000 // drop spans when the collector can't keep up
+)
@@ -0,0 +1,135 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	coretrace "github.com/coredns/coredns/plugin/pkg/trace"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+	zipkin "github.com/openzipkin/zipkin-go-opentracing"
+	"google.golang.org/grpc"
+)
+
+func TestOTLPExportHTTP(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/x-protobuf" {
+			t.Errorf("Unexpected content type: %s", ct)
+		}
+		buf, _ := ioutil.ReadAll(r.Body)
+		bodies <- buf
+	}))
+	defer s.Close()
+
+	tr, err := traceParse(caddy.NewTestController("dns", `trace otlp `+s.URL))
+	if err != nil {
+		t.Fatalf("Error parsing test input: %s", err)
+	}
+	if err := tr.OnStartup(); err != nil {
+		t.Fatalf("Error starting tracing plugin: %s", err)
+	}
+	tr.Next = test.NextHandler(dns.RcodeSuccess, nil)
+
+	// Continue the trace of a client.
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	parent, err := coretrace.Extract(tr.Tracer(), "00-"+traceID+"-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatalf("Failed to extract trace context: %s", err)
+	}
+	ctx := coretrace.WithRemoteParent(context.TODO(), parent)
+
+	w := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := tr.ServeDNS(ctx, w, new(dns.Msg).SetQuestion("example.org.", dns.TypeA)); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	tr.OnShutdown()
+
+	body := <-bodies
+	traceIDBytes := []byte{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+	parentIDBytes := []byte{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7}
+	for _, want := range [][]byte{
+		traceIDBytes,
+		parentIDBytes,
+		[]byte("example.org."),
+		[]byte(tagName),
+		[]byte("service.name"),
+	} {
+		if !bytes.Contains(body, want) {
+			t.Errorf("Expected exported spans to contain %q", want)
+		}
+	}
+}
+
+func TestEncodeSpan(t *testing.T) {
+	parent := uint64(0x0102030405060708)
+	span := zipkin.RawSpan{
+		Context:   zipkin.SpanContext{SpanID: 0x1112131415161718, ParentSpanID: &parent, Sampled: true},
+		Operation: "connect",
+		Tags:      map[string]interface{}{"span.kind": "client", "error": true, coretrace.TagUpstream: "127.0.0.1:53"},
+	}
+	buf := encodeSpan(span)
+
+	for _, want := range [][]byte{
+		{fieldParentSpanID<<3 | wireBytes, 8, 1, 2, 3, 4, 5, 6, 7, 8},
+		{fieldName<<3 | wireBytes, 7, 'c', 'o', 'n', 'n', 'e', 'c', 't'},
+		{fieldKind<<3 | wireVarint, kindClient},
+		{fieldStatus<<3 | wireBytes, 2, fieldStatusCode<<3 | wireVarint, statusError},
+		[]byte("127.0.0.1:53"),
+	} {
+		if !bytes.Contains(buf, want) {
+			t.Errorf("Expected encoded span to contain %v", want)
+		}
+	}
+	if bytes.Contains(buf, []byte("span.kind")) {
+		t.Errorf("Expected span.kind to be encoded as the span kind, not as an attribute")
+	}
+}
+
+func TestOTLPExportGRPC(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	methods := make(chan string, 1)
+	s := grpc.NewServer(grpc.CustomCodec(serverCodec{}), grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+		m, _ := grpc.MethodFromServerStream(stream)
+		methods <- m
+		req := []byte{}
+		if err := stream.RecvMsg(&req); err != nil {
+			return err
+		}
+		return stream.SendMsg([]byte{})
+	}))
+	go s.Serve(l)
+	defer s.Stop()
+
+	r, err := newOTLPRecorder("grpc://"+l.Addr().String(), "coredns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.conn.Close()
+
+	if err := r.export(encodeExportRequest("coredns", []zipkin.RawSpan{{Operation: "test"}})); err != nil {
+		t.Fatalf("Failed to export spans: %s", err)
+	}
+	if m := <-methods; m != otlpExportMethod {
+		t.Errorf("Expected method %s, got %s", otlpExportMethod, m)
+	}
+}
+
+// serverCodec adapts rawCodec to the older grpc.Codec interface the server options want.
+type serverCodec struct{ rawCodec }
+
+func (serverCodec) String() string { return "proto" }
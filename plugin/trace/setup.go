@@ -2,15 +2,19 @@ package trace
 
 import (
 	"fmt"
+	"net/url"
 	"strconv"
 	"strings"
 
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
 
 	"github.com/mholt/caddy"
 )
 
+var log = clog.NewWithPlugin("trace")
+
 func init() {
 	caddy.RegisterPlugin("trace", caddy.Plugin{
 		ServerType: "dns",
@@ -30,6 +34,7 @@ func setup(c *caddy.Controller) error {
 	})
 
 	c.OnStartup(t.OnStartup)
+	c.OnShutdown(t.OnShutdown)
 
 	return nil
 }
@@ -50,6 +55,10 @@ func traceParse(c *caddy.Controller) (*trace, error) {
 		case 0:
 			tr.EndpointType, tr.Endpoint, err = normalizeEndpoint(defEpType, "")
 		case 1:
+			if _, ok := supportedProviders[strings.ToLower(args[0])]; ok {
+				tr.EndpointType, tr.Endpoint, err = normalizeEndpoint(strings.ToLower(args[0]), "")
+				break
+			}
 			tr.EndpointType, tr.Endpoint, err = normalizeEndpoint(defEpType, args[0])
 		case 2:
 			epType := strings.ToLower(args[0])
@@ -89,6 +98,11 @@ func traceParse(c *caddy.Controller) (*trace, error) {
 				if err != nil {
 					return nil, err
 				}
+			case "trust_traceparent":
+				if len(c.RemainingArgs()) != 0 {
+					return nil, c.ArgErr()
+				}
+				tr.trustParent = true
 			}
 		}
 	}
@@ -110,12 +124,27 @@ func normalizeEndpoint(epType, ep string) (string, string, error) {
 		}
 	}
 
+	if epType == "otlp" {
+		switch {
+		case strings.HasPrefix(ep, "grpc://"):
+		case strings.HasPrefix(ep, "http://"), strings.HasPrefix(ep, "https://"):
+			if u, err := url.Parse(ep); err == nil && (u.Path == "" || u.Path == "/") {
+				ep = strings.TrimSuffix(ep, "/") + "/v1/traces"
+			}
+		case strings.Contains(ep, "://"):
+			return "", "", fmt.Errorf("otlp endpoint '%s' must use grpc, http or https", ep)
+		default:
+			ep = "grpc://" + ep
+		}
+	}
+
 	return epType, ep, nil
 }
 
 var supportedProviders = map[string]string{
 	"zipkin":  "localhost:9411",
 	"datadog": "localhost:8126",
+	"otlp":    "localhost:4317",
 }
 
 const (
@@ -22,12 +22,17 @@ func TestTraceParse(t *testing.T) {
 		{`trace zipkin localhost:1234`, false, "http://localhost:1234/api/v1/spans", 1, `coredns`, false},
 		{`trace datadog localhost`, false, "localhost", 1, `coredns`, false},
 		{`trace datadog http://localhost:8127`, false, "http://localhost:8127", 1, `coredns`, false},
+		{`trace otlp`, false, "grpc://localhost:4317", 1, `coredns`, false},
+		{`trace otlp collector:4317`, false, "grpc://collector:4317", 1, `coredns`, false},
+		{`trace otlp http://collector:4318`, false, "http://collector:4318/v1/traces", 1, `coredns`, false},
+		{`trace otlp https://collector/otlp/traces`, false, "https://collector/otlp/traces", 1, `coredns`, false},
 		{"trace {\n every 100\n}", false, "http://localhost:9411/api/v1/spans", 100, `coredns`, false},
 		{"trace {\n every 100\n service foobar\nclient_server\n}", false, "http://localhost:9411/api/v1/spans", 100, `foobar`, true},
 		{"trace {\n every 2\n client_server true\n}", false, "http://localhost:9411/api/v1/spans", 2, `coredns`, true},
 		{"trace {\n client_server false\n}", false, "http://localhost:9411/api/v1/spans", 1, `coredns`, false},
 		// fails
 		{`trace footype localhost:4321`, true, "", 1, "", false},
+		{`trace otlp udp://collector:4317`, true, "", 1, "", false},
 		{"trace {\n every 2\n client_server junk\n}", true, "", 1, "", false},
 	}
 	for i, test := range tests {
@@ -59,3 +64,31 @@ func TestTraceParse(t *testing.T) {
 		}
 	}
 }
+
+func TestTraceParseTrustParent(t *testing.T) {
+	tests := []struct {
+		input       string
+		shouldErr   bool
+		trustParent bool
+	}{
+		{`trace`, false, false},
+		{"trace {\n trust_traceparent\n}", false, true},
+		{"trace {\n trust_traceparent yes\n}", true, false},
+	}
+	for i, test := range tests {
+		m, err := traceParse(caddy.NewTestController("dns", test.input))
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %v: Expected error but found nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if m.trustParent != test.trustParent {
+			t.Errorf("Test %v: Expected trust_traceparent %t but found: %t", i, test.trustParent, m.trustParent)
+		}
+	}
+}
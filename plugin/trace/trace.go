@@ -10,23 +10,21 @@ import (
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/metrics"
 	"github.com/coredns/coredns/plugin/pkg/dnstest"
-	"github.com/coredns/coredns/plugin/pkg/rcode"
+	coretrace "github.com/coredns/coredns/plugin/pkg/trace"
+	"github.com/coredns/coredns/request"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/opentracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 
-	// Plugin the trace package.
-	_ "github.com/coredns/coredns/plugin/pkg/trace"
-	"github.com/coredns/coredns/request"
-
 	"github.com/miekg/dns"
 	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
 	zipkin "github.com/openzipkin/zipkin-go-opentracing"
 )
 
 const (
-	tagName  = "coredns.io/name"
-	tagType  = "coredns.io/type"
-	tagRcode = "coredns.io/rcode"
+	tagName  = coretrace.TagName
+	tagType  = coretrace.TagType
+	tagRcode = coretrace.TagRcode
 )
 
 type trace struct {
@@ -37,9 +35,12 @@ type trace struct {
 	serviceEndpoint string
 	serviceName     string
 	clientServer    bool
+	trustParent     bool
 	every           uint64
 	count           uint64
 	Once            sync.Once
+
+	recorder *otlpRecorder // only set for the otlp endpoint type
 }
 
 func (t *trace) Tracer() ot.Tracer {
@@ -53,6 +54,8 @@ func (t *trace) OnStartup() error {
 		switch t.EndpointType {
 		case "zipkin":
 			err = t.setupZipkin()
+		case "otlp":
+			err = t.setupOTLP()
 		case "datadog":
 			tracer := opentracer.New(tracer.WithAgentAddr(t.Endpoint), tracer.WithServiceName(t.serviceName), tracer.WithDebugMode(true))
 			t.tracer = tracer
@@ -76,6 +79,27 @@ func (t *trace) setupZipkin() error {
 	return err
 }
 
+func (t *trace) setupOTLP() error {
+	var err error
+	t.recorder, err = newOTLPRecorder(t.Endpoint, t.serviceName)
+	if err != nil {
+		return err
+	}
+	t.recorder.start()
+
+	// W3C trace context requires 128 bit trace IDs.
+	t.tracer, err = zipkin.NewTracer(t.recorder, zipkin.ClientServerSameSpan(t.clientServer), zipkin.TraceID128Bit(true))
+	return err
+}
+
+// OnShutdown flushes any spans that are not yet exported.
+func (t *trace) OnShutdown() error {
+	if t.recorder != nil {
+		t.recorder.stop()
+	}
+	return nil
+}
+
 // Name implements the Handler interface.
 func (t *trace) Name() string { return "trace" }
 
@@ -89,22 +113,34 @@ func (t *trace) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 			trace = true
 		}
 	}
+	// We only join the trace of a client that samples it. Only when we trust the client, it decides
+	// itself if it wants this query traced, otherwise every applies.
+	parent := coretrace.RemoteParent(ctx)
+	if parent != nil && !coretrace.Sampled(t.Tracer(), parent) {
+		parent = nil
+	}
+	if parent != nil && t.trustParent {
+		trace = true
+	}
 	span := ot.SpanFromContext(ctx)
 	if !trace || span != nil {
 		return plugin.NextOrFailure(t.Name(), t.Next, ctx, w, r)
 	}
 
 	req := request.Request{W: w, Req: r}
-	span = t.Tracer().StartSpan(spanName(ctx, req))
+	opts := []ot.StartSpanOption{ext.SpanKindRPCServer}
+	if parent != nil {
+		opts = append(opts, ot.ChildOf(parent))
+	}
+	span = t.Tracer().StartSpan(spanName(ctx, req), opts...)
 	defer span.Finish()
 
 	rw := dnstest.NewRecorder(w)
 	ctx = ot.ContextWithSpan(ctx, span)
 	status, err := plugin.NextOrFailure(t.Name(), t.Next, ctx, rw, r)
 
-	span.SetTag(tagName, req.Name())
-	span.SetTag(tagType, req.Type())
-	span.SetTag(tagRcode, rcode.ToString(rw.Rcode))
+	coretrace.SetRequestTags(span, req)
+	coretrace.SetRcodeTag(span, rw.Rcode)
 
 	return status, err
 }
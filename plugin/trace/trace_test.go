@@ -6,12 +6,14 @@ import (
 
 	"github.com/coredns/coredns/plugin/pkg/dnstest"
 	"github.com/coredns/coredns/plugin/pkg/rcode"
+	coretrace "github.com/coredns/coredns/plugin/pkg/trace"
 	"github.com/coredns/coredns/plugin/test"
 	"github.com/coredns/coredns/request"
 
 	"github.com/mholt/caddy"
 	"github.com/miekg/dns"
 	"github.com/opentracing/opentracing-go/mocktracer"
+	zipkin "github.com/openzipkin/zipkin-go-opentracing"
 )
 
 const server = "coolServer"
@@ -96,3 +98,61 @@ func TestTrace(t *testing.T) {
 		})
 	}
 }
+
+func TestTraceRemoteParent(t *testing.T) {
+	const (
+		traceID   = "4bf92f3577b34da6a3ce929d0e0e4736"
+		sampled   = "00-" + traceID + "-00f067aa0ba902b7-01"
+		unsampled = "00-" + traceID + "-00f067aa0ba902b7-00"
+	)
+	cases := []struct {
+		traceparent string
+		every       uint64
+		trust       bool
+		spans       int
+		child       bool
+	}{
+		{sampled, 0, false, 0, false},
+		{sampled, 0, true, 2, true},
+		{sampled, 1, false, 2, true},
+		{unsampled, 0, true, 0, false},
+		{unsampled, 1, true, 2, false},
+	}
+
+	for i, tc := range cases {
+		recorder := zipkin.NewInMemoryRecorder()
+		tracer, err := zipkin.NewTracer(recorder, zipkin.TraceID128Bit(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr := &trace{
+			Next:        test.NextHandler(dns.RcodeSuccess, nil),
+			every:       tc.every,
+			trustParent: tc.trust,
+			tracer:      tracer,
+		}
+		parent, err := coretrace.Extract(tracer, tc.traceparent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := coretrace.WithRemoteParent(context.TODO(), parent)
+
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := tr.ServeDNS(ctx, w, new(dns.Msg).SetQuestion("example.org.", dns.TypeA)); err != nil {
+			t.Fatalf("Test %d: unexpected error: %s", i, err)
+		}
+
+		// A traced query has two spans; the root and the Next function.
+		spans := recorder.GetSpans()
+		if len(spans) != tc.spans {
+			t.Errorf("Test %d: expected %d spans, got %d", i, tc.spans, len(spans))
+			continue
+		}
+		if len(spans) == 0 {
+			continue
+		}
+		if child := spans[len(spans)-1].Context.TraceID.ToHex() == traceID; child != tc.child {
+			t.Errorf("Test %d: expected child of the remote parent to be %t, got %t", i, tc.child, child)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package tsig
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+)
+
+var log = clog.NewWithPlugin("tsig")
+
+func init() {
+	caddy.RegisterPlugin("tsig", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	t, err := parse(c)
+	if err != nil {
+		return plugin.Error("tsig", err)
+	}
+
+	config := dnsserver.GetConfig(c)
+	config.TsigSecret = t.secrets
+
+	config.AddPlugin(func(next plugin.Handler) plugin.Handler {
+		t.Next = next
+		return t
+	})
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (*TSIGServer, error) {
+	t := &TSIGServer{secrets: make(map[string]string), types: make(map[uint16]bool)}
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		t.Zones = make([]string, len(c.ServerBlockKeys))
+		copy(t.Zones, c.ServerBlockKeys)
+		if args := c.RemainingArgs(); len(args) > 0 {
+			t.Zones = args
+		}
+		for j := range t.Zones {
+			t.Zones[j] = plugin.Host(t.Zones[j]).Normalize()
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "secret":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return nil, c.ArgErr()
+				}
+				if _, ok := dns.IsDomainName(args[0]); !ok {
+					return nil, fmt.Errorf("invalid key name %q", args[0])
+				}
+				if _, err := base64.StdEncoding.DecodeString(args[1]); err != nil {
+					return nil, fmt.Errorf("invalid secret for key %q: %s", args[0], err)
+				}
+				name := strings.ToLower(dns.Fqdn(args[0]))
+				if _, ok := t.secrets[name]; ok {
+					return nil, fmt.Errorf("key %q is defined more than once", name)
+				}
+				t.secrets[name] = args[1]
+			case "require":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, arg := range args {
+					switch strings.ToLower(arg) {
+					case "all":
+						t.all = true
+					case "none":
+						t.all = false
+						t.types = make(map[uint16]bool)
+					default:
+						qtype, ok := dns.StringToType[strings.ToUpper(arg)]
+						if !ok {
+							return nil, fmt.Errorf("unknown query type %q", arg)
+						}
+						t.types[qtype] = true
+					}
+				}
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	if len(t.secrets) == 0 {
+		return nil, fmt.Errorf("at least one secret is required")
+	}
+	return t, nil
+}
@@ -0,0 +1,96 @@
+package tsig
+
+import (
+	"testing"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+)
+
+func TestSetupParse(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		secrets   int
+		all       bool
+		types     []uint16
+	}{
+		{`tsig {
+			secret Transfer.Key c2VjcmV0
+		}`, false, 1, false, nil},
+		{`tsig example.org {
+			secret key1. c2VjcmV0
+			secret key2. c2VjcmV0
+			require AXFR ixfr
+		}`, false, 2, false, []uint16{dns.TypeAXFR, dns.TypeIXFR}},
+		{`tsig {
+			secret key. c2VjcmV0
+			require all
+		}`, false, 1, true, nil},
+		{`tsig {
+			secret key. c2VjcmV0
+			require AXFR
+			require none
+		}`, false, 1, false, nil},
+		// fails
+		{`tsig`, true, 0, false, nil},
+		{`tsig {
+			secret key.
+		}`, true, 0, false, nil},
+		{`tsig {
+			secret key. !secret
+		}`, true, 0, false, nil},
+		{`tsig {
+			secret key. c2VjcmV0
+			secret KEY c2VjcmV0
+		}`, true, 0, false, nil},
+		{`tsig {
+			secret key. c2VjcmV0
+			require
+		}`, true, 0, false, nil},
+		{`tsig {
+			secret key. c2VjcmV0
+			require FOO
+		}`, true, 0, false, nil},
+		{`tsig {
+			secret key. c2VjcmV0
+			bogus
+		}`, true, 0, false, nil},
+		{`tsig {
+			secret key. c2VjcmV0
+		}
+		tsig {
+			secret key. c2VjcmV0
+		}`, true, 0, false, nil},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		c.ServerBlockKeys = []string{"."}
+		ts, err := parse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if len(ts.secrets) != tc.secrets {
+			t.Errorf("Test %d: expected %d secrets, got %d", i, tc.secrets, len(ts.secrets))
+		}
+		if ts.all != tc.all {
+			t.Errorf("Test %d: expected all to be %t", i, tc.all)
+		}
+		if len(ts.types) != len(tc.types) {
+			t.Errorf("Test %d: expected %d required types, got %d", i, len(tc.types), len(ts.types))
+		}
+		for _, qtype := range tc.types {
+			if !ts.types[qtype] {
+				t.Errorf("Test %d: expected %s to be required", i, dns.TypeToString[qtype])
+			}
+		}
+	}
+}
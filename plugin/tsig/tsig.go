@@ -0,0 +1,71 @@
+// Package tsig implements a plugin that declares TSIG keys and requires signed queries.
+package tsig
+
+import (
+	"context"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// TSIGServer requires queries to be signed with one of its keys. Verifying the signatures and
+// signing the replies is done by the server, with the keys of all server blocks on a listener.
+type TSIGServer struct {
+	Next  plugin.Handler
+	Zones []string
+
+	secrets map[string]string // key name to base64 encoded secret
+	all     bool              // all query types must be signed
+	types   map[uint16]bool   // query types that must be signed
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (t *TSIGServer) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	if plugin.Zones(t.Zones).Matches(state.Name()) == "" {
+		return plugin.NextOrFailure(t.Name(), t.Next, ctx, w, r)
+	}
+
+	if t.required(state.QType()) && t.key(state) == "" {
+		log.Debugf("Refusing unsigned %s query for %s from %s", state.Type(), state.Name(), state.IP())
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeRefused)
+		w.WriteMsg(m)
+		return dns.RcodeRefused, nil
+	}
+
+	return plugin.NextOrFailure(t.Name(), t.Next, ctx, w, r)
+}
+
+// Name implements the plugin.Handler interface.
+func (t *TSIGServer) Name() string { return "tsig" }
+
+// Metadata implements the metadata.Provider interface.
+func (t *TSIGServer) Metadata(ctx context.Context, state request.Request) context.Context {
+	if key := t.key(state); key != "" {
+		metadata.SetValueFunc(ctx, "tsig/key", func() string { return key })
+	}
+	return ctx
+}
+
+// required returns true if queries of type qtype must be signed.
+func (t *TSIGServer) required(qtype uint16) bool { return t.all || t.types[qtype] }
+
+// key returns the name of the key the query was signed with, or the empty string if it isn't
+// signed with one of our keys, or the signature wasn't valid.
+func (t *TSIGServer) key(state request.Request) string {
+	tsig := state.Req.IsTsig()
+	if tsig == nil {
+		return ""
+	}
+	if _, ok := t.secrets[tsig.Hdr.Name]; !ok {
+		return ""
+	}
+	if state.W.TsigStatus() != nil {
+		return ""
+	}
+	return tsig.Hdr.Name
+}
@@ -0,0 +1,89 @@
+package tsig
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// badSigWriter is a ResponseWriter for a request whose signature didn't verify.
+type badSigWriter struct{ test.ResponseWriter }
+
+func (w *badSigWriter) TsigStatus() error { return dns.ErrSig }
+
+func TestTSIGServer(t *testing.T) {
+	ts := &TSIGServer{
+		Next:    test.NextHandler(dns.RcodeSuccess, nil),
+		Zones:   []string{"example.org."},
+		secrets: map[string]string{"key.": "c2VjcmV0"},
+		types:   map[uint16]bool{dns.TypeAXFR: true},
+	}
+
+	tests := []struct {
+		qname  string
+		qtype  uint16
+		key    string
+		w      dns.ResponseWriter
+		refuse bool
+	}{
+		{"example.org.", dns.TypeA, "", &test.ResponseWriter{}, false},
+		{"example.org.", dns.TypeAXFR, "", &test.ResponseWriter{}, true},
+		{"example.org.", dns.TypeAXFR, "key.", &test.ResponseWriter{}, false},
+		{"example.org.", dns.TypeAXFR, "other.", &test.ResponseWriter{}, true},
+		{"example.org.", dns.TypeAXFR, "key.", &badSigWriter{}, true},
+		{"example.net.", dns.TypeAXFR, "", &test.ResponseWriter{}, false},
+	}
+
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, tc.qtype)
+		if tc.key != "" {
+			m.SetTsig(tc.key, dns.HmacSHA256, 300, time.Now().Unix())
+		}
+		rec := dnstest.NewRecorder(tc.w)
+		rcode, _ := ts.ServeDNS(context.TODO(), rec, m)
+		if refused := rcode == dns.RcodeRefused; refused != tc.refuse {
+			t.Errorf("Test %d: expected refused to be %t, got rcode %d", i, tc.refuse, rcode)
+		}
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	ts := &TSIGServer{secrets: map[string]string{"key.": "c2VjcmV0"}}
+
+	tests := []struct {
+		key      string
+		w        dns.ResponseWriter
+		expected string
+	}{
+		{"key.", &test.ResponseWriter{}, "key."},
+		{"", &test.ResponseWriter{}, ""},
+		{"other.", &test.ResponseWriter{}, ""},
+		{"key.", &badSigWriter{}, ""},
+	}
+
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		if tc.key != "" {
+			m.SetTsig(tc.key, dns.HmacSHA256, 300, time.Now().Unix())
+		}
+		ctx := metadata.ContextWithMetadata(context.TODO())
+		ctx = ts.Metadata(ctx, request.Request{W: tc.w, Req: m})
+
+		value := ""
+		if f := metadata.ValueFunc(ctx, "tsig/key"); f != nil {
+			value = f()
+		}
+		if value != tc.expected {
+			t.Errorf("Test %d: expected tsig/key %q, got %q", i, tc.expected, value)
+		}
+	}
+}
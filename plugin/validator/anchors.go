@@ -0,0 +1,88 @@
+package validator
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// rootAnchors are the DS records of the root key signing keys, from
+// https://data.iana.org/root-anchors/root-anchors.xml.
+const rootAnchors = `. IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D
+. IN DS 38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16
+`
+
+// anchor holds the trust anchors of a zone, as DS or DNSKEY records.
+type anchor struct {
+	zone string
+	ds   []*dns.DS
+	keys []*dns.DNSKEY
+}
+
+// anchors holds the trust anchors by zone.
+type anchors map[string]*anchor
+
+// add adds rr, which must be a DS or DNSKEY record, as trust anchor.
+func (as anchors) add(rr dns.RR) error {
+	zone := strings.ToLower(rr.Header().Name)
+	a, ok := as[zone]
+	if !ok {
+		a = &anchor{zone: zone}
+		as[zone] = a
+	}
+	switch x := rr.(type) {
+	case *dns.DS:
+		a.ds = append(a.ds, x)
+	case *dns.DNSKEY:
+		a.keys = append(a.keys, x)
+	default:
+		return fmt.Errorf("trust anchor must be a DS or DNSKEY record: %s", rr)
+	}
+	return nil
+}
+
+// parse adds the DS and DNSKEY records in r as trust anchors.
+func (as anchors) parse(r io.Reader, file string) error {
+	zp := dns.NewZoneParser(r, ".", file)
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		if err := as.add(rr); err != nil {
+			return err
+		}
+	}
+	return zp.Err()
+}
+
+// closest returns the anchor of the closest zone that encloses name, or nil.
+func (as anchors) closest(name string) *anchor {
+	for off, end := 0, false; !end; off, end = dns.NextLabel(name, off) {
+		if a, ok := as[name[off:]]; ok {
+			return a
+		}
+	}
+	return as["."]
+}
+
+// match returns true if key is one of the anchors.
+func (a *anchor) match(key *dns.DNSKEY) bool {
+	for _, k := range a.keys {
+		if k.Flags == key.Flags && k.Algorithm == key.Algorithm && k.PublicKey == key.PublicKey {
+			return true
+		}
+	}
+	return matchDS(a.ds, key)
+}
+
+// matchDS returns true if one of ds is the digest of key.
+func matchDS(ds []*dns.DS, key *dns.DNSKEY) bool {
+	for _, d := range ds {
+		if d.KeyTag != key.KeyTag() || d.Algorithm != key.Algorithm {
+			continue
+		}
+		if x := key.ToDS(d.DigestType); x != nil && strings.EqualFold(x.Digest, d.Digest) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,252 @@
+package validator
+
+import (
+	"strings"
+
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+
+	"github.com/miekg/dns"
+)
+
+// covers returns true if nsec proves that name doesn't exist: name sorts between its owner and
+// next name. An NSEC of a delegation or a DNAME doesn't prove anything about the names below it.
+func covers(nsec *dns.NSEC, name string) bool {
+	owner := nsec.Hdr.Name
+	if dns.IsSubDomain(owner, name) && !strings.EqualFold(owner, name) &&
+		(dnsutil.IsDelegation(nsec.TypeBitMap) || dnsutil.HasType(nsec.TypeBitMap, dns.TypeDNAME)) {
+		return false
+	}
+	if dnsutil.Compare(owner, name) >= 0 {
+		return false
+	}
+	if dnsutil.Compare(owner, nsec.NextDomain) >= 0 {
+		// Last NSEC of the zone, the next name is the apex.
+		return dns.IsSubDomain(nsec.NextDomain, name)
+	}
+	return dnsutil.Compare(name, nsec.NextDomain) < 0
+}
+
+// matches returns the NSEC with owner name, or nil.
+func matches(nsecs []*dns.NSEC, name string) *dns.NSEC {
+	for _, n := range nsecs {
+		if strings.EqualFold(n.Hdr.Name, name) {
+			return n
+		}
+	}
+	return nil
+}
+
+// covered returns the NSEC that covers name, or nil.
+func covered(nsecs []*dns.NSEC, name string) *dns.NSEC {
+	for _, n := range nsecs {
+		if covers(n, name) {
+			return n
+		}
+	}
+	return nil
+}
+
+// closestEncloser returns the closest existing ancestor of name that the NSEC, which covers
+// name, proves: the longest common ancestor of name with the owner and the next name of nsec.
+func closestEncloser(nsec *dns.NSEC, name string) string {
+	ce := commonAncestor(name, nsec.Hdr.Name)
+	if n := commonAncestor(name, nsec.NextDomain); dns.CountLabel(n) > dns.CountLabel(ce) {
+		ce = n
+	}
+	return ce
+}
+
+func commonAncestor(a, b string) string {
+	n := dns.CompareDomainName(a, b)
+	idx := dns.Split(a)
+	if n >= len(idx) {
+		return strings.ToLower(a)
+	}
+	if n == 0 {
+		return "."
+	}
+	return strings.ToLower(a[idx[len(idx)-n]:])
+}
+
+// wildcard returns the wildcard name directly below ce.
+func wildcard(ce string) string {
+	if ce == "." {
+		return "*."
+	}
+	return "*." + ce
+}
+
+// nextCloser returns the name one label longer than ce, on the way to name.
+func nextCloser(name, ce string) string {
+	idx := dns.Split(name)
+	n := dns.CountLabel(ce) + 1
+	if n > len(idx) {
+		return name
+	}
+	return strings.ToLower(name[idx[len(idx)-n]:])
+}
+
+// nsec3Match returns the NSEC3 that matches name, or nil.
+func nsec3Match(nsec3s []*dns.NSEC3, name string) *dns.NSEC3 {
+	for _, n := range nsec3s {
+		if n.Match(name) {
+			return n
+		}
+	}
+	return nil
+}
+
+// nsec3Cover returns the NSEC3 that covers name, or nil.
+func nsec3Cover(nsec3s []*dns.NSEC3, name string) *dns.NSEC3 {
+	for _, n := range nsec3s {
+		if n.Cover(name) {
+			return n
+		}
+	}
+	return nil
+}
+
+// nsec3ClosestEncloser does the closest encloser proof of RFC 5155, section 8.3: it returns the
+// closest encloser of name, and the NSEC3 covering the next closer name, or nil if there is no proof.
+func nsec3ClosestEncloser(nsec3s []*dns.NSEC3, name string) (string, *dns.NSEC3) {
+	ce := strings.ToLower(name)
+	for {
+		if nsec3Match(nsec3s, ce) != nil {
+			if ce == strings.ToLower(name) {
+				return "", nil
+			}
+			if n := nsec3Cover(nsec3s, nextCloser(name, ce)); n != nil {
+				return ce, n
+			}
+			return "", nil
+		}
+		p, ok := parent(ce)
+		if !ok {
+			return "", nil
+		}
+		ce = p
+	}
+}
+
+// parent returns the name one label up from name, or false for the root.
+func parent(name string) (string, bool) {
+	if name == "." {
+		return "", false
+	}
+	off, end := dns.NextLabel(name, 0)
+	if end {
+		return ".", true
+	}
+	return name[off:], true
+}
+
+// optOut returns true if the opt-out flag of nsec3 is set.
+func optOut(nsec3 *dns.NSEC3) bool { return nsec3.Flags&1 == 1 }
+
+// denial holds the verified NSEC and NSEC3 records of a response.
+type denial struct {
+	nsec  []*dns.NSEC
+	nsec3 []*dns.NSEC3
+}
+
+// tooManyIterations returns true if the NSEC3 records use more iterations than we accept.
+func (d denial) tooManyIterations() bool {
+	for _, n := range d.nsec3 {
+		if n.Iterations > dnsutil.MaxIterations {
+			return true
+		}
+	}
+	return false
+}
+
+// nxdomain returns true if d proves that name doesn't exist, and there is no wildcard for it.
+func (d denial) nxdomain(name string) bool {
+	if n := covered(d.nsec, name); n != nil {
+		ce := closestEncloser(n, name)
+		w := wildcard(ce)
+		return covered(d.nsec, w) != nil
+	}
+	if ce, _ := nsec3ClosestEncloser(d.nsec3, name); ce != "" {
+		return nsec3Cover(d.nsec3, wildcard(ce)) != nil
+	}
+	return false
+}
+
+// nodata returns true if d proves that name has no records of type qtype, nor a CNAME.
+func (d denial) nodata(name string, qtype uint16) bool {
+	without := func(bitmap []uint16) bool {
+		return !dnsutil.HasType(bitmap, qtype) && !dnsutil.HasType(bitmap, dns.TypeCNAME)
+	}
+	if n := matches(d.nsec, name); n != nil {
+		return without(n.TypeBitMap)
+	}
+	if n := nsec3Match(d.nsec3, name); n != nil {
+		return without(n.TypeBitMap)
+	}
+	// Wildcard NODATA: name doesn't exist, the wildcard does but without qtype.
+	if n := covered(d.nsec, name); n != nil {
+		if w := matches(d.nsec, wildcard(closestEncloser(n, name))); w != nil {
+			return without(w.TypeBitMap)
+		}
+	}
+	if ce, n := nsec3ClosestEncloser(d.nsec3, name); ce != "" {
+		if w := nsec3Match(d.nsec3, wildcard(ce)); w != nil {
+			return without(w.TypeBitMap)
+		}
+		// A DS for a name in an opt-out span may be an unsigned delegation.
+		return qtype == dns.TypeDS && optOut(n)
+	}
+	return false
+}
+
+// expanded returns true if d proves that name doesn't exist, for an answer synthesized from a
+// wildcard below ce.
+func (d denial) expanded(name, ce string) bool {
+	if covered(d.nsec, name) != nil {
+		return true
+	}
+	return nsec3Cover(d.nsec3, nextCloser(name, ce)) != nil
+}
+
+// Results of the proof that zone is, or isn't, a delegation without DS records.
+const (
+	proofNone     = iota // nothing proven
+	proofInsecure        // a delegation without DS records
+	proofNoCut           // not a delegation, zone is part of the zone of the proof
+)
+
+// delegation returns what d proves about the DS records of zone.
+func (d denial) delegation(zone string) int {
+	if n := matches(d.nsec, zone); n != nil {
+		switch {
+		case dnsutil.HasType(n.TypeBitMap, dns.TypeDS):
+			return proofNone
+		case dnsutil.IsDelegation(n.TypeBitMap):
+			return proofInsecure
+		case dnsutil.HasType(n.TypeBitMap, dns.TypeSOA):
+			return proofNone
+		}
+		return proofNoCut
+	}
+	if covered(d.nsec, zone) != nil {
+		return proofNoCut
+	}
+	if n := nsec3Match(d.nsec3, zone); n != nil {
+		switch {
+		case dnsutil.HasType(n.TypeBitMap, dns.TypeDS):
+			return proofNone
+		case dnsutil.IsDelegation(n.TypeBitMap):
+			return proofInsecure
+		case dnsutil.HasType(n.TypeBitMap, dns.TypeSOA):
+			return proofNone
+		}
+		return proofNoCut
+	}
+	if ce, n := nsec3ClosestEncloser(d.nsec3, zone); ce != "" {
+		if optOut(n) {
+			return proofInsecure
+		}
+		return proofNoCut
+	}
+	return proofNone
+}
@@ -0,0 +1,45 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestCovers(t *testing.T) {
+	tests := []struct {
+		nsec   dns.RR
+		name   string
+		covers bool
+	}{
+		{nsec("a.example.org.", "c.example.org.", dns.TypeA), "b.example.org.", true},
+		{nsec("a.example.org.", "c.example.org.", dns.TypeA), "x.a.example.org.", true},
+		{nsec("a.example.org.", "c.example.org.", dns.TypeA), "a.example.org.", false},
+		{nsec("a.example.org.", "c.example.org.", dns.TypeA), "c.example.org.", false},
+		{nsec("a.example.org.", "c.example.org.", dns.TypeA), "d.example.org.", false},
+		// last NSEC of the zone
+		{nsec("z.example.org.", "example.org.", dns.TypeA), "zz.example.org.", true},
+		{nsec("z.example.org.", "example.org.", dns.TypeA), "example.com.", false},
+		// delegation
+		{nsec("a.example.org.", "c.example.org.", dns.TypeNS), "x.a.example.org.", false},
+		{nsec("a.example.org.", "c.example.org.", dns.TypeNS), "b.example.org.", true},
+	}
+	for i, tc := range tests {
+		if got := covers(tc.nsec.(*dns.NSEC), tc.name); got != tc.covers {
+			t.Errorf("Test %d: expected covers %t for %s, got %t", i, tc.covers, tc.name, got)
+		}
+	}
+}
+
+func TestClosestEncloser(t *testing.T) {
+	n := nsec("a.example.org.", "b.c.example.org.", dns.TypeA).(*dns.NSEC)
+	if ce := closestEncloser(n, "x.b.example.org."); ce != "example.org." {
+		t.Errorf("Expected closest encloser example.org., got %s", ce)
+	}
+	if ce := closestEncloser(n, "a.c.example.org."); ce != "c.example.org." {
+		t.Errorf("Expected closest encloser c.example.org., got %s", ce)
+	}
+	if nc := nextCloser("a.b.c.example.org.", "example.org."); nc != "c.example.org." {
+		t.Errorf("Expected next closer c.example.org., got %s", nc)
+	}
+}
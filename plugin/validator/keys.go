@@ -0,0 +1,316 @@
+package validator
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/nonwriter"
+
+	"github.com/miekg/dns"
+)
+
+// Security status of data, see RFC 4035, section 4.3.
+const (
+	statusSecure = iota
+	statusInsecure
+	statusBogus
+)
+
+var statusToString = map[int]string{statusSecure: "secure", statusInsecure: "insecure", statusBogus: "bogus"}
+
+// keyEntry is the result of building the chain of trust to a name: the validated keys of the zone
+// it is in, or the reason it is insecure or bogus.
+type keyEntry struct {
+	zone   string // the zone the keys are of
+	status int
+	keys   []*dns.DNSKEY
+	reason string
+	expire time.Time
+}
+
+// keyCache caches the key entries by zone cut, and by name for names that are proven not to be a
+// zone cut. When full, expired entries are removed, and if that
+// isn't enough, arbitrary ones.
+type keyCache struct {
+	sync.RWMutex
+	m    map[string]*keyEntry
+	size int
+}
+
+func newKeyCache(size int) *keyCache { return &keyCache{m: map[string]*keyEntry{}, size: size} }
+
+func (c *keyCache) get(name string, now time.Time) *keyEntry {
+	c.RLock()
+	defer c.RUnlock()
+	if e, ok := c.m[name]; ok && now.Before(e.expire) {
+		return e
+	}
+	return nil
+}
+
+func (c *keyCache) add(name string, e *keyEntry) {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.m[name]; !ok && len(c.m) >= c.size {
+		now := time.Now()
+		for n, x := range c.m {
+			if !now.Before(x.expire) || len(c.m) >= c.size {
+				delete(c.m, n)
+			}
+		}
+	}
+	c.m[name] = e
+}
+
+// TTLs of the key entries.
+const (
+	maxKeyTTL = 24 * time.Hour
+	bogusTTL  = time.Minute // retry building the chain of trust after this time
+	maxDepth  = 32          // names walked while building a chain of trust
+)
+
+func bogus(name, reason string, now time.Time) *keyEntry {
+	return &keyEntry{zone: name, status: statusBogus, reason: reason, expire: now.Add(bogusTTL)}
+}
+
+func insecure(name string, ttl time.Duration, now time.Time) *keyEntry {
+	return &keyEntry{zone: name, status: statusInsecure, expire: now.Add(ttl)}
+}
+
+// keysFor returns the key entry of the zone name is in, building the chain of trust from the
+// closest trust anchor: name is insecure if it isn't below an anchor, or below a delegation that
+// is proven to be without DS records. Otherwise it is secure if the DS records of each delegation
+// and the DNSKEY records they point to validate.
+func (v *Validator) keysFor(ctx context.Context, w dns.ResponseWriter, name string, depth int) *keyEntry {
+	name = strings.ToLower(dns.Fqdn(name))
+	now := time.Now()
+	if v.negative(name) {
+		return insecure(name, maxKeyTTL, now)
+	}
+	a := v.anchors.closest(name)
+	if a == nil {
+		return insecure(name, maxKeyTTL, now)
+	}
+	if e := v.cached(name, a.zone, now); e != nil {
+		return e
+	}
+	e := v.buildChain(ctx, w, name, a, depth, now)
+	if e.status == statusBogus {
+		log.Warningf("Failed to build chain of trust for %s: %s", name, e.reason)
+	}
+	v.keys.add(e.zone, e)
+	if e.status == statusSecure && e.zone != name {
+		// name is proven not to be a zone cut, remember that to skip the DS lookup next time.
+		v.keys.add(name, e)
+	}
+	return e
+}
+
+// cached returns the cached key entry of name, or the insecure entry of the closest cached zone
+// cut above it: everything below an insecure delegation is insecure. The walk up stops at the
+// trust anchor zone, an anchor deeper than an insecure delegation makes its zone secure again.
+func (v *Validator) cached(name, anchor string, now time.Time) *keyEntry {
+	for n := name; ; {
+		if e := v.keys.get(n, now); e != nil {
+			if n == name || e.status == statusInsecure {
+				return e
+			}
+			return nil
+		}
+		if n == anchor {
+			return nil
+		}
+		p, ok := parent(n)
+		if !ok {
+			return nil
+		}
+		n = p
+	}
+}
+
+func (v *Validator) buildChain(ctx context.Context, w dns.ResponseWriter, name string, a *anchor, depth int, now time.Time) *keyEntry {
+	if a.zone == name {
+		return v.anchorKeys(ctx, w, a, now)
+	}
+	if depth > maxDepth {
+		return bogus(name, "chain of trust too long", now)
+	}
+
+	m := v.lookup(ctx, w, name, dns.TypeDS)
+	if m == nil || (m.Rcode != dns.RcodeSuccess && m.Rcode != dns.RcodeNameError) {
+		return bogus(name, "no reply for DS", now)
+	}
+
+	ds, sigs := rrset(m.Answer, name, dns.TypeDS)
+	if len(ds) > 0 {
+		if len(sigs) == 0 {
+			return v.unsigned(ctx, w, name, depth, now)
+		}
+		signer := strings.ToLower(sigs[0].SignerName)
+		if signer == name || !dns.IsSubDomain(signer, name) {
+			return bogus(name, "DS signed by "+signer, now)
+		}
+		pe := v.keysFor(ctx, w, signer, depth+1)
+		if pe.status != statusSecure {
+			return pe
+		}
+		if !verify(ds, sigs, pe.keys, now) {
+			return bogus(name, "DS signature doesn't validate", now)
+		}
+		return v.zoneKeys(ctx, w, name, ds, now)
+	}
+
+	// No DS records, this must be proven by the zone above.
+	signer := ""
+	for _, rr := range m.Ns {
+		if s, ok := rr.(*dns.RRSIG); ok {
+			signer = strings.ToLower(s.SignerName)
+			break
+		}
+	}
+	if signer == "" || signer == name {
+		// Unsigned, or answered by the zone itself: we're in an insecure zone, or below one.
+		return v.unsigned(ctx, w, name, depth, now)
+	}
+	if !dns.IsSubDomain(signer, name) {
+		return bogus(name, "denial of DS signed by "+signer, now)
+	}
+	pe := v.keysFor(ctx, w, signer, depth+1)
+	if pe.status != statusSecure {
+		return pe
+	}
+	d, ok := verifyDenial(m.Ns, pe.keys, now)
+	if !ok {
+		return bogus(name, "denial of DS doesn't validate", now)
+	}
+	if d.tooManyIterations() {
+		return insecure(name, ttl(m.Ns, now), now)
+	}
+	switch d.delegation(name) {
+	case proofInsecure:
+		return insecure(name, ttl(m.Ns, now), now)
+	case proofNoCut:
+		return pe
+	}
+	return bogus(name, "no proof of missing DS", now)
+}
+
+// unsigned returns the key entry for name when the zone above didn't sign its reply for the DS
+// records: name must be in or below an insecure zone, which the name one label up will tell.
+func (v *Validator) unsigned(ctx context.Context, w dns.ResponseWriter, name string, depth int, now time.Time) *keyEntry {
+	p, ok := parent(name)
+	if !ok {
+		return bogus(name, "unsigned DS reply", now)
+	}
+	pe := v.keysFor(ctx, w, p, depth+1)
+	if pe.status == statusSecure {
+		return bogus(name, "unsigned DS reply", now)
+	}
+	return pe
+}
+
+// anchorKeys validates the DNSKEY records of the zone of a with the trust anchors.
+func (v *Validator) anchorKeys(ctx context.Context, w dns.ResponseWriter, a *anchor, now time.Time) *keyEntry {
+	keys, sigs, m := v.dnskeys(ctx, w, a.zone)
+	if m == nil {
+		return bogus(a.zone, "no reply for DNSKEY", now)
+	}
+	for _, k := range keys {
+		if a.match(k) && verify(keysToRRs(keys), sigs, []*dns.DNSKEY{k}, now) {
+			return secure(a.zone, keys, m, now)
+		}
+	}
+	return bogus(a.zone, "DNSKEY doesn't validate with the trust anchors", now)
+}
+
+// zoneKeys validates the DNSKEY records of zone with its DS records.
+func (v *Validator) zoneKeys(ctx context.Context, w dns.ResponseWriter, zone string, ds []dns.RR, now time.Time) *keyEntry {
+	supported := []*dns.DS{}
+	for _, rr := range ds {
+		d := rr.(*dns.DS)
+		if supportedAlgorithm(d.Algorithm) && supportedDigest(d.DigestType) {
+			supported = append(supported, d)
+		}
+	}
+	if len(supported) == 0 {
+		// RFC 4035, section 5.2: treat as insecure.
+		return insecure(zone, ttl(ds, now), now)
+	}
+
+	keys, sigs, m := v.dnskeys(ctx, w, zone)
+	if m == nil {
+		return bogus(zone, "no reply for DNSKEY", now)
+	}
+	for _, k := range keys {
+		if matchDS(supported, k) && verify(keysToRRs(keys), sigs, []*dns.DNSKEY{k}, now) {
+			e := secure(zone, keys, m, now)
+			if t := now.Add(ttl(ds, now)); t.Before(e.expire) {
+				e.expire = t
+			}
+			return e
+		}
+	}
+	return bogus(zone, "DNSKEY doesn't validate with the DS records", now)
+}
+
+func secure(zone string, keys []*dns.DNSKEY, m *dns.Msg, now time.Time) *keyEntry {
+	return &keyEntry{zone: zone, status: statusSecure, keys: keys, expire: now.Add(ttl(m.Answer, now))}
+}
+
+// dnskeys looks up the DNSKEY records of zone.
+func (v *Validator) dnskeys(ctx context.Context, w dns.ResponseWriter, zone string) ([]*dns.DNSKEY, []*dns.RRSIG, *dns.Msg) {
+	m := v.lookup(ctx, w, zone, dns.TypeDNSKEY)
+	if m == nil || m.Rcode != dns.RcodeSuccess {
+		return nil, nil, nil
+	}
+	rrs, sigs := rrset(m.Answer, zone, dns.TypeDNSKEY)
+	keys := make([]*dns.DNSKEY, len(rrs))
+	for i := range rrs {
+		keys[i] = rrs[i].(*dns.DNSKEY)
+	}
+	return keys, sigs, m
+}
+
+// lookup queries the next plugin for name and qtype, with the DO and CD bits set.
+func (v *Validator) lookup(ctx context.Context, w dns.ResponseWriter, name string, qtype uint16) *dns.Msg {
+	req := new(dns.Msg)
+	req.SetQuestion(name, qtype)
+	req.CheckingDisabled = true
+	req.SetEdns0(4096, true)
+
+	nw := nonwriter.New(w)
+	if _, err := plugin.NextOrFailure(v.Name(), v.Next, ctx, nw, req); err != nil {
+		log.Debugf("Failed to look up %s %s: %s", name, dns.TypeToString[qtype], err)
+	}
+	return nw.Msg
+}
+
+// ttl returns the lowest TTL in rrs, capped by maxKeyTTL and the expiration of signatures.
+func ttl(rrs []dns.RR, now time.Time) time.Duration {
+	min := maxKeyTTL
+	for _, rr := range rrs {
+		if d := time.Duration(rr.Header().Ttl) * time.Second; d < min {
+			min = d
+		}
+		if s, ok := rr.(*dns.RRSIG); ok {
+			if d := time.Unix(int64(s.Expiration), 0).Sub(now); d < min {
+				min = d
+			}
+		}
+	}
+	if min < 0 {
+		min = 0
+	}
+	return min
+}
+
+func keysToRRs(keys []*dns.DNSKEY) []dns.RR {
+	rrs := make([]dns.RR, len(keys))
+	for i := range keys {
+		rrs[i] = keys[i]
+	}
+	return rrs
+}
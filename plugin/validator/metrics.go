@@ -0,0 +1,15 @@
+package validator
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ResponseCount is the number of validated replies, by security status.
+var ResponseCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: "validator",
+	Name:      "responses_total",
+	Help:      "Counter of validated replies by security status.",
+}, []string{"server", "result"})
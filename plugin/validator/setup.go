@@ -0,0 +1,125 @@
+package validator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
+)
+
+var log = clog.NewWithPlugin("validator")
+
+func init() {
+	caddy.RegisterPlugin("validator", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+// defaultCacheSize is the number of names we keep the chain of trust for.
+const defaultCacheSize = 10000
+
+func setup(c *caddy.Controller) error {
+	v, err := parse(c)
+	if err != nil {
+		return plugin.Error("validator", err)
+	}
+
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, ResponseCount)
+		return nil
+	})
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		v.Next = next
+		return v
+	})
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (*Validator, error) {
+	config := dnsserver.GetConfig(c)
+	v := &Validator{anchors: anchors{}, keys: newKeyCache(defaultCacheSize)}
+	negative := []string{}
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		v.Zones = make([]string, len(c.ServerBlockKeys))
+		copy(v.Zones, c.ServerBlockKeys)
+		if args := c.RemainingArgs(); len(args) > 0 {
+			v.Zones = args
+		}
+		for j := range v.Zones {
+			v.Zones[j] = plugin.Host(v.Zones[j]).Normalize()
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "trust_anchor":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				rr, err := dns.NewRR(strings.Join(args, " "))
+				if err != nil {
+					return nil, err
+				}
+				if rr == nil {
+					return nil, c.ArgErr()
+				}
+				if err := v.anchors.add(rr); err != nil {
+					return nil, err
+				}
+			case "trust_anchor_file":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				fileName := args[0]
+				if !filepath.IsAbs(fileName) && config.Root != "" {
+					fileName = filepath.Join(config.Root, fileName)
+				}
+				f, err := os.Open(fileName)
+				if err != nil {
+					return nil, err
+				}
+				err = v.anchors.parse(f, fileName)
+				f.Close()
+				if err != nil {
+					return nil, err
+				}
+			case "negative_trust_anchor":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, a := range args {
+					negative = append(negative, plugin.Host(a).Normalize())
+				}
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+
+	if len(v.anchors) == 0 {
+		if err := v.anchors.parse(strings.NewReader(rootAnchors), "root anchors"); err != nil {
+			return nil, err
+		}
+	}
+	v.negative = negativeAnchors(negative)
+	return v, nil
+}
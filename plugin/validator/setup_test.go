@@ -0,0 +1,90 @@
+package validator
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/mholt/caddy"
+)
+
+const testAnchors = `example.org. IN DS 31589 8 2 CDE0D742D6998AA554A92D890F8184C698CFAC8A26FA59875A990C03E576343C
+example.org. IN DNSKEY 257 3 8 AwEAAbOFAxl+Lkt0UMglZizKEC1AxUu8zlj65KYatR5wBWMrh18TYzK/ig6Y1t5YTWCO68bynorpNu9fqNFALX7bVl9/gybA0v0EhF+dgXmoUfRX7ksMGgBvtfa2/Y9a3klXNLqkTszIQ4PEMVCjtryl19Be9/PkFeC9ITjgMRQsQhmB39eyMYnal+f3bUxKk4fq7cuEU0dbRpue4H/N6jPucXWOwiMAkTJhghqgy+o9FfIp+tR/emKao94/wpVXDcPf5B18j7xz2SvTTxiuqCzCMtsxnikZHcoh1j4g+Y1B8zIMIvrEM+pZGhh/Yuf4RwCBgaYCi9hpiMWVvS4WBzx0/lU=
+`
+
+func TestSetupParse(t *testing.T) {
+	file, err := ioutil.TempFile("", "anchors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString(testAnchors)
+	file.Close()
+
+	tests := []struct {
+		input     string
+		shouldErr bool
+		zones     []string
+		anchors   []string
+		negative  string
+	}{
+		{`validator`, false, []string{"."}, []string{"."}, ""},
+		{`validator example.org`, false, []string{"example.org."}, []string{"."}, ""},
+		{`validator {
+			trust_anchor example.org. IN DS 31589 8 2 CDE0D742D6998AA554A92D890F8184C698CFAC8A26FA59875A990C03E576343C
+			trust_anchor_file ` + file.Name() + `
+			negative_trust_anchor example.net broken.example.org
+		}`, false, []string{"."}, []string{"example.org."}, "www.broken.example.org."},
+		// fails
+		{`validator {
+			trust_anchor
+		}`, true, nil, nil, ""},
+		{`validator {
+			trust_anchor example.org. IN A 127.0.0.1
+		}`, true, nil, nil, ""},
+		{`validator {
+			trust_anchor_file /does/not/exist
+		}`, true, nil, nil, ""},
+		{`validator {
+			negative_trust_anchor
+		}`, true, nil, nil, ""},
+		{`validator {
+			blaat
+		}`, true, nil, nil, ""},
+		{`validator
+		validator`, true, nil, nil, ""},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		c.ServerBlockKeys = []string{"."}
+		v, err := parse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if len(v.Zones) != len(tc.zones) || v.Zones[0] != tc.zones[0] {
+			t.Errorf("Test %d: expected zones %v, got %v", i, tc.zones, v.Zones)
+		}
+		if len(v.anchors) != len(tc.anchors) {
+			t.Errorf("Test %d: expected anchors for %v, got %d", i, tc.anchors, len(v.anchors))
+		}
+		for _, a := range tc.anchors {
+			if _, ok := v.anchors[a]; !ok {
+				t.Errorf("Test %d: expected an anchor for %s", i, a)
+			}
+		}
+		if tc.negative != "" && !v.negative(tc.negative) {
+			t.Errorf("Test %d: expected %s to be below a negative trust anchor", i, tc.negative)
+		}
+		if v.negative("example.com.") {
+			t.Errorf("Test %d: expected example.com. not to be below a negative trust anchor", i)
+		}
+	}
+}
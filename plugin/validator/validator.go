@@ -0,0 +1,118 @@
+// Package validator implements a plugin that validates DNSSEC signed replies.
+package validator
+
+import (
+	"context"
+	"strings"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/plugin/pkg/nonwriter"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// Validator validates the replies of the next plugin with DNSSEC.
+type Validator struct {
+	Next  plugin.Handler
+	Zones []string
+
+	anchors  anchors
+	negative func(name string) bool // negative trust anchors
+	keys     *keyCache
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (v *Validator) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	if plugin.Zones(v.Zones).Matches(state.Name()) == "" || r.CheckingDisabled {
+		return plugin.NextOrFailure(v.Name(), v.Next, ctx, w, r)
+	}
+
+	do := state.Do()
+	req := r.Copy()
+	if o := req.IsEdns0(); o != nil {
+		o.SetDo()
+	} else {
+		req.SetEdns0(4096, true)
+	}
+
+	nw := nonwriter.New(w)
+	rcode, err := plugin.NextOrFailure(v.Name(), v.Next, ctx, nw, req)
+	m := nw.Msg
+	if m == nil {
+		return rcode, err
+	}
+
+	// Names below a negative trust anchor are treated as insecure.
+	status, reason := statusInsecure, ""
+	if (m.Rcode == dns.RcodeSuccess || m.Rcode == dns.RcodeNameError) && !v.negative(state.Name()) {
+		status, reason = v.validate(ctx, w, state.Name(), state.QType(), m)
+	}
+	ResponseCount.WithLabelValues(metrics.WithServer(ctx), statusToString[status]).Inc()
+
+	if status == statusBogus {
+		log.Infof("Bogus reply for %s %s: %s", state.Name(), state.Type(), reason)
+		reply := new(dns.Msg)
+		reply.SetRcode(r, dns.RcodeServerFailure)
+		reply.RecursionAvailable = m.RecursionAvailable
+		state.SizeAndDo(reply)
+		w.WriteMsg(reply)
+		return dns.RcodeServerFailure, nil
+	}
+
+	m.AuthenticatedData = status == statusSecure && (do || r.AuthenticatedData)
+	if !do {
+		m.Answer = strip(m.Answer, state.QType())
+		m.Ns = strip(m.Ns, state.QType())
+		m.Extra = strip(m.Extra, state.QType())
+		if r.IsEdns0() == nil {
+			m.Extra = stripOPT(m.Extra)
+		} else if o := m.IsEdns0(); o != nil {
+			o.Hdr.Ttl &^= 1 << 15 // clear the DO bit
+		}
+	}
+	w.WriteMsg(m)
+	return rcode, err
+}
+
+// Name implements the plugin.Handler interface.
+func (v *Validator) Name() string { return "validator" }
+
+// strip removes the DNSSEC records from rrs, except those of type qtype.
+func strip(rrs []dns.RR, qtype uint16) []dns.RR {
+	out := rrs[:0]
+	for _, rr := range rrs {
+		switch t := rr.Header().Rrtype; t {
+		case dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNSEC3:
+			if t != qtype {
+				continue
+			}
+		}
+		out = append(out, rr)
+	}
+	return out
+}
+
+func stripOPT(rrs []dns.RR) []dns.RR {
+	out := rrs[:0]
+	for _, rr := range rrs {
+		if rr.Header().Rrtype != dns.TypeOPT {
+			out = append(out, rr)
+		}
+	}
+	return out
+}
+
+// negativeAnchors returns a function that returns true for names in or below one of names.
+func negativeAnchors(names []string) func(string) bool {
+	return func(name string) bool {
+		for _, n := range names {
+			if dns.IsSubDomain(n, strings.ToLower(name)) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,369 @@
+package validator
+
+import (
+	"context"
+	"crypto"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+// signer signs the records of a zone with a single key.
+type signer struct {
+	zone string
+	key  *dns.DNSKEY
+	priv crypto.Signer
+}
+
+func newSigner(t *testing.T, zone string) *signer {
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: zone, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     257,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := key.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &signer{zone: zone, key: key, priv: priv.(crypto.Signer)}
+}
+
+// sign returns rrs with the signatures of each RRset.
+func (s *signer) sign(rrs ...dns.RR) []dns.RR {
+	out := append([]dns.RR{}, rrs...)
+	for _, st := range sets(rrs) {
+		sig := &dns.RRSIG{
+			Hdr:        dns.RR_Header{Ttl: 3600},
+			Algorithm:  s.key.Algorithm,
+			Expiration: uint32(time.Now().Add(time.Hour).Unix()),
+			Inception:  uint32(time.Now().Add(-time.Hour).Unix()),
+			KeyTag:     s.key.KeyTag(),
+			SignerName: s.zone,
+		}
+		if err := sig.Sign(s.priv, st.rrs); err != nil {
+			panic(err)
+		}
+		out = append(out, sig)
+	}
+	return out
+}
+
+func (s *signer) ds() *dns.DS { return s.key.ToDS(dns.SHA256) }
+
+// upstream answers queries with the replies it holds, and counts the queries.
+type upstream struct {
+	sync.Mutex
+	replies map[string]*dns.Msg // by "name type"
+	queries map[string]int
+}
+
+func (u *upstream) add(name string, qtype uint16, rcode int, answer, ns []dns.RR) {
+	m := new(dns.Msg)
+	m.Rcode = rcode
+	m.Answer = answer
+	m.Ns = ns
+	u.replies[name+" "+dns.TypeToString[qtype]] = m
+}
+
+func (u *upstream) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	key := strings.ToLower(r.Question[0].Name) + " " + dns.TypeToString[r.Question[0].Qtype]
+	u.Lock()
+	u.queries[key]++
+	m, ok := u.replies[key]
+	u.Unlock()
+
+	reply := new(dns.Msg)
+	if !ok {
+		reply.SetRcode(r, dns.RcodeServerFailure)
+		w.WriteMsg(reply)
+		return dns.RcodeServerFailure, nil
+	}
+	reply.SetRcode(r, m.Rcode)
+	reply.RecursionAvailable = true
+	c := m.Copy()
+	reply.Answer, reply.Ns = c.Answer, c.Ns
+	reply.SetEdns0(4096, true)
+	w.WriteMsg(reply)
+	return m.Rcode, nil
+}
+
+func (u *upstream) Name() string { return "upstream" }
+
+func (u *upstream) count(name string, qtype uint16) int {
+	u.Lock()
+	defer u.Unlock()
+	return u.queries[name+" "+dns.TypeToString[qtype]]
+}
+
+func soa(zone string) dns.RR {
+	return test.SOA(zone + " 3600 IN SOA ns." + zone + " admin." + zone + " 1 3600 600 86400 60")
+}
+
+// nsec3 returns the NSEC3 records for the names of a zone, without salt and iterations.
+func nsec3(zone string, flags uint8, names map[string][]uint16) []dns.RR {
+	type hashed struct {
+		hash  string
+		types []uint16
+	}
+	var hs []hashed
+	for n, types := range names {
+		sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+		hs = append(hs, hashed{dns.HashName(n, dns.SHA1, 0, ""), types})
+	}
+	for i := range hs {
+		for j := i + 1; j < len(hs); j++ {
+			if hs[j].hash < hs[i].hash {
+				hs[i], hs[j] = hs[j], hs[i]
+			}
+		}
+	}
+	var out []dns.RR
+	for i, h := range hs {
+		out = append(out, &dns.NSEC3{
+			Hdr:        dns.RR_Header{Name: strings.ToLower(h.hash) + "." + zone, Rrtype: dns.TypeNSEC3, Class: dns.ClassINET, Ttl: 3600},
+			Hash:       dns.SHA1,
+			Flags:      flags,
+			NextDomain: hs[(i+1)%len(hs)].hash,
+			HashLength: 20,
+			TypeBitMap: h.types,
+		})
+	}
+	return out
+}
+
+func nsec(owner, next string, types ...uint16) dns.RR {
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return &dns.NSEC{Hdr: dns.RR_Header{Name: owner, Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: 3600}, NextDomain: next, TypeBitMap: types}
+}
+
+// newTestValidator returns a validator in front of an upstream with a signed root, org. and
+// example.org., nsec3.org. that uses NSEC3, and the unsigned delegations insecure.org. and
+// unsigned.nsec3.org.
+func newTestValidator(t *testing.T) (*Validator, *upstream) {
+	root, org, example, n3 := newSigner(t, "."), newSigner(t, "org."), newSigner(t, "example.org."), newSigner(t, "nsec3.org.")
+	u := &upstream{replies: map[string]*dns.Msg{}, queries: map[string]int{}}
+
+	u.add(".", dns.TypeDNSKEY, dns.RcodeSuccess, root.sign(root.key), nil)
+	u.add("org.", dns.TypeDS, dns.RcodeSuccess, root.sign(org.ds()), nil)
+	u.add("org.", dns.TypeDNSKEY, dns.RcodeSuccess, org.sign(org.key), nil)
+	u.add("example.org.", dns.TypeDS, dns.RcodeSuccess, org.sign(example.ds()), nil)
+	u.add("example.org.", dns.TypeDNSKEY, dns.RcodeSuccess, example.sign(example.key), nil)
+	u.add("nsec3.org.", dns.TypeDS, dns.RcodeSuccess, org.sign(n3.ds()), nil)
+	u.add("nsec3.org.", dns.TypeDNSKEY, dns.RcodeSuccess, n3.sign(n3.key), nil)
+
+	// example.org.
+	u.add("www.example.org.", dns.TypeA, dns.RcodeSuccess, example.sign(test.A("www.example.org. 3600 IN A 192.0.2.1")), nil)
+	bad := example.sign(test.A("bad.example.org. 3600 IN A 192.0.2.2"))
+	bad[0].(*dns.A).A = test.A("bad.example.org. 3600 IN A 192.0.2.66").A
+	u.add("bad.example.org.", dns.TypeA, dns.RcodeSuccess, bad, nil)
+	u.add("unsigned.example.org.", dns.TypeA, dns.RcodeSuccess, []dns.RR{test.A("unsigned.example.org. 3600 IN A 192.0.2.3")}, nil)
+	u.add("unsigned.example.org.", dns.TypeDS, dns.RcodeSuccess, nil,
+		example.sign(soa("example.org."), nsec("unsigned.example.org.", "www.example.org.", dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC)))
+	u.add("nx.example.org.", dns.TypeA, dns.RcodeNameError, nil, example.sign(soa("example.org."),
+		nsec("bad.example.org.", "unsigned.example.org.", dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC),
+		nsec("example.org.", "bad.example.org.", dns.TypeSOA, dns.TypeNS, dns.TypeDNSKEY, dns.TypeRRSIG, dns.TypeNSEC)))
+	u.add("nxnoproof.example.org.", dns.TypeA, dns.RcodeNameError, nil, example.sign(soa("example.org.")))
+	u.add("www.example.org.", dns.TypeMX, dns.RcodeSuccess, nil,
+		example.sign(soa("example.org."), nsec("www.example.org.", "example.org.", dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC)))
+	u.add("alias.example.org.", dns.TypeA, dns.RcodeSuccess, append(
+		example.sign(test.CNAME("alias.example.org. 3600 IN CNAME www.insecure.org.")),
+		test.A("www.insecure.org. 3600 IN A 192.0.2.4")), nil)
+
+	wild := example.sign(test.A("*.wild.example.org. 3600 IN A 192.0.2.9"))
+	for _, rr := range wild {
+		rr.Header().Name = "a.wild.example.org."
+	}
+	u.add("a.wild.example.org.", dns.TypeA, dns.RcodeSuccess, wild,
+		example.sign(nsec("*.wild.example.org.", "www.example.org.", dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC)))
+	u.add("b.wild.example.org.", dns.TypeA, dns.RcodeSuccess, (&dns.Msg{Answer: wild}).Copy().Answer, nil)
+	for _, rr := range u.replies["b.wild.example.org. A"].Answer {
+		rr.Header().Name = "b.wild.example.org."
+	}
+
+	// insecure.org.
+	u.add("insecure.org.", dns.TypeDS, dns.RcodeSuccess, nil,
+		org.sign(soa("org."), nsec("insecure.org.", "nsec3.org.", dns.TypeNS, dns.TypeRRSIG, dns.TypeNSEC)))
+	u.add("www.insecure.org.", dns.TypeA, dns.RcodeSuccess, []dns.RR{test.A("www.insecure.org. 3600 IN A 192.0.2.4")}, nil)
+	u.add("www.insecure.org.", dns.TypeDS, dns.RcodeSuccess, nil, []dns.RR{soa("insecure.org.")})
+
+	// nsec3.org.
+	u.add("nx.nsec3.org.", dns.TypeA, dns.RcodeNameError, nil, n3.sign(append([]dns.RR{soa("nsec3.org.")}, nsec3("nsec3.org.", 0, map[string][]uint16{
+		"nsec3.org.":     {dns.TypeSOA, dns.TypeNS, dns.TypeDNSKEY, dns.TypeRRSIG, dns.TypeNSEC3PARAM},
+		"www.nsec3.org.": {dns.TypeA, dns.TypeRRSIG},
+	})...)...))
+	u.add("www.nsec3.org.", dns.TypeMX, dns.RcodeSuccess, nil, n3.sign(append([]dns.RR{soa("nsec3.org.")}, nsec3("nsec3.org.", 0, map[string][]uint16{
+		"www.nsec3.org.": {dns.TypeA, dns.TypeRRSIG},
+	})...)...))
+	u.add("unsigned.nsec3.org.", dns.TypeDS, dns.RcodeSuccess, nil, n3.sign(append([]dns.RR{soa("nsec3.org.")}, nsec3("nsec3.org.", 1, map[string][]uint16{
+		"nsec3.org.":     {dns.TypeSOA, dns.TypeNS, dns.TypeDNSKEY, dns.TypeRRSIG, dns.TypeNSEC3PARAM},
+		"www.nsec3.org.": {dns.TypeA, dns.TypeRRSIG},
+	})...)...))
+	u.add("www.unsigned.nsec3.org.", dns.TypeA, dns.RcodeSuccess, []dns.RR{test.A("www.unsigned.nsec3.org. 3600 IN A 192.0.2.5")}, nil)
+	u.add("www.unsigned.nsec3.org.", dns.TypeDS, dns.RcodeSuccess, nil, []dns.RR{soa("unsigned.nsec3.org.")})
+
+	v := &Validator{
+		Next:     u,
+		Zones:    []string{"."},
+		anchors:  anchors{},
+		negative: negativeAnchors(nil),
+		keys:     newKeyCache(defaultCacheSize),
+	}
+	v.anchors.add(root.ds())
+	return v, u
+}
+
+func TestValidator(t *testing.T) {
+	v, _ := newTestValidator(t)
+
+	tests := []struct {
+		qname  string
+		qtype  uint16
+		rcode  int
+		secure bool
+	}{
+		{"www.example.org.", dns.TypeA, dns.RcodeSuccess, true},
+		{"bad.example.org.", dns.TypeA, dns.RcodeServerFailure, false},
+		{"unsigned.example.org.", dns.TypeA, dns.RcodeServerFailure, false},
+		{"nx.example.org.", dns.TypeA, dns.RcodeNameError, true},
+		{"nxnoproof.example.org.", dns.TypeA, dns.RcodeServerFailure, false},
+		{"www.example.org.", dns.TypeMX, dns.RcodeSuccess, true},
+		{"alias.example.org.", dns.TypeA, dns.RcodeSuccess, false},
+		{"a.wild.example.org.", dns.TypeA, dns.RcodeSuccess, true},
+		{"b.wild.example.org.", dns.TypeA, dns.RcodeServerFailure, false},
+		{"www.insecure.org.", dns.TypeA, dns.RcodeSuccess, false},
+		{"nx.nsec3.org.", dns.TypeA, dns.RcodeNameError, true},
+		{"www.nsec3.org.", dns.TypeMX, dns.RcodeSuccess, true},
+		{"www.unsigned.nsec3.org.", dns.TypeA, dns.RcodeSuccess, false},
+		{"unknown.org.", dns.TypeA, dns.RcodeServerFailure, false}, // SERVFAIL from the upstream
+	}
+
+	for i, tc := range tests {
+		req := new(dns.Msg)
+		req.SetQuestion(tc.qname, tc.qtype)
+		req.SetEdns0(4096, true)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		v.ServeDNS(context.TODO(), rec, req)
+		if rec.Msg == nil {
+			t.Fatalf("Test %d: expected a reply", i)
+		}
+		if rec.Msg.Rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %s for %s, got %s", i, dns.RcodeToString[tc.rcode], tc.qname, dns.RcodeToString[rec.Msg.Rcode])
+		}
+		if rec.Msg.AuthenticatedData != tc.secure {
+			t.Errorf("Test %d: expected AD %t for %s", i, tc.secure, tc.qname)
+		}
+	}
+}
+
+func TestValidatorDO(t *testing.T) {
+	v, _ := newTestValidator(t)
+
+	tests := []struct {
+		do, ad bool
+		sigs   bool
+		setAD  bool
+	}{
+		{do: true, sigs: true, setAD: true},
+		{do: false, sigs: false, setAD: false},
+		{do: false, ad: true, sigs: false, setAD: true},
+	}
+	for i, tc := range tests {
+		req := new(dns.Msg)
+		req.SetQuestion("www.example.org.", dns.TypeA)
+		req.AuthenticatedData = tc.ad
+		if tc.do {
+			req.SetEdns0(4096, true)
+		}
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		v.ServeDNS(context.TODO(), rec, req)
+
+		sigs := false
+		for _, rr := range rec.Msg.Answer {
+			if rr.Header().Rrtype == dns.TypeRRSIG {
+				sigs = true
+			}
+		}
+		if sigs != tc.sigs {
+			t.Errorf("Test %d: expected signatures %t", i, tc.sigs)
+		}
+		if rec.Msg.AuthenticatedData != tc.setAD {
+			t.Errorf("Test %d: expected AD %t", i, tc.setAD)
+		}
+		if !tc.do && !tc.ad && rec.Msg.IsEdns0() != nil {
+			t.Errorf("Test %d: expected no OPT record", i)
+		}
+	}
+}
+
+func TestValidatorNegativeAnchorAndCD(t *testing.T) {
+	v, _ := newTestValidator(t)
+	v.negative = negativeAnchors([]string{"example.org."})
+
+	req := new(dns.Msg)
+	req.SetQuestion("bad.example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	v.ServeDNS(context.TODO(), rec, req)
+	if rec.Msg.Rcode != dns.RcodeSuccess || rec.Msg.AuthenticatedData {
+		t.Errorf("Expected an insecure answer below the negative trust anchor, got %s", rec.Msg)
+	}
+
+	v.negative = negativeAnchors(nil)
+	req.CheckingDisabled = true
+	rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	v.ServeDNS(context.TODO(), rec, req)
+	if rec.Msg.Rcode != dns.RcodeSuccess {
+		t.Errorf("Expected the bogus answer with CD, got %s", dns.RcodeToString[rec.Msg.Rcode])
+	}
+}
+
+func TestValidatorKeyCache(t *testing.T) {
+	v, u := newTestValidator(t)
+
+	for _, qname := range []string{"www.example.org.", "www.example.org.", "nx.example.org."} {
+		req := new(dns.Msg)
+		req.SetQuestion(qname, dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		v.ServeDNS(context.TODO(), rec, req)
+	}
+	for _, q := range []struct {
+		name  string
+		qtype uint16
+	}{{".", dns.TypeDNSKEY}, {"org.", dns.TypeDS}, {"example.org.", dns.TypeDNSKEY}} {
+		if n := u.count(q.name, q.qtype); n != 1 {
+			t.Errorf("Expected a single query for %s %s, got %d", q.name, dns.TypeToString[q.qtype], n)
+		}
+	}
+}
+
+func TestValidatorKeyCacheInsecure(t *testing.T) {
+	v, u := newTestValidator(t)
+	u.add("mail.insecure.org.", dns.TypeA, dns.RcodeSuccess, []dns.RR{test.A("mail.insecure.org. 3600 IN A 192.0.2.5")}, nil)
+
+	for _, qname := range []string{"www.insecure.org.", "www.insecure.org.", "mail.insecure.org."} {
+		req := new(dns.Msg)
+		req.SetQuestion(qname, dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		v.ServeDNS(context.TODO(), rec, req)
+		if rec.Msg.Rcode != dns.RcodeSuccess || rec.Msg.AuthenticatedData {
+			t.Errorf("Expected an insecure answer for %s, got %s", qname, rec.Msg)
+		}
+	}
+	// Once the insecure delegation is known, the names below it reuse its key entry without
+	// looking up their DS records.
+	for _, q := range []struct {
+		name  string
+		count int
+	}{{"insecure.org.", 1}, {"www.insecure.org.", 1}, {"mail.insecure.org.", 0}} {
+		if n := u.count(q.name, dns.TypeDS); n != q.count {
+			t.Errorf("Expected %d queries for %s DS, got %d", q.count, q.name, n)
+		}
+	}
+}
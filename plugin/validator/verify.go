@@ -0,0 +1,295 @@
+package validator
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// rrset returns the records of rrs with name and type t, and the signatures covering them.
+func rrset(rrs []dns.RR, name string, t uint16) ([]dns.RR, []*dns.RRSIG) {
+	var (
+		set  []dns.RR
+		sigs []*dns.RRSIG
+	)
+	for _, rr := range rrs {
+		if !strings.EqualFold(rr.Header().Name, name) {
+			continue
+		}
+		if s, ok := rr.(*dns.RRSIG); ok {
+			if s.TypeCovered == t {
+				sigs = append(sigs, s)
+			}
+			continue
+		}
+		if rr.Header().Rrtype == t {
+			set = append(set, rr)
+		}
+	}
+	return set, sigs
+}
+
+// set is an RRset with its signatures.
+type set struct {
+	rrs  []dns.RR
+	sigs []*dns.RRSIG
+}
+
+// sets groups rrs into RRsets, in order of appearance. OPT records are skipped.
+func sets(rrs []dns.RR) []set {
+	type key struct {
+		name string
+		t    uint16
+	}
+	var (
+		keys []key
+		seen = map[key]bool{}
+	)
+	for _, rr := range rrs {
+		t := rr.Header().Rrtype
+		if s, ok := rr.(*dns.RRSIG); ok {
+			t = s.TypeCovered
+		}
+		if t == dns.TypeOPT {
+			continue
+		}
+		k := key{strings.ToLower(rr.Header().Name), t}
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	out := make([]set, 0, len(keys))
+	for _, k := range keys {
+		rrs, sigs := rrset(rrs, k.name, k.t)
+		if len(rrs) > 0 {
+			out = append(out, set{rrs: rrs, sigs: sigs})
+		}
+	}
+	return out
+}
+
+// verify returns true if one of sigs over rrs validates with one of keys.
+func verify(rrs []dns.RR, sigs []*dns.RRSIG, keys []*dns.DNSKEY, now time.Time) bool {
+	if len(rrs) == 0 {
+		return false
+	}
+	for _, s := range sigs {
+		if !s.ValidityPeriod(now) {
+			continue
+		}
+		for _, k := range keys {
+			if k.KeyTag() != s.KeyTag || k.Algorithm != s.Algorithm || k.Flags&dns.ZONE == 0 {
+				continue
+			}
+			if s.Verify(k, rrs) == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// verifyDenial verifies the signatures of the NSEC and NSEC3 records in rrs, and returns them.
+func verifyDenial(rrs []dns.RR, keys []*dns.DNSKEY, now time.Time) (denial, bool) {
+	d := denial{}
+	for _, s := range sets(rrs) {
+		t := s.rrs[0].Header().Rrtype
+		if t != dns.TypeNSEC && t != dns.TypeNSEC3 {
+			continue
+		}
+		if !verify(s.rrs, s.sigs, keys, now) {
+			return d, false
+		}
+		for _, rr := range s.rrs {
+			switch x := rr.(type) {
+			case *dns.NSEC:
+				d.nsec = append(d.nsec, x)
+			case *dns.NSEC3:
+				d.nsec3 = append(d.nsec3, x)
+			}
+		}
+	}
+	return d, true
+}
+
+func supportedAlgorithm(alg uint8) bool {
+	switch alg {
+	case dns.RSASHA1, dns.RSASHA1NSEC3SHA1, dns.RSASHA256, dns.RSASHA512, dns.ECDSAP256SHA256, dns.ECDSAP384SHA384, dns.ED25519:
+		return true
+	}
+	return false
+}
+
+func supportedDigest(t uint8) bool {
+	switch t {
+	case dns.SHA1, dns.SHA256, dns.SHA384:
+		return true
+	}
+	return false
+}
+
+// validate validates the reply m for qname and qtype, and returns its security status and, for
+// bogus replies, the reason.
+func (v *Validator) validate(ctx context.Context, w dns.ResponseWriter, qname string, qtype uint16, m *dns.Msg) (int, string) {
+	now := time.Now()
+	status := statusSecure
+	worse := func(s int) {
+		if s > status {
+			status = s
+		}
+	}
+
+	// The answer: every RRset must validate, or be in an insecure zone.
+	type expansion struct{ name, ce string }
+	var expansions []expansion
+	for _, s := range sets(m.Answer) {
+		owner := strings.ToLower(s.rrs[0].Header().Name)
+		st, reason := v.verifySet(ctx, w, s, now)
+		if st == statusBogus {
+			return statusBogus, owner + " " + dns.TypeToString[s.rrs[0].Header().Rrtype] + ": " + reason
+		}
+		worse(st)
+		if st == statusSecure {
+			if labels := int(s.sigs[0].Labels); labels < dns.CountLabel(owner) {
+				idx := dns.Split(owner)
+				expansions = append(expansions, expansion{owner, owner[idx[len(idx)-labels]:]})
+			}
+		}
+	}
+
+	// The authority section: signed RRsets must validate, the NSEC and NSEC3 records are kept for
+	// the proofs. Unsigned NS records of delegations are fine.
+	d := denial{}
+	negative := m.Rcode == dns.RcodeNameError || len(follow(m.Answer, qname, qtype)) == 0
+	zone := ""
+	for _, s := range sets(m.Ns) {
+		t := s.rrs[0].Header().Rrtype
+		if len(s.sigs) == 0 && (t == dns.TypeNS || !negative) {
+			continue
+		}
+		st, reason := v.verifySet(ctx, w, s, now)
+		if st == statusBogus {
+			return statusBogus, strings.ToLower(s.rrs[0].Header().Name) + " " + dns.TypeToString[t] + ": " + reason
+		}
+		if st == statusInsecure {
+			worse(st)
+			continue
+		}
+		if zone == "" {
+			zone = strings.ToLower(s.sigs[0].SignerName)
+		}
+		for _, rr := range s.rrs {
+			switch x := rr.(type) {
+			case *dns.NSEC:
+				d.nsec = append(d.nsec, x)
+			case *dns.NSEC3:
+				d.nsec3 = append(d.nsec3, x)
+			}
+		}
+	}
+
+	for _, e := range expansions {
+		if !d.expanded(e.name, e.ce) {
+			return statusBogus, "no proof for wildcard expansion of " + e.name
+		}
+	}
+
+	if !negative {
+		return status, ""
+	}
+
+	// A negative answer: the name the CNAMEs lead to doesn't exist, or not with this type. In a
+	// secure zone the NSEC or NSEC3 records must prove this.
+	name := target(m.Answer, qname, qtype)
+	if zone == "" || !dns.IsSubDomain(zone, name) {
+		e := v.keysFor(ctx, w, name, 0)
+		if e.status != statusSecure {
+			worse(e.status)
+			return status, e.reason
+		}
+		return statusBogus, "missing proof of non-existence for " + name
+	}
+	if d.tooManyIterations() {
+		worse(statusInsecure)
+		return status, ""
+	}
+	if m.Rcode == dns.RcodeNameError {
+		if !d.nxdomain(name) {
+			return statusBogus, "no proof of non-existence for " + name
+		}
+		return status, ""
+	}
+	if !d.nodata(name, qtype) {
+		return statusBogus, "no proof of non-existence for " + name + " " + dns.TypeToString[qtype]
+	}
+	return status, ""
+}
+
+// verifySet returns the security status of s. An unsigned RRset is insecure when it is in an
+// insecure zone, otherwise it is bogus.
+func (v *Validator) verifySet(ctx context.Context, w dns.ResponseWriter, s set, now time.Time) (int, string) {
+	owner := strings.ToLower(s.rrs[0].Header().Name)
+	if len(s.sigs) == 0 {
+		e := v.keysFor(ctx, w, owner, 0)
+		switch e.status {
+		case statusSecure:
+			return statusBogus, "missing signature"
+		case statusBogus:
+			return statusBogus, e.reason
+		}
+		return statusInsecure, ""
+	}
+
+	signer := strings.ToLower(s.sigs[0].SignerName)
+	if !dns.IsSubDomain(signer, owner) {
+		return statusBogus, "signed by " + signer
+	}
+	e := v.keysFor(ctx, w, signer, 0)
+	switch e.status {
+	case statusInsecure:
+		return statusInsecure, ""
+	case statusBogus:
+		return statusBogus, e.reason
+	}
+	if !verify(s.rrs, s.sigs, e.keys, now) {
+		return statusBogus, "signature doesn't validate"
+	}
+	return statusSecure, ""
+}
+
+// follow returns the records of answer with type qtype for qname, following CNAMEs.
+func follow(answer []dns.RR, qname string, qtype uint16) []dns.RR {
+	name := target(answer, qname, qtype)
+	var out []dns.RR
+	for _, rr := range answer {
+		if strings.EqualFold(rr.Header().Name, name) && (rr.Header().Rrtype == qtype || qtype == dns.TypeANY) {
+			out = append(out, rr)
+		}
+	}
+	return out
+}
+
+// target returns the name the CNAMEs in answer lead to from qname, for queries of type qtype.
+func target(answer []dns.RR, qname string, qtype uint16) string {
+	name := strings.ToLower(qname)
+	if qtype == dns.TypeCNAME {
+		return name
+	}
+	for i := 0; i < 8; i++ {
+		found := false
+		for _, rr := range answer {
+			if c, ok := rr.(*dns.CNAME); ok && strings.EqualFold(c.Hdr.Name, name) {
+				name = strings.ToLower(c.Target)
+				found = true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	return name
+}
@@ -0,0 +1,107 @@
+package view
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/mholt/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("view", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	v, err := parse(c)
+	if err != nil {
+		return plugin.Error("view", err)
+	}
+
+	config := dnsserver.GetConfig(c)
+	config.ViewName = v.Name
+	config.ViewFilter = v.Filter
+
+	return nil
+}
+
+func parse(c *caddy.Controller) (*View, error) {
+	v := &View{}
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		if len(args) != 1 {
+			return nil, c.ArgErr()
+		}
+		v.Name = args[0]
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "client", "ecs":
+				prop := c.Val()
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, c.ArgErr()
+				}
+				nets, err := parseNets(args)
+				if err != nil {
+					return nil, err
+				}
+				if prop == "client" {
+					v.clients = append(v.clients, nets...)
+				} else {
+					v.ecs = append(v.ecs, nets...)
+				}
+			case "metadata":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return nil, c.ArgErr()
+				}
+				v.meta = append(v.meta, match{label: args[0], value: args[1]})
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+
+	if len(v.clients) == 0 && len(v.ecs) == 0 && len(v.meta) == 0 {
+		return nil, fmt.Errorf("view %q has no conditions", v.Name)
+	}
+	return v, nil
+}
+
+// parseNets parses the networks in args, a plain address is taken as a host network.
+func parseNets(args []string) ([]*net.IPNet, error) {
+	nets := []*net.IPNet{}
+	for _, a := range args {
+		if !strings.Contains(a, "/") {
+			ip := net.ParseIP(a)
+			if ip == nil {
+				return nil, fmt.Errorf("not an IP address or network: %s", a)
+			}
+			if ip.To4() != nil {
+				a += "/32"
+			} else {
+				a += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(a)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
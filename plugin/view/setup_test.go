@@ -0,0 +1,69 @@
+package view
+
+import (
+	"testing"
+
+	"github.com/mholt/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		name      string
+		clients   int
+		ecs       int
+		meta      int
+	}{
+		{`view internal {
+			client 10.0.0.0/8 192.168.0.1
+		}`, false, "internal", 2, 0, 0},
+		{`view blue {
+			ecs 2001:db8::/32
+			metadata kubernetes/client-namespace blue
+			metadata test/label value
+		}`, false, "blue", 0, 1, 2},
+		// fails
+		{`view`, true, "", 0, 0, 0},
+		{`view internal`, true, "", 0, 0, 0},
+		{`view a b {
+			client 10.0.0.0/8
+		}`, true, "", 0, 0, 0},
+		{`view internal {
+			client
+		}`, true, "", 0, 0, 0},
+		{`view internal {
+			client 10.0.0.0/33
+		}`, true, "", 0, 0, 0},
+		{`view internal {
+			client example.org
+		}`, true, "", 0, 0, 0},
+		{`view internal {
+			metadata label
+		}`, true, "", 0, 0, 0},
+		{`view internal {
+			blah
+		}`, true, "", 0, 0, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		v, err := parse(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			continue
+		}
+		if v.Name != test.name {
+			t.Errorf("Test %d: expected name %s, got %s", i, test.name, v.Name)
+		}
+		if len(v.clients) != test.clients || len(v.ecs) != test.ecs || len(v.meta) != test.meta {
+			t.Errorf("Test %d: unexpected number of conditions: %d, %d, %d", i, len(v.clients), len(v.ecs), len(v.meta))
+		}
+	}
+}
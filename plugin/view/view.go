@@ -0,0 +1,70 @@
+// Package view implements views: several server blocks for the same zone, each serving a different
+// set of clients.
+package view
+
+import (
+	"context"
+	"net"
+
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// View holds the conditions a request must match to be handled by a server block.
+type View struct {
+	Name string
+
+	clients []*net.IPNet // the client address must be in one of these
+	ecs     []*net.IPNet // the ECS address must be in one of these
+	meta    []match      // all metadata labels must match
+}
+
+type match struct {
+	label string
+	value string
+}
+
+// Filter returns true if the request matches all conditions of v.
+func (v *View) Filter(ctx context.Context, state request.Request) bool {
+	if len(v.clients) > 0 && !contains(v.clients, net.ParseIP(state.IP())) {
+		return false
+	}
+	if len(v.ecs) > 0 && !contains(v.ecs, ecsAddr(state)) {
+		return false
+	}
+	for _, m := range v.meta {
+		f := metadata.ValueFunc(ctx, m.label)
+		if f == nil || f() != m.value {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ecsAddr returns the address from the EDNS0 client subnet option, or nil if there is none.
+func ecsAddr(state request.Request) net.IP {
+	opt := state.Req.IsEdns0()
+	if opt == nil {
+		return nil
+	}
+	for _, o := range opt.Option {
+		if e, ok := o.(*dns.EDNS0_SUBNET); ok {
+			return e.Address
+		}
+	}
+	return nil
+}
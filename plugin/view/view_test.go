@@ -0,0 +1,65 @@
+package view
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+func mustNets(s ...string) []*net.IPNet {
+	nets, err := parseNets(s)
+	if err != nil {
+		panic(err)
+	}
+	return nets
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		view     *View
+		remote   string
+		ecs      string
+		meta     map[string]string
+		expected bool
+	}{
+		{&View{clients: mustNets("10.0.0.0/8")}, "10.1.2.3", "", nil, true},
+		{&View{clients: mustNets("10.0.0.0/8")}, "192.168.0.1", "", nil, false},
+		{&View{clients: mustNets("192.168.0.0/16", "10.0.0.0/8")}, "10.1.2.3", "", nil, true},
+		{&View{ecs: mustNets("172.16.0.0/12")}, "10.1.2.3", "172.16.1.0", nil, true},
+		{&View{ecs: mustNets("172.16.0.0/12")}, "10.1.2.3", "", nil, false},
+		{&View{ecs: mustNets("172.16.0.0/12")}, "172.16.1.1", "10.0.0.0", nil, false},
+		{&View{meta: []match{{"test/tenant", "blue"}}}, "10.1.2.3", "", map[string]string{"test/tenant": "blue"}, true},
+		{&View{meta: []match{{"test/tenant", "blue"}}}, "10.1.2.3", "", map[string]string{"test/tenant": "red"}, false},
+		{&View{meta: []match{{"test/tenant", "blue"}}}, "10.1.2.3", "", nil, false},
+		// All conditions must match.
+		{&View{clients: mustNets("10.0.0.0/8"), meta: []match{{"test/tenant", "blue"}}}, "10.1.2.3", "", map[string]string{"test/tenant": "red"}, false},
+	}
+
+	for i, tc := range tests {
+		r := new(dns.Msg)
+		r.SetQuestion("example.org.", dns.TypeA)
+		if tc.ecs != "" {
+			r.SetEdns0(4096, false)
+			ecs := &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: net.ParseIP(tc.ecs).To4()}
+			o := r.IsEdns0()
+			o.Option = append(o.Option, ecs)
+		}
+		state := request.Request{W: &test.ResponseWriter{RemoteIP: tc.remote}, Req: r}
+
+		ctx := metadata.ContextWithMetadata(context.TODO())
+		for k, v := range tc.meta {
+			v := v
+			metadata.SetValueFunc(ctx, k, func() string { return v })
+		}
+
+		if got := tc.view.Filter(ctx, state); got != tc.expected {
+			t.Errorf("Test %d: expected %t, got %t", i, tc.expected, got)
+		}
+	}
+}
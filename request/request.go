@@ -55,6 +55,19 @@ func (r *Request) IP() string {
 	return r.ip
 }
 
+// ClientIP returns the IP address of the client the request is for: the address of the EDNS0
+// client subnet option when present, otherwise the remote IP address.
+func (r *Request) ClientIP() net.IP {
+	if opt := r.Req.IsEdns0(); opt != nil {
+		for _, o := range opt.Option {
+			if e, ok := o.(*dns.EDNS0_SUBNET); ok && e.Address != nil {
+				return e.Address
+			}
+		}
+	}
+	return net.ParseIP(r.IP())
+}
+
 // LocalIP gets the (local) IP address of server handling the request.
 func (r *Request) LocalIP() string {
 	if r.localIP != "" {
@@ -2,6 +2,7 @@ package request
 
 import (
 	"fmt"
+	"net"
 	"testing"
 
 	"github.com/coredns/coredns/plugin/test"
@@ -32,6 +33,19 @@ func TestRequestRemote(t *testing.T) {
 	}
 }
 
+func TestRequestClientIP(t *testing.T) {
+	st := testRequest()
+	if ip := st.ClientIP(); ip.String() != "10.240.0.1" {
+		t.Errorf("Expected the remote IP without a client subnet option, got %s", ip)
+	}
+
+	o := st.Req.IsEdns0()
+	o.Option = append(o.Option, &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: net.ParseIP("192.0.2.0")})
+	if ip := st.ClientIP(); ip.String() != "192.0.2.0" {
+		t.Errorf("Expected the address of the client subnet option, got %s", ip)
+	}
+}
+
 func TestRequestMalformed(t *testing.T) {
 	m := new(dns.Msg)
 	st := Request{Req: m}
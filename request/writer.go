@@ -1,22 +1,47 @@
 package request
 
-import "github.com/miekg/dns"
+import (
+	"crypto/tls"
+
+	"github.com/miekg/dns"
+)
 
 // ScrubWriter will, when writing the message, call scrub to make it fit the client's buffer.
 type ScrubWriter struct {
 	dns.ResponseWriter
-	req *dns.Msg // original request
+	req     *dns.Msg // original request
+	reserve int      // octets left free in the reply
 }
 
 // NewScrubWriter returns a new and initialized ScrubWriter.
-func NewScrubWriter(req *dns.Msg, w dns.ResponseWriter) *ScrubWriter { return &ScrubWriter{w, req} }
+func NewScrubWriter(req *dns.Msg, w dns.ResponseWriter) *ScrubWriter {
+	return &ScrubWriter{ResponseWriter: w, req: req}
+}
+
+// NewScrubWriterReserve returns a new ScrubWriter that leaves n octets free in the reply, for a
+// record that the underlying dns.ResponseWriter adds after scrubbing, such as a TSIG record.
+func NewScrubWriterReserve(req *dns.Msg, w dns.ResponseWriter, n int) *ScrubWriter {
+	return &ScrubWriter{ResponseWriter: w, req: req, reserve: n}
+}
 
 // WriteMsg overrides the default implementation of the underlying dns.ResponseWriter and calls
 // scrub on the message m and will then write it to the client.
 func (s *ScrubWriter) WriteMsg(m *dns.Msg) error {
 	state := Request{Req: s.req, W: s.ResponseWriter}
+	if s.reserve > 0 {
+		state.size = state.Size() - s.reserve
+	}
 
 	n := state.Scrub(m)
 	state.SizeAndDo(n)
 	return s.ResponseWriter.WriteMsg(n)
 }
+
+// ConnectionState implements the dns.ConnectionStater interface. It returns the TLS connection
+// state of the underlying dns.ResponseWriter, or nil if it doesn't have one.
+func (s *ScrubWriter) ConnectionState() *tls.ConnectionState {
+	if cs, ok := s.ResponseWriter.(dns.ConnectionStater); ok {
+		return cs.ConnectionState()
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package test
 
 import (
+	"os"
 	"testing"
 
 	"github.com/coredns/coredns/plugin/test"
@@ -65,3 +66,61 @@ func testCase(t *testing.T, name, addr string, expectAnsLen int, expectTTL uint3
 		t.Errorf("Expected TTL to be %d, got %d", expectTTL, ttl)
 	}
 }
+
+func TestLookupCachePersist(t *testing.T) {
+	name, rm, err := test.TempFile(".", exampleOrg)
+	if err != nil {
+		t.Fatalf("Failed to create zone: %s", err)
+	}
+	defer rm()
+	snapshot, rm1, err := test.TempFile(".", "")
+	if err != nil {
+		t.Fatalf("Failed to create snapshot file: %s", err)
+	}
+	defer rm1()
+	defer os.Remove(snapshot + ".tmp")
+
+	corefile := `example.org:0 {
+       file ` + name + `
+}
+`
+	auth, udp, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+
+	corefile = `example.org:0 {
+	forward . ` + udp + `
+	cache {
+		persist ` + snapshot + `
+	}
+}
+`
+	i, udp1, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	if _, err := dns.Exchange(m, udp1); err != nil {
+		t.Fatalf("Expected to receive reply, but didn't: %s", err)
+	}
+	// Shutting down saves the cache.
+	i.ShutdownCallbacks()
+	i.Stop()
+	auth.Stop()
+
+	// Without the authoritative server, the answer can only come from the restored cache.
+	i, udp1, _, err = CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+	resp, err := dns.Exchange(m, udp1)
+	if err != nil {
+		t.Fatalf("Expected to receive reply, but didn't: %s", err)
+	}
+	if len(resp.Answer) != 2 {
+		t.Errorf("Expected 2 RRs in the answer section from the restored cache, got %d", len(resp.Answer))
+	}
+}
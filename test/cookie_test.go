@@ -0,0 +1,58 @@
+package test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/edns"
+
+	"github.com/miekg/dns"
+)
+
+func TestCookie(t *testing.T) {
+	corefile := `example.org:0 {
+		cookie {
+			require
+		}
+		hosts {
+			10.0.0.1 example.org
+		}
+	}`
+
+	i, udp, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	client := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.SetEdns0(4096, false)
+	edns.SetCookie(m.IsEdns0(), client, nil)
+
+	resp, err := dns.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Expected to receive reply, but didn't: %s", err)
+	}
+	if resp.Rcode != dns.RcodeBadCookie {
+		t.Fatalf("Expected BADCOOKIE, got: %s", dns.RcodeToString[resp.Rcode])
+	}
+	c, server, err := edns.Cookie(resp)
+	if err != nil || !bytes.Equal(c, client) || server == nil {
+		t.Fatalf("Expected client and server cookie in the reply, got %x %x: %v", c, server, err)
+	}
+
+	// Retry with the server cookie.
+	edns.SetCookie(m.IsEdns0(), client, server)
+	resp, err = dns.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Expected to receive reply, but didn't: %s", err)
+	}
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) == 0 {
+		t.Fatalf("Expected an answer, got: %s", resp)
+	}
+	if _, server, _ = edns.Cookie(resp); server == nil {
+		t.Errorf("Expected a server cookie in the reply")
+	}
+}
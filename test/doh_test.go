@@ -0,0 +1,78 @@
+package test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/doh"
+
+	"github.com/miekg/dns"
+)
+
+func TestDoH(t *testing.T) {
+	corefile := `https://.:0 {
+		tls ../plugin/tls/test_cert.pem ../plugin/tls/test_key.pem
+		https {
+			path /resolve
+			json
+		}
+		whoami
+}
+`
+	i, _, tcp, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			ForceAttemptHTTP2: true,
+		},
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("whoami.example.org.", dns.TypeA)
+	req, _ := doh.NewRequest(http.MethodPost, tcp, m)
+	req.URL.Path = "/resolve"
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %s", err)
+	}
+	if resp.ProtoMajor != 2 {
+		t.Errorf("Expected HTTP/2, got %s", resp.Proto)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	d, err := doh.ResponseToMsg(resp)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %s", err)
+	}
+	if len(d.Extra) != 2 {
+		t.Errorf("Expected 2 RRs in additional section, but got %d", len(d.Extra))
+	}
+
+	resp, err = client.Get("https://" + tcp + "/resolve?name=whoami.example.org&type=A")
+	if err != nil {
+		t.Fatalf("Expected no error but got: %s", err)
+	}
+	resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != doh.MimeTypeJSON {
+		t.Errorf("Expected Content-Type %q, got %q", doh.MimeTypeJSON, ct)
+	}
+
+	resp, err = client.Get("https://" + tcp + doh.Path + "?name=whoami.example.org")
+	if err != nil {
+		t.Fatalf("Expected no error but got: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404 for %s, got %d", doh.Path, resp.StatusCode)
+	}
+}
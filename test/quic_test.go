@@ -0,0 +1,61 @@
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/doq"
+
+	"github.com/miekg/dns"
+)
+
+func TestQUIC(t *testing.T) {
+	corefile := `quic://.:0 {
+		bind 127.0.0.1
+		tls ../plugin/tls/test_cert.pem ../plugin/tls/test_key.pem
+		whoami
+}
+`
+	q, udp, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer q.Stop()
+
+	c := doq.NewClient(udp, &tls.Config{InsecureSkipVerify: true})
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for i := 0; i < 2; i++ { // the second query reuses the connection
+		m := new(dns.Msg)
+		m.SetQuestion("whoami.example.org.", dns.TypeA)
+
+		d, err := c.Exchange(ctx, m)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %s", err)
+		}
+		if d.Id != m.Id {
+			t.Errorf("Expected ID %d, got %d", m.Id, d.Id)
+		}
+		if d.Rcode != dns.RcodeSuccess {
+			t.Errorf("Expected success but got %d", d.Rcode)
+		}
+		if len(d.Extra) != 2 {
+			t.Errorf("Expected 2 RRs in additional section, but got %d", len(d.Extra))
+		}
+	}
+}
+
+func TestQUICNoTLS(t *testing.T) {
+	corefile := `quic://.:0 {
+		whoami
+}
+`
+	if _, err := CoreDNSServer(corefile); err == nil {
+		t.Fatal("Expected an error when QUIC is used without TLS")
+	}
+}
@@ -0,0 +1,73 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+const rpzZone = `$ORIGIN rpz.example.
+@	3600 IN	SOA ns.rpz.example. admin.rpz.example. 1 3600 600 86400 60
+@	3600 IN	NS  ns.rpz.example.
+blocked.example.org	CNAME .
+local.example.org	A 192.0.2.53
+`
+
+func TestRPZSecondary(t *testing.T) {
+	name, rm, err := test.TempFile(".", rpzZone)
+	if err != nil {
+		t.Fatalf("Failed to create zone: %s", err)
+	}
+	defer rm()
+
+	corefile := `rpz.example:0 {
+		bind 127.0.0.1
+		file ` + name + ` {
+			transfer to *
+		}
+	}`
+	i, _, tcp, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	corefile = `.:0 {
+		bind 127.0.0.1
+		rpz {
+			secondary rpz.example. ` + tcp + `
+		}
+		whoami
+	}`
+	i1, udp, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i1.Stop()
+
+	tests := []struct {
+		qname  string
+		rcode  int
+		answer int
+	}{
+		{"blocked.example.org.", dns.RcodeNameError, 0},
+		{"local.example.org.", dns.RcodeSuccess, 1},
+		{"example.org.", dns.RcodeSuccess, 0}, // whoami puts its answer in the additional section
+	}
+	for _, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		r, err := dns.Exchange(m, udp)
+		if err != nil {
+			t.Fatalf("Expected to receive reply for %s, but didn't: %s", tc.qname, err)
+		}
+		if r.Rcode != tc.rcode {
+			t.Errorf("Expected rcode %d for %s, got %d", tc.rcode, tc.qname, r.Rcode)
+		}
+		if len(r.Answer) != tc.answer {
+			t.Errorf("Expected %d answers for %s, got %d", tc.answer, tc.qname, len(r.Answer))
+		}
+	}
+}
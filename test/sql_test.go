@@ -0,0 +1,76 @@
+package test
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestLookupSQL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dns.db")
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`
+CREATE TABLE zones (name TEXT PRIMARY KEY);
+CREATE TABLE records (zone TEXT, name TEXT, type TEXT, ttl INTEGER, content TEXT);
+CREATE TABLE changes (id INTEGER PRIMARY KEY, zone TEXT);
+INSERT INTO zones VALUES ('example.org.');
+INSERT INTO records VALUES
+	('example.org.', '@', 'SOA', 3600, 'ns hostmaster 1 7200 1800 86400 300'),
+	('example.org.', '@', 'NS', 3600, 'ns'),
+	('example.org.', 'ns', 'A', 3600, '127.0.0.53'),
+	('example.org.', 'www', 'A', 300, '127.0.0.1');
+`); err != nil {
+		t.Fatal(err)
+	}
+
+	corefile := `example.org:0 {
+		sql sqlite ` + path + ` {
+			changes
+			poll 100ms
+		}
+	}`
+	i, udp, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	m := new(dns.Msg)
+	m.SetQuestion("www.example.org.", dns.TypeA)
+	resp, err := dns.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Expected to receive reply, but didn't: %s", err)
+	}
+	if len(resp.Answer) != 1 || resp.Answer[0].(*dns.A).A.String() != "127.0.0.1" {
+		t.Fatalf("Expected www.example.org. A 127.0.0.1, got %v", resp.Answer)
+	}
+
+	if _, err := db.Exec(`UPDATE records SET content = '127.0.0.2' WHERE name = 'www';
+INSERT INTO changes (zone) VALUES ('example.org.');`); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond) // poll interval
+
+	resp, err = dns.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Expected to receive reply, but didn't: %s", err)
+	}
+	if len(resp.Answer) != 1 || resp.Answer[0].(*dns.A).A.String() != "127.0.0.2" {
+		t.Fatalf("Expected www.example.org. A 127.0.0.2 after the change, got %v", resp.Answer)
+	}
+}
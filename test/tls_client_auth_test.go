@@ -0,0 +1,150 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// testCA issues certificates for the client authentication tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "CoreDNS test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key}
+}
+
+// issue returns a certificate for cn, signed by the CA.
+func (ca *testCA) issue(t *testing.T, cn string, usage x509.ExtKeyUsage) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLSClientAuthMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t)
+	server := ca.issue(t, "dns.example.org", x509.ExtKeyUsageServerAuth)
+	keyDER, _ := x509.MarshalECPrivateKey(server.PrivateKey.(*ecdsa.PrivateKey))
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", ca.cert.Raw)
+	writePEM(t, filepath.Join(dir, "cert.pem"), "CERTIFICATE", server.Certificate[0])
+	writePEM(t, filepath.Join(dir, "key.pem"), "EC PRIVATE KEY", keyDER)
+
+	tlsLine := "tls " + filepath.Join(dir, "cert.pem") + " " + filepath.Join(dir, "key.pem") + " " + filepath.Join(dir, "ca.pem") +
+		" {\n client_auth require_and_verify\n}"
+	corefile := `tls://example.org:0 {
+		bind 127.0.0.1
+		metadata
+		view alice {
+			metadata tls/client_common_name alice
+		}
+		` + tlsLine + `
+		hosts {
+			10.0.0.1 example.org
+		}
+	}
+	tls://example.org:0 {
+		bind 127.0.0.1
+		` + tlsLine + `
+		hosts {
+			192.0.2.1 example.org
+		}
+	}`
+
+	i, _, tcp, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	tests := []struct {
+		cn       string
+		expected string
+	}{
+		{"alice", "10.0.0.1"},
+		{"bob", "192.0.2.1"},
+	}
+	for _, tc := range tests {
+		c := &dns.Client{Net: "tcp-tls", TLSConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			Certificates:       []tls.Certificate{ca.issue(t, tc.cn, x509.ExtKeyUsageClientAuth)},
+		}}
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		resp, _, err := c.Exchange(m, tcp)
+		if err != nil {
+			t.Fatalf("Expected to receive reply for %s, but didn't: %s", tc.cn, err)
+		}
+		if len(resp.Answer) == 0 {
+			t.Fatalf("Expected an answer for %s, got none", tc.cn)
+		}
+		if a := resp.Answer[0].(*dns.A).A.String(); a != tc.expected {
+			t.Errorf("Expected %s for %s, got %s", tc.expected, tc.cn, a)
+		}
+	}
+
+	// Without a client certificate the handshake fails.
+	c := &dns.Client{Net: "tcp-tls", TLSConfig: &tls.Config{InsecureSkipVerify: true}}
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	if _, _, err := c.Exchange(m, tcp); err == nil {
+		t.Errorf("Expected an error without client certificate")
+	}
+}
@@ -0,0 +1,206 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	tsigKey    = "transfer.key."
+	tsigSecret = "c2VjcmV0c2VjcmV0c2VjcmV0"
+)
+
+func TestTsig(t *testing.T) {
+	name, rm, err := test.TempFile(".", exampleOrg)
+	if err != nil {
+		t.Fatalf("Failed to create zone: %s", err)
+	}
+	defer rm()
+
+	corefile := `example.org:0 {
+		bind 127.0.0.1
+		tsig {
+			secret ` + tsigKey + ` ` + tsigSecret + `
+			require AXFR
+		}
+		file ` + name + ` {
+			transfer to *
+		}
+	}`
+	i, udp, tcp, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	tests := []struct {
+		name   string
+		secret string
+		rcode  int
+		signed bool // we expect a verified signed reply
+	}{
+		{"", "", dns.RcodeSuccess, false},
+		{tsigKey, tsigSecret, dns.RcodeSuccess, true},
+		{"other.key.", tsigSecret, dns.RcodeNotAuth, false},
+		{tsigKey, "b3RoZXJvdGhlcm90aGVy", dns.RcodeNotAuth, false},
+	}
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeSOA)
+		c := new(dns.Client)
+		if tc.name != "" {
+			m.SetTsig(tc.name, dns.HmacSHA256, 300, time.Now().Unix())
+			c.TsigSecret = map[string]string{tc.name: tc.secret}
+		}
+		r, _, err := c.Exchange(m, udp)
+		if r == nil {
+			t.Fatalf("Test %d: expected a reply, got error %s", i, err)
+		}
+		if r.Rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %d, got %d", i, tc.rcode, r.Rcode)
+		}
+		if tc.signed && (err != nil || r.IsTsig() == nil) {
+			t.Errorf("Test %d: expected a verified signed reply, got error %v", i, err)
+		}
+	}
+
+	// Unsigned transfers are refused.
+	m := new(dns.Msg)
+	m.SetAxfr("example.org.")
+	c := &dns.Client{Net: "tcp"}
+	r, _, err := c.Exchange(m, tcp)
+	if err != nil {
+		t.Fatalf("Expected a reply, got error %s", err)
+	}
+	if r.Rcode != dns.RcodeRefused {
+		t.Errorf("Expected unsigned transfer to be refused, got rcode %d", r.Rcode)
+	}
+
+	// Signed transfers succeed and every envelope is verified.
+	m = new(dns.Msg)
+	m.SetAxfr("example.org.")
+	m.SetTsig(tsigKey, dns.HmacSHA256, 300, time.Now().Unix())
+	tr := &dns.Transfer{TsigSecret: map[string]string{tsigKey: tsigSecret}}
+	ch, err := tr.In(m, tcp)
+	if err != nil {
+		t.Fatalf("Failed to setup transfer: %s", err)
+	}
+	rrs := 0
+	for env := range ch {
+		if env.Error != nil {
+			t.Fatalf("Failed to transfer: %s", env.Error)
+		}
+		rrs += len(env.RR)
+	}
+	if rrs == 0 {
+		t.Errorf("Expected records in the transfer")
+	}
+}
+
+func TestTsigForward(t *testing.T) {
+	name, rm, err := test.TempFile(".", exampleOrg)
+	if err != nil {
+		t.Fatalf("Failed to create zone: %s", err)
+	}
+	defer rm()
+
+	corefile := `example.org:0 {
+		bind 127.0.0.1
+		tsig {
+			secret ` + tsigKey + ` ` + tsigSecret + `
+			require all
+		}
+		file ` + name + `
+	}`
+	i, udp, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	corefile = `example.org:0 {
+		bind 127.0.0.1
+		forward . ` + udp + ` {
+			tsig ` + tsigKey + ` hmac-sha256 ` + tsigSecret + `
+		}
+	}`
+	i1, udp1, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i1.Stop()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeSOA)
+	r, err := dns.Exchange(m, udp1)
+	if err != nil {
+		t.Fatalf("Expected a reply, got error %s", err)
+	}
+	if r.Rcode != dns.RcodeSuccess || len(r.Answer) != 1 {
+		t.Errorf("Expected a SOA answer through the signed upstream, got %s", r)
+	}
+	if r.IsTsig() != nil {
+		t.Errorf("Expected the upstream signature to be stripped")
+	}
+}
+
+func TestTsigTruncate(t *testing.T) {
+	zone := exampleOrg
+	for i := 1; i <= 25; i++ {
+		zone += fmt.Sprintf("big.example.org. IN A 192.0.2.%d\n", i)
+	}
+	name, rm, err := test.TempFile(".", zone)
+	if err != nil {
+		t.Fatalf("Failed to create zone: %s", err)
+	}
+	defer rm()
+
+	corefile := `example.org:0 {
+		bind 127.0.0.1
+		tsig {
+			secret ` + tsigKey + ` ` + tsigSecret + `
+		}
+		file ` + name + `
+	}`
+	i, udp, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	// Unsigned, the reply fits in 512 octets.
+	m := new(dns.Msg)
+	m.SetQuestion("big.example.org.", dns.TypeA)
+	r, err := dns.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Expected a reply, got error %s", err)
+	}
+	if r.Truncated || len(r.Answer) != 25 {
+		t.Fatalf("Expected the unsigned reply to fit, got %d answers and TC %t", len(r.Answer), r.Truncated)
+	}
+
+	// Signed, the reply is scrubbed to leave room for the TSIG record.
+	m = new(dns.Msg)
+	m.SetQuestion("big.example.org.", dns.TypeA)
+	m.SetTsig(tsigKey, dns.HmacSHA256, 300, time.Now().Unix())
+	c := &dns.Client{TsigSecret: map[string]string{tsigKey: tsigSecret}}
+	r, _, err = c.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Expected a verified signed reply, got error %s", err)
+	}
+	if r.IsTsig() == nil {
+		t.Errorf("Expected a signed reply")
+	}
+	if !r.Truncated {
+		t.Errorf("Expected the signed reply to be truncated")
+	}
+	r.Compress = true
+	if l := r.Len(); l > dns.MinMsgSize {
+		t.Errorf("Expected the signed reply to fit in %d octets, got %d", dns.MinMsgSize, l)
+	}
+}
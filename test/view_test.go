@@ -0,0 +1,60 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestView(t *testing.T) {
+	corefile := `example.org:0 {
+		view local {
+			client 127.0.0.0/8 ::1
+		}
+		hosts {
+			10.0.0.1 example.org
+		}
+	}
+	example.org:0 {
+		hosts {
+			192.0.2.1 example.org
+		}
+	}
+	example.net:0 {
+		view other {
+			client 192.0.2.0/24
+		}
+		hosts {
+			192.0.2.1 example.net
+		}
+	}`
+
+	i, udp, _, err := CoreDNSServerAndPorts(corefile)
+	if err != nil {
+		t.Fatalf("Could not get CoreDNS serving instance: %s", err)
+	}
+	defer i.Stop()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	resp, err := dns.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Expected to receive reply, but didn't: %s", err)
+	}
+	if len(resp.Answer) == 0 {
+		t.Fatal("Expected to at least one RR in the answer section, got none")
+	}
+	if resp.Answer[0].(*dns.A).A.String() != "10.0.0.1" {
+		t.Errorf("Expected 10.0.0.1 from the local view, got: %s", resp.Answer[0].(*dns.A).A.String())
+	}
+
+	// No view matches and there is no fallback.
+	m.SetQuestion("example.net.", dns.TypeA)
+	resp, err = dns.Exchange(m, udp)
+	if err != nil {
+		t.Fatalf("Expected to receive reply, but didn't: %s", err)
+	}
+	if resp.Rcode != dns.RcodeRefused {
+		t.Errorf("Expected REFUSED, got: %s", dns.RcodeToString[resp.Rcode])
+	}
+}